@@ -4,16 +4,21 @@ go 1.13
 
 require (
 	github.com/aws/aws-sdk-go v1.42.39
+	github.com/beevik/etree v1.1.0
 	github.com/btnguyen2k/consu/gjrc v0.1.1
 	github.com/btnguyen2k/consu/olaf v0.1.3
 	github.com/btnguyen2k/consu/reddo v0.1.7
 	github.com/btnguyen2k/consu/semita v0.1.5
 	github.com/btnguyen2k/gocosmos v0.1.4
+	github.com/btnguyen2k/godal v0.5.2
 	github.com/btnguyen2k/henge v0.5.6
 	github.com/btnguyen2k/prom v0.2.15
 	github.com/denisenkom/go-mssqldb v0.12.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/fxamacker/cbor/v2 v2.2.0
 	github.com/go-akka/configuration v0.0.0-20200606091224-a002c0330665
+	github.com/go-asn1-ber/asn1-ber v1.5.1
+	github.com/go-ldap/ldap/v3 v3.4.1
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/godror/godror v0.30.2
 	github.com/golang/protobuf v1.5.2
@@ -23,8 +28,10 @@ require (
 	github.com/jackc/pgx/v4 v4.14.1
 	github.com/labstack/echo/v4 v4.6.3
 	github.com/mattn/go-sqlite3 v1.14.10
+	github.com/russellhaering/goxmldsig v1.1.1
 	github.com/shirou/gopsutil v3.21.11+incompatible
 	github.com/yusufpapurcu/wmi v1.2.2 // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
 	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8
 	google.golang.org/api v0.65.0
 	google.golang.org/grpc v1.43.0
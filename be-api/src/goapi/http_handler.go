@@ -29,7 +29,8 @@ func _parseRequest(apiName string, c echo.Context) (*itineris.ApiContext, *itine
 		SetContextValue("method", httpMethod).
 		SetContextValue("remote_addr", c.RealIP()).
 		SetContextValue("remote_real_id", c.Request().RemoteAddr).
-		SetContextValue("url", c.Request().URL.String())
+		SetContextValue("url", c.Request().URL.String()).
+		SetContextValue("authorization", c.Request().Header.Get("Authorization")) // since v0.8.0: e.g. bearer token of OpenID Connect userinfo requests
 
 	auth := itineris.NewApiAuth(c.Request().Header.Get(httpHeaderAppId), c.Request().Header.Get(httpHeaderAccessToken))
 
@@ -78,5 +79,23 @@ func apiHttpHandler(c echo.Context) error {
 	ctx, auth, params := _parseRequest(apiName, c)
 
 	apiResult := ApiRouter.CallApi(ctx, auth, params)
+	if apiResult.Status == itineris.StatusSeeOther {
+		// since v0.8.0: API asks to redirect client to another url (e.g. OAuth2 form_post callbacks)
+		if redirectUrl, ok := apiResult.Data.(string); ok && redirectUrl != "" {
+			return c.Redirect(http.StatusSeeOther, redirectUrl)
+		}
+	}
+	if raw, ok := apiResult.Data.(*itineris.ApiResultRawContent); ok && (apiResult.Status == itineris.StatusOk || raw.HttpStatus != 0) {
+		// since v0.8.0: API returns a non-JSON document (e.g. SAML metadata) or a document in a format defined by
+		// another standard (e.g. OpenID Connect)
+		for k, v := range raw.Headers {
+			c.Response().Header().Set(k, v)
+		}
+		httpStatus := raw.HttpStatus
+		if httpStatus == 0 {
+			httpStatus = http.StatusOK
+		}
+		return c.Blob(httpStatus, raw.ContentType, raw.Content)
+	}
 	return c.JSON(http.StatusOK, apiResult.ToMap())
 }
@@ -72,6 +72,18 @@ func NewAppFromUbo(ubo *henge.UniversalBo) *App {
 			if v, err := app.GetDataAttrAs(AttrAppPublicAttrs+".tags", typSliceStr); err == nil && v != nil {
 				publicAttrs.Tags = v.([]string)
 			}
+			if v, err := app.GetDataAttrAs(AttrAppPublicAttrs+".mtid", typSliceStr); err == nil && v != nil {
+				publicAttrs.MicrosoftTenantIds = v.([]string)
+			}
+			if v, err := app.GetDataAttrAs(AttrAppPublicAttrs+".rmfa", reddo.TypeBool); err == nil && v != nil {
+				publicAttrs.RequireMfa = v.(bool)
+			}
+			if v, err := app.GetDataAttrAs(AttrAppPublicAttrs+".rvem", reddo.TypeBool); err == nil && v != nil {
+				publicAttrs.RequireVerifiedEmail = v.(bool)
+			}
+			if v, err := app.GetDataAttrAs(AttrAppPublicAttrs+".etok", reddo.TypeBool); err == nil && v != nil {
+				publicAttrs.EncryptLoginToken = v.(bool)
+			}
 		}
 		app.SetAttrsPublic(publicAttrs)
 	}
@@ -81,22 +93,29 @@ func NewAppFromUbo(ubo *henge.UniversalBo) *App {
 
 // AppAttrsPublic holds application's public attributes.
 type AppAttrsPublic struct {
-	IsActive         bool            `json:"actv"` // is this app active or not
-	Description      string          `json:"desc"` // description text
-	DefaultReturnUrl string          `json:"rurl"` // default return url after login
-	DefaultCancelUrl string          `json:"curl"` // default cancel url after login
-	IdentitySources  map[string]bool `json:"isrc"` // sources of identity
-	Tags             []string        `json:"tags"` // arbitrary tags
-	RsaPublicKey     string          `json:"rpub"` // RSA public key in ASCII-armor format
+	IsActive             bool            `json:"actv"` // is this app active or not
+	Description          string          `json:"desc"` // description text
+	DefaultReturnUrl     string          `json:"rurl"` // default return url after login
+	DefaultCancelUrl     string          `json:"curl"` // default cancel url after login
+	IdentitySources      map[string]bool `json:"isrc"` // sources of identity
+	Tags                 []string        `json:"tags"` // arbitrary tags
+	RsaPublicKey         string          `json:"rpub"` // RSA public key in ASCII-armor format
+	MicrosoftTenantIds   []string        `json:"mtid"` // (since v0.8.0) if not empty, only Microsoft accounts from these tenants are allowed to login
+	RequireMfa           bool            `json:"rmfa"` // (since v0.8.0) users must pass multi-factor authentication to login
+	RequireVerifiedEmail bool            `json:"rvem"` // (since v0.8.0) users must login with identities whose email address is verified by the provider
+	EncryptLoginToken    bool            `json:"etok"` // (since v0.8.0) login tokens handed over to the app are encrypted (JWE) with the app's RSA public key
 }
 
 func (apub AppAttrsPublic) clone() AppAttrsPublic {
 	clone := AppAttrsPublic{
-		IsActive:         apub.IsActive,
-		Description:      apub.Description,
-		DefaultReturnUrl: apub.DefaultReturnUrl,
-		DefaultCancelUrl: apub.DefaultCancelUrl,
-		RsaPublicKey:     apub.RsaPublicKey,
+		IsActive:             apub.IsActive,
+		Description:          apub.Description,
+		DefaultReturnUrl:     apub.DefaultReturnUrl,
+		DefaultCancelUrl:     apub.DefaultCancelUrl,
+		RsaPublicKey:         apub.RsaPublicKey,
+		RequireMfa:           apub.RequireMfa,
+		RequireVerifiedEmail: apub.RequireVerifiedEmail,
+		EncryptLoginToken:    apub.EncryptLoginToken,
 	}
 	if apub.IdentitySources != nil {
 		clone.IdentitySources = make(map[string]bool)
@@ -107,6 +126,9 @@ func (apub AppAttrsPublic) clone() AppAttrsPublic {
 	if apub.Tags != nil {
 		clone.Tags = append([]string{}, apub.Tags...)
 	}
+	if apub.MicrosoftTenantIds != nil {
+		clone.MicrosoftTenantIds = append([]string{}, apub.MicrosoftTenantIds...)
+	}
 	return clone
 }
 
@@ -56,6 +56,7 @@ func TestNewAppFromUbo(t *testing.T) {
 	_rsaPubKey := "rsa pub key"
 	_idstr := map[string]bool{"src1": true, "src2": false}
 	_tags := []string{"tag1", "tag2", "tag3"}
+	_mtids := []string{"tenant1", "tenant2"}
 	_domains := []string{"domain1", "domain2", "domain3"}
 	ubo := henge.NewUniversalBo(_aid, _appVersion)
 	ubo.SetDataJson("invalid json string")
@@ -65,13 +66,17 @@ func TestNewAppFromUbo(t *testing.T) {
 
 	ubo.SetExtraAttr(FieldAppOwnerId, _oid)
 	ubo.SetDataAttr(AttrAppPublicAttrs, AppAttrsPublic{
-		IsActive:         _isAtive,
-		Description:      _desc,
-		DefaultReturnUrl: _rurl,
-		DefaultCancelUrl: _curl,
-		IdentitySources:  _idstr,
-		Tags:             _tags,
-		RsaPublicKey:     _rsaPubKey,
+		IsActive:             _isAtive,
+		Description:          _desc,
+		DefaultReturnUrl:     _rurl,
+		DefaultCancelUrl:     _curl,
+		IdentitySources:      _idstr,
+		Tags:                 _tags,
+		RsaPublicKey:         _rsaPubKey,
+		MicrosoftTenantIds:   _mtids,
+		RequireMfa:           true,
+		RequireVerifiedEmail: true,
+		EncryptLoginToken:    true,
 	})
 	ubo.SetDataAttr(AttrAppDomains, _domains)
 	app := NewAppFromUbo(ubo)
@@ -109,6 +114,18 @@ func TestNewAppFromUbo(t *testing.T) {
 	if f, v, expected := "public-attrs/tags", app.GetAttrsPublic().Tags, _tags; !reflect.DeepEqual(v, expected) {
 		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
 	}
+	if f, v, expected := "public-attrs/microsoft-tenant-ids", app.GetAttrsPublic().MicrosoftTenantIds, _mtids; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-attrs/require-mfa", app.GetAttrsPublic().RequireMfa, true; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-attrs/require-verified-email", app.GetAttrsPublic().RequireVerifiedEmail, true; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-attrs/encrypt-login-token", app.GetAttrsPublic().EncryptLoginToken, true; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
 }
 
 func TestApp_json(t *testing.T) {
@@ -124,6 +141,7 @@ func TestApp_json(t *testing.T) {
 	_rsaPubKey := "rsa pub key"
 	_idstr := map[string]bool{"src1": true, "src2": false}
 	_tags := []string{"tag1", "tag2", "tag3"}
+	_mtids := []string{"tenant1", "tenant2"}
 	_domains := []string{"domain1", "domain2", "domain3"}
 	app1 := NewApp(_appVersion, _aid, _oid, _desc)
 	attrs := app1.GetAttrsPublic()
@@ -133,6 +151,10 @@ func TestApp_json(t *testing.T) {
 	attrs.Tags = _tags
 	attrs.IdentitySources = _idstr
 	attrs.RsaPublicKey = _rsaPubKey
+	attrs.MicrosoftTenantIds = _mtids
+	attrs.RequireMfa = true
+	attrs.RequireVerifiedEmail = true
+	attrs.EncryptLoginToken = true
 	app1.SetAttrsPublic(attrs)
 	app1.SetDomains(_domains)
 
@@ -174,6 +196,18 @@ func TestApp_json(t *testing.T) {
 	if f, v, expected := "public-attrs/tags", app2.GetAttrsPublic().Tags, _tags; !reflect.DeepEqual(v, expected) {
 		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
 	}
+	if f, v, expected := "public-attrs/microsoft-tenant-ids", app2.GetAttrsPublic().MicrosoftTenantIds, _mtids; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-attrs/require-mfa", app2.GetAttrsPublic().RequireMfa, true; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-attrs/require-verified-email", app2.GetAttrsPublic().RequireVerifiedEmail, true; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-attrs/encrypt-login-token", app2.GetAttrsPublic().EncryptLoginToken, true; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
 
 	if app1.GetChecksum() != app2.GetChecksum() {
 		t.Fatalf("%s failed: expected %#v but received %#v", testName, app1.GetChecksum(), app2.GetChecksum())
@@ -10,11 +10,15 @@ import (
 const (
 	CosmosdbPkName = henge.FieldId
 
-	CosmosdbMultitenantTableName      = "exter_mt"
-	CosmosdbMultitenantPkName         = "__mtpk"
-	CosmosdbMultitenantPkValueApp     = "app"
-	CosmosdbMultitenantPkValueSession = "session"
-	CosmosdbMultitenantPkValueUser    = "user"
+	CosmosdbMultitenantTableName         = "exter_mt"
+	CosmosdbMultitenantPkName            = "__mtpk"
+	CosmosdbMultitenantPkValueApp        = "app"
+	CosmosdbMultitenantPkValueCredential = "credential"
+	CosmosdbMultitenantPkValueIdentity   = "identity"
+	CosmosdbMultitenantPkValueJob        = "job"
+	CosmosdbMultitenantPkValueSession    = "session"
+	CosmosdbMultitenantPkValueSigningKey = "signing_key"
+	CosmosdbMultitenantPkValueUser       = "user"
 )
 
 // InitMultitenantTableCosmosdb is helper function to initialize Cosmos DB multi-tenant table(s) to store BO.
@@ -0,0 +1,294 @@
+// Package credential contains business object (BO) and data access object (DAO) implementations for WebAuthn Credential.
+//
+// Available since v0.8.0
+package credential
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	"github.com/btnguyen2k/henge"
+	"main/src/gvabe/bo"
+)
+
+// IdFromCredentialId builds the BO id from a WebAuthn credential id. Credential ids can be up to 1023 bytes long,
+// hence their hash is used as BO id.
+func IdFromCredentialId(credentialId []byte) string {
+	h := sha256.Sum256(credentialId)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// NewCredential is helper function to create new Credential bo.
+func NewCredential(tagVersion uint64, credentialId []byte, ownerId string, publicKey []byte) *Credential {
+	cred := &Credential{
+		UniversalBo: henge.NewUniversalBo(IdFromCredentialId(credentialId), tagVersion, henge.UboOpt{TimeLayout: bo.UboTimeLayout, TimestampRounding: bo.UboTimestampRounding}),
+	}
+	cred.
+		SetOwnerId(ownerId).
+		SetCredentialId(credentialId).
+		SetPublicKey(publicKey)
+	return cred.sync()
+}
+
+var typSliceStr = reflect.TypeOf([]string{})
+
+// NewCredentialFromUbo is helper function to create new Credential bo from a universal bo.
+func NewCredentialFromUbo(ubo *henge.UniversalBo) *Credential {
+	if ubo == nil {
+		return nil
+	}
+	ubo = ubo.Clone()
+	cred := &Credential{UniversalBo: ubo}
+	if v, err := cred.GetExtraAttrAs(FieldCredentialOwnerId, reddo.TypeString); err == nil && v != nil {
+		cred.SetOwnerId(v.(string))
+	}
+	fieldListBytes := []string{AttrCredentialId, AttrCredentialPublicKey, AttrCredentialUserHandle, AttrCredentialAaguid}
+	setterListBytes := []func([]byte) *Credential{cred.SetCredentialId, cred.SetPublicKey, cred.SetUserHandle, cred.SetAaguid}
+	for i, attr := range fieldListBytes {
+		if v, err := cred.GetDataAttrAs(attr, reddo.TypeString); err == nil && v != nil {
+			if b, err := base64.RawURLEncoding.DecodeString(v.(string)); err == nil {
+				setterListBytes[i](b)
+			}
+		}
+	}
+	if v, err := cred.GetDataAttrAs(AttrCredentialName, reddo.TypeString); err == nil && v != nil {
+		cred.SetName(v.(string))
+	}
+	if v, err := cred.GetDataAttrAs(AttrCredentialSignCount, reddo.TypeUint); err == nil && v != nil {
+		cred.SetSignCount(uint32(v.(uint64)))
+	}
+	if v, err := cred.GetDataAttrAs(AttrCredentialTransports, typSliceStr); err == nil && v != nil {
+		cred.SetTransports(v.([]string))
+	}
+	if v, err := cred.GetDataAttrAs(AttrCredentialLastUsed, reddo.TypeTime); err == nil && v != nil {
+		cred.SetLastUsed(v.(time.Time))
+	}
+	return cred.sync()
+}
+
+const (
+	FieldCredentialOwnerId = "oid"
+
+	AttrCredentialId         = "cid"
+	AttrCredentialPublicKey  = "pkey"
+	AttrCredentialUserHandle = "uhdl"
+	AttrCredentialAaguid     = "aaguid"
+	AttrCredentialName       = "name"
+	AttrCredentialSignCount  = "count"
+	AttrCredentialTransports = "trans"
+	AttrCredentialLastUsed   = "lat"
+	AttrCredentialUbo        = "_ubo"
+)
+
+// Credential is the business object: a WebAuthn public key credential (passkey or security key) registered by an user.
+// Credential's unique id is derived from the WebAuthn credential id (see IdFromCredentialId).
+type Credential struct {
+	*henge.UniversalBo `json:"_ubo"`
+	ownerId            string    `json:"oid"`    // id of user who registered the credential
+	credentialId       []byte    `json:"cid"`    // WebAuthn credential id
+	publicKey          []byte    `json:"pkey"`   // credential's public key, COSE_Key format
+	userHandle         []byte    `json:"uhdl"`   // WebAuthn user handle the credential was registered with
+	aaguid             []byte    `json:"aaguid"` // AAGUID of the authenticator
+	name               string    `json:"name"`   // user-friendly name of the credential
+	signCount          uint32    `json:"count"`  // signature counter, used to detect cloned authenticators
+	transports         []string  `json:"trans"`  // transport hints (usb, nfc, ble, internal, hybrid)
+	lastUsed           time.Time `json:"lat"`    // timestamp when the credential was last used
+}
+
+// MarshalJSON implements json.encode.Marshaler.MarshalJSON.
+func (cred *Credential) MarshalJSON() ([]byte, error) {
+	cred.sync()
+	m := map[string]interface{}{
+		AttrCredentialUbo: cred.UniversalBo.Clone(),
+		bo.SerKeyFields: map[string]interface{}{
+			FieldCredentialOwnerId: cred.GetOwnerId(),
+		},
+		bo.SerKeyAttrs: map[string]interface{}{
+			AttrCredentialId:         base64.RawURLEncoding.EncodeToString(cred.credentialId),
+			AttrCredentialPublicKey:  base64.RawURLEncoding.EncodeToString(cred.publicKey),
+			AttrCredentialUserHandle: base64.RawURLEncoding.EncodeToString(cred.userHandle),
+			AttrCredentialAaguid:     base64.RawURLEncoding.EncodeToString(cred.aaguid),
+			AttrCredentialName:       cred.GetName(),
+			AttrCredentialSignCount:  cred.GetSignCount(),
+			AttrCredentialTransports: cred.GetTransports(),
+			AttrCredentialLastUsed:   cred.GetLastUsed(),
+		},
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.decode.Unmarshaler.UnmarshalJSON.
+func (cred *Credential) UnmarshalJSON(data []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if m[AttrCredentialUbo] != nil {
+		js, _ := json.Marshal(m[AttrCredentialUbo])
+		if err := json.Unmarshal(js, &cred.UniversalBo); err != nil {
+			return err
+		}
+	}
+	if _cols, ok := m[bo.SerKeyFields].(map[string]interface{}); ok {
+		if v, err := reddo.ToString(_cols[FieldCredentialOwnerId]); err != nil {
+			return err
+		} else {
+			cred.SetOwnerId(v)
+		}
+	}
+	if _attrs, ok := m[bo.SerKeyAttrs].(map[string]interface{}); ok {
+		attrListBytes := []string{AttrCredentialId, AttrCredentialPublicKey, AttrCredentialUserHandle, AttrCredentialAaguid}
+		setterListBytes := []func([]byte) *Credential{cred.SetCredentialId, cred.SetPublicKey, cred.SetUserHandle, cred.SetAaguid}
+		for i, attr := range attrListBytes {
+			if v, err := reddo.ToString(_attrs[attr]); err != nil {
+				return err
+			} else if b, err := base64.RawURLEncoding.DecodeString(v); err != nil {
+				return err
+			} else {
+				setterListBytes[i](b)
+			}
+		}
+		if v, err := reddo.ToString(_attrs[AttrCredentialName]); err != nil {
+			return err
+		} else {
+			cred.SetName(v)
+		}
+		if v, err := reddo.ToUint(_attrs[AttrCredentialSignCount]); err != nil {
+			return err
+		} else {
+			cred.SetSignCount(uint32(v))
+		}
+		if v, err := reddo.ToSlice(_attrs[AttrCredentialTransports], typSliceStr); err != nil {
+			return err
+		} else if v != nil {
+			cred.SetTransports(v.([]string))
+		}
+		if v, err := reddo.ToTime(_attrs[AttrCredentialLastUsed]); err != nil {
+			return err
+		} else {
+			cred.SetLastUsed(v)
+		}
+	}
+
+	cred.sync()
+	return nil
+}
+
+// GetOwnerId returns credential's 'owner-id' value.
+func (cred *Credential) GetOwnerId() string {
+	return cred.ownerId
+}
+
+// SetOwnerId sets credential's 'owner-id' value.
+func (cred *Credential) SetOwnerId(value string) *Credential {
+	cred.ownerId = strings.TrimSpace(strings.ToLower(value))
+	return cred
+}
+
+// GetCredentialId returns the WebAuthn credential id.
+func (cred *Credential) GetCredentialId() []byte {
+	return append([]byte{}, cred.credentialId...)
+}
+
+// SetCredentialId sets the WebAuthn credential id.
+func (cred *Credential) SetCredentialId(value []byte) *Credential {
+	cred.credentialId = append([]byte{}, value...)
+	return cred
+}
+
+// GetPublicKey returns credential's public key (COSE_Key format).
+func (cred *Credential) GetPublicKey() []byte {
+	return append([]byte{}, cred.publicKey...)
+}
+
+// SetPublicKey sets credential's public key (COSE_Key format).
+func (cred *Credential) SetPublicKey(value []byte) *Credential {
+	cred.publicKey = append([]byte{}, value...)
+	return cred
+}
+
+// GetUserHandle returns the WebAuthn user handle the credential was registered with.
+func (cred *Credential) GetUserHandle() []byte {
+	return append([]byte{}, cred.userHandle...)
+}
+
+// SetUserHandle sets the WebAuthn user handle the credential was registered with.
+func (cred *Credential) SetUserHandle(value []byte) *Credential {
+	cred.userHandle = append([]byte{}, value...)
+	return cred
+}
+
+// GetAaguid returns AAGUID of the authenticator.
+func (cred *Credential) GetAaguid() []byte {
+	return append([]byte{}, cred.aaguid...)
+}
+
+// SetAaguid sets AAGUID of the authenticator.
+func (cred *Credential) SetAaguid(value []byte) *Credential {
+	cred.aaguid = append([]byte{}, value...)
+	return cred
+}
+
+// GetName returns credential's user-friendly name.
+func (cred *Credential) GetName() string {
+	return cred.name
+}
+
+// SetName sets credential's user-friendly name.
+func (cred *Credential) SetName(value string) *Credential {
+	cred.name = strings.TrimSpace(value)
+	return cred
+}
+
+// GetSignCount returns credential's signature counter.
+func (cred *Credential) GetSignCount() uint32 {
+	return cred.signCount
+}
+
+// SetSignCount sets credential's signature counter.
+func (cred *Credential) SetSignCount(value uint32) *Credential {
+	cred.signCount = value
+	return cred
+}
+
+// GetTransports returns credential's transport hints.
+func (cred *Credential) GetTransports() []string {
+	return append([]string{}, cred.transports...)
+}
+
+// SetTransports sets credential's transport hints.
+func (cred *Credential) SetTransports(value []string) *Credential {
+	cred.transports = append([]string{}, value...)
+	return cred
+}
+
+// GetLastUsed returns the timestamp when the credential was last used.
+func (cred *Credential) GetLastUsed() time.Time {
+	return cred.lastUsed
+}
+
+// SetLastUsed sets the timestamp when the credential was last used.
+func (cred *Credential) SetLastUsed(value time.Time) *Credential {
+	cred.lastUsed = cred.RoundTimestamp(value)
+	return cred
+}
+
+func (cred *Credential) sync() *Credential {
+	cred.SetExtraAttr(FieldCredentialOwnerId, cred.ownerId)
+	cred.SetDataAttr(AttrCredentialId, base64.RawURLEncoding.EncodeToString(cred.credentialId))
+	cred.SetDataAttr(AttrCredentialPublicKey, base64.RawURLEncoding.EncodeToString(cred.publicKey))
+	cred.SetDataAttr(AttrCredentialUserHandle, base64.RawURLEncoding.EncodeToString(cred.userHandle))
+	cred.SetDataAttr(AttrCredentialAaguid, base64.RawURLEncoding.EncodeToString(cred.aaguid))
+	cred.SetDataAttr(AttrCredentialName, cred.name)
+	cred.SetDataAttr(AttrCredentialSignCount, cred.signCount)
+	cred.SetDataAttr(AttrCredentialTransports, cred.transports)
+	cred.SetDataAttr(AttrCredentialLastUsed, cred.lastUsed)
+	cred.UniversalBo.Sync()
+	return cred
+}
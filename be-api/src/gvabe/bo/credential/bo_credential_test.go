@@ -0,0 +1,159 @@
+package credential
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/henge"
+)
+
+func TestIdFromCredentialId(t *testing.T) {
+	testName := "TestIdFromCredentialId"
+	id1 := IdFromCredentialId([]byte("credential-1"))
+	id2 := IdFromCredentialId([]byte("credential-2"))
+	if len(id1) != 43 || id1 == id2 {
+		t.Fatalf("%s failed: %#v / %#v", testName, id1, id2)
+	}
+	if v := IdFromCredentialId([]byte("credential-1")); v != id1 {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, id1, v)
+	}
+}
+
+func TestNewCredential(t *testing.T) {
+	testName := "TestNewCredential"
+	_tagVersion := uint64(1337)
+	_cid := []byte("credential-id")
+	_oid := "System"
+	_pkey := []byte("public-key")
+	cred := NewCredential(_tagVersion, _cid, _oid, _pkey)
+	if cred == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+	if f, v, expected := "tag-version", cred.GetTagVersion(), _tagVersion; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "id", cred.GetId(), IdFromCredentialId(_cid); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "owner-id", cred.GetOwnerId(), "system"; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "credential-id", cred.GetCredentialId(), _cid; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-key", cred.GetPublicKey(), _pkey; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+}
+
+func TestNewCredentialFromUbo(t *testing.T) {
+	testName := "TestNewCredentialFromUbo"
+	if cred := NewCredentialFromUbo(nil); cred != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, cred)
+	}
+
+	_tagVersion := uint64(1337)
+	_cid := []byte("credential-id")
+	_oid := "system"
+	_pkey := []byte("public-key")
+	_uhdl := []byte("user-handle")
+	_name := "My passkey"
+	_count := uint32(7)
+	_trans := []string{"usb", "nfc"}
+	_lat := time.Now().Round(time.Second)
+	ubo := henge.NewUniversalBo(IdFromCredentialId(_cid), _tagVersion)
+	ubo.SetDataJson("invalid json string")
+	if cred := NewCredentialFromUbo(ubo); cred == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ubo.SetExtraAttr(FieldCredentialOwnerId, _oid)
+	ubo.SetDataAttr(AttrCredentialId, base64.RawURLEncoding.EncodeToString(_cid))
+	ubo.SetDataAttr(AttrCredentialPublicKey, base64.RawURLEncoding.EncodeToString(_pkey))
+	ubo.SetDataAttr(AttrCredentialUserHandle, base64.RawURLEncoding.EncodeToString(_uhdl))
+	ubo.SetDataAttr(AttrCredentialName, _name)
+	ubo.SetDataAttr(AttrCredentialSignCount, _count)
+	ubo.SetDataAttr(AttrCredentialTransports, _trans)
+	ubo.SetDataAttr(AttrCredentialLastUsed, _lat)
+	cred := NewCredentialFromUbo(ubo)
+	if cred == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	if f, v, expected := "owner-id", cred.GetOwnerId(), _oid; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "credential-id", cred.GetCredentialId(), _cid; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-key", cred.GetPublicKey(), _pkey; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "user-handle", cred.GetUserHandle(), _uhdl; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "name", cred.GetName(), _name; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "sign-count", cred.GetSignCount(), _count; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "transports", cred.GetTransports(), _trans; !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "last-used", cred.GetLastUsed(), _lat; !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+}
+
+func TestCredential_json(t *testing.T) {
+	testName := "TestCredential_json"
+
+	cred1 := NewCredential(1337, []byte("credential-id"), "system", []byte("public-key"))
+	cred1.SetUserHandle([]byte("user-handle")).SetAaguid(make([]byte, 16)).SetName("My passkey").
+		SetSignCount(7).SetTransports([]string{"internal", "hybrid"}).SetLastUsed(time.Now().Round(time.Second))
+	js1, _ := json.Marshal(cred1)
+
+	var cred2 *Credential
+	err := json.Unmarshal(js1, &cred2)
+	if err != nil {
+		t.Fatalf("%s failed: %e", testName, err)
+	}
+
+	if f, v, expected := "id", cred2.GetId(), cred1.GetId(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "owner-id", cred2.GetOwnerId(), cred1.GetOwnerId(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "credential-id", cred2.GetCredentialId(), cred1.GetCredentialId(); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "public-key", cred2.GetPublicKey(), cred1.GetPublicKey(); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "user-handle", cred2.GetUserHandle(), cred1.GetUserHandle(); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "aaguid", cred2.GetAaguid(), cred1.GetAaguid(); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "name", cred2.GetName(), cred1.GetName(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "sign-count", cred2.GetSignCount(), cred1.GetSignCount(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "transports", cred2.GetTransports(), cred1.GetTransports(); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "last-used", cred2.GetLastUsed(), cred1.GetLastUsed(); !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if cred1.GetChecksum() != cred2.GetChecksum() {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, cred1.GetChecksum(), cred2.GetChecksum())
+	}
+}
@@ -0,0 +1,150 @@
+package credential
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/btnguyen2k/prom"
+	"main/src/gvabe/bo/user"
+)
+
+type TestSetupOrTeardownFunc func(t *testing.T, testName string)
+
+func setupTest(t *testing.T, testName string, extraSetupFunc, extraTeardownFunc TestSetupOrTeardownFunc) func(t *testing.T) {
+	if extraSetupFunc != nil {
+		extraSetupFunc(t, testName)
+	}
+	return func(t *testing.T) {
+		if extraTeardownFunc != nil {
+			extraTeardownFunc(t, testName)
+		}
+	}
+}
+
+var (
+	testAdc  *prom.AwsDynamodbConnect
+	testMc   *prom.MongoConnect
+	testSqlc *prom.SqlConnect
+)
+
+/*----------------------------------------------------------------------*/
+
+var (
+	testCredentialId = []byte("credential-id")
+	testPublicKey    = []byte("public-key")
+)
+
+func doTestCredentialDao_Create(t *testing.T, testName string, credDao CredentialDao) {
+	cred := NewCredential(1357, testCredentialId, "btnguyen2k", testPublicKey)
+	ok, err := credDao.Create(cred)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+}
+
+func doTestCredentialDao_Get(t *testing.T, testName string, credDao CredentialDao) {
+	credDao.Create(NewCredential(1357, testCredentialId, "btnguyen2k", testPublicKey))
+
+	if cred, err := credDao.Get("not_found"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if cred != nil {
+		t.Fatalf("%s failed: credential %s should not exist", testName, "not_found")
+	}
+
+	id := IdFromCredentialId(testCredentialId)
+	if cred, err := credDao.Get(id); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if cred == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := cred.GetId(); v != id {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, id, v)
+		}
+		if v := cred.GetTagVersion(); v != 1357 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 1357, v)
+		}
+		if v := cred.GetOwnerId(); v != "btnguyen2k" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "btnguyen2k", v)
+		}
+		if v := cred.GetCredentialId(); !bytes.Equal(v, testCredentialId) {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, testCredentialId, v)
+		}
+		if v := cred.GetPublicKey(); !bytes.Equal(v, testPublicKey) {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, testPublicKey, v)
+		}
+	}
+}
+
+func doTestCredentialDao_Delete(t *testing.T, testName string, credDao CredentialDao) {
+	credDao.Create(NewCredential(1357, testCredentialId, "btnguyen2k", testPublicKey))
+	id := IdFromCredentialId(testCredentialId)
+	cred, err := credDao.Get(id)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if cred == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ok, err := credDao.Delete(cred)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if !ok {
+		t.Fatalf("%s failed: cannot delete credential [%s]", testName, cred.GetId())
+	}
+
+	if cred, err := credDao.Get(id); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if cred != nil {
+		t.Fatalf("%s failed: credential %s should not exist", testName, id)
+	}
+}
+
+func doTestCredentialDao_Update(t *testing.T, testName string, credDao CredentialDao) {
+	cred := NewCredential(1357, testCredentialId, "btnguyen2k", testPublicKey)
+	credDao.Create(cred)
+
+	cred.SetTagVersion(2468)
+	cred.SetName("My security key").SetSignCount(12)
+	ok, err := credDao.Update(cred)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+
+	if cred, err := credDao.Get(IdFromCredentialId(testCredentialId)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if cred == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := cred.GetTagVersion(); v != 2468 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 2468, v)
+		}
+		if v := cred.GetName(); v != "My security key" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "My security key", v)
+		}
+		if v := cred.GetSignCount(); v != 12 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 12, v)
+		}
+	}
+}
+
+func doTestCredentialDao_GetUserCredentials(t *testing.T, testName string, credDao CredentialDao) {
+	for i := 0; i < 10; i++ {
+		cred := NewCredential(uint64(i), []byte(strconv.Itoa(i)), strconv.Itoa(i%3), testPublicKey)
+		credDao.Create(cred)
+	}
+
+	u := user.NewUser(123, "2")
+	credList, err := credDao.GetUserCredentials(u)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(credList) != 3 {
+		t.Fatalf("%s failed: expected %#v credentials but received %#v", testName, 3, len(credList))
+	}
+	for _, cred := range credList {
+		if cred.GetOwnerId() != "2" {
+			t.Fatalf("%s failed: credential %#v does not belong to user %#v", testName, cred.GetId(), "2")
+		}
+	}
+}
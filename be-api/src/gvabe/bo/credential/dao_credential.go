@@ -0,0 +1,33 @@
+package credential
+
+import (
+	"main/src/gvabe/bo/user"
+)
+
+const (
+	TableCredential = "exter_credential"
+)
+
+// CredentialDao defines API to access Credential storage.
+type CredentialDao interface {
+	// Delete removes the specified business object from storage.
+	Delete(bo *Credential) (bool, error)
+
+	// Create persists a new business object to storage.
+	Create(bo *Credential) (bool, error)
+
+	// Get retrieves a business object from storage.
+	Get(id string) (*Credential, error)
+
+	// // getN retrieves N business objects from storage.
+	// getN(fromOffset, maxNumRows int) ([]*Credential, error)
+	//
+	// // getAll retrieves all available business objects from storage.
+	// getAll() ([]*Credential, error)
+
+	// GetUserCredentials retrieves all credentials registered by a specific user.
+	GetUserCredentials(u *user.User) ([]*Credential, error)
+
+	// Update modifies an existing business object.
+	Update(bo *Credential) (bool, error)
+}
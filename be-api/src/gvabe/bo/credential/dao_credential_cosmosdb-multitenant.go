@@ -0,0 +1,17 @@
+package credential
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewCredentialDaoMultitenantCosmosdb is helper method to create CosmosDB-implementation (multi-tenant table) of CredentialDao.
+func NewCredentialDaoMultitenantCosmosdb(sqlc *prom.SqlConnect, tableName string) CredentialDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbMultitenantPkName, PkValue: bo.CosmosdbMultitenantPkValueCredential, TxModeOnWrite: true}
+	innerDao := CredentialDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &CredentialDaoCosmosdb{CredentialDaoSql: innerDao, spec: spec}
+	return dao
+}
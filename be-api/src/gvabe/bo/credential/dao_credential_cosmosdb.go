@@ -0,0 +1,46 @@
+package credential
+
+import (
+	"fmt"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewCredentialDaoCosmosdb is helper method to create CosmosDB-implementation of CredentialDao.
+func NewCredentialDaoCosmosdb(sqlc *prom.SqlConnect, tableName string) CredentialDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbPkName, TxModeOnWrite: true}
+	innerDao := CredentialDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &CredentialDaoCosmosdb{CredentialDaoSql: innerDao, spec: spec}
+	return dao
+}
+
+// InitCredentialTableCosmosdb is helper function to initialize CosmosDB-based table to store WebAuthn credential data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitCredentialTableCosmosdb(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorCosmosDb:
+		return InitCredentialTableSql(sqlc, tableName)
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// CredentialDaoCosmosdb is CosmosDB-implementation of CredentialDao.
+type CredentialDaoCosmosdb struct {
+	CredentialDaoSql
+	spec *henge.CosmosdbDaoSpec
+}
+
+// Create implements CredentialDao.Create.
+func (dao *CredentialDaoCosmosdb) Create(bo *Credential) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkName != "" && dao.spec.PkValue != "" {
+		ubo.SetExtraAttr(dao.spec.PkName, dao.spec.PkValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
@@ -0,0 +1,21 @@
+package credential
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+const (
+	dynamodbPkValueCredential = "credential"
+)
+
+// NewCredentialDaoMultitenantAwsDynamodb is helper method to create AWS DynamoDB-implementation (multi-tenant table) of CredentialDao.
+func NewCredentialDaoMultitenantAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) CredentialDao {
+	spec := &henge.DynamodbDaoSpec{PkPrefix: bo.DynamodbMultitenantPkName, PkPrefixValue: dynamodbPkValueCredential}
+	dao := &CredentialDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
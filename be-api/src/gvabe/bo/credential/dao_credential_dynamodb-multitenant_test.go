@@ -0,0 +1,138 @@
+package credential
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/henge"
+	"github.com/btnguyen2k/prom"
+
+	"main/src/gvabe/bo"
+)
+
+const tableNameMultitenantDynamodb = "exter_test"
+
+var setupTestDynamodbMultitenant = func(t *testing.T, testName string) {
+	testAdc = _createAwsDynamodbConnect(t, testName)
+	for _, tableName := range []string{tableNameMultitenantDynamodb, tableNameMultitenantDynamodb + henge.AwsDynamodbUidxTableSuffix} {
+		testAdc.DeleteTable(nil, tableName)
+		err := prom.AwsDynamodbWaitForTableStatus(testAdc, tableName, []string{""}, 1*time.Second, 10*time.Second)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+	err := bo.InitMultitenantTableAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestDynamodbMultitenant = func(t *testing.T, testName string) {
+	if testAdc != nil {
+		defer func() {
+			defer func() { testAdc = nil }()
+			testAdc.Close()
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewCredentialDaoMultitenantAwsDynamodb(t *testing.T) {
+	testName := "TestNewCredentialDaoMultitenantAwsDynamodb"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	if credDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestCredentialDaoMultitenantAwsDynamodb_Create(t *testing.T) {
+	testName := "TestCredentialDaoMultitenantAwsDynamodb_Create"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestCredentialDao_Create(t, testName, credDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("%s failed: expected 1 item inserted but received %#v", testName, len(items))
+	}
+	if v, _ := items[0][bo.DynamodbMultitenantPkName].(string); v != dynamodbPkValueCredential {
+		t.Fatalf("%s failed: expected item has field '%s' with value '%s' but received %#v", testName, bo.DynamodbMultitenantPkName, dynamodbPkValueCredential, items[0])
+	}
+}
+
+func TestCredentialDaoMultitenantAwsDynamodb_Get(t *testing.T) {
+	testName := "TestCredentialDaoMultitenantAwsDynamodb_Get"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestCredentialDao_Get(t, testName, credDao)
+}
+
+func TestCredentialDaoMultitenantAwsDynamodb_Delete(t *testing.T) {
+	testName := "TestCredentialDaoMultitenantAwsDynamodb_Delete"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestCredentialDao_Delete(t, testName, credDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 0 {
+		for _, item := range items {
+			fmt.Printf("\tDEBUG: %#v\n", item)
+		}
+		t.Fatalf("%s failed: expected 0 item inserted but received %#v", testName, len(items))
+	}
+}
+
+func TestCredentialDaoMultitenantAwsDynamodb_Update(t *testing.T) {
+	testName := "TestCredentialDaoMultitenantAwsDynamodb_Update"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestCredentialDao_Update(t, testName, credDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 1 {
+		for _, item := range items {
+			fmt.Printf("\tDEBUG: %#v\n", item)
+		}
+		t.Fatalf("%s failed: expected 1 item inserted but received %#v", testName, len(items))
+	}
+	if v, _ := items[0][bo.DynamodbMultitenantPkName].(string); v != dynamodbPkValueCredential {
+		t.Fatalf("%s failed: expected item has field '%s' with value '%s' but received %#v", testName, bo.DynamodbMultitenantPkName, dynamodbPkValueCredential, items[0])
+	}
+}
+
+func TestCredentialDaoMultitenantAwsDynamodb_GetUserCredentials(t *testing.T) {
+	testName := "TestCredentialDaoMultitenantAwsDynamodb_GetUserCredentials"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestCredentialDao_GetUserCredentials(t, testName, credDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 10 {
+		for _, item := range items {
+			fmt.Printf("\tDEBUG: %#v\n", item)
+		}
+		t.Fatalf("%s failed: expected 10 items inserted but received %#v", testName, len(items))
+	}
+	for _, item := range items {
+		if v, _ := item[bo.DynamodbMultitenantPkName].(string); v != dynamodbPkValueCredential {
+			t.Fatalf("%s failed: expected item has field '%s' with value '%s' but received %#v", testName, bo.DynamodbMultitenantPkName, dynamodbPkValueCredential, items[0])
+		}
+	}
+}
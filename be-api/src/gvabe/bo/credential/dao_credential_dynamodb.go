@@ -0,0 +1,91 @@
+package credential
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo/user"
+)
+
+// NewCredentialDaoAwsDynamodb is helper method to create AWS DynamoDB-implementation of CredentialDao.
+func NewCredentialDaoAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) CredentialDao {
+	var spec *henge.DynamodbDaoSpec = nil
+	dao := &CredentialDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
+
+// InitCredentialTableAwsDynamodb is helper function to initialize AWS DynamoDB table(s) to store WebAuthn credential data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitCredentialTableAwsDynamodb(adc *prom.AwsDynamodbConnect, tableName string) error {
+	spec := &henge.DynamodbTablesSpec{MainTableRcu: 1, MainTableWcu: 1}
+	return henge.InitDynamodbTables(adc, tableName, spec)
+}
+
+// CredentialDaoAwsDynamodb is AWS DynamoDB-implementation of CredentialDao.
+type CredentialDaoAwsDynamodb struct {
+	henge.UniversalDao
+	spec *henge.DynamodbDaoSpec
+}
+
+// Delete implements CredentialDao.Delete.
+func (dao *CredentialDaoAwsDynamodb) Delete(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements CredentialDao.Create.
+func (dao *CredentialDaoAwsDynamodb) Create(bo *Credential) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkPrefix != "" {
+		ubo.SetExtraAttr(dao.spec.PkPrefix, dao.spec.PkPrefixValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
+
+// Get implements CredentialDao.Get.
+func (dao *CredentialDaoAwsDynamodb) Get(id string) (*Credential, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewCredentialFromUbo(ubo), err
+}
+
+// getN implements CredentialDao.getN.
+func (dao *CredentialDaoAwsDynamodb) getN(fromOffset, maxNumRows int) ([]*Credential, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Credential, 0)
+	for _, ubo := range uboList {
+		bo := NewCredentialFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements CredentialDao.getAll.
+func (dao *CredentialDaoAwsDynamodb) getAll() ([]*Credential, error) {
+	return dao.getN(0, 0)
+}
+
+// GetUserCredentials implements CredentialDao.GetUserCredentials.
+func (dao *CredentialDaoAwsDynamodb) GetUserCredentials(u *user.User) ([]*Credential, error) {
+	if credList, err := dao.getAll(); err != nil {
+		return nil, err
+	} else {
+		result := make([]*Credential, 0)
+		for _, cred := range credList {
+			if cred.ownerId == u.GetId() {
+				result = append(result, cred)
+			}
+		}
+		return result, nil
+	}
+}
+
+// Update implements CredentialDao.Update.
+func (dao *CredentialDaoAwsDynamodb) Update(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
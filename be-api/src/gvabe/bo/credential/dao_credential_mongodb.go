@@ -0,0 +1,86 @@
+package credential
+
+import (
+	"strings"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo/user"
+)
+
+// NewCredentialDaoMongo is helper method to create MongoDB-implementation of CredentialDao.
+func NewCredentialDaoMongo(mc *prom.MongoConnect, collectionName string) CredentialDao {
+	txMode := strings.Index(strings.ToLower(mc.GetUrl()), "replicaset=") > 0
+	dao := &CredentialDaoMongo{UniversalDao: henge.NewUniversalDaoMongo(mc, collectionName, txMode)}
+	return dao
+}
+
+// InitCredentialTableMongo is helper function to initialize MongoDB table (collection) to store WebAuthn credential data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitCredentialTableMongo(mc *prom.MongoConnect, collectionName string) error {
+	return henge.InitMongoCollection(mc, collectionName)
+}
+
+// CredentialDaoMongo is MongoDB-implementation of CredentialDao.
+type CredentialDaoMongo struct {
+	henge.UniversalDao
+}
+
+// Delete implements CredentialDao.Delete.
+func (dao *CredentialDaoMongo) Delete(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements CredentialDao.Create.
+func (dao *CredentialDaoMongo) Create(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements CredentialDao.Get.
+func (dao *CredentialDaoMongo) Get(id string) (*Credential, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewCredentialFromUbo(ubo), err
+}
+
+// getN implements CredentialDao.getN.
+func (dao *CredentialDaoMongo) getN(fromOffset, maxNumRows int) ([]*Credential, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Credential, 0)
+	for _, ubo := range uboList {
+		bo := NewCredentialFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements CredentialDao.getAll.
+func (dao *CredentialDaoMongo) getAll() ([]*Credential, error) {
+	return dao.getN(0, 0)
+}
+
+// GetUserCredentials implements CredentialDao.GetUserCredentials.
+func (dao *CredentialDaoMongo) GetUserCredentials(u *user.User) ([]*Credential, error) {
+	if credList, err := dao.getAll(); err != nil {
+		return nil, err
+	} else {
+		result := make([]*Credential, 0)
+		for _, cred := range credList {
+			if cred.ownerId == u.GetId() {
+				result = append(result, cred)
+			}
+		}
+		return result, nil
+	}
+}
+
+// Update implements CredentialDao.Update.
+func (dao *CredentialDaoMongo) Update(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
@@ -0,0 +1,101 @@
+package credential
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+)
+
+func _createMongoConnect(t *testing.T, testName string) *prom.MongoConnect {
+	mongoDb := strings.ReplaceAll(os.Getenv("MONGO_DB"), `"`, "")
+	mongoUrl := strings.ReplaceAll(os.Getenv("MONGO_URL"), `"`, "")
+	if mongoDb == "" || mongoUrl == "" {
+		t.Skipf("%s skipped", testName)
+		return nil
+	}
+	mongoPoolOpts := &prom.MongoPoolOpts{
+		ConnectTimeout:         5 * time.Second,
+		SocketTimeout:          7 * time.Second,
+		ServerSelectionTimeout: 11 * time.Second,
+	}
+	mc, err := prom.NewMongoConnectWithPoolOptions(mongoUrl, mongoDb, 10000, mongoPoolOpts)
+	if err != nil {
+		t.Fatalf("%s/%s failed: %s", testName, "NewMongoConnect", err)
+	}
+	return mc
+}
+
+const collectionNameMongo = "exter_test_credential"
+
+var setupTestMongo = func(t *testing.T, testName string) {
+	testMc = _createMongoConnect(t, testName)
+	testMc.GetCollection(collectionNameMongo).Drop(nil)
+	err := InitCredentialTableMongo(testMc, collectionNameMongo)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestMongo = func(t *testing.T, testName string) {
+	if testMc != nil {
+		defer func() {
+			defer func() { testMc = nil }()
+			testMc.Close(nil)
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewCredentialDaoMongo(t *testing.T) {
+	testName := "TestNewCredentialDaoMongo"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMongo(testMc, collectionNameMongo)
+	if credDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestCredentialDaoMongo_Create(t *testing.T) {
+	testName := "TestCredentialDaoMongo_Create"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMongo(testMc, collectionNameMongo)
+	doTestCredentialDao_Create(t, testName, credDao)
+}
+
+func TestCredentialDaoMongo_Get(t *testing.T) {
+	testName := "TestCredentialDaoMongo_Get"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMongo(testMc, collectionNameMongo)
+	doTestCredentialDao_Get(t, testName, credDao)
+}
+
+func TestCredentialDaoMongo_Delete(t *testing.T) {
+	testName := "TestCredentialDaoMongo_Delete"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMongo(testMc, collectionNameMongo)
+	doTestCredentialDao_Delete(t, testName, credDao)
+}
+
+func TestCredentialDaoMongo_Update(t *testing.T) {
+	testName := "TestCredentialDaoMongo_Update"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMongo(testMc, collectionNameMongo)
+	doTestCredentialDao_Update(t, testName, credDao)
+}
+
+func TestCredentialDaoMongo_GetUserCredentials(t *testing.T) {
+	testName := "TestCredentialDaoMongo_GetUserCredentials"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	credDao := NewCredentialDaoMongo(testMc, collectionNameMongo)
+	doTestCredentialDao_GetUserCredentials(t, testName, credDao)
+}
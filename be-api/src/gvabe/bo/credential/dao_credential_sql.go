@@ -0,0 +1,105 @@
+package credential
+
+import (
+	"fmt"
+
+	"github.com/btnguyen2k/prom"
+	"main/src/gvabe/bo"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo/user"
+)
+
+const (
+	SqlColCredentialUserId = "zuid"
+)
+
+// NewCredentialDaoSql is helper method to create SQL-implementation of CredentialDao.
+func NewCredentialDaoSql(sqlc *prom.SqlConnect, tableName string) CredentialDao {
+	dao := &CredentialDaoSql{}
+	dao.UniversalDao = henge.NewUniversalDaoSql(sqlc, tableName, true, map[string]string{SqlColCredentialUserId: FieldCredentialOwnerId})
+	return dao
+}
+
+// InitCredentialTableSql is helper function to initialize SQL-based table to store WebAuthn credential data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitCredentialTableSql(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorPgSql:
+		return henge.InitPgsqlTable(sqlc, tableName, map[string]string{SqlColCredentialUserId: "VARCHAR(32)"})
+	case prom.FlavorMsSql:
+		return henge.InitMssqlTable(sqlc, tableName, map[string]string{SqlColCredentialUserId: "NVARCHAR(32)"})
+	case prom.FlavorMySql:
+		return henge.InitMysqlTable(sqlc, tableName, map[string]string{SqlColCredentialUserId: "VARCHAR(32)"})
+	case prom.FlavorOracle:
+		return henge.InitOracleTable(sqlc, tableName, map[string]string{SqlColCredentialUserId: "NVARCHAR2(32)"})
+	case prom.FlavorSqlite:
+		return henge.InitSqliteTable(sqlc, tableName, map[string]string{SqlColCredentialUserId: "VARCHAR(32)"})
+	case prom.FlavorCosmosDb:
+		return henge.InitCosmosdbCollection(sqlc, tableName, &henge.CosmosdbCollectionSpec{Pk: bo.CosmosdbPkName})
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// CredentialDaoSql is SQL-implementation of CredentialDao.
+type CredentialDaoSql struct {
+	henge.UniversalDao
+}
+
+// Delete implements CredentialDao.Delete.
+func (dao *CredentialDaoSql) Delete(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements CredentialDao.Create.
+func (dao *CredentialDaoSql) Create(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements CredentialDao.Get.
+func (dao *CredentialDaoSql) Get(id string) (*Credential, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewCredentialFromUbo(ubo), err
+}
+
+// getN implements CredentialDao.getN.
+func (dao *CredentialDaoSql) getN(fromOffset, maxNumRows int) ([]*Credential, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Credential, 0)
+	for _, ubo := range uboList {
+		bo := NewCredentialFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements CredentialDao.getAll.
+func (dao *CredentialDaoSql) getAll() ([]*Credential, error) {
+	return dao.getN(0, 0)
+}
+
+// GetUserCredentials implements CredentialDao.GetUserCredentials.
+func (dao *CredentialDaoSql) GetUserCredentials(u *user.User) ([]*Credential, error) {
+	if credList, err := dao.getAll(); err != nil {
+		return nil, err
+	} else {
+		result := make([]*Credential, 0)
+		for _, cred := range credList {
+			if cred.ownerId == u.GetId() {
+				result = append(result, cred)
+			}
+		}
+		return result, nil
+	}
+}
+
+// Update implements CredentialDao.Update.
+func (dao *CredentialDaoSql) Update(bo *Credential) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
@@ -0,0 +1,205 @@
+// Package identity contains business object (BO) and data access object (DAO) implementations for linked Identity.
+//
+// Available since v0.8.0
+package identity
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	"github.com/btnguyen2k/henge"
+	"main/src/gvabe/bo"
+)
+
+// IdFromProviderSubject builds the BO id from an identity provider's name and the subject (immutable user id) issued
+// by that provider. Subjects are opaque and can be long, hence their hash is used as BO id.
+func IdFromProviderSubject(provider, subject string) string {
+	h := sha256.Sum256([]byte(strings.TrimSpace(strings.ToLower(provider)) + "\n" + strings.TrimSpace(subject)))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// NewIdentity is helper function to create new Identity bo.
+func NewIdentity(tagVersion uint64, provider, subject, ownerId string) *Identity {
+	ident := &Identity{
+		UniversalBo: henge.NewUniversalBo(IdFromProviderSubject(provider, subject), tagVersion, henge.UboOpt{TimeLayout: bo.UboTimeLayout, TimestampRounding: bo.UboTimestampRounding}),
+	}
+	ident.
+		SetOwnerId(ownerId).
+		SetProvider(provider).
+		SetSubject(subject).
+		SetLinkedAt(time.Now())
+	return ident.sync()
+}
+
+// NewIdentityFromUbo is helper function to create new Identity bo from a universal bo.
+func NewIdentityFromUbo(ubo *henge.UniversalBo) *Identity {
+	if ubo == nil {
+		return nil
+	}
+	ubo = ubo.Clone()
+	ident := &Identity{UniversalBo: ubo}
+	if v, err := ident.GetExtraAttrAs(FieldIdentityOwnerId, reddo.TypeString); err == nil && v != nil {
+		ident.SetOwnerId(v.(string))
+	}
+	fieldListStr := []string{AttrIdentityProvider, AttrIdentitySubject, AttrIdentityEmail}
+	setterListStr := []func(string) *Identity{ident.SetProvider, ident.SetSubject, ident.SetEmail}
+	for i, attr := range fieldListStr {
+		if v, err := ident.GetDataAttrAs(attr, reddo.TypeString); err == nil && v != nil {
+			setterListStr[i](v.(string))
+		}
+	}
+	if v, err := ident.GetDataAttrAs(AttrIdentityLinkedAt, reddo.TypeTime); err == nil && v != nil {
+		ident.SetLinkedAt(v.(time.Time))
+	}
+	return ident.sync()
+}
+
+const (
+	FieldIdentityOwnerId = "oid"
+
+	AttrIdentityProvider = "prov"
+	AttrIdentitySubject  = "sub"
+	AttrIdentityEmail    = "email"
+	AttrIdentityLinkedAt = "lnkat"
+	AttrIdentityUbo      = "_ubo"
+)
+
+// Identity is the business object: an external identity (provider + subject) linked to an Exter user account.
+// Identity's unique id is derived from the provider name and subject (see IdFromProviderSubject), so that one external
+// identity can be linked to at most one user.
+type Identity struct {
+	*henge.UniversalBo `json:"_ubo"`
+	ownerId            string    `json:"oid"`   // id of user the identity is linked to
+	provider           string    `json:"prov"`  // name of the identity provider (e.g. "google", "github", "saml:<idp>")
+	subject            string    `json:"sub"`   // immutable user id issued by the identity provider
+	email              string    `json:"email"` // email address last reported by the identity provider
+	linkedAt           time.Time `json:"lnkat"` // timestamp when the identity was linked
+}
+
+// MarshalJSON implements json.encode.Marshaler.MarshalJSON.
+func (ident *Identity) MarshalJSON() ([]byte, error) {
+	ident.sync()
+	m := map[string]interface{}{
+		AttrIdentityUbo: ident.UniversalBo.Clone(),
+		bo.SerKeyFields: map[string]interface{}{
+			FieldIdentityOwnerId: ident.GetOwnerId(),
+		},
+		bo.SerKeyAttrs: map[string]interface{}{
+			AttrIdentityProvider: ident.GetProvider(),
+			AttrIdentitySubject:  ident.GetSubject(),
+			AttrIdentityEmail:    ident.GetEmail(),
+			AttrIdentityLinkedAt: ident.GetLinkedAt(),
+		},
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.decode.Unmarshaler.UnmarshalJSON.
+func (ident *Identity) UnmarshalJSON(data []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if m[AttrIdentityUbo] != nil {
+		js, _ := json.Marshal(m[AttrIdentityUbo])
+		if err := json.Unmarshal(js, &ident.UniversalBo); err != nil {
+			return err
+		}
+	}
+	if _cols, ok := m[bo.SerKeyFields].(map[string]interface{}); ok {
+		if v, err := reddo.ToString(_cols[FieldIdentityOwnerId]); err != nil {
+			return err
+		} else {
+			ident.SetOwnerId(v)
+		}
+	}
+	if _attrs, ok := m[bo.SerKeyAttrs].(map[string]interface{}); ok {
+		attrListStr := []string{AttrIdentityProvider, AttrIdentitySubject, AttrIdentityEmail}
+		setterListStr := []func(string) *Identity{ident.SetProvider, ident.SetSubject, ident.SetEmail}
+		for i, attr := range attrListStr {
+			if v, err := reddo.ToString(_attrs[attr]); err != nil {
+				return err
+			} else {
+				setterListStr[i](v)
+			}
+		}
+		if v, err := reddo.ToTime(_attrs[AttrIdentityLinkedAt]); err != nil {
+			return err
+		} else {
+			ident.SetLinkedAt(v)
+		}
+	}
+
+	ident.sync()
+	return nil
+}
+
+// GetOwnerId returns identity's 'owner-id' value.
+func (ident *Identity) GetOwnerId() string {
+	return ident.ownerId
+}
+
+// SetOwnerId sets identity's 'owner-id' value.
+func (ident *Identity) SetOwnerId(value string) *Identity {
+	ident.ownerId = strings.TrimSpace(strings.ToLower(value))
+	return ident
+}
+
+// GetProvider returns name of the identity provider.
+func (ident *Identity) GetProvider() string {
+	return ident.provider
+}
+
+// SetProvider sets name of the identity provider.
+func (ident *Identity) SetProvider(value string) *Identity {
+	ident.provider = strings.TrimSpace(strings.ToLower(value))
+	return ident
+}
+
+// GetSubject returns the immutable user id issued by the identity provider.
+func (ident *Identity) GetSubject() string {
+	return ident.subject
+}
+
+// SetSubject sets the immutable user id issued by the identity provider.
+func (ident *Identity) SetSubject(value string) *Identity {
+	ident.subject = strings.TrimSpace(value)
+	return ident
+}
+
+// GetEmail returns the email address last reported by the identity provider.
+func (ident *Identity) GetEmail() string {
+	return ident.email
+}
+
+// SetEmail sets the email address reported by the identity provider.
+func (ident *Identity) SetEmail(value string) *Identity {
+	ident.email = strings.TrimSpace(strings.ToLower(value))
+	return ident
+}
+
+// GetLinkedAt returns the timestamp when the identity was linked.
+func (ident *Identity) GetLinkedAt() time.Time {
+	return ident.linkedAt
+}
+
+// SetLinkedAt sets the timestamp when the identity was linked.
+func (ident *Identity) SetLinkedAt(value time.Time) *Identity {
+	ident.linkedAt = ident.RoundTimestamp(value)
+	return ident
+}
+
+func (ident *Identity) sync() *Identity {
+	ident.SetExtraAttr(FieldIdentityOwnerId, ident.ownerId)
+	ident.SetDataAttr(AttrIdentityProvider, ident.provider)
+	ident.SetDataAttr(AttrIdentitySubject, ident.subject)
+	ident.SetDataAttr(AttrIdentityEmail, ident.email)
+	ident.SetDataAttr(AttrIdentityLinkedAt, ident.linkedAt)
+	ident.UniversalBo.Sync()
+	return ident
+}
@@ -0,0 +1,133 @@
+package identity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/henge"
+)
+
+func TestIdFromProviderSubject(t *testing.T) {
+	testName := "TestIdFromProviderSubject"
+	id1 := IdFromProviderSubject("google", "subject-1")
+	id2 := IdFromProviderSubject("google", "subject-2")
+	id3 := IdFromProviderSubject("github", "subject-1")
+	if len(id1) != 43 || id1 == id2 || id1 == id3 {
+		t.Fatalf("%s failed: %#v / %#v / %#v", testName, id1, id2, id3)
+	}
+	if v := IdFromProviderSubject(" Google ", "subject-1"); v != id1 {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, id1, v)
+	}
+}
+
+func TestNewIdentity(t *testing.T) {
+	testName := "TestNewIdentity"
+	_tagVersion := uint64(1337)
+	_prov := "Google"
+	_sub := "1234567890"
+	_oid := "System"
+	ident := NewIdentity(_tagVersion, _prov, _sub, _oid)
+	if ident == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+	if f, v, expected := "tag-version", ident.GetTagVersion(), _tagVersion; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "id", ident.GetId(), IdFromProviderSubject(_prov, _sub); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "owner-id", ident.GetOwnerId(), "system"; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "provider", ident.GetProvider(), "google"; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "subject", ident.GetSubject(), _sub; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if ident.GetLinkedAt().IsZero() {
+		t.Fatalf("%s failed: linked-at must be set", testName)
+	}
+}
+
+func TestNewIdentityFromUbo(t *testing.T) {
+	testName := "TestNewIdentityFromUbo"
+	if ident := NewIdentityFromUbo(nil); ident != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, ident)
+	}
+
+	_tagVersion := uint64(1337)
+	_prov := "github"
+	_sub := "42"
+	_oid := "system"
+	_email := "user@domain.com"
+	_lat := time.Now().Round(time.Second)
+	ubo := henge.NewUniversalBo(IdFromProviderSubject(_prov, _sub), _tagVersion)
+	ubo.SetDataJson("invalid json string")
+	if ident := NewIdentityFromUbo(ubo); ident == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ubo.SetExtraAttr(FieldIdentityOwnerId, _oid)
+	ubo.SetDataAttr(AttrIdentityProvider, _prov)
+	ubo.SetDataAttr(AttrIdentitySubject, _sub)
+	ubo.SetDataAttr(AttrIdentityEmail, _email)
+	ubo.SetDataAttr(AttrIdentityLinkedAt, _lat)
+	ident := NewIdentityFromUbo(ubo)
+	if ident == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	if f, v, expected := "owner-id", ident.GetOwnerId(), _oid; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "provider", ident.GetProvider(), _prov; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "subject", ident.GetSubject(), _sub; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "email", ident.GetEmail(), _email; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "linked-at", ident.GetLinkedAt(), _lat; !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+}
+
+func TestIdentity_json(t *testing.T) {
+	testName := "TestIdentity_json"
+
+	ident1 := NewIdentity(1337, "saml:okta", "user@domain.com", "system")
+	ident1.SetEmail("user@domain.com").SetLinkedAt(time.Now().Round(time.Second))
+	js1, _ := json.Marshal(ident1)
+
+	var ident2 *Identity
+	err := json.Unmarshal(js1, &ident2)
+	if err != nil {
+		t.Fatalf("%s failed: %e", testName, err)
+	}
+
+	if f, v, expected := "id", ident2.GetId(), ident1.GetId(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "owner-id", ident2.GetOwnerId(), ident1.GetOwnerId(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "provider", ident2.GetProvider(), ident1.GetProvider(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "subject", ident2.GetSubject(), ident1.GetSubject(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "email", ident2.GetEmail(), ident1.GetEmail(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "linked-at", ident2.GetLinkedAt(), ident1.GetLinkedAt(); !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if ident1.GetChecksum() != ident2.GetChecksum() {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, ident1.GetChecksum(), ident2.GetChecksum())
+	}
+}
@@ -0,0 +1,33 @@
+package identity
+
+import (
+	"main/src/gvabe/bo/user"
+)
+
+const (
+	TableIdentity = "exter_identity"
+)
+
+// IdentityDao defines API to access Identity storage.
+type IdentityDao interface {
+	// Delete removes the specified business object from storage.
+	Delete(bo *Identity) (bool, error)
+
+	// Create persists a new business object to storage.
+	Create(bo *Identity) (bool, error)
+
+	// Get retrieves a business object from storage.
+	Get(id string) (*Identity, error)
+
+	// // getN retrieves N business objects from storage.
+	// getN(fromOffset, maxNumRows int) ([]*Identity, error)
+	//
+	// // getAll retrieves all available business objects from storage.
+	// getAll() ([]*Identity, error)
+
+	// GetUserIdentities retrieves all identities registered by a specific user.
+	GetUserIdentities(u *user.User) ([]*Identity, error)
+
+	// Update modifies an existing business object.
+	Update(bo *Identity) (bool, error)
+}
@@ -0,0 +1,17 @@
+package identity
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewIdentityDaoMultitenantCosmosdb is helper method to create CosmosDB-implementation (multi-tenant table) of IdentityDao.
+func NewIdentityDaoMultitenantCosmosdb(sqlc *prom.SqlConnect, tableName string) IdentityDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbMultitenantPkName, PkValue: bo.CosmosdbMultitenantPkValueIdentity, TxModeOnWrite: true}
+	innerDao := IdentityDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &IdentityDaoCosmosdb{IdentityDaoSql: innerDao, spec: spec}
+	return dao
+}
@@ -0,0 +1,96 @@
+package identity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btnguyen2k/prom"
+
+	"main/src/gvabe/bo"
+)
+
+const tableNameMultitenantCosmosdb = "exter_test"
+
+var setupTestMultitenantCosmosdb = func(t *testing.T, testName string) {
+	testSqlc = _createCosmosdbConnect(t, testName)
+	testSqlc.GetDB().Exec(fmt.Sprintf("DROP COLLECTION IF EXISTS %s", tableNameMultitenantCosmosdb))
+	err := bo.InitMultitenantTableCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestMultitenantCosmosdb = func(t *testing.T, testName string) {
+	if testSqlc != nil {
+		defer func() {
+			defer func() { testSqlc = nil }()
+			testSqlc.Close()
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewIdentityDaoMultitenantCosmosdb(t *testing.T) {
+	testName := "tableNameMultitenantCosmosdb"
+	teardownTest := setupTest(t, testName, setupTestMultitenantCosmosdb, teardownTestMultitenantCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMultitenantCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	if identDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func _ensureMultitenantCosmosdbNumRows(t *testing.T, testName string, sqlc *prom.SqlConnect, numRows int) {
+	if dbRows, err := sqlc.GetDB().Query(fmt.Sprintf("SELECT COUNT(1) FROM %s c WITH cross_partition=true", tableNameMultitenantCosmosdb)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if rows, err := sqlc.FetchRows(dbRows); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if value := rows[0]["$1"]; int(value.(float64)) != numRows {
+		t.Fatalf("%s failed: expected collection to have %#v rows but received %#v", testName, numRows, value)
+	}
+}
+
+func TestIdentityDaoMultitenantCosmosdb_Create(t *testing.T) {
+	testName := "TestIdentityDaoMultitenantCosmosdb_Create"
+	teardownTest := setupTest(t, testName, setupTestMultitenantCosmosdb, teardownTestMultitenantCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMultitenantCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	doTestIdentityDao_Create(t, testName, identDao)
+	_ensureMultitenantCosmosdbNumRows(t, testName, testSqlc, 1)
+}
+
+func TestIdentityDaoMultitenantCosmosdb_Get(t *testing.T) {
+	testName := "TestIdentityDaoMultitenantCosmosdb_Get"
+	teardownTest := setupTest(t, testName, setupTestMultitenantCosmosdb, teardownTestMultitenantCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMultitenantCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	doTestIdentityDao_Get(t, testName, identDao)
+}
+
+func TestIdentityDaoMultitenantCosmosdb_Delete(t *testing.T) {
+	testName := "TestIdentityDaoMultitenantCosmosdb_Delete"
+	teardownTest := setupTest(t, testName, setupTestMultitenantCosmosdb, teardownTestMultitenantCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMultitenantCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	doTestIdentityDao_Delete(t, testName, identDao)
+	_ensureMultitenantCosmosdbNumRows(t, testName, testSqlc, 0)
+}
+
+func TestIdentityDaoMultitenantCosmosdb_Update(t *testing.T) {
+	testName := "TestIdentityDaoMultitenantCosmosdb_Update"
+	teardownTest := setupTest(t, testName, setupTestMultitenantCosmosdb, teardownTestMultitenantCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMultitenantCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	doTestIdentityDao_Update(t, testName, identDao)
+	_ensureMultitenantCosmosdbNumRows(t, testName, testSqlc, 1)
+}
+
+func TestIdentityDaoMultitenantCosmosdb_GetUserIdentities(t *testing.T) {
+	testName := "TestIdentityDaoMultitenantCosmosdb_GetUserIdentities"
+	teardownTest := setupTest(t, testName, setupTestMultitenantCosmosdb, teardownTestMultitenantCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMultitenantCosmosdb(testSqlc, tableNameMultitenantCosmosdb)
+	doTestIdentityDao_GetUserIdentities(t, testName, identDao)
+	_ensureMultitenantCosmosdbNumRows(t, testName, testSqlc, 10)
+}
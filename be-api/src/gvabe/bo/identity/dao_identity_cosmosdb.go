@@ -0,0 +1,46 @@
+package identity
+
+import (
+	"fmt"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewIdentityDaoCosmosdb is helper method to create CosmosDB-implementation of IdentityDao.
+func NewIdentityDaoCosmosdb(sqlc *prom.SqlConnect, tableName string) IdentityDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbPkName, TxModeOnWrite: true}
+	innerDao := IdentityDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &IdentityDaoCosmosdb{IdentityDaoSql: innerDao, spec: spec}
+	return dao
+}
+
+// InitIdentityTableCosmosdb is helper function to initialize CosmosDB-based table to store linked identity data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitIdentityTableCosmosdb(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorCosmosDb:
+		return InitIdentityTableSql(sqlc, tableName)
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// IdentityDaoCosmosdb is CosmosDB-implementation of IdentityDao.
+type IdentityDaoCosmosdb struct {
+	IdentityDaoSql
+	spec *henge.CosmosdbDaoSpec
+}
+
+// Create implements IdentityDao.Create.
+func (dao *IdentityDaoCosmosdb) Create(bo *Identity) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkName != "" && dao.spec.PkValue != "" {
+		ubo.SetExtraAttr(dao.spec.PkName, dao.spec.PkValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
@@ -0,0 +1,122 @@
+package identity
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/btnguyen2k/gocosmos"
+	"github.com/btnguyen2k/henge"
+	"github.com/btnguyen2k/prom"
+)
+
+func _createCosmosdbConnect(t *testing.T, testName string) *prom.SqlConnect {
+	driver := strings.ReplaceAll(os.Getenv("COSMOSDB_DRIVER"), `"`, "")
+	url := strings.ReplaceAll(os.Getenv("COSMOSDB_URL"), `"`, "")
+	if driver == "" || url == "" {
+		t.Skipf("%s skipped", testName)
+		return nil
+	}
+	timezone := strings.ReplaceAll(os.Getenv("TIMEZONE"), `"`, "")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	urlTimezone := strings.ReplaceAll(timezone, "/", "%2f")
+	url = strings.ReplaceAll(url, "${loc}", urlTimezone)
+	url = strings.ReplaceAll(url, "${tz}", urlTimezone)
+	url = strings.ReplaceAll(url, "${timezone}", urlTimezone)
+	url += ";Db=exter"
+	sqlc, err := henge.NewCosmosdbConnection(url, timezone, driver, 10000, nil)
+	if err != nil {
+		t.Fatalf("%s/%s failed: %s", testName, "NewCosmosdbConnection", err)
+	}
+	sqlc.GetDB().Exec("CREATE DATABASE exter WITH maxru=10000")
+	return sqlc
+}
+
+const tableNameCosmosdb = "exter_test_identity"
+
+var setupTestCosmosdb = func(t *testing.T, testName string) {
+	testSqlc = _createCosmosdbConnect(t, testName)
+	testSqlc.GetDB().Exec(fmt.Sprintf("DROP COLLECTION IF EXISTS %s", tableNameCosmosdb))
+	err := InitIdentityTableCosmosdb(testSqlc, tableNameCosmosdb)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestCosmosdb = func(t *testing.T, testName string) {
+	if testSqlc != nil {
+		defer func() {
+			defer func() { testSqlc = nil }()
+			testSqlc.Close()
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewIdentityDaoCosmosdb(t *testing.T) {
+	testName := "TestNewIdentityDaoCosmosdb"
+	teardownTest := setupTest(t, testName, setupTestCosmosdb, teardownTestCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoCosmosdb(testSqlc, tableNameCosmosdb)
+	if identDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func _ensureCosmosdbNumRows(t *testing.T, testName string, sqlc *prom.SqlConnect, numRows int) {
+	if dbRows, err := sqlc.GetDB().Query(fmt.Sprintf("SELECT COUNT(1) FROM %s c WITH cross_partition=true", tableNameCosmosdb)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if rows, err := sqlc.FetchRows(dbRows); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if value := rows[0]["$1"]; int(value.(float64)) != numRows {
+		t.Fatalf("%s failed: expected collection to have %#v rows but received %#v", testName, numRows, value)
+	}
+}
+
+func TestIdentityDaoCosmosdb_Create(t *testing.T) {
+	testName := "TestIdentityDaoCosmosdb_Create"
+	teardownTest := setupTest(t, testName, setupTestCosmosdb, teardownTestCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoCosmosdb(testSqlc, tableNameCosmosdb)
+	doTestIdentityDao_Create(t, testName, identDao)
+	_ensureCosmosdbNumRows(t, testName, testSqlc, 1)
+}
+
+func TestIdentityDaoCosmosdb_Get(t *testing.T) {
+	testName := "TestIdentityDaoCosmosdb_Get"
+	teardownTest := setupTest(t, testName, setupTestCosmosdb, teardownTestCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoCosmosdb(testSqlc, tableNameCosmosdb)
+	doTestIdentityDao_Get(t, testName, identDao)
+}
+
+func TestIdentityDaoCosmosdb_Delete(t *testing.T) {
+	testName := "TestIdentityDaoCosmosdb_Delete"
+	teardownTest := setupTest(t, testName, setupTestCosmosdb, teardownTestCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoCosmosdb(testSqlc, tableNameCosmosdb)
+	doTestIdentityDao_Delete(t, testName, identDao)
+	_ensureCosmosdbNumRows(t, testName, testSqlc, 0)
+}
+
+func TestIdentityDaoCosmosdb_Update(t *testing.T) {
+	testName := "TestIdentityDaoCosmosdb_Update"
+	teardownTest := setupTest(t, testName, setupTestCosmosdb, teardownTestCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoCosmosdb(testSqlc, tableNameCosmosdb)
+	doTestIdentityDao_Update(t, testName, identDao)
+	_ensureCosmosdbNumRows(t, testName, testSqlc, 1)
+}
+
+func TestIdentityDaoCosmosdb_GetUserIdentities(t *testing.T) {
+	testName := "TestIdentityDaoCosmosdb_GetUserIdentities"
+	teardownTest := setupTest(t, testName, setupTestCosmosdb, teardownTestCosmosdb)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoCosmosdb(testSqlc, tableNameCosmosdb)
+	doTestIdentityDao_GetUserIdentities(t, testName, identDao)
+	_ensureCosmosdbNumRows(t, testName, testSqlc, 10)
+}
@@ -0,0 +1,21 @@
+package identity
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+const (
+	dynamodbPkValueIdentity = "identity"
+)
+
+// NewIdentityDaoMultitenantAwsDynamodb is helper method to create AWS DynamoDB-implementation (multi-tenant table) of IdentityDao.
+func NewIdentityDaoMultitenantAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) IdentityDao {
+	spec := &henge.DynamodbDaoSpec{PkPrefix: bo.DynamodbMultitenantPkName, PkPrefixValue: dynamodbPkValueIdentity}
+	dao := &IdentityDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
@@ -0,0 +1,91 @@
+package identity
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo/user"
+)
+
+// NewIdentityDaoAwsDynamodb is helper method to create AWS DynamoDB-implementation of IdentityDao.
+func NewIdentityDaoAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) IdentityDao {
+	var spec *henge.DynamodbDaoSpec = nil
+	dao := &IdentityDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
+
+// InitIdentityTableAwsDynamodb is helper function to initialize AWS DynamoDB table(s) to store linked identity data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitIdentityTableAwsDynamodb(adc *prom.AwsDynamodbConnect, tableName string) error {
+	spec := &henge.DynamodbTablesSpec{MainTableRcu: 1, MainTableWcu: 1}
+	return henge.InitDynamodbTables(adc, tableName, spec)
+}
+
+// IdentityDaoAwsDynamodb is AWS DynamoDB-implementation of IdentityDao.
+type IdentityDaoAwsDynamodb struct {
+	henge.UniversalDao
+	spec *henge.DynamodbDaoSpec
+}
+
+// Delete implements IdentityDao.Delete.
+func (dao *IdentityDaoAwsDynamodb) Delete(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements IdentityDao.Create.
+func (dao *IdentityDaoAwsDynamodb) Create(bo *Identity) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkPrefix != "" {
+		ubo.SetExtraAttr(dao.spec.PkPrefix, dao.spec.PkPrefixValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
+
+// Get implements IdentityDao.Get.
+func (dao *IdentityDaoAwsDynamodb) Get(id string) (*Identity, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewIdentityFromUbo(ubo), err
+}
+
+// getN implements IdentityDao.getN.
+func (dao *IdentityDaoAwsDynamodb) getN(fromOffset, maxNumRows int) ([]*Identity, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Identity, 0)
+	for _, ubo := range uboList {
+		bo := NewIdentityFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements IdentityDao.getAll.
+func (dao *IdentityDaoAwsDynamodb) getAll() ([]*Identity, error) {
+	return dao.getN(0, 0)
+}
+
+// GetUserIdentities implements IdentityDao.GetUserIdentities.
+func (dao *IdentityDaoAwsDynamodb) GetUserIdentities(u *user.User) ([]*Identity, error) {
+	if identList, err := dao.getAll(); err != nil {
+		return nil, err
+	} else {
+		result := make([]*Identity, 0)
+		for _, ident := range identList {
+			if ident.ownerId == u.GetId() {
+				result = append(result, ident)
+			}
+		}
+		return result, nil
+	}
+}
+
+// Update implements IdentityDao.Update.
+func (dao *IdentityDaoAwsDynamodb) Update(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
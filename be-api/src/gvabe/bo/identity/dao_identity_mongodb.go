@@ -0,0 +1,86 @@
+package identity
+
+import (
+	"strings"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo/user"
+)
+
+// NewIdentityDaoMongo is helper method to create MongoDB-implementation of IdentityDao.
+func NewIdentityDaoMongo(mc *prom.MongoConnect, collectionName string) IdentityDao {
+	txMode := strings.Index(strings.ToLower(mc.GetUrl()), "replicaset=") > 0
+	dao := &IdentityDaoMongo{UniversalDao: henge.NewUniversalDaoMongo(mc, collectionName, txMode)}
+	return dao
+}
+
+// InitIdentityTableMongo is helper function to initialize MongoDB table (collection) to store linked identity data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitIdentityTableMongo(mc *prom.MongoConnect, collectionName string) error {
+	return henge.InitMongoCollection(mc, collectionName)
+}
+
+// IdentityDaoMongo is MongoDB-implementation of IdentityDao.
+type IdentityDaoMongo struct {
+	henge.UniversalDao
+}
+
+// Delete implements IdentityDao.Delete.
+func (dao *IdentityDaoMongo) Delete(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements IdentityDao.Create.
+func (dao *IdentityDaoMongo) Create(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements IdentityDao.Get.
+func (dao *IdentityDaoMongo) Get(id string) (*Identity, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewIdentityFromUbo(ubo), err
+}
+
+// getN implements IdentityDao.getN.
+func (dao *IdentityDaoMongo) getN(fromOffset, maxNumRows int) ([]*Identity, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Identity, 0)
+	for _, ubo := range uboList {
+		bo := NewIdentityFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements IdentityDao.getAll.
+func (dao *IdentityDaoMongo) getAll() ([]*Identity, error) {
+	return dao.getN(0, 0)
+}
+
+// GetUserIdentities implements IdentityDao.GetUserIdentities.
+func (dao *IdentityDaoMongo) GetUserIdentities(u *user.User) ([]*Identity, error) {
+	if identList, err := dao.getAll(); err != nil {
+		return nil, err
+	} else {
+		result := make([]*Identity, 0)
+		for _, ident := range identList {
+			if ident.ownerId == u.GetId() {
+				result = append(result, ident)
+			}
+		}
+		return result, nil
+	}
+}
+
+// Update implements IdentityDao.Update.
+func (dao *IdentityDaoMongo) Update(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
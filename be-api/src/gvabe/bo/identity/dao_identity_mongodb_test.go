@@ -0,0 +1,101 @@
+package identity
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+)
+
+func _createMongoConnect(t *testing.T, testName string) *prom.MongoConnect {
+	mongoDb := strings.ReplaceAll(os.Getenv("MONGO_DB"), `"`, "")
+	mongoUrl := strings.ReplaceAll(os.Getenv("MONGO_URL"), `"`, "")
+	if mongoDb == "" || mongoUrl == "" {
+		t.Skipf("%s skipped", testName)
+		return nil
+	}
+	mongoPoolOpts := &prom.MongoPoolOpts{
+		ConnectTimeout:         5 * time.Second,
+		SocketTimeout:          7 * time.Second,
+		ServerSelectionTimeout: 11 * time.Second,
+	}
+	mc, err := prom.NewMongoConnectWithPoolOptions(mongoUrl, mongoDb, 10000, mongoPoolOpts)
+	if err != nil {
+		t.Fatalf("%s/%s failed: %s", testName, "NewMongoConnect", err)
+	}
+	return mc
+}
+
+const collectionNameMongo = "exter_test_identity"
+
+var setupTestMongo = func(t *testing.T, testName string) {
+	testMc = _createMongoConnect(t, testName)
+	testMc.GetCollection(collectionNameMongo).Drop(nil)
+	err := InitIdentityTableMongo(testMc, collectionNameMongo)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestMongo = func(t *testing.T, testName string) {
+	if testMc != nil {
+		defer func() {
+			defer func() { testMc = nil }()
+			testMc.Close(nil)
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewIdentityDaoMongo(t *testing.T) {
+	testName := "TestNewIdentityDaoMongo"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMongo(testMc, collectionNameMongo)
+	if identDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestIdentityDaoMongo_Create(t *testing.T) {
+	testName := "TestIdentityDaoMongo_Create"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMongo(testMc, collectionNameMongo)
+	doTestIdentityDao_Create(t, testName, identDao)
+}
+
+func TestIdentityDaoMongo_Get(t *testing.T) {
+	testName := "TestIdentityDaoMongo_Get"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMongo(testMc, collectionNameMongo)
+	doTestIdentityDao_Get(t, testName, identDao)
+}
+
+func TestIdentityDaoMongo_Delete(t *testing.T) {
+	testName := "TestIdentityDaoMongo_Delete"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMongo(testMc, collectionNameMongo)
+	doTestIdentityDao_Delete(t, testName, identDao)
+}
+
+func TestIdentityDaoMongo_Update(t *testing.T) {
+	testName := "TestIdentityDaoMongo_Update"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMongo(testMc, collectionNameMongo)
+	doTestIdentityDao_Update(t, testName, identDao)
+}
+
+func TestIdentityDaoMongo_GetUserIdentities(t *testing.T) {
+	testName := "TestIdentityDaoMongo_GetUserIdentities"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	identDao := NewIdentityDaoMongo(testMc, collectionNameMongo)
+	doTestIdentityDao_GetUserIdentities(t, testName, identDao)
+}
@@ -0,0 +1,105 @@
+package identity
+
+import (
+	"fmt"
+
+	"github.com/btnguyen2k/prom"
+	"main/src/gvabe/bo"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo/user"
+)
+
+const (
+	SqlColIdentityUserId = "zuid"
+)
+
+// NewIdentityDaoSql is helper method to create SQL-implementation of IdentityDao.
+func NewIdentityDaoSql(sqlc *prom.SqlConnect, tableName string) IdentityDao {
+	dao := &IdentityDaoSql{}
+	dao.UniversalDao = henge.NewUniversalDaoSql(sqlc, tableName, true, map[string]string{SqlColIdentityUserId: FieldIdentityOwnerId})
+	return dao
+}
+
+// InitIdentityTableSql is helper function to initialize SQL-based table to store linked identity data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitIdentityTableSql(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorPgSql:
+		return henge.InitPgsqlTable(sqlc, tableName, map[string]string{SqlColIdentityUserId: "VARCHAR(32)"})
+	case prom.FlavorMsSql:
+		return henge.InitMssqlTable(sqlc, tableName, map[string]string{SqlColIdentityUserId: "NVARCHAR(32)"})
+	case prom.FlavorMySql:
+		return henge.InitMysqlTable(sqlc, tableName, map[string]string{SqlColIdentityUserId: "VARCHAR(32)"})
+	case prom.FlavorOracle:
+		return henge.InitOracleTable(sqlc, tableName, map[string]string{SqlColIdentityUserId: "NVARCHAR2(32)"})
+	case prom.FlavorSqlite:
+		return henge.InitSqliteTable(sqlc, tableName, map[string]string{SqlColIdentityUserId: "VARCHAR(32)"})
+	case prom.FlavorCosmosDb:
+		return henge.InitCosmosdbCollection(sqlc, tableName, &henge.CosmosdbCollectionSpec{Pk: bo.CosmosdbPkName})
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// IdentityDaoSql is SQL-implementation of IdentityDao.
+type IdentityDaoSql struct {
+	henge.UniversalDao
+}
+
+// Delete implements IdentityDao.Delete.
+func (dao *IdentityDaoSql) Delete(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements IdentityDao.Create.
+func (dao *IdentityDaoSql) Create(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements IdentityDao.Get.
+func (dao *IdentityDaoSql) Get(id string) (*Identity, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewIdentityFromUbo(ubo), err
+}
+
+// getN implements IdentityDao.getN.
+func (dao *IdentityDaoSql) getN(fromOffset, maxNumRows int) ([]*Identity, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Identity, 0)
+	for _, ubo := range uboList {
+		bo := NewIdentityFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements IdentityDao.getAll.
+func (dao *IdentityDaoSql) getAll() ([]*Identity, error) {
+	return dao.getN(0, 0)
+}
+
+// GetUserIdentities implements IdentityDao.GetUserIdentities.
+func (dao *IdentityDaoSql) GetUserIdentities(u *user.User) ([]*Identity, error) {
+	if identList, err := dao.getAll(); err != nil {
+		return nil, err
+	} else {
+		result := make([]*Identity, 0)
+		for _, ident := range identList {
+			if ident.ownerId == u.GetId() {
+				result = append(result, ident)
+			}
+		}
+		return result, nil
+	}
+}
+
+// Update implements IdentityDao.Update.
+func (dao *IdentityDaoSql) Update(bo *Identity) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
@@ -0,0 +1,149 @@
+package identity
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/btnguyen2k/prom"
+	"main/src/gvabe/bo/user"
+)
+
+type TestSetupOrTeardownFunc func(t *testing.T, testName string)
+
+func setupTest(t *testing.T, testName string, extraSetupFunc, extraTeardownFunc TestSetupOrTeardownFunc) func(t *testing.T) {
+	if extraSetupFunc != nil {
+		extraSetupFunc(t, testName)
+	}
+	return func(t *testing.T) {
+		if extraTeardownFunc != nil {
+			extraTeardownFunc(t, testName)
+		}
+	}
+}
+
+var (
+	testAdc  *prom.AwsDynamodbConnect
+	testMc   *prom.MongoConnect
+	testSqlc *prom.SqlConnect
+)
+
+/*----------------------------------------------------------------------*/
+
+const (
+	testProvider = "google"
+	testSubject  = "1234567890"
+)
+
+func doTestIdentityDao_Create(t *testing.T, testName string, identDao IdentityDao) {
+	ident := NewIdentity(1357, testProvider, testSubject, "btnguyen2k")
+	ok, err := identDao.Create(ident)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+}
+
+func doTestIdentityDao_Get(t *testing.T, testName string, identDao IdentityDao) {
+	identDao.Create(NewIdentity(1357, testProvider, testSubject, "btnguyen2k").SetEmail("btnguyen2k@domain.com"))
+
+	if ident, err := identDao.Get("not_found"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if ident != nil {
+		t.Fatalf("%s failed: identity %s should not exist", testName, "not_found")
+	}
+
+	id := IdFromProviderSubject(testProvider, testSubject)
+	if ident, err := identDao.Get(id); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if ident == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := ident.GetId(); v != id {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, id, v)
+		}
+		if v := ident.GetTagVersion(); v != 1357 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 1357, v)
+		}
+		if v := ident.GetOwnerId(); v != "btnguyen2k" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "btnguyen2k", v)
+		}
+		if v := ident.GetProvider(); v != testProvider {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, testProvider, v)
+		}
+		if v := ident.GetSubject(); v != testSubject {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, testSubject, v)
+		}
+		if v := ident.GetEmail(); v != "btnguyen2k@domain.com" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "btnguyen2k@domain.com", v)
+		}
+	}
+}
+
+func doTestIdentityDao_Delete(t *testing.T, testName string, identDao IdentityDao) {
+	identDao.Create(NewIdentity(1357, testProvider, testSubject, "btnguyen2k"))
+	id := IdFromProviderSubject(testProvider, testSubject)
+	ident, err := identDao.Get(id)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if ident == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ok, err := identDao.Delete(ident)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if !ok {
+		t.Fatalf("%s failed: cannot delete identity [%s]", testName, ident.GetId())
+	}
+
+	if ident, err := identDao.Get(id); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if ident != nil {
+		t.Fatalf("%s failed: identity %s should not exist", testName, id)
+	}
+}
+
+func doTestIdentityDao_Update(t *testing.T, testName string, identDao IdentityDao) {
+	ident := NewIdentity(1357, testProvider, testSubject, "btnguyen2k")
+	identDao.Create(ident)
+
+	ident.SetTagVersion(2468)
+	ident.SetEmail("new-email@domain.com")
+	ok, err := identDao.Update(ident)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+
+	if ident, err := identDao.Get(IdFromProviderSubject(testProvider, testSubject)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if ident == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := ident.GetTagVersion(); v != 2468 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 2468, v)
+		}
+		if v := ident.GetEmail(); v != "new-email@domain.com" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "new-email@domain.com", v)
+		}
+	}
+}
+
+func doTestIdentityDao_GetUserIdentities(t *testing.T, testName string, identDao IdentityDao) {
+	for i := 0; i < 10; i++ {
+		ident := NewIdentity(uint64(i), testProvider, strconv.Itoa(i), strconv.Itoa(i%3))
+		identDao.Create(ident)
+	}
+
+	u := user.NewUser(123, "2")
+	identList, err := identDao.GetUserIdentities(u)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(identList) != 3 {
+		t.Fatalf("%s failed: expected %#v identities but received %#v", testName, 3, len(identList))
+	}
+	for _, ident := range identList {
+		if ident.GetOwnerId() != "2" {
+			t.Fatalf("%s failed: identity %#v does not belong to user %#v", testName, ident.GetId(), "2")
+		}
+	}
+}
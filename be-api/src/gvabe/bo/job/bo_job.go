@@ -0,0 +1,241 @@
+// Package job contains business object (BO) and data access object (DAO) implementations for background Job.
+//
+// Available since v0.8.0
+package job
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	"github.com/btnguyen2k/henge"
+	"main/src/gvabe/bo"
+
+	"main/src/utils"
+)
+
+const (
+	// StatusPending: job is waiting to be run, either for the first time or to be retried
+	StatusPending = "pending"
+
+	// StatusRunning: job has been picked up by a worker; if the worker dies, the job is run again once its lease expires
+	StatusRunning = "running"
+
+	// StatusFailed: job has failed permanently and will not be retried
+	StatusFailed = "failed"
+
+	// StatusClaim: not a job but the record of a job's attempt being claimed by a worker, see ClaimJob
+	StatusClaim = "claim"
+)
+
+// NewJob is helper function to create new Job bo. The new job is pending and due to run immediately.
+func NewJob(tagVersion uint64, kind, payload string) *Job {
+	job := &Job{
+		UniversalBo: henge.NewUniversalBo(utils.UniqueId(), tagVersion, henge.UboOpt{TimeLayout: bo.UboTimeLayout, TimestampRounding: bo.UboTimestampRounding}),
+	}
+	job.
+		SetKind(kind).
+		SetPayload(payload).
+		SetStatus(StatusPending).
+		SetNextRunAt(time.Now())
+	return job.sync()
+}
+
+// NewJobFromUbo is helper function to create new Job bo from a universal bo.
+func NewJobFromUbo(ubo *henge.UniversalBo) *Job {
+	if ubo == nil {
+		return nil
+	}
+	ubo = ubo.Clone()
+	job := &Job{UniversalBo: ubo}
+	if v, err := ubo.GetExtraAttrAs(FieldJobStatus, reddo.TypeString); err == nil && v != nil {
+		job.SetStatus(v.(string))
+	}
+	if v, err := ubo.GetExtraAttrAsTimeWithLayout(FieldJobNextRunAt, bo.UboTimeLayout); err == nil {
+		job.SetNextRunAt(v)
+	}
+	attrListStr := []string{AttrJobKind, AttrJobPayload, AttrJobLastError}
+	setterListStr := []func(string) *Job{job.SetKind, job.SetPayload, job.SetLastError}
+	for i, attr := range attrListStr {
+		if v, err := job.GetDataAttrAs(attr, reddo.TypeString); err == nil && v != nil {
+			setterListStr[i](v.(string))
+		}
+	}
+	if v, err := job.GetDataAttrAs(AttrJobAttempts, reddo.TypeInt); err == nil && v != nil {
+		job.SetAttempts(int(v.(int64)))
+	}
+	return job.sync()
+}
+
+const (
+	FieldJobStatus    = "status"
+	FieldJobNextRunAt = "nrat"
+
+	AttrJobKind      = "kind"
+	AttrJobPayload   = "payload"
+	AttrJobAttempts  = "atts"
+	AttrJobLastError = "lerr"
+	AttrJobUbo       = "_ubo"
+)
+
+// Job is the business object: a unit of background work that is persisted so that it survives restarts and can be
+// retried upon failures.
+type Job struct {
+	*henge.UniversalBo `json:"_ubo"`
+	kind               string    `json:"kind"`    // kind of the job, determines the handler that runs it
+	payload            string    `json:"payload"` // job's input, interpreted by the handler
+	status             string    `json:"status"`  // job's status, see StatusPending, StatusRunning and StatusFailed
+	attempts           int       `json:"atts"`    // number of times the job has been run
+	nextRunAt          time.Time `json:"nrat"`    // timestamp when the job is due to run (or when the running lease expires)
+	lastError          string    `json:"lerr"`    // error returned by the last run
+}
+
+// MarshalJSON implements json.encode.Marshaler.MarshalJSON.
+func (job *Job) MarshalJSON() ([]byte, error) {
+	job.sync()
+	m := map[string]interface{}{
+		AttrJobUbo: job.UniversalBo.Clone(),
+		bo.SerKeyFields: map[string]interface{}{
+			FieldJobStatus:    job.GetStatus(),
+			FieldJobNextRunAt: job.GetNextRunAt(),
+		},
+		bo.SerKeyAttrs: map[string]interface{}{
+			AttrJobKind:      job.GetKind(),
+			AttrJobPayload:   job.GetPayload(),
+			AttrJobAttempts:  job.GetAttempts(),
+			AttrJobLastError: job.GetLastError(),
+		},
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.decode.Unmarshaler.UnmarshalJSON.
+func (job *Job) UnmarshalJSON(data []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if m[AttrJobUbo] != nil {
+		js, _ := json.Marshal(m[AttrJobUbo])
+		if err := json.Unmarshal(js, &job.UniversalBo); err != nil {
+			return err
+		}
+	}
+	if _cols, ok := m[bo.SerKeyFields].(map[string]interface{}); ok {
+		if v, err := reddo.ToString(_cols[FieldJobStatus]); err != nil {
+			return err
+		} else {
+			job.SetStatus(v)
+		}
+		if v, err := reddo.ToTime(_cols[FieldJobNextRunAt]); err != nil {
+			return err
+		} else {
+			job.SetNextRunAt(v)
+		}
+	}
+	if _attrs, ok := m[bo.SerKeyAttrs].(map[string]interface{}); ok {
+		attrListStr := []string{AttrJobKind, AttrJobPayload, AttrJobLastError}
+		setterListStr := []func(string) *Job{job.SetKind, job.SetPayload, job.SetLastError}
+		for i, attr := range attrListStr {
+			if v, err := reddo.ToString(_attrs[attr]); err != nil {
+				return err
+			} else {
+				setterListStr[i](v)
+			}
+		}
+		if v, err := reddo.ToInt(_attrs[AttrJobAttempts]); err != nil {
+			return err
+		} else {
+			job.SetAttempts(int(v))
+		}
+	}
+
+	job.sync()
+	return nil
+}
+
+// IsDue checks if the job is due to run at the specified time. Only pending or running jobs can be due.
+func (job *Job) IsDue(now time.Time) bool {
+	return (job.status == StatusPending || job.status == StatusRunning) && !job.nextRunAt.After(now)
+}
+
+// GetKind returns job's 'kind' value.
+func (job *Job) GetKind() string {
+	return job.kind
+}
+
+// SetKind sets job's 'kind' value.
+func (job *Job) SetKind(value string) *Job {
+	job.kind = strings.TrimSpace(strings.ToLower(value))
+	return job
+}
+
+// GetPayload returns job's input.
+func (job *Job) GetPayload() string {
+	return job.payload
+}
+
+// SetPayload sets job's input.
+func (job *Job) SetPayload(value string) *Job {
+	job.payload = value
+	return job
+}
+
+// GetStatus returns job's status.
+func (job *Job) GetStatus() string {
+	return job.status
+}
+
+// SetStatus sets job's status.
+func (job *Job) SetStatus(value string) *Job {
+	job.status = strings.TrimSpace(strings.ToLower(value))
+	return job
+}
+
+// GetAttempts returns number of times the job has been run.
+func (job *Job) GetAttempts() int {
+	return job.attempts
+}
+
+// SetAttempts sets number of times the job has been run.
+func (job *Job) SetAttempts(value int) *Job {
+	job.attempts = value
+	return job
+}
+
+// GetNextRunAt returns the timestamp when the job is due to run.
+func (job *Job) GetNextRunAt() time.Time {
+	return job.nextRunAt
+}
+
+// SetNextRunAt sets the timestamp when the job is due to run. The timestamp is truncated (rather than rounded) to
+// second, so that the job never becomes due later than scheduled, and kept in UTC so that storages comparing it as
+// formatted string (see formatJobTime) still order jobs chronologically.
+func (job *Job) SetNextRunAt(value time.Time) *Job {
+	job.nextRunAt = value.Truncate(time.Second).UTC()
+	return job
+}
+
+// GetLastError returns the error returned by job's last run.
+func (job *Job) GetLastError() string {
+	return job.lastError
+}
+
+// SetLastError sets the error returned by job's last run.
+func (job *Job) SetLastError(value string) *Job {
+	job.lastError = strings.TrimSpace(value)
+	return job
+}
+
+func (job *Job) sync() *Job {
+	job.SetDataAttr(AttrJobKind, job.kind)
+	job.SetDataAttr(AttrJobPayload, job.payload)
+	job.SetDataAttr(AttrJobAttempts, job.attempts)
+	job.SetDataAttr(AttrJobLastError, job.lastError)
+	job.SetExtraAttr(FieldJobStatus, job.status)
+	job.SetExtraAttr(FieldJobNextRunAt, job.nextRunAt)
+	job.UniversalBo.Sync()
+	return job
+}
@@ -0,0 +1,141 @@
+package job
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/henge"
+)
+
+func TestNewJob(t *testing.T) {
+	testName := "TestNewJob"
+	_tagVersion := uint64(1337)
+	_kind := "Login_Profile"
+	_payload := "session-id"
+	job := NewJob(_tagVersion, _kind, _payload)
+	if job == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+	if job.GetId() == "" {
+		t.Fatalf("%s failed: id must be generated", testName)
+	}
+	if f, v, expected := "tag-version", job.GetTagVersion(), _tagVersion; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "kind", job.GetKind(), "login_profile"; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "payload", job.GetPayload(), _payload; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "status", job.GetStatus(), StatusPending; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if !job.IsDue(time.Now().Add(time.Second)) {
+		t.Fatalf("%s failed: new job must be due", testName)
+	}
+}
+
+func TestJob_IsDue(t *testing.T) {
+	testName := "TestJob_IsDue"
+	now := time.Now()
+	job := NewJob(1337, "login_profile", "session-id").SetNextRunAt(now.Add(time.Minute))
+	if job.IsDue(now) {
+		t.Fatalf("%s failed: job is scheduled in the future", testName)
+	}
+	if !job.IsDue(now.Add(time.Hour)) {
+		t.Fatalf("%s failed: job should be due", testName)
+	}
+	if job.SetStatus(StatusFailed).IsDue(now.Add(time.Hour)) {
+		t.Fatalf("%s failed: failed job must not be due", testName)
+	}
+}
+
+func TestNewJobFromUbo(t *testing.T) {
+	testName := "TestNewJobFromUbo"
+	if job := NewJobFromUbo(nil); job != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, job)
+	}
+
+	_tagVersion := uint64(1337)
+	_kind := "login_profile"
+	_payload := "session-id"
+	_status := StatusRunning
+	_atts := 3
+	_nrat := time.Now().Round(time.Second)
+	_lerr := "timeout"
+	ubo := henge.NewUniversalBo("id", _tagVersion)
+	ubo.SetDataJson("invalid json string")
+	if job := NewJobFromUbo(ubo); job == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ubo.SetDataAttr(AttrJobKind, _kind)
+	ubo.SetDataAttr(AttrJobPayload, _payload)
+	ubo.SetExtraAttr(FieldJobStatus, _status)
+	ubo.SetDataAttr(AttrJobAttempts, _atts)
+	ubo.SetExtraAttr(FieldJobNextRunAt, _nrat)
+	ubo.SetDataAttr(AttrJobLastError, _lerr)
+	job := NewJobFromUbo(ubo)
+	if job == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+	if f, v, expected := "kind", job.GetKind(), _kind; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "payload", job.GetPayload(), _payload; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "status", job.GetStatus(), _status; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "attempts", job.GetAttempts(), _atts; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "next-run-at", job.GetNextRunAt(), _nrat; !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "last-error", job.GetLastError(), _lerr; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+}
+
+func TestJob_json(t *testing.T) {
+	testName := "TestJob_json"
+
+	job1 := NewJob(1337, "login_profile", "session-id")
+	job1.SetStatus(StatusFailed).SetAttempts(5).SetNextRunAt(time.Now().Round(time.Second)).SetLastError("timeout")
+	js1, _ := json.Marshal(job1)
+
+	var job2 *Job
+	err := json.Unmarshal(js1, &job2)
+	if err != nil {
+		t.Fatalf("%s failed: %e", testName, err)
+	}
+
+	if f, v, expected := "id", job2.GetId(), job1.GetId(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "kind", job2.GetKind(), job1.GetKind(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "payload", job2.GetPayload(), job1.GetPayload(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "status", job2.GetStatus(), job1.GetStatus(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "attempts", job2.GetAttempts(), job1.GetAttempts(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "next-run-at", job2.GetNextRunAt(), job1.GetNextRunAt(); !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "last-error", job2.GetLastError(), job1.GetLastError(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if job1.GetChecksum() != job2.GetChecksum() {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, job1.GetChecksum(), job2.GetChecksum())
+	}
+}
@@ -0,0 +1,159 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/btnguyen2k/godal"
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+const (
+	TableJob = "exter_job"
+)
+
+// JobDao defines API to access Job storage.
+type JobDao interface {
+	// Delete removes the specified business object from storage.
+	Delete(bo *Job) (bool, error)
+
+	// Create persists a new business object to storage.
+	Create(bo *Job) (bool, error)
+
+	// Get retrieves a business object from storage.
+	Get(id string) (*Job, error)
+
+	// // getN retrieves N business objects from storage.
+	// getN(fromOffset, maxNumRows int) ([]*Job, error)
+	//
+	// // getAll retrieves all available business objects from storage.
+	// getAll() ([]*Job, error)
+
+	// GetDueJobs retrieves at most limit jobs that are due to run at the specified time, ordered by their scheduled
+	// time (limit <= 0 means no limit). Failed jobs are never due.
+	GetDueJobs(now time.Time, limit int) ([]*Job, error)
+
+	// GetExpiredJobs retrieves at most limit failed jobs and claim records (see ClaimJob) scheduled before the specified
+	// time (limit <= 0 means no limit). Failed jobs are scheduled at the time they failed.
+	GetExpiredJobs(before time.Time, limit int) ([]*Job, error)
+
+	// Update modifies an existing business object.
+	Update(bo *Job) (bool, error)
+}
+
+// ClaimJob claims the next attempt of a job for a worker, marking the job as running until leaseUntil.
+//
+// The claim is a compare-and-set on job's attempt counter: a claim record identified by job's id and the attempt number
+// is created first, and storages reject duplicated ids. Hence, of the workers racing for the same attempt only one can
+// claim it; false is returned to the others (and also if the job has been removed in the meantime). Claim records are
+// kept so that the attempt can not be claimed again, until they expire (see GetExpiredJobs).
+//
+// The job is updated only if its attempt counter has not moved since it was loaded; the outcome of the attempt must be
+// persisted with ReleaseJob.
+func ClaimJob(dao JobDao, job *Job, leaseUntil time.Time) (bool, error) {
+	attempt := job.GetAttempts() + 1
+	claim := newJobClaim(job, attempt, leaseUntil)
+	if ok, err := dao.Create(claim); err == godal.ErrGdaoDuplicatedEntry || (err == nil && !ok) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	ok, err := isLatestAttempt(dao, job.GetId(), attempt-1)
+	if err == nil && ok {
+		job.SetStatus(StatusRunning).SetAttempts(attempt).SetNextRunAt(leaseUntil)
+		ok, err = dao.Update(job)
+	}
+	if err != nil || !ok {
+		// release the attempt, otherwise the job could never be claimed again
+		dao.Delete(claim)
+	}
+	return ok, err
+}
+
+// ReleaseJob persists the outcome of a claimed attempt of a job: the job is removed if remove is true, otherwise it is
+// updated (e.g. rescheduled or marked as failed).
+//
+// Once the lease has expired, the job can be claimed again by another worker: the job is left untouched and false is
+// returned if the attempt is no longer job's latest one (or the job has been removed in the meantime), so that a worker
+// whose lease has expired does not overwrite the state of the attempt claimed after it.
+func ReleaseJob(dao JobDao, job *Job, remove bool) (bool, error) {
+	if ok, err := isLatestAttempt(dao, job.GetId(), job.GetAttempts()); err != nil || !ok {
+		return false, err
+	}
+	if remove {
+		return dao.Delete(job)
+	}
+	return dao.Update(job)
+}
+
+// isLatestAttempt re-reads a job and checks that its attempt counter is still at the specified attempt.
+//
+// Storages do not offer conditional updates across the board, the check is made right before the update to narrow the
+// window during which another worker can claim the job.
+func isLatestAttempt(dao JobDao, id string, attempt int) (bool, error) {
+	current, err := dao.Get(id)
+	if err != nil || current == nil {
+		return false, err
+	}
+	return current.GetAttempts() == attempt, nil
+}
+
+// newJobClaim creates the claim record of a job's attempt.
+func newJobClaim(job *Job, attempt int, leaseUntil time.Time) *Job {
+	claim := &Job{
+		UniversalBo: henge.NewUniversalBo(fmt.Sprintf("%s-%d", job.GetId(), attempt), job.GetTagVersion(), henge.UboOpt{TimeLayout: bo.UboTimeLayout, TimestampRounding: bo.UboTimestampRounding}),
+	}
+	claim.
+		SetKind(job.GetKind()).
+		SetStatus(StatusClaim).
+		SetAttempts(attempt).
+		SetNextRunAt(leaseUntil)
+	return claim.sync()
+}
+
+// dueJobsFilter builds the filter matching jobs that are due to run at the specified time (i.e. pending or running
+// jobs scheduled no later than now). Field names and the timestamp value are storage-specific.
+func dueJobsFilter(statusField, nextRunAtField string, now interface{}) godal.FilterOpt {
+	return jobsFilter(statusField, []string{StatusPending, StatusRunning}, nextRunAtField, godal.FilterOpLessOrEqual, now)
+}
+
+// expiredJobsFilter builds the filter matching failed jobs and claim records scheduled before the specified time.
+// Field names and the timestamp value are storage-specific.
+func expiredJobsFilter(statusField, nextRunAtField string, before interface{}) godal.FilterOpt {
+	return jobsFilter(statusField, []string{StatusFailed, StatusClaim}, nextRunAtField, godal.FilterOpLess, before)
+}
+
+// jobsFilter builds the filter matching jobs of the listed statuses whose scheduled time compares to t with operator op.
+func jobsFilter(statusField string, statusList []string, nextRunAtField string, op godal.FilterOperator, t interface{}) godal.FilterOpt {
+	statusFilter := &godal.FilterOptOr{Filters: []godal.FilterOpt{}}
+	for _, status := range statusList {
+		statusFilter.Filters = append(statusFilter.Filters, &godal.FilterOptFieldOpValue{FieldName: statusField, Operator: godal.FilterOpEqual, Value: status})
+	}
+	return &godal.FilterOptAnd{Filters: []godal.FilterOpt{
+		statusFilter,
+		&godal.FilterOptFieldOpValue{FieldName: nextRunAtField, Operator: op, Value: t},
+	}}
+}
+
+// formatJobTime formats a timestamp the way document storages (Cosmos DB, DynamoDB) store job's scheduled time, so that
+// it can be compared with stored values: job's scheduled time is kept in UTC at whole seconds (see Job.SetNextRunAt),
+// hence formatted timestamps compare chronologically.
+func formatJobTime(t time.Time) string {
+	return t.Truncate(time.Second).UTC().Format(bo.UboTimeLayout)
+}
+
+// dueJobsSorting orders jobs by their scheduled time.
+func dueJobsSorting(nextRunAtField string) *godal.SortingOpt {
+	return &godal.SortingOpt{Fields: []*godal.SortingField{{FieldName: nextRunAtField}}}
+}
+
+// sortJobsByNextRunAt orders jobs by their scheduled time, for storages that can not sort query results.
+func sortJobsByNextRunAt(jobList []*Job) []*Job {
+	sort.SliceStable(jobList, func(i, j int) bool {
+		return jobList[i].nextRunAt.Before(jobList[j].nextRunAt)
+	})
+	return jobList
+}
@@ -0,0 +1,17 @@
+package job
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewJobDaoMultitenantCosmosdb is helper method to create CosmosDB-implementation (multi-tenant table) of JobDao.
+func NewJobDaoMultitenantCosmosdb(sqlc *prom.SqlConnect, tableName string) JobDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbMultitenantPkName, PkValue: bo.CosmosdbMultitenantPkValueJob, TxModeOnWrite: true}
+	innerDao := JobDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &JobDaoCosmosdb{JobDaoSql: innerDao, spec: spec}
+	return dao
+}
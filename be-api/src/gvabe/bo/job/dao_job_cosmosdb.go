@@ -0,0 +1,61 @@
+package job
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewJobDaoCosmosdb is helper method to create CosmosDB-implementation of JobDao.
+func NewJobDaoCosmosdb(sqlc *prom.SqlConnect, tableName string) JobDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbPkName, TxModeOnWrite: true}
+	innerDao := JobDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &JobDaoCosmosdb{JobDaoSql: innerDao, spec: spec}
+	return dao
+}
+
+// InitJobTableCosmosdb is helper function to initialize CosmosDB-based table to store job data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitJobTableCosmosdb(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorCosmosDb:
+		return InitJobTableSql(sqlc, tableName)
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// JobDaoCosmosdb is CosmosDB-implementation of JobDao.
+type JobDaoCosmosdb struct {
+	JobDaoSql
+	spec *henge.CosmosdbDaoSpec
+}
+
+// Create implements JobDao.Create.
+func (dao *JobDaoCosmosdb) Create(bo *Job) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkName != "" && dao.spec.PkValue != "" {
+		ubo.SetExtraAttr(dao.spec.PkName, dao.spec.PkValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
+
+// GetDueJobs implements JobDao.GetDueJobs.
+//
+// Cosmos DB stores job's scheduled time as string, see formatJobTime.
+func (dao *JobDaoCosmosdb) GetDueJobs(now time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, dueJobsFilter(FieldJobStatus, FieldJobNextRunAt, formatJobTime(now)), dueJobsSorting(FieldJobNextRunAt))
+}
+
+// GetExpiredJobs implements JobDao.GetExpiredJobs.
+//
+// Cosmos DB stores job's scheduled time as string, see formatJobTime.
+func (dao *JobDaoCosmosdb) GetExpiredJobs(before time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, expiredJobsFilter(FieldJobStatus, FieldJobNextRunAt, formatJobTime(before)), nil)
+}
@@ -0,0 +1,21 @@
+package job
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+const (
+	dynamodbPkValueJob = "job"
+)
+
+// NewJobDaoMultitenantAwsDynamodb is helper method to create AWS DynamoDB-implementation (multi-tenant table) of JobDao.
+func NewJobDaoMultitenantAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) JobDao {
+	spec := &henge.DynamodbDaoSpec{PkPrefix: bo.DynamodbMultitenantPkName, PkPrefixValue: dynamodbPkValueJob}
+	dao := &JobDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
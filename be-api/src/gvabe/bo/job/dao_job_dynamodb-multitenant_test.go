@@ -0,0 +1,138 @@
+package job
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/henge"
+	"github.com/btnguyen2k/prom"
+
+	"main/src/gvabe/bo"
+)
+
+const tableNameMultitenantDynamodb = "exter_test"
+
+var setupTestDynamodbMultitenant = func(t *testing.T, testName string) {
+	testAdc = _createAwsDynamodbConnect(t, testName)
+	for _, tableName := range []string{tableNameMultitenantDynamodb, tableNameMultitenantDynamodb + henge.AwsDynamodbUidxTableSuffix} {
+		testAdc.DeleteTable(nil, tableName)
+		err := prom.AwsDynamodbWaitForTableStatus(testAdc, tableName, []string{""}, 1*time.Second, 10*time.Second)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+	err := bo.InitMultitenantTableAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestDynamodbMultitenant = func(t *testing.T, testName string) {
+	if testAdc != nil {
+		defer func() {
+			defer func() { testAdc = nil }()
+			testAdc.Close()
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewJobDaoMultitenantAwsDynamodb(t *testing.T) {
+	testName := "TestNewJobDaoMultitenantAwsDynamodb"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	if jobDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestJobDaoMultitenantAwsDynamodb_Create(t *testing.T) {
+	testName := "TestJobDaoMultitenantAwsDynamodb_Create"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestJobDao_Create(t, testName, jobDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("%s failed: expected 1 item inserted but received %#v", testName, len(items))
+	}
+	if v, _ := items[0][bo.DynamodbMultitenantPkName].(string); v != dynamodbPkValueJob {
+		t.Fatalf("%s failed: expected item has field '%s' with value '%s' but received %#v", testName, bo.DynamodbMultitenantPkName, dynamodbPkValueJob, items[0])
+	}
+}
+
+func TestJobDaoMultitenantAwsDynamodb_Get(t *testing.T) {
+	testName := "TestJobDaoMultitenantAwsDynamodb_Get"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestJobDao_Get(t, testName, jobDao)
+}
+
+func TestJobDaoMultitenantAwsDynamodb_Delete(t *testing.T) {
+	testName := "TestJobDaoMultitenantAwsDynamodb_Delete"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestJobDao_Delete(t, testName, jobDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 0 {
+		for _, item := range items {
+			fmt.Printf("\tDEBUG: %#v\n", item)
+		}
+		t.Fatalf("%s failed: expected 0 item inserted but received %#v", testName, len(items))
+	}
+}
+
+func TestJobDaoMultitenantAwsDynamodb_Update(t *testing.T) {
+	testName := "TestJobDaoMultitenantAwsDynamodb_Update"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestJobDao_Update(t, testName, jobDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 1 {
+		for _, item := range items {
+			fmt.Printf("\tDEBUG: %#v\n", item)
+		}
+		t.Fatalf("%s failed: expected 1 item inserted but received %#v", testName, len(items))
+	}
+	if v, _ := items[0][bo.DynamodbMultitenantPkName].(string); v != dynamodbPkValueJob {
+		t.Fatalf("%s failed: expected item has field '%s' with value '%s' but received %#v", testName, bo.DynamodbMultitenantPkName, dynamodbPkValueJob, items[0])
+	}
+}
+
+func TestJobDaoMultitenantAwsDynamodb_GetDueJobs(t *testing.T) {
+	testName := "TestJobDaoMultitenantAwsDynamodb_GetDueJobs"
+	teardownTest := setupTest(t, testName, setupTestDynamodbMultitenant, teardownTestDynamodbMultitenant)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMultitenantAwsDynamodb(testAdc, tableNameMultitenantDynamodb)
+	doTestJobDao_GetDueJobs(t, testName, jobDao)
+	items, err := testAdc.ScanItems(nil, tableNameMultitenantDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 10 {
+		for _, item := range items {
+			fmt.Printf("\tDEBUG: %#v\n", item)
+		}
+		t.Fatalf("%s failed: expected 10 items inserted but received %#v", testName, len(items))
+	}
+	for _, item := range items {
+		if v, _ := item[bo.DynamodbMultitenantPkName].(string); v != dynamodbPkValueJob {
+			t.Fatalf("%s failed: expected item has field '%s' with value '%s' but received %#v", testName, bo.DynamodbMultitenantPkName, dynamodbPkValueJob, items[0])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package job
+
+import (
+	"time"
+
+	"github.com/btnguyen2k/godal"
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+)
+
+// NewJobDaoAwsDynamodb is helper method to create AWS DynamoDB-implementation of JobDao.
+func NewJobDaoAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) JobDao {
+	var spec *henge.DynamodbDaoSpec = nil
+	dao := &JobDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
+
+// InitJobTableAwsDynamodb is helper function to initialize AWS DynamoDB table(s) to store job data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitJobTableAwsDynamodb(adc *prom.AwsDynamodbConnect, tableName string) error {
+	spec := &henge.DynamodbTablesSpec{MainTableRcu: 1, MainTableWcu: 1}
+	return henge.InitDynamodbTables(adc, tableName, spec)
+}
+
+// JobDaoAwsDynamodb is AWS DynamoDB-implementation of JobDao.
+type JobDaoAwsDynamodb struct {
+	henge.UniversalDao
+	spec *henge.DynamodbDaoSpec
+}
+
+// Delete implements JobDao.Delete.
+func (dao *JobDaoAwsDynamodb) Delete(bo *Job) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements JobDao.Create.
+func (dao *JobDaoAwsDynamodb) Create(bo *Job) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkPrefix != "" {
+		ubo.SetExtraAttr(dao.spec.PkPrefix, dao.spec.PkPrefixValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
+
+// Get implements JobDao.Get.
+func (dao *JobDaoAwsDynamodb) Get(id string) (*Job, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewJobFromUbo(ubo), err
+}
+
+// getN implements JobDao.getN.
+func (dao *JobDaoAwsDynamodb) getN(fromOffset, maxNumRows int, filter godal.FilterOpt, sorting *godal.SortingOpt) ([]*Job, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, filter, sorting)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Job, 0)
+	for _, ubo := range uboList {
+		bo := NewJobFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements JobDao.getAll.
+func (dao *JobDaoAwsDynamodb) getAll() ([]*Job, error) {
+	return dao.getN(0, 0, nil, nil)
+}
+
+// GetDueJobs implements JobDao.GetDueJobs.
+//
+// DynamoDB stores job's scheduled time as string (see formatJobTime) and can not sort scan results, hence jobs are
+// sorted after being retrieved.
+func (dao *JobDaoAwsDynamodb) GetDueJobs(now time.Time, limit int) ([]*Job, error) {
+	if jobList, err := dao.getN(0, limit, dueJobsFilter(FieldJobStatus, FieldJobNextRunAt, formatJobTime(now)), nil); err != nil {
+		return nil, err
+	} else {
+		return sortJobsByNextRunAt(jobList), nil
+	}
+}
+
+// GetExpiredJobs implements JobDao.GetExpiredJobs.
+//
+// DynamoDB stores job's scheduled time as string, see formatJobTime.
+func (dao *JobDaoAwsDynamodb) GetExpiredJobs(before time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, expiredJobsFilter(FieldJobStatus, FieldJobNextRunAt, formatJobTime(before)), nil)
+}
+
+// Update implements JobDao.Update.
+func (dao *JobDaoAwsDynamodb) Update(bo *Job) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
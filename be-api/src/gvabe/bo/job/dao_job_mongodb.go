@@ -0,0 +1,91 @@
+package job
+
+import (
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/godal"
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+)
+
+// NewJobDaoMongo is helper method to create MongoDB-implementation of JobDao.
+func NewJobDaoMongo(mc *prom.MongoConnect, collectionName string) JobDao {
+	txMode := strings.Index(strings.ToLower(mc.GetUrl()), "replicaset=") > 0
+	dao := &JobDaoMongo{UniversalDao: henge.NewUniversalDaoMongo(mc, collectionName, txMode)}
+	return dao
+}
+
+// InitJobTableMongo is helper function to initialize MongoDB table (collection) to store job data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitJobTableMongo(mc *prom.MongoConnect, collectionName string) error {
+	if err := henge.InitMongoCollection(mc, collectionName); err != nil {
+		return err
+	}
+	// due jobs are looked up by status and scheduled time
+	_, err := mc.CreateCollectionIndexes(collectionName, []interface{}{
+		map[string]interface{}{
+			"key":  map[string]interface{}{FieldJobStatus: 1, FieldJobNextRunAt: 1},
+			"name": "idx_status_nrat",
+		},
+	})
+	return err
+}
+
+// JobDaoMongo is MongoDB-implementation of JobDao.
+type JobDaoMongo struct {
+	henge.UniversalDao
+}
+
+// Delete implements JobDao.Delete.
+func (dao *JobDaoMongo) Delete(bo *Job) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements JobDao.Create.
+func (dao *JobDaoMongo) Create(bo *Job) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements JobDao.Get.
+func (dao *JobDaoMongo) Get(id string) (*Job, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewJobFromUbo(ubo), err
+}
+
+// getN implements JobDao.getN.
+func (dao *JobDaoMongo) getN(fromOffset, maxNumRows int, filter godal.FilterOpt, sorting *godal.SortingOpt) ([]*Job, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, filter, sorting)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Job, 0)
+	for _, ubo := range uboList {
+		bo := NewJobFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements JobDao.getAll.
+func (dao *JobDaoMongo) getAll() ([]*Job, error) {
+	return dao.getN(0, 0, nil, nil)
+}
+
+// GetDueJobs implements JobDao.GetDueJobs.
+func (dao *JobDaoMongo) GetDueJobs(now time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, dueJobsFilter(FieldJobStatus, FieldJobNextRunAt, now), dueJobsSorting(FieldJobNextRunAt))
+}
+
+// GetExpiredJobs implements JobDao.GetExpiredJobs.
+func (dao *JobDaoMongo) GetExpiredJobs(before time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, expiredJobsFilter(FieldJobStatus, FieldJobNextRunAt, before), nil)
+}
+
+// Update implements JobDao.Update.
+func (dao *JobDaoMongo) Update(bo *Job) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
@@ -0,0 +1,101 @@
+package job
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+)
+
+func _createMongoConnect(t *testing.T, testName string) *prom.MongoConnect {
+	mongoDb := strings.ReplaceAll(os.Getenv("MONGO_DB"), `"`, "")
+	mongoUrl := strings.ReplaceAll(os.Getenv("MONGO_URL"), `"`, "")
+	if mongoDb == "" || mongoUrl == "" {
+		t.Skipf("%s skipped", testName)
+		return nil
+	}
+	mongoPoolOpts := &prom.MongoPoolOpts{
+		ConnectTimeout:         5 * time.Second,
+		SocketTimeout:          7 * time.Second,
+		ServerSelectionTimeout: 11 * time.Second,
+	}
+	mc, err := prom.NewMongoConnectWithPoolOptions(mongoUrl, mongoDb, 10000, mongoPoolOpts)
+	if err != nil {
+		t.Fatalf("%s/%s failed: %s", testName, "NewMongoConnect", err)
+	}
+	return mc
+}
+
+const collectionNameMongo = "exter_test_job"
+
+var setupTestMongo = func(t *testing.T, testName string) {
+	testMc = _createMongoConnect(t, testName)
+	testMc.GetCollection(collectionNameMongo).Drop(nil)
+	err := InitJobTableMongo(testMc, collectionNameMongo)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestMongo = func(t *testing.T, testName string) {
+	if testMc != nil {
+		defer func() {
+			defer func() { testMc = nil }()
+			testMc.Close(nil)
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewJobDaoMongo(t *testing.T) {
+	testName := "TestNewJobDaoMongo"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMongo(testMc, collectionNameMongo)
+	if jobDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestJobDaoMongo_Create(t *testing.T) {
+	testName := "TestJobDaoMongo_Create"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMongo(testMc, collectionNameMongo)
+	doTestJobDao_Create(t, testName, jobDao)
+}
+
+func TestJobDaoMongo_Get(t *testing.T) {
+	testName := "TestJobDaoMongo_Get"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMongo(testMc, collectionNameMongo)
+	doTestJobDao_Get(t, testName, jobDao)
+}
+
+func TestJobDaoMongo_Delete(t *testing.T) {
+	testName := "TestJobDaoMongo_Delete"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMongo(testMc, collectionNameMongo)
+	doTestJobDao_Delete(t, testName, jobDao)
+}
+
+func TestJobDaoMongo_Update(t *testing.T) {
+	testName := "TestJobDaoMongo_Update"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMongo(testMc, collectionNameMongo)
+	doTestJobDao_Update(t, testName, jobDao)
+}
+
+func TestJobDaoMongo_GetDueJobs(t *testing.T) {
+	testName := "TestJobDaoMongo_GetDueJobs"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	jobDao := NewJobDaoMongo(testMc, collectionNameMongo)
+	doTestJobDao_GetDueJobs(t, testName, jobDao)
+}
@@ -0,0 +1,126 @@
+package job
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btnguyen2k/godal"
+	"github.com/btnguyen2k/prom"
+	"main/src/gvabe/bo"
+
+	"github.com/btnguyen2k/henge"
+)
+
+const (
+	SqlColJobStatus    = "zstatus"
+	SqlColJobNextRunAt = "znextrun"
+)
+
+// NewJobDaoSql is helper method to create SQL-implementation of JobDao.
+func NewJobDaoSql(sqlc *prom.SqlConnect, tableName string) JobDao {
+	dao := &JobDaoSql{}
+	dao.UniversalDao = henge.NewUniversalDaoSql(sqlc, tableName, true, map[string]string{
+		SqlColJobStatus:    FieldJobStatus,
+		SqlColJobNextRunAt: FieldJobNextRunAt,
+	})
+	return dao
+}
+
+// InitJobTableSql is helper function to initialize SQL-based table to store job data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitJobTableSql(sqlc *prom.SqlConnect, tableName string) error {
+	var err error
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorPgSql:
+		err = henge.InitPgsqlTable(sqlc, tableName, map[string]string{
+			SqlColJobStatus:    "VARCHAR(32)",
+			SqlColJobNextRunAt: "TIMESTAMP WITH TIME ZONE",
+		})
+	case prom.FlavorMsSql:
+		err = henge.InitMssqlTable(sqlc, tableName, map[string]string{
+			SqlColJobStatus:    "NVARCHAR(32)",
+			SqlColJobNextRunAt: "DATETIMEOFFSET",
+		})
+	case prom.FlavorMySql:
+		err = henge.InitMysqlTable(sqlc, tableName, map[string]string{
+			SqlColJobStatus:    "VARCHAR(32)",
+			SqlColJobNextRunAt: "TIMESTAMP",
+		})
+	case prom.FlavorOracle:
+		err = henge.InitOracleTable(sqlc, tableName, map[string]string{
+			SqlColJobStatus:    "NVARCHAR2(32)",
+			SqlColJobNextRunAt: "TIMESTAMP WITH TIME ZONE",
+		})
+	case prom.FlavorSqlite:
+		err = henge.InitSqliteTable(sqlc, tableName, map[string]string{
+			SqlColJobStatus:    "VARCHAR(32)",
+			SqlColJobNextRunAt: "TIMESTAMP",
+		})
+	case prom.FlavorCosmosDb:
+		return henge.InitCosmosdbCollection(sqlc, tableName, &henge.CosmosdbCollectionSpec{Pk: bo.CosmosdbPkName})
+	default:
+		return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+	}
+	if err != nil {
+		return err
+	}
+	// due jobs are looked up by status and scheduled time
+	return henge.CreateIndexSql(sqlc, tableName, false, []string{SqlColJobStatus, SqlColJobNextRunAt})
+}
+
+// JobDaoSql is SQL-implementation of JobDao.
+type JobDaoSql struct {
+	henge.UniversalDao
+}
+
+// Delete implements JobDao.Delete.
+func (dao *JobDaoSql) Delete(bo *Job) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements JobDao.Create.
+func (dao *JobDaoSql) Create(bo *Job) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements JobDao.Get.
+func (dao *JobDaoSql) Get(id string) (*Job, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewJobFromUbo(ubo), err
+}
+
+// getN implements JobDao.getN.
+func (dao *JobDaoSql) getN(fromOffset, maxNumRows int, filter godal.FilterOpt, sorting *godal.SortingOpt) ([]*Job, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, filter, sorting)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Job, 0)
+	for _, ubo := range uboList {
+		bo := NewJobFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// getAll implements JobDao.getAll.
+func (dao *JobDaoSql) getAll() ([]*Job, error) {
+	return dao.getN(0, 0, nil, nil)
+}
+
+// GetDueJobs implements JobDao.GetDueJobs.
+func (dao *JobDaoSql) GetDueJobs(now time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, dueJobsFilter(SqlColJobStatus, SqlColJobNextRunAt, now), dueJobsSorting(SqlColJobNextRunAt))
+}
+
+// GetExpiredJobs implements JobDao.GetExpiredJobs.
+func (dao *JobDaoSql) GetExpiredJobs(before time.Time, limit int) ([]*Job, error) {
+	return dao.getN(0, limit, expiredJobsFilter(SqlColJobStatus, SqlColJobNextRunAt, before), nil)
+}
+
+// Update implements JobDao.Update.
+func (dao *JobDaoSql) Update(bo *Job) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
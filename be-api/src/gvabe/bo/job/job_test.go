@@ -0,0 +1,180 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+)
+
+type TestSetupOrTeardownFunc func(t *testing.T, testName string)
+
+func setupTest(t *testing.T, testName string, extraSetupFunc, extraTeardownFunc TestSetupOrTeardownFunc) func(t *testing.T) {
+	if extraSetupFunc != nil {
+		extraSetupFunc(t, testName)
+	}
+	return func(t *testing.T) {
+		if extraTeardownFunc != nil {
+			extraTeardownFunc(t, testName)
+		}
+	}
+}
+
+var (
+	testAdc  *prom.AwsDynamodbConnect
+	testMc   *prom.MongoConnect
+	testSqlc *prom.SqlConnect
+)
+
+/*----------------------------------------------------------------------*/
+
+const (
+	testKind    = "login_profile"
+	testPayload = "session-id"
+)
+
+func doTestJobDao_Create(t *testing.T, testName string, jobDao JobDao) {
+	job := NewJob(1357, testKind, testPayload)
+	ok, err := jobDao.Create(job)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+}
+
+func doTestJobDao_Get(t *testing.T, testName string, jobDao JobDao) {
+	_job := NewJob(1357, testKind, testPayload)
+	jobDao.Create(_job)
+
+	if job, err := jobDao.Get("not_found"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if job != nil {
+		t.Fatalf("%s failed: job %s should not exist", testName, "not_found")
+	}
+
+	if job, err := jobDao.Get(_job.GetId()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if job == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := job.GetTagVersion(); v != 1357 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 1357, v)
+		}
+		if v := job.GetKind(); v != testKind {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, testKind, v)
+		}
+		if v := job.GetPayload(); v != testPayload {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, testPayload, v)
+		}
+		if v := job.GetStatus(); v != StatusPending {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, StatusPending, v)
+		}
+		if v := job.GetNextRunAt(); !v.Equal(_job.GetNextRunAt()) {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, _job.GetNextRunAt(), v)
+		}
+	}
+}
+
+func doTestJobDao_Delete(t *testing.T, testName string, jobDao JobDao) {
+	_job := NewJob(1357, testKind, testPayload)
+	jobDao.Create(_job)
+	job, err := jobDao.Get(_job.GetId())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if job == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ok, err := jobDao.Delete(job)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if !ok {
+		t.Fatalf("%s failed: cannot delete job [%s]", testName, job.GetId())
+	}
+
+	if job, err := jobDao.Get(_job.GetId()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if job != nil {
+		t.Fatalf("%s failed: job %s should not exist", testName, _job.GetId())
+	}
+}
+
+func doTestJobDao_Update(t *testing.T, testName string, jobDao JobDao) {
+	job := NewJob(1357, testKind, testPayload)
+	jobDao.Create(job)
+
+	nextRunAt := time.Now().Add(30 * time.Second)
+	job.SetTagVersion(2468)
+	job.SetStatus(StatusRunning).SetAttempts(2).SetNextRunAt(nextRunAt).SetLastError("timeout")
+	ok, err := jobDao.Update(job)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+
+	if job, err := jobDao.Get(job.GetId()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if job == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := job.GetTagVersion(); v != 2468 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 2468, v)
+		}
+		if v := job.GetStatus(); v != StatusRunning {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, StatusRunning, v)
+		}
+		if v := job.GetAttempts(); v != 2 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 2, v)
+		}
+		if v := job.GetLastError(); v != "timeout" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "timeout", v)
+		}
+	}
+}
+
+func doTestJobDao_GetDueJobs(t *testing.T, testName string, jobDao JobDao) {
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		job := NewJob(uint64(i), testKind, testPayload)
+		switch i % 3 {
+		case 0:
+			job.SetNextRunAt(now.Add(-time.Duration(i+1) * time.Second))
+		case 1:
+			job.SetNextRunAt(now.Add(time.Minute))
+		case 2:
+			job.SetStatus(StatusFailed)
+		}
+		jobDao.Create(job)
+	}
+
+	jobList, err := jobDao.GetDueJobs(now, 0)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(jobList) != 4 {
+		t.Fatalf("%s failed: expected %#v jobs but received %#v", testName, 4, len(jobList))
+	}
+	if limited, err := jobDao.GetDueJobs(now, 3); err != nil || len(limited) != 3 {
+		t.Fatalf("%s failed: expected %#v jobs but received %#v / %s", testName, 3, len(limited), err)
+	}
+	for i, job := range jobList {
+		if !job.IsDue(now) {
+			t.Fatalf("%s failed: job %#v is not due", testName, job.GetId())
+		}
+		if i > 0 && job.GetNextRunAt().Before(jobList[i-1].GetNextRunAt()) {
+			t.Fatalf("%s failed: jobs are not ordered by scheduled time", testName)
+		}
+	}
+
+	// failed jobs expire once their scheduled time has passed
+	if expired, err := jobDao.GetExpiredJobs(now.Add(-time.Minute), 0); err != nil || len(expired) != 0 {
+		t.Fatalf("%s failed: expected %#v jobs but received %#v / %s", testName, 0, len(expired), err)
+	}
+	expired, err := jobDao.GetExpiredJobs(now.Add(time.Second), 0)
+	if err != nil || len(expired) != 3 {
+		t.Fatalf("%s failed: expected %#v jobs but received %#v / %s", testName, 3, len(expired), err)
+	}
+	for _, job := range expired {
+		if job.GetStatus() != StatusFailed {
+			t.Fatalf("%s failed: job %#v has not failed", testName, job.GetId())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package signingkey contains business object (BO) and data access object (DAO) implementations for SigningKey: keys
+// Exter signs tokens with, rotated on schedule.
+//
+// Available since v0.8.0
+package signingkey
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	"github.com/btnguyen2k/henge"
+	"main/src/gvabe/bo"
+)
+
+const (
+	// StatusNext: key is published (e.g. via JWKS) but not used to sign tokens yet, it becomes active upon next rotation
+	StatusNext = "next"
+
+	// StatusActive: key is used to sign new tokens
+	StatusActive = "active"
+
+	// StatusRetired: key no longer signs tokens, but tokens it has signed are still verified until the grace period ends
+	StatusRetired = "retired"
+)
+
+// NewSigningKey is helper function to create new SigningKey bo. The new key has status "next".
+//
+// - kid: the key id
+// - alg: the JWT signing algorithm, e.g. "RS256"
+// - keyData: the (encrypted) private key
+func NewSigningKey(tagVersion uint64, kid, alg, keyData string) *SigningKey {
+	key := &SigningKey{
+		UniversalBo: henge.NewUniversalBo(kid, tagVersion, henge.UboOpt{TimeLayout: bo.UboTimeLayout, TimestampRounding: bo.UboTimestampRounding}),
+	}
+	key.
+		SetAlg(alg).
+		SetKeyData(keyData).
+		SetStatus(StatusNext)
+	return key.sync()
+}
+
+// NewSigningKeyFromUbo is helper function to create new SigningKey bo from a universal bo.
+func NewSigningKeyFromUbo(ubo *henge.UniversalBo) *SigningKey {
+	if ubo == nil {
+		return nil
+	}
+	ubo = ubo.Clone()
+	key := &SigningKey{UniversalBo: ubo}
+	fieldListStr := []string{AttrSigningKeyAlg, AttrSigningKeyStatus, AttrSigningKeyData}
+	setterListStr := []func(string) *SigningKey{key.SetAlg, key.SetStatus, key.SetKeyData}
+	for i, attr := range fieldListStr {
+		if v, err := key.GetDataAttrAs(attr, reddo.TypeString); err == nil && v != nil {
+			setterListStr[i](v.(string))
+		}
+	}
+	fieldListTime := []string{AttrSigningKeyActivatedAt, AttrSigningKeyRetiredAt}
+	setterListTime := []func(time.Time) *SigningKey{key.SetActivatedAt, key.SetRetiredAt}
+	for i, attr := range fieldListTime {
+		if v, err := key.GetDataAttrAs(attr, reddo.TypeTime); err == nil && v != nil {
+			setterListTime[i](v.(time.Time))
+		}
+	}
+	return key.sync()
+}
+
+const (
+	AttrSigningKeyAlg         = "alg"
+	AttrSigningKeyStatus      = "status"
+	AttrSigningKeyData        = "key"
+	AttrSigningKeyActivatedAt = "aat"
+	AttrSigningKeyRetiredAt   = "rat"
+	AttrSigningKeyUbo         = "_ubo"
+)
+
+// SigningKey is the business object: a key Exter signs tokens with. Its id is the key id ("kid" header of JWTs).
+type SigningKey struct {
+	*henge.UniversalBo `json:"_ubo"`
+	alg                string    `json:"alg"`    // JWT signing algorithm
+	status             string    `json:"status"` // key's status, see StatusNext, StatusActive and StatusRetired
+	keyData            string    `json:"key"`    // the (encrypted) private key
+	activatedAt        time.Time `json:"aat"`    // timestamp when the key became active
+	retiredAt          time.Time `json:"rat"`    // timestamp when the key was retired
+}
+
+// MarshalJSON implements json.encode.Marshaler.MarshalJSON.
+func (key *SigningKey) MarshalJSON() ([]byte, error) {
+	key.sync()
+	m := map[string]interface{}{
+		AttrSigningKeyUbo: key.UniversalBo.Clone(),
+		bo.SerKeyAttrs: map[string]interface{}{
+			AttrSigningKeyAlg:         key.GetAlg(),
+			AttrSigningKeyStatus:      key.GetStatus(),
+			AttrSigningKeyData:        key.GetKeyData(),
+			AttrSigningKeyActivatedAt: key.GetActivatedAt(),
+			AttrSigningKeyRetiredAt:   key.GetRetiredAt(),
+		},
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.decode.Unmarshaler.UnmarshalJSON.
+func (key *SigningKey) UnmarshalJSON(data []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if m[AttrSigningKeyUbo] != nil {
+		js, _ := json.Marshal(m[AttrSigningKeyUbo])
+		if err := json.Unmarshal(js, &key.UniversalBo); err != nil {
+			return err
+		}
+	}
+	if _attrs, ok := m[bo.SerKeyAttrs].(map[string]interface{}); ok {
+		attrListStr := []string{AttrSigningKeyAlg, AttrSigningKeyStatus, AttrSigningKeyData}
+		setterListStr := []func(string) *SigningKey{key.SetAlg, key.SetStatus, key.SetKeyData}
+		for i, attr := range attrListStr {
+			if v, err := reddo.ToString(_attrs[attr]); err != nil {
+				return err
+			} else {
+				setterListStr[i](v)
+			}
+		}
+		attrListTime := []string{AttrSigningKeyActivatedAt, AttrSigningKeyRetiredAt}
+		setterListTime := []func(time.Time) *SigningKey{key.SetActivatedAt, key.SetRetiredAt}
+		for i, attr := range attrListTime {
+			if v, err := reddo.ToTime(_attrs[attr]); err != nil {
+				return err
+			} else {
+				setterListTime[i](v)
+			}
+		}
+	}
+
+	key.sync()
+	return nil
+}
+
+// GetAlg returns key's 'alg' value.
+func (key *SigningKey) GetAlg() string {
+	return key.alg
+}
+
+// SetAlg sets key's 'alg' value.
+func (key *SigningKey) SetAlg(value string) *SigningKey {
+	key.alg = strings.TrimSpace(value)
+	return key
+}
+
+// GetStatus returns key's status.
+func (key *SigningKey) GetStatus() string {
+	return key.status
+}
+
+// SetStatus sets key's status.
+func (key *SigningKey) SetStatus(value string) *SigningKey {
+	key.status = strings.TrimSpace(strings.ToLower(value))
+	return key
+}
+
+// GetKeyData returns the (encrypted) private key.
+func (key *SigningKey) GetKeyData() string {
+	return key.keyData
+}
+
+// SetKeyData sets the (encrypted) private key.
+func (key *SigningKey) SetKeyData(value string) *SigningKey {
+	key.keyData = strings.TrimSpace(value)
+	return key
+}
+
+// GetActivatedAt returns the timestamp when the key became active.
+func (key *SigningKey) GetActivatedAt() time.Time {
+	return key.activatedAt
+}
+
+// SetActivatedAt sets the timestamp when the key became active.
+func (key *SigningKey) SetActivatedAt(value time.Time) *SigningKey {
+	key.activatedAt = value
+	return key
+}
+
+// GetRetiredAt returns the timestamp when the key was retired.
+func (key *SigningKey) GetRetiredAt() time.Time {
+	return key.retiredAt
+}
+
+// SetRetiredAt sets the timestamp when the key was retired.
+func (key *SigningKey) SetRetiredAt(value time.Time) *SigningKey {
+	key.retiredAt = value
+	return key
+}
+
+func (key *SigningKey) sync() *SigningKey {
+	key.SetDataAttr(AttrSigningKeyAlg, key.alg)
+	key.SetDataAttr(AttrSigningKeyStatus, key.status)
+	key.SetDataAttr(AttrSigningKeyData, key.keyData)
+	key.SetDataAttr(AttrSigningKeyActivatedAt, key.activatedAt)
+	key.SetDataAttr(AttrSigningKeyRetiredAt, key.retiredAt)
+	key.UniversalBo.Sync()
+	return key
+}
@@ -0,0 +1,119 @@
+package signingkey
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/henge"
+)
+
+func TestNewSigningKey(t *testing.T) {
+	testName := "TestNewSigningKey"
+	_tagVersion := uint64(1337)
+	_kid := "kid"
+	_alg := "RS256"
+	_keyData := "encrypted-key"
+	key := NewSigningKey(_tagVersion, _kid, _alg, _keyData)
+	if key == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+	if f, v, expected := "id", key.GetId(), _kid; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "tag-version", key.GetTagVersion(), _tagVersion; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "alg", key.GetAlg(), _alg; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "key-data", key.GetKeyData(), _keyData; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "status", key.GetStatus(), StatusNext; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if !key.GetActivatedAt().IsZero() || !key.GetRetiredAt().IsZero() {
+		t.Fatalf("%s failed: new key is neither activated nor retired", testName)
+	}
+}
+
+func TestNewSigningKeyFromUbo(t *testing.T) {
+	testName := "TestNewSigningKeyFromUbo"
+	if key := NewSigningKeyFromUbo(nil); key != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, key)
+	}
+
+	_tagVersion := uint64(1337)
+	_alg := "RS256"
+	_status := StatusRetired
+	_keyData := "encrypted-key"
+	_aat := time.Now().Add(-time.Hour).Round(time.Second)
+	_rat := time.Now().Round(time.Second)
+	ubo := henge.NewUniversalBo("kid", _tagVersion)
+	ubo.SetDataJson("invalid json string")
+	if key := NewSigningKeyFromUbo(ubo); key == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ubo.SetDataAttr(AttrSigningKeyAlg, _alg)
+	ubo.SetDataAttr(AttrSigningKeyStatus, _status)
+	ubo.SetDataAttr(AttrSigningKeyData, _keyData)
+	ubo.SetDataAttr(AttrSigningKeyActivatedAt, _aat)
+	ubo.SetDataAttr(AttrSigningKeyRetiredAt, _rat)
+	key := NewSigningKeyFromUbo(ubo)
+	if key == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+	if f, v, expected := "alg", key.GetAlg(), _alg; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "status", key.GetStatus(), _status; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "key-data", key.GetKeyData(), _keyData; v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "activated-at", key.GetActivatedAt(), _aat; !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "retired-at", key.GetRetiredAt(), _rat; !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+}
+
+func TestSigningKey_json(t *testing.T) {
+	testName := "TestSigningKey_json"
+
+	key1 := NewSigningKey(1337, "kid", "RS256", "encrypted-key")
+	key1.SetStatus(StatusActive).SetActivatedAt(time.Now().Round(time.Second))
+	js1, _ := json.Marshal(key1)
+
+	var key2 *SigningKey
+	err := json.Unmarshal(js1, &key2)
+	if err != nil {
+		t.Fatalf("%s failed: %e", testName, err)
+	}
+
+	if f, v, expected := "id", key2.GetId(), key1.GetId(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "alg", key2.GetAlg(), key1.GetAlg(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "status", key2.GetStatus(), key1.GetStatus(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "key-data", key2.GetKeyData(), key1.GetKeyData(); v != expected {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "activated-at", key2.GetActivatedAt(), key1.GetActivatedAt(); !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if f, v, expected := "retired-at", key2.GetRetiredAt(), key1.GetRetiredAt(); !v.Equal(expected) {
+		t.Fatalf("%s failed: expected %s to be %#v but received %#v", testName, f, expected, v)
+	}
+	if key1.GetChecksum() != key2.GetChecksum() {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, key1.GetChecksum(), key2.GetChecksum())
+	}
+}
@@ -0,0 +1,26 @@
+package signingkey
+
+const (
+	TableSigningKey = "exter_signing_key"
+)
+
+// SigningKeyDao defines API to access SigningKey storage.
+type SigningKeyDao interface {
+	// Delete removes the specified business object from storage.
+	Delete(bo *SigningKey) (bool, error)
+
+	// Create persists a new business object to storage.
+	Create(bo *SigningKey) (bool, error)
+
+	// Get retrieves a business object from storage.
+	Get(id string) (*SigningKey, error)
+
+	// // getN retrieves N business objects from storage.
+	// getN(fromOffset, maxNumRows int) ([]*SigningKey, error)
+
+	// GetAll retrieves all available business objects from storage.
+	GetAll() ([]*SigningKey, error)
+
+	// Update modifies an existing business object.
+	Update(bo *SigningKey) (bool, error)
+}
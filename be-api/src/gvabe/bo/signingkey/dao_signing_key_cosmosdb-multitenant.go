@@ -0,0 +1,17 @@
+package signingkey
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewSigningKeyDaoMultitenantCosmosdb is helper method to create CosmosDB-implementation (multi-tenant table) of SigningKeyDao.
+func NewSigningKeyDaoMultitenantCosmosdb(sqlc *prom.SqlConnect, tableName string) SigningKeyDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbMultitenantPkName, PkValue: bo.CosmosdbMultitenantPkValueSigningKey, TxModeOnWrite: true}
+	innerDao := SigningKeyDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &SigningKeyDaoCosmosdb{SigningKeyDaoSql: innerDao, spec: spec}
+	return dao
+}
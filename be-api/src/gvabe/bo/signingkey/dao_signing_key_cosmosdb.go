@@ -0,0 +1,46 @@
+package signingkey
+
+import (
+	"fmt"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+// NewSigningKeyDaoCosmosdb is helper method to create CosmosDB-implementation of SigningKeyDao.
+func NewSigningKeyDaoCosmosdb(sqlc *prom.SqlConnect, tableName string) SigningKeyDao {
+	spec := &henge.CosmosdbDaoSpec{PkName: bo.CosmosdbPkName, TxModeOnWrite: true}
+	innerDao := SigningKeyDaoSql{UniversalDao: henge.NewUniversalDaoCosmosdbSql(sqlc, tableName, spec)}
+	dao := &SigningKeyDaoCosmosdb{SigningKeyDaoSql: innerDao, spec: spec}
+	return dao
+}
+
+// InitSigningKeyTableCosmosdb is helper function to initialize CosmosDB-based table to store signing key data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitSigningKeyTableCosmosdb(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorCosmosDb:
+		return InitSigningKeyTableSql(sqlc, tableName)
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// SigningKeyDaoCosmosdb is CosmosDB-implementation of SigningKeyDao.
+type SigningKeyDaoCosmosdb struct {
+	SigningKeyDaoSql
+	spec *henge.CosmosdbDaoSpec
+}
+
+// Create implements SigningKeyDao.Create.
+func (dao *SigningKeyDaoCosmosdb) Create(bo *SigningKey) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkName != "" && dao.spec.PkValue != "" {
+		ubo.SetExtraAttr(dao.spec.PkName, dao.spec.PkValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
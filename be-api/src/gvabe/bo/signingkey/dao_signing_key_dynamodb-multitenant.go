@@ -0,0 +1,21 @@
+package signingkey
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+
+	"main/src/gvabe/bo"
+)
+
+const (
+	dynamodbPkValueSigningKey = "signing_key"
+)
+
+// NewSigningKeyDaoMultitenantAwsDynamodb is helper method to create AWS DynamoDB-implementation (multi-tenant table) of SigningKeyDao.
+func NewSigningKeyDaoMultitenantAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) SigningKeyDao {
+	spec := &henge.DynamodbDaoSpec{PkPrefix: bo.DynamodbMultitenantPkName, PkPrefixValue: dynamodbPkValueSigningKey}
+	dao := &SigningKeyDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
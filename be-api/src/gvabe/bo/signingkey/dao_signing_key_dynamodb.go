@@ -0,0 +1,74 @@
+package signingkey
+
+import (
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+)
+
+// NewSigningKeyDaoAwsDynamodb is helper method to create AWS DynamoDB-implementation of SigningKeyDao.
+func NewSigningKeyDaoAwsDynamodb(dync *prom.AwsDynamodbConnect, tableName string) SigningKeyDao {
+	var spec *henge.DynamodbDaoSpec = nil
+	dao := &SigningKeyDaoAwsDynamodb{UniversalDao: henge.NewUniversalDaoDynamodb(dync, tableName, spec)}
+	dao.spec = spec
+	return dao
+}
+
+// InitSigningKeyTableAwsDynamodb is helper function to initialize AWS DynamoDB table(s) to store signing key data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitSigningKeyTableAwsDynamodb(adc *prom.AwsDynamodbConnect, tableName string) error {
+	spec := &henge.DynamodbTablesSpec{MainTableRcu: 1, MainTableWcu: 1}
+	return henge.InitDynamodbTables(adc, tableName, spec)
+}
+
+// SigningKeyDaoAwsDynamodb is AWS DynamoDB-implementation of SigningKeyDao.
+type SigningKeyDaoAwsDynamodb struct {
+	henge.UniversalDao
+	spec *henge.DynamodbDaoSpec
+}
+
+// Delete implements SigningKeyDao.Delete.
+func (dao *SigningKeyDaoAwsDynamodb) Delete(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements SigningKeyDao.Create.
+func (dao *SigningKeyDaoAwsDynamodb) Create(bo *SigningKey) (bool, error) {
+	ubo := bo.sync().UniversalBo
+	if dao.spec != nil && dao.spec.PkPrefix != "" {
+		ubo.SetExtraAttr(dao.spec.PkPrefix, dao.spec.PkPrefixValue)
+	}
+	return dao.UniversalDao.Create(ubo)
+}
+
+// Get implements SigningKeyDao.Get.
+func (dao *SigningKeyDaoAwsDynamodb) Get(id string) (*SigningKey, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewSigningKeyFromUbo(ubo), err
+}
+
+// getN implements SigningKeyDao.getN.
+func (dao *SigningKeyDaoAwsDynamodb) getN(fromOffset, maxNumRows int) ([]*SigningKey, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*SigningKey, 0)
+	for _, ubo := range uboList {
+		bo := NewSigningKeyFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// GetAll implements SigningKeyDao.GetAll.
+func (dao *SigningKeyDaoAwsDynamodb) GetAll() ([]*SigningKey, error) {
+	return dao.getN(0, 0)
+}
+
+// Update implements SigningKeyDao.Update.
+func (dao *SigningKeyDaoAwsDynamodb) Update(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
@@ -0,0 +1,141 @@
+package signingkey
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/btnguyen2k/prom"
+)
+
+func _createAwsDynamodbConnect(t *testing.T, testName string) *prom.AwsDynamodbConnect {
+	awsRegion := strings.ReplaceAll(os.Getenv("AWS_REGION"), `"`, "")
+	awsAccessKeyId := strings.ReplaceAll(os.Getenv("AWS_ACCESS_KEY_ID"), `"`, "")
+	awsSecretAccessKey := strings.ReplaceAll(os.Getenv("AWS_SECRET_ACCESS_KEY"), `"`, "")
+	if awsRegion == "" || awsAccessKeyId == "" || awsSecretAccessKey == "" {
+		t.Skipf("%s skipped", testName)
+		return nil
+	}
+	cfg := &aws.Config{
+		Region:      aws.String(awsRegion),
+		Credentials: credentials.NewEnvCredentials(),
+	}
+	if awsDynamodbEndpoint := strings.ReplaceAll(os.Getenv("AWS_DYNAMODB_ENDPOINT"), `"`, ""); awsDynamodbEndpoint != "" {
+		cfg.Endpoint = aws.String(awsDynamodbEndpoint)
+		if strings.HasPrefix(awsDynamodbEndpoint, "http://") {
+			cfg.DisableSSL = aws.Bool(true)
+		}
+	}
+	adc, err := prom.NewAwsDynamodbConnect(cfg, nil, nil, 10000)
+	if err != nil {
+		t.Fatalf("%s/%s failed: %s", testName, "NewAwsDynamodbConnect", err)
+	}
+	return adc
+}
+
+const tableNameDynamodb = "exter_test_signing_key"
+
+var setupTestDynamodb = func(t *testing.T, testName string) {
+	testAdc = _createAwsDynamodbConnect(t, testName)
+	testAdc.DeleteTable(nil, tableNameDynamodb)
+	err := prom.AwsDynamodbWaitForTableStatus(testAdc, tableNameDynamodb, []string{""}, 1*time.Second, 10*time.Second)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	err = InitSigningKeyTableAwsDynamodb(testAdc, tableNameDynamodb)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestDynamodb = func(t *testing.T, testName string) {
+	if testAdc != nil {
+		defer func() {
+			defer func() { testAdc = nil }()
+			testAdc.Close()
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewSigningKeyDaoAwsDynamodb(t *testing.T) {
+	testName := "TestNewSigningKeyDaoAwsDynamodb"
+	teardownTest := setupTest(t, testName, setupTestDynamodb, teardownTestDynamodb)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoAwsDynamodb(testAdc, tableNameDynamodb)
+	if keyDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestSigningKeyDaoAwsDynamodb_Create(t *testing.T) {
+	testName := "TestSigningKeyDaoAwsDynamodb_Create"
+	teardownTest := setupTest(t, testName, setupTestDynamodb, teardownTestDynamodb)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoAwsDynamodb(testAdc, tableNameDynamodb)
+	doTestSigningKeyDao_Create(t, testName, keyDao)
+	items, err := testAdc.ScanItems(nil, tableNameDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("%s failed: expected 1 item inserted but received %#v", testName, len(items))
+	}
+}
+
+func TestSigningKeyDaoAwsDynamodb_Get(t *testing.T) {
+	testName := "TestSigningKeyDaoAwsDynamodb_Get"
+	teardownTest := setupTest(t, testName, setupTestDynamodb, teardownTestDynamodb)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoAwsDynamodb(testAdc, tableNameDynamodb)
+	doTestSigningKeyDao_Get(t, testName, keyDao)
+}
+
+func TestSigningKeyDaoAwsDynamodb_Delete(t *testing.T) {
+	testName := "TestSigningKeyDaoAwsDynamodb_Delete"
+	teardownTest := setupTest(t, testName, setupTestDynamodb, teardownTestDynamodb)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoAwsDynamodb(testAdc, tableNameDynamodb)
+	doTestSigningKeyDao_Delete(t, testName, keyDao)
+	items, err := testAdc.ScanItems(nil, tableNameDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("%s failed: expected 0 item inserted but received %#v", testName, len(items))
+	}
+}
+
+func TestSigningKeyDaoAwsDynamodb_Update(t *testing.T) {
+	testName := "TestSigningKeyDaoAwsDynamodb_Update"
+	teardownTest := setupTest(t, testName, setupTestDynamodb, teardownTestDynamodb)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoAwsDynamodb(testAdc, tableNameDynamodb)
+	doTestSigningKeyDao_Update(t, testName, keyDao)
+	items, err := testAdc.ScanItems(nil, tableNameDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("%s failed: expected 1 item inserted but received %#v", testName, len(items))
+	}
+}
+
+func TestSigningKeyDaoAwsDynamodb_GetAll(t *testing.T) {
+	testName := "TestSigningKeyDaoAwsDynamodb_GetAll"
+	teardownTest := setupTest(t, testName, setupTestDynamodb, teardownTestDynamodb)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoAwsDynamodb(testAdc, tableNameDynamodb)
+	doTestSigningKeyDao_GetAll(t, testName, keyDao)
+	items, err := testAdc.ScanItems(nil, tableNameDynamodb, nil, "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(items) != 10 {
+		t.Fatalf("%s failed: expected 10 items inserted but received %#v", testName, len(items))
+	}
+}
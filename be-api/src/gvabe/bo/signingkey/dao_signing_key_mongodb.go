@@ -0,0 +1,69 @@
+package signingkey
+
+import (
+	"strings"
+
+	"github.com/btnguyen2k/prom"
+
+	"github.com/btnguyen2k/henge"
+)
+
+// NewSigningKeyDaoMongo is helper method to create MongoDB-implementation of SigningKeyDao.
+func NewSigningKeyDaoMongo(mc *prom.MongoConnect, collectionName string) SigningKeyDao {
+	txMode := strings.Index(strings.ToLower(mc.GetUrl()), "replicaset=") > 0
+	dao := &SigningKeyDaoMongo{UniversalDao: henge.NewUniversalDaoMongo(mc, collectionName, txMode)}
+	return dao
+}
+
+// InitSigningKeyTableMongo is helper function to initialize MongoDB table (collection) to store signing key data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitSigningKeyTableMongo(mc *prom.MongoConnect, collectionName string) error {
+	return henge.InitMongoCollection(mc, collectionName)
+}
+
+// SigningKeyDaoMongo is MongoDB-implementation of SigningKeyDao.
+type SigningKeyDaoMongo struct {
+	henge.UniversalDao
+}
+
+// Delete implements SigningKeyDao.Delete.
+func (dao *SigningKeyDaoMongo) Delete(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements SigningKeyDao.Create.
+func (dao *SigningKeyDaoMongo) Create(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements SigningKeyDao.Get.
+func (dao *SigningKeyDaoMongo) Get(id string) (*SigningKey, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewSigningKeyFromUbo(ubo), err
+}
+
+// getN implements SigningKeyDao.getN.
+func (dao *SigningKeyDaoMongo) getN(fromOffset, maxNumRows int) ([]*SigningKey, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*SigningKey, 0)
+	for _, ubo := range uboList {
+		bo := NewSigningKeyFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// GetAll implements SigningKeyDao.GetAll.
+func (dao *SigningKeyDaoMongo) GetAll() ([]*SigningKey, error) {
+	return dao.getN(0, 0)
+}
+
+// Update implements SigningKeyDao.Update.
+func (dao *SigningKeyDaoMongo) Update(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
@@ -0,0 +1,101 @@
+package signingkey
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+)
+
+func _createMongoConnect(t *testing.T, testName string) *prom.MongoConnect {
+	mongoDb := strings.ReplaceAll(os.Getenv("MONGO_DB"), `"`, "")
+	mongoUrl := strings.ReplaceAll(os.Getenv("MONGO_URL"), `"`, "")
+	if mongoDb == "" || mongoUrl == "" {
+		t.Skipf("%s skipped", testName)
+		return nil
+	}
+	mongoPoolOpts := &prom.MongoPoolOpts{
+		ConnectTimeout:         5 * time.Second,
+		SocketTimeout:          7 * time.Second,
+		ServerSelectionTimeout: 11 * time.Second,
+	}
+	mc, err := prom.NewMongoConnectWithPoolOptions(mongoUrl, mongoDb, 10000, mongoPoolOpts)
+	if err != nil {
+		t.Fatalf("%s/%s failed: %s", testName, "NewMongoConnect", err)
+	}
+	return mc
+}
+
+const collectionNameMongo = "exter_test_signing_key"
+
+var setupTestMongo = func(t *testing.T, testName string) {
+	testMc = _createMongoConnect(t, testName)
+	testMc.GetCollection(collectionNameMongo).Drop(nil)
+	err := InitSigningKeyTableMongo(testMc, collectionNameMongo)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+var teardownTestMongo = func(t *testing.T, testName string) {
+	if testMc != nil {
+		defer func() {
+			defer func() { testMc = nil }()
+			testMc.Close(nil)
+		}()
+	}
+}
+
+/*----------------------------------------------------------------------*/
+
+func TestNewSigningKeyDaoMongo(t *testing.T) {
+	testName := "TestNewSigningKeyDaoMongo"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoMongo(testMc, collectionNameMongo)
+	if keyDao == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+}
+
+func TestSigningKeyDaoMongo_Create(t *testing.T) {
+	testName := "TestSigningKeyDaoMongo_Create"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoMongo(testMc, collectionNameMongo)
+	doTestSigningKeyDao_Create(t, testName, keyDao)
+}
+
+func TestSigningKeyDaoMongo_Get(t *testing.T) {
+	testName := "TestSigningKeyDaoMongo_Get"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoMongo(testMc, collectionNameMongo)
+	doTestSigningKeyDao_Get(t, testName, keyDao)
+}
+
+func TestSigningKeyDaoMongo_Delete(t *testing.T) {
+	testName := "TestSigningKeyDaoMongo_Delete"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoMongo(testMc, collectionNameMongo)
+	doTestSigningKeyDao_Delete(t, testName, keyDao)
+}
+
+func TestSigningKeyDaoMongo_Update(t *testing.T) {
+	testName := "TestSigningKeyDaoMongo_Update"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoMongo(testMc, collectionNameMongo)
+	doTestSigningKeyDao_Update(t, testName, keyDao)
+}
+
+func TestSigningKeyDaoMongo_GetAll(t *testing.T) {
+	testName := "TestSigningKeyDaoMongo_GetAll"
+	teardownTest := setupTest(t, testName, setupTestMongo, teardownTestMongo)
+	defer teardownTest(t)
+	keyDao := NewSigningKeyDaoMongo(testMc, collectionNameMongo)
+	doTestSigningKeyDao_GetAll(t, testName, keyDao)
+}
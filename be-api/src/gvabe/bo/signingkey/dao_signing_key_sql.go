@@ -0,0 +1,84 @@
+package signingkey
+
+import (
+	"fmt"
+
+	"github.com/btnguyen2k/prom"
+	"main/src/gvabe/bo"
+
+	"github.com/btnguyen2k/henge"
+)
+
+// NewSigningKeyDaoSql is helper method to create SQL-implementation of SigningKeyDao.
+func NewSigningKeyDaoSql(sqlc *prom.SqlConnect, tableName string) SigningKeyDao {
+	dao := &SigningKeyDaoSql{}
+	dao.UniversalDao = henge.NewUniversalDaoSql(sqlc, tableName, true, nil)
+	return dao
+}
+
+// InitSigningKeyTableSql is helper function to initialize SQL-based table to store signing key data.
+// This function also creates table indexes if needed.
+//
+// Available since v0.8.0.
+func InitSigningKeyTableSql(sqlc *prom.SqlConnect, tableName string) error {
+	switch sqlc.GetDbFlavor() {
+	case prom.FlavorPgSql:
+		return henge.InitPgsqlTable(sqlc, tableName, nil)
+	case prom.FlavorMsSql:
+		return henge.InitMssqlTable(sqlc, tableName, nil)
+	case prom.FlavorMySql:
+		return henge.InitMysqlTable(sqlc, tableName, nil)
+	case prom.FlavorOracle:
+		return henge.InitOracleTable(sqlc, tableName, nil)
+	case prom.FlavorSqlite:
+		return henge.InitSqliteTable(sqlc, tableName, nil)
+	case prom.FlavorCosmosDb:
+		return henge.InitCosmosdbCollection(sqlc, tableName, &henge.CosmosdbCollectionSpec{Pk: bo.CosmosdbPkName})
+	}
+	return fmt.Errorf("unsupported database type %v", sqlc.GetDbFlavor())
+}
+
+// SigningKeyDaoSql is SQL-implementation of SigningKeyDao.
+type SigningKeyDaoSql struct {
+	henge.UniversalDao
+}
+
+// Delete implements SigningKeyDao.Delete.
+func (dao *SigningKeyDaoSql) Delete(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Delete(bo.UniversalBo)
+}
+
+// Create implements SigningKeyDao.Create.
+func (dao *SigningKeyDaoSql) Create(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Create(bo.sync().UniversalBo)
+}
+
+// Get implements SigningKeyDao.Get.
+func (dao *SigningKeyDaoSql) Get(id string) (*SigningKey, error) {
+	ubo, err := dao.UniversalDao.Get(id)
+	return NewSigningKeyFromUbo(ubo), err
+}
+
+// getN implements SigningKeyDao.getN.
+func (dao *SigningKeyDaoSql) getN(fromOffset, maxNumRows int) ([]*SigningKey, error) {
+	uboList, err := dao.UniversalDao.GetN(fromOffset, maxNumRows, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*SigningKey, 0)
+	for _, ubo := range uboList {
+		bo := NewSigningKeyFromUbo(ubo)
+		result = append(result, bo)
+	}
+	return result, nil
+}
+
+// GetAll implements SigningKeyDao.GetAll.
+func (dao *SigningKeyDaoSql) GetAll() ([]*SigningKey, error) {
+	return dao.getN(0, 0)
+}
+
+// Update implements SigningKeyDao.Update.
+func (dao *SigningKeyDaoSql) Update(bo *SigningKey) (bool, error) {
+	return dao.UniversalDao.Update(bo.sync().UniversalBo)
+}
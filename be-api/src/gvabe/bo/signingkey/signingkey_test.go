@@ -0,0 +1,139 @@
+package signingkey
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/prom"
+)
+
+type TestSetupOrTeardownFunc func(t *testing.T, testName string)
+
+func setupTest(t *testing.T, testName string, extraSetupFunc, extraTeardownFunc TestSetupOrTeardownFunc) func(t *testing.T) {
+	if extraSetupFunc != nil {
+		extraSetupFunc(t, testName)
+	}
+	return func(t *testing.T) {
+		if extraTeardownFunc != nil {
+			extraTeardownFunc(t, testName)
+		}
+	}
+}
+
+var (
+	testAdc  *prom.AwsDynamodbConnect
+	testMc   *prom.MongoConnect
+	testSqlc *prom.SqlConnect
+)
+
+func doTestSigningKeyDao_Create(t *testing.T, testName string, keyDao SigningKeyDao) {
+	key := NewSigningKey(1357, "kid", "RS256", "encrypted-key")
+	ok, err := keyDao.Create(key)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+}
+
+func doTestSigningKeyDao_Get(t *testing.T, testName string, keyDao SigningKeyDao) {
+	_key := NewSigningKey(1357, "kid", "RS256", "encrypted-key")
+	keyDao.Create(_key)
+
+	if key, err := keyDao.Get("not_found"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if key != nil {
+		t.Fatalf("%s failed: key %s should not exist", testName, "not_found")
+	}
+
+	if key, err := keyDao.Get(_key.GetId()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if key == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := key.GetTagVersion(); v != 1357 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 1357, v)
+		}
+		if v := key.GetAlg(); v != "RS256" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "RS256", v)
+		}
+		if v := key.GetKeyData(); v != "encrypted-key" {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, "encrypted-key", v)
+		}
+		if v := key.GetStatus(); v != StatusNext {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, StatusNext, v)
+		}
+	}
+}
+
+func doTestSigningKeyDao_Delete(t *testing.T, testName string, keyDao SigningKeyDao) {
+	_key := NewSigningKey(1357, "kid", "RS256", "encrypted-key")
+	keyDao.Create(_key)
+	key, err := keyDao.Get(_key.GetId())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if key == nil {
+		t.Fatalf("%s failed: nil", testName)
+	}
+
+	ok, err := keyDao.Delete(key)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if !ok {
+		t.Fatalf("%s failed: cannot delete key [%s]", testName, key.GetId())
+	}
+
+	if key, err := keyDao.Get(_key.GetId()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if key != nil {
+		t.Fatalf("%s failed: key %s should not exist", testName, _key.GetId())
+	}
+}
+
+func doTestSigningKeyDao_Update(t *testing.T, testName string, keyDao SigningKeyDao) {
+	key := NewSigningKey(1357, "kid", "RS256", "encrypted-key")
+	keyDao.Create(key)
+
+	activatedAt := time.Now().Add(-time.Hour).Round(time.Second)
+	retiredAt := time.Now().Round(time.Second)
+	key.SetTagVersion(2468)
+	key.SetStatus(StatusRetired).SetActivatedAt(activatedAt).SetRetiredAt(retiredAt)
+	ok, err := keyDao.Update(key)
+	if err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+
+	if key, err := keyDao.Get(key.GetId()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if key == nil {
+		t.Fatalf("%s failed: nil", testName)
+	} else {
+		if v := key.GetTagVersion(); v != 2468 {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, 2468, v)
+		}
+		if v := key.GetStatus(); v != StatusRetired {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, StatusRetired, v)
+		}
+		if v := key.GetActivatedAt(); !v.Equal(activatedAt) {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, activatedAt, v)
+		}
+		if v := key.GetRetiredAt(); !v.Equal(retiredAt) {
+			t.Fatalf("%s failed: expected [%#v] but received [%#v]", testName, retiredAt, v)
+		}
+	}
+}
+
+func doTestSigningKeyDao_GetAll(t *testing.T, testName string, keyDao SigningKeyDao) {
+	statusList := []string{StatusNext, StatusActive, StatusRetired}
+	for i := 0; i < 10; i++ {
+		key := NewSigningKey(uint64(i), fmt.Sprintf("kid%d", i), "RS256", "encrypted-key")
+		keyDao.Create(key.SetStatus(statusList[i%3]))
+	}
+
+	keyList, err := keyDao.GetAll()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(keyList) != 10 {
+		t.Fatalf("%s failed: expected %#v keys but received %#v", testName, 10, len(keyList))
+	}
+}
@@ -4,6 +4,7 @@ package user
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/btnguyen2k/consu/reddo"
 	"main/src/gvabe/bo"
@@ -20,7 +21,8 @@ func NewUser(appVersion uint64, id string) *User {
 	}
 	user.
 		SetDisplayName(id).
-		SetAesKey(utils.RandomString(16))
+		SetAesKey(utils.RandomString(16)).
+		SetFirstSeenAt(time.Now())
 	return user.sync()
 }
 
@@ -41,6 +43,52 @@ func NewUserFromUbo(ubo *henge.UniversalBo) *User {
 	} else if v != nil {
 		user.SetDisplayName(v.(string))
 	}
+	if v, err := ubo.GetDataAttr(AttrUserPassword); err == nil && v != nil {
+		// since v0.8.0
+		pwd := &PasswordHash{}
+		js, _ := json.Marshal(v)
+		if err := json.Unmarshal(js, pwd); err == nil {
+			user.SetPassword(pwd)
+		}
+	}
+	if v, err := ubo.GetDataAttr(AttrUserMfa); err == nil && v != nil {
+		// since v0.8.0
+		mfa := &MfaSettings{}
+		js, _ := json.Marshal(v)
+		if err := json.Unmarshal(js, mfa); err == nil {
+			user.SetMfa(mfa)
+		}
+	}
+	// since v0.8.0: profile attributes
+	attrListStr := []string{AttrUserAvatarUrl, AttrUserLocale, AttrUserGivenName, AttrUserFamilyName, AttrUserLastLoginChannel}
+	setterListStr := []func(string) *User{user.SetAvatarUrl, user.SetLocale, user.SetGivenName, user.SetFamilyName, user.SetLastLoginChannel}
+	for i, attr := range attrListStr {
+		if v, err := ubo.GetDataAttrAs(attr, reddo.TypeString); err == nil && v != nil {
+			setterListStr[i](v.(string))
+		}
+	}
+	attrListTime := []string{AttrUserFirstSeenAt, AttrUserLastLoginAt}
+	setterListTime := []func(time.Time) *User{user.SetFirstSeenAt, user.SetLastLoginAt}
+	for i, attr := range attrListTime {
+		if v, err := ubo.GetDataAttrAs(attr, reddo.TypeTime); err == nil && v != nil {
+			setterListTime[i](v.(time.Time))
+		}
+	}
+	if v, err := ubo.GetDataAttr(AttrUserProviderIds); err == nil && v != nil {
+		pids := make(map[string]string)
+		js, _ := json.Marshal(v)
+		if err := json.Unmarshal(js, &pids); err == nil {
+			user.SetProviderIds(pids)
+		}
+	}
+	if v, err := ubo.GetDataAttr(AttrUserSessionsRevokedAt); err == nil && v != nil {
+		// since v0.8.0
+		revokedAt := make(map[string]time.Time)
+		js, _ := json.Marshal(v)
+		if err := json.Unmarshal(js, &revokedAt); err == nil {
+			user.SetSessionsRevokedAt(revokedAt)
+		}
+	}
 	return user.sync()
 }
 
@@ -48,14 +96,96 @@ const (
 	AttrUserUbo         = "_ubo"
 	AttrUserAesKey      = "aes"
 	AttrUserDisplayName = "dname"
+	AttrUserPassword    = "pwd" // available since v0.8.0
+	AttrUserMfa         = "mfa" // available since v0.8.0
+
+	// profile attributes, available since v0.8.0
+	AttrUserAvatarUrl        = "avatar"
+	AttrUserLocale           = "locale"
+	AttrUserGivenName        = "gname"
+	AttrUserFamilyName       = "fname"
+	AttrUserProviderIds      = "pids"
+	AttrUserFirstSeenAt      = "fsat"
+	AttrUserLastLoginAt      = "llat"
+	AttrUserLastLoginChannel = "llchan"
+
+	AttrUserSessionsRevokedAt = "srat" // available since v0.8.0
 )
 
+// SessionsRevokedAllApps is the key, in user's sessions-revoked-at map, of the revocation of user's sessions of all apps.
+//
+// available since v0.8.0
+const SessionsRevokedAllApps = "*"
+
+// PasswordHash captures a hashed password of a local (first-party) account, together with the metadata
+// needed to verify it and to decide if it should be re-hashed with stronger settings.
+//
+// available since v0.8.0
+type PasswordHash struct {
+	Algorithm string         `json:"alg"`              // hashing algorithm, e.g. "argon2id" or "bcrypt"
+	Params    map[string]int `json:"params,omitempty"` // algorithm's parameters (e.g. argon2id's iterations/memory/threads, bcrypt's cost)
+	Salt      string         `json:"salt,omitempty"`   // salt, base64-encoded (bcrypt embeds the salt in the hash)
+	Hash      string         `json:"hash"`             // the hash, base64-encoded (bcrypt: the modular crypt format string)
+	UpdatedAt time.Time      `json:"uat"`              // timestamp when the password was last set
+}
+
+func (ph *PasswordHash) clone() *PasswordHash {
+	if ph == nil {
+		return nil
+	}
+	clone := *ph
+	if ph.Params != nil {
+		clone.Params = make(map[string]int)
+		for k, v := range ph.Params {
+			clone.Params[k] = v
+		}
+	}
+	return &clone
+}
+
+// MfaSettings captures user's multi-factor authentication (TOTP) settings.
+//
+// available since v0.8.0
+type MfaSettings struct {
+	TotpSecret    string    `json:"totp"`             // TOTP secret, encrypted with server's key
+	Enabled       bool      `json:"enabled"`          // false while enrollment is waiting for confirmation
+	LastCounter   int64     `json:"last"`             // time-step of the last accepted OTP, an OTP can not be used twice
+	RecoveryCodes []string  `json:"rcodes,omitempty"` // hashes of unused recovery codes
+	EnabledAt     time.Time `json:"eat"`              // timestamp when MFA was enabled
+}
+
+func (mfa *MfaSettings) clone() *MfaSettings {
+	if mfa == nil {
+		return nil
+	}
+	clone := *mfa
+	if mfa.RecoveryCodes != nil {
+		clone.RecoveryCodes = append([]string{}, mfa.RecoveryCodes...)
+	}
+	return &clone
+}
+
 // User is the business object.
 // User inherits unique id from bo.UniversalBo. Email address is used to uniquely identify user (e.g. user-id is email address).
 type User struct {
 	*henge.UniversalBo `json:"_ubo"`
-	aesKey             string `json:"aes"`
-	displayName        string `json:"dname"`
+	aesKey             string        `json:"aes"`
+	displayName        string        `json:"dname"`
+	password           *PasswordHash `json:"pwd"` // (since v0.8.0) nil if user has no local password
+	mfa                *MfaSettings  `json:"mfa"` // (since v0.8.0) nil if user has not enrolled MFA
+
+	// profile attributes, available since v0.8.0
+	avatarUrl        string            `json:"avatar"` // url of user's avatar picture
+	locale           string            `json:"locale"` // user's preferred locale, e.g. "en-US"
+	givenName        string            `json:"gname"`  // user's given name
+	familyName       string            `json:"fname"`  // user's family name
+	providerIds      map[string]string `json:"pids"`   // subject ids issued to user by identity providers, indexed by provider name
+	firstSeenAt      time.Time         `json:"fsat"`   // timestamp when the user was first seen
+	lastLoginAt      time.Time         `json:"llat"`   // timestamp of user's last login
+	lastLoginChannel string            `json:"llchan"` // login channel of user's last login
+
+	// (since v0.8.0) sessions created before these timestamps (second precision) are revoked, indexed by app id (SessionsRevokedAllApps for all apps)
+	sessionsRevokedAt map[string]time.Time `json:"srat"`
 }
 
 // MarshalJSON implements json.encode.Marshaler.MarshalJSON.
@@ -67,6 +197,19 @@ func (u *User) MarshalJSON() ([]byte, error) {
 		bo.SerKeyAttrs: map[string]interface{}{
 			AttrUserAesKey:      u.GetAesKey(),
 			AttrUserDisplayName: u.GetDisplayName(),
+			AttrUserPassword:    u.GetPassword(),
+			AttrUserMfa:         u.GetMfa(),
+
+			AttrUserAvatarUrl:        u.GetAvatarUrl(),
+			AttrUserLocale:           u.GetLocale(),
+			AttrUserGivenName:        u.GetGivenName(),
+			AttrUserFamilyName:       u.GetFamilyName(),
+			AttrUserProviderIds:      u.GetProviderIds(),
+			AttrUserFirstSeenAt:      u.GetFirstSeenAt(),
+			AttrUserLastLoginAt:      u.GetLastLoginAt(),
+			AttrUserLastLoginChannel: u.GetLastLoginChannel(),
+
+			AttrUserSessionsRevokedAt: u.GetSessionsRevokedAt(),
 		},
 	}
 	return json.Marshal(m)
@@ -97,6 +240,71 @@ func (u *User) UnmarshalJSON(data []byte) error {
 		} else {
 			u.SetDisplayName(v)
 		}
+		u.SetPassword(nil)
+		if _attrs[AttrUserPassword] != nil {
+			// since v0.8.0
+			js, _ := json.Marshal(_attrs[AttrUserPassword])
+			pwd := &PasswordHash{}
+			if err := json.Unmarshal(js, pwd); err != nil {
+				return err
+			}
+			u.SetPassword(pwd)
+		}
+		u.SetMfa(nil)
+		if _attrs[AttrUserMfa] != nil {
+			// since v0.8.0
+			js, _ := json.Marshal(_attrs[AttrUserMfa])
+			mfa := &MfaSettings{}
+			if err := json.Unmarshal(js, mfa); err != nil {
+				return err
+			}
+			u.SetMfa(mfa)
+		}
+
+		// since v0.8.0: profile attributes
+		attrListStr := []string{AttrUserAvatarUrl, AttrUserLocale, AttrUserGivenName, AttrUserFamilyName, AttrUserLastLoginChannel}
+		setterListStr := []func(string) *User{u.SetAvatarUrl, u.SetLocale, u.SetGivenName, u.SetFamilyName, u.SetLastLoginChannel}
+		for i, attr := range attrListStr {
+			setterListStr[i]("")
+			if _attrs[attr] != nil {
+				if v, err := reddo.ToString(_attrs[attr]); err != nil {
+					return err
+				} else {
+					setterListStr[i](v)
+				}
+			}
+		}
+		attrListTime := []string{AttrUserFirstSeenAt, AttrUserLastLoginAt}
+		setterListTime := []func(time.Time) *User{u.SetFirstSeenAt, u.SetLastLoginAt}
+		for i, attr := range attrListTime {
+			setterListTime[i](time.Time{})
+			if _attrs[attr] != nil {
+				if v, err := reddo.ToTime(_attrs[attr]); err != nil {
+					return err
+				} else {
+					setterListTime[i](v)
+				}
+			}
+		}
+		u.SetProviderIds(nil)
+		if _attrs[AttrUserProviderIds] != nil {
+			js, _ := json.Marshal(_attrs[AttrUserProviderIds])
+			pids := make(map[string]string)
+			if err := json.Unmarshal(js, &pids); err != nil {
+				return err
+			}
+			u.SetProviderIds(pids)
+		}
+		u.SetSessionsRevokedAt(nil)
+		if _attrs[AttrUserSessionsRevokedAt] != nil {
+			// since v0.8.0
+			js, _ := json.Marshal(_attrs[AttrUserSessionsRevokedAt])
+			revokedAt := make(map[string]time.Time)
+			if err := json.Unmarshal(js, &revokedAt); err != nil {
+				return err
+			}
+			u.SetSessionsRevokedAt(revokedAt)
+		}
 	}
 
 	u.sync()
@@ -127,9 +335,262 @@ func (u *User) SetDisplayName(v string) *User {
 	return u
 }
 
+// GetPassword returns user's hashed local password, nil if user has no local password.
+// available since v0.8.0
+func (u *User) GetPassword() *PasswordHash {
+	return u.password.clone()
+}
+
+// SetPassword sets user's hashed local password, nil to remove it.
+// available since v0.8.0
+func (u *User) SetPassword(v *PasswordHash) *User {
+	u.password = v.clone()
+	return u
+}
+
+// HasPassword returns true if user has a local password.
+// available since v0.8.0
+func (u *User) HasPassword() bool {
+	return u.password != nil && u.password.Hash != ""
+}
+
+// GetMfa returns user's multi-factor authentication settings, nil if user has not enrolled MFA.
+// available since v0.8.0
+func (u *User) GetMfa() *MfaSettings {
+	return u.mfa.clone()
+}
+
+// SetMfa sets user's multi-factor authentication settings, nil to remove them.
+// available since v0.8.0
+func (u *User) SetMfa(v *MfaSettings) *User {
+	u.mfa = v.clone()
+	return u
+}
+
+// IsMfaEnabled returns true if user has enrolled and confirmed MFA.
+// available since v0.8.0
+func (u *User) IsMfaEnabled() bool {
+	return u.mfa != nil && u.mfa.Enabled && u.mfa.TotpSecret != ""
+}
+
+// GetAvatarUrl returns url of user's avatar picture.
+// available since v0.8.0
+func (u *User) GetAvatarUrl() string {
+	return u.avatarUrl
+}
+
+// SetAvatarUrl sets url of user's avatar picture.
+// available since v0.8.0
+func (u *User) SetAvatarUrl(v string) *User {
+	u.avatarUrl = strings.TrimSpace(v)
+	return u
+}
+
+// GetLocale returns user's preferred locale.
+// available since v0.8.0
+func (u *User) GetLocale() string {
+	return u.locale
+}
+
+// SetLocale sets user's preferred locale.
+// available since v0.8.0
+func (u *User) SetLocale(v string) *User {
+	u.locale = strings.TrimSpace(v)
+	return u
+}
+
+// GetGivenName returns user's given name.
+// available since v0.8.0
+func (u *User) GetGivenName() string {
+	return u.givenName
+}
+
+// SetGivenName sets user's given name.
+// available since v0.8.0
+func (u *User) SetGivenName(v string) *User {
+	u.givenName = strings.TrimSpace(v)
+	return u
+}
+
+// GetFamilyName returns user's family name.
+// available since v0.8.0
+func (u *User) GetFamilyName() string {
+	return u.familyName
+}
+
+// SetFamilyName sets user's family name.
+// available since v0.8.0
+func (u *User) SetFamilyName(v string) *User {
+	u.familyName = strings.TrimSpace(v)
+	return u
+}
+
+// GetProviderIds returns subject ids issued to user by identity providers, indexed by provider name.
+// available since v0.8.0
+func (u *User) GetProviderIds() map[string]string {
+	result := make(map[string]string)
+	for k, v := range u.providerIds {
+		result[k] = v
+	}
+	return result
+}
+
+// SetProviderIds sets subject ids issued to user by identity providers, indexed by provider name.
+// available since v0.8.0
+func (u *User) SetProviderIds(v map[string]string) *User {
+	u.providerIds = make(map[string]string)
+	for provider, subject := range v {
+		u.SetProviderId(provider, subject)
+	}
+	return u
+}
+
+// SetProviderId sets the subject id issued to user by an identity provider, empty subject to remove it.
+// available since v0.8.0
+func (u *User) SetProviderId(provider, subject string) *User {
+	if u.providerIds == nil {
+		u.providerIds = make(map[string]string)
+	}
+	provider, subject = strings.TrimSpace(strings.ToLower(provider)), strings.TrimSpace(subject)
+	if subject == "" {
+		delete(u.providerIds, provider)
+	} else if provider != "" {
+		u.providerIds[provider] = subject
+	}
+	return u
+}
+
+// GetFirstSeenAt returns the timestamp when the user was first seen.
+// available since v0.8.0
+func (u *User) GetFirstSeenAt() time.Time {
+	return u.firstSeenAt
+}
+
+// SetFirstSeenAt sets the timestamp when the user was first seen.
+// available since v0.8.0
+func (u *User) SetFirstSeenAt(v time.Time) *User {
+	u.firstSeenAt = u.RoundTimestamp(v)
+	return u
+}
+
+// GetLastLoginAt returns the timestamp of user's last login, zero if user has not logged in yet.
+// available since v0.8.0
+func (u *User) GetLastLoginAt() time.Time {
+	return u.lastLoginAt
+}
+
+// SetLastLoginAt sets the timestamp of user's last login.
+// available since v0.8.0
+func (u *User) SetLastLoginAt(v time.Time) *User {
+	u.lastLoginAt = u.RoundTimestamp(v)
+	return u
+}
+
+// GetLastLoginChannel returns the login channel of user's last login.
+// available since v0.8.0
+func (u *User) GetLastLoginChannel() string {
+	return u.lastLoginChannel
+}
+
+// SetLastLoginChannel sets the login channel of user's last login.
+// available since v0.8.0
+func (u *User) SetLastLoginChannel(v string) *User {
+	u.lastLoginChannel = strings.TrimSpace(strings.ToLower(v))
+	return u
+}
+
+// GetSessionsRevokedAt returns the timestamps user's sessions were revoked at, indexed by app id
+// (SessionsRevokedAllApps for all apps).
+// available since v0.8.0
+func (u *User) GetSessionsRevokedAt() map[string]time.Time {
+	result := make(map[string]time.Time)
+	for k, v := range u.sessionsRevokedAt {
+		result[k] = v
+	}
+	return result
+}
+
+// SetSessionsRevokedAt sets the timestamps user's sessions were revoked at, indexed by app id
+// (SessionsRevokedAllApps for all apps).
+// available since v0.8.0
+func (u *User) SetSessionsRevokedAt(v map[string]time.Time) *User {
+	u.sessionsRevokedAt = make(map[string]time.Time)
+	for appId, t := range v {
+		u.RevokeSessions(appId, t)
+	}
+	return u
+}
+
+// RevokeSessions revokes user's sessions of an app (all apps if appId is SessionsRevokedAllApps) created before the
+// timestamp. The timestamp is truncated to second, the precision of sessions' creation time (claim "iat"), so that
+// sessions created right after the revocation are not revoked. Revocations of individual apps superseded by a
+// revocation of all apps are removed.
+// available since v0.8.0
+func (u *User) RevokeSessions(appId string, t time.Time) *User {
+	if u.sessionsRevokedAt == nil {
+		u.sessionsRevokedAt = make(map[string]time.Time)
+	}
+	appId, t = strings.TrimSpace(strings.ToLower(appId)), t.Truncate(time.Second)
+	if appId == "" || t.Before(u.sessionsRevokedAt[appId]) {
+		return u
+	}
+	u.sessionsRevokedAt[appId] = t
+	if appId == SessionsRevokedAllApps {
+		for k, v := range u.sessionsRevokedAt {
+			if k != SessionsRevokedAllApps && !v.After(t) {
+				delete(u.sessionsRevokedAt, k)
+			}
+		}
+	}
+	return u
+}
+
+// IsSessionRevoked returns true if user's session of an app, created at the timestamp, has been revoked.
+// available since v0.8.0
+func (u *User) IsSessionRevoked(appId string, createdAt time.Time) bool {
+	for _, k := range []string{SessionsRevokedAllApps, strings.TrimSpace(strings.ToLower(appId))} {
+		if t, ok := u.sessionsRevokedAt[k]; ok && createdAt.Before(t) {
+			return true
+		}
+	}
+	return false
+}
+
 func (u *User) sync() *User {
 	u.SetDataAttr(AttrUserAesKey, u.aesKey)
 	u.SetDataAttr(AttrUserDisplayName, u.displayName)
+	if u.password != nil {
+		u.SetDataAttr(AttrUserPassword, u.password)
+	} else {
+		u.SetDataAttr(AttrUserPassword, nil)
+	}
+	if u.mfa != nil {
+		u.SetDataAttr(AttrUserMfa, u.mfa)
+	} else {
+		u.SetDataAttr(AttrUserMfa, nil)
+	}
+	u.SetDataAttr(AttrUserAvatarUrl, u.avatarUrl)
+	u.SetDataAttr(AttrUserLocale, u.locale)
+	u.SetDataAttr(AttrUserGivenName, u.givenName)
+	u.SetDataAttr(AttrUserFamilyName, u.familyName)
+	u.SetDataAttr(AttrUserLastLoginChannel, u.lastLoginChannel)
+	if len(u.providerIds) > 0 {
+		u.SetDataAttr(AttrUserProviderIds, u.providerIds)
+	} else {
+		u.SetDataAttr(AttrUserProviderIds, nil)
+	}
+	if len(u.sessionsRevokedAt) > 0 {
+		u.SetDataAttr(AttrUserSessionsRevokedAt, u.sessionsRevokedAt)
+	} else {
+		u.SetDataAttr(AttrUserSessionsRevokedAt, nil)
+	}
+	for attr, v := range map[string]time.Time{AttrUserFirstSeenAt: u.firstSeenAt, AttrUserLastLoginAt: u.lastLoginAt} {
+		if v.IsZero() {
+			u.SetDataAttr(attr, nil)
+		} else {
+			u.SetDataAttr(attr, v)
+		}
+	}
 	u.UniversalBo.Sync()
 	return u
 }
@@ -2,8 +2,10 @@ package user
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/btnguyen2k/henge"
 )
@@ -99,3 +101,165 @@ func TestUser_json(t *testing.T) {
 		}
 	}
 }
+
+func TestUser_password(t *testing.T) {
+	name := "TestUser_password"
+	user1 := NewUser(1357, "myid")
+	if user1.HasPassword() || user1.GetPassword() != nil {
+		t.Fatalf("%s failed: new user must not have password", name)
+	}
+	pwd := &PasswordHash{
+		Algorithm: "argon2id",
+		Params:    map[string]int{"t": 3, "m": 65536, "p": 2},
+		Salt:      "c2FsdA",
+		Hash:      "aGFzaA",
+		UpdatedAt: time.Now().Round(time.Second),
+	}
+	user1.SetPassword(pwd)
+	pwd.Params["t"] = 1
+	if !user1.HasPassword() || user1.GetPassword().Params["t"] != 3 {
+		t.Fatalf("%s failed: password must be copied", name)
+	}
+
+	js1, _ := json.Marshal(user1)
+	var user2 *User
+	if err := json.Unmarshal(js1, &user2); err != nil {
+		t.Fatalf("%s failed: %e", name, err)
+	}
+	pwd1, pwd2 := user1.GetPassword(), user2.GetPassword()
+	if pwd2 == nil || pwd1.Algorithm != pwd2.Algorithm || pwd1.Salt != pwd2.Salt || pwd1.Hash != pwd2.Hash ||
+		pwd1.Params["m"] != pwd2.Params["m"] || !pwd1.UpdatedAt.Equal(pwd2.UpdatedAt) {
+		t.Fatalf("%s failed: expected %#v but received %#v", name, pwd1, pwd2)
+	}
+
+	user2.SetPassword(nil)
+	if user2.HasPassword() {
+		t.Fatalf("%s failed: password must be removed", name)
+	}
+}
+
+func TestUser_mfa(t *testing.T) {
+	name := "TestUser_mfa"
+	user1 := NewUser(1357, "myid")
+	if user1.IsMfaEnabled() || user1.GetMfa() != nil {
+		t.Fatalf("%s failed: new user must not have MFA", name)
+	}
+	mfa := &MfaSettings{TotpSecret: "encrypted", LastCounter: 123, RecoveryCodes: []string{"code1", "code2"}}
+	user1.SetMfa(mfa)
+	if user1.IsMfaEnabled() {
+		t.Fatalf("%s failed: MFA has not been confirmed", name)
+	}
+	mfa.Enabled, mfa.EnabledAt = true, time.Now().Round(time.Second)
+	user1.SetMfa(mfa)
+	mfa.RecoveryCodes[0] = "changed"
+	if !user1.IsMfaEnabled() || user1.GetMfa().RecoveryCodes[0] != "code1" {
+		t.Fatalf("%s failed: MFA settings must be copied", name)
+	}
+
+	js1, _ := json.Marshal(user1)
+	var user2 *User
+	if err := json.Unmarshal(js1, &user2); err != nil {
+		t.Fatalf("%s failed: %e", name, err)
+	}
+	mfa1, mfa2 := user1.GetMfa(), user2.GetMfa()
+	if mfa2 == nil || mfa1.TotpSecret != mfa2.TotpSecret || mfa1.LastCounter != mfa2.LastCounter || !mfa1.EnabledAt.Equal(mfa2.EnabledAt) ||
+		len(mfa2.RecoveryCodes) != 2 || !user2.IsMfaEnabled() {
+		t.Fatalf("%s failed: expected %#v but received %#v", name, mfa1, mfa2)
+	}
+}
+
+func TestUser_profile(t *testing.T) {
+	name := "TestUser_profile"
+	user1 := NewUser(1357, "myid")
+	if user1.GetFirstSeenAt().IsZero() {
+		t.Fatalf("%s failed: first-seen must be set", name)
+	}
+	if !user1.GetLastLoginAt().IsZero() || len(user1.GetProviderIds()) != 0 {
+		t.Fatalf("%s failed: new user must not have logged in", name)
+	}
+	_lat := time.Now().Round(time.Second)
+	user1.SetAvatarUrl(" https://domain.com/avatar.png ").SetLocale("en-US").SetGivenName("Thanh").SetFamilyName("Nguyen").
+		SetProviderId("Google", "1234567890").SetProviderId("github", "42").SetLastLoginAt(_lat).SetLastLoginChannel("Google")
+	if v := user1.GetAvatarUrl(); v != "https://domain.com/avatar.png" {
+		t.Fatalf("%s failed: expected %#v but received %#v", name, "https://domain.com/avatar.png", v)
+	}
+	if v := user1.GetProviderIds(); !reflect.DeepEqual(v, map[string]string{"google": "1234567890", "github": "42"}) {
+		t.Fatalf("%s failed: received %#v", name, v)
+	}
+	user1.GetProviderIds()["github"] = "changed"
+	if v := user1.GetProviderIds()["github"]; v != "42" {
+		t.Fatalf("%s failed: provider ids must be copied", name)
+	}
+
+	js1, _ := json.Marshal(user1)
+	var user2 *User
+	if err := json.Unmarshal(js1, &user2); err != nil {
+		t.Fatalf("%s failed: %e", name, err)
+	}
+	for _, user := range []*User{user2, NewUserFromUbo(user1.UniversalBo)} {
+		if user.GetAvatarUrl() != user1.GetAvatarUrl() || user.GetLocale() != "en-US" || user.GetGivenName() != "Thanh" ||
+			user.GetFamilyName() != "Nguyen" || user.GetLastLoginChannel() != "google" {
+			t.Fatalf("%s failed: expected %#v but received %#v", name, user1, user)
+		}
+		if !reflect.DeepEqual(user.GetProviderIds(), user1.GetProviderIds()) {
+			t.Fatalf("%s failed: expected %#v but received %#v", name, user1.GetProviderIds(), user.GetProviderIds())
+		}
+		if !user.GetFirstSeenAt().Equal(user1.GetFirstSeenAt()) || !user.GetLastLoginAt().Equal(_lat) {
+			t.Fatalf("%s failed: expected %#v / %#v but received %#v / %#v", name,
+				user1.GetFirstSeenAt(), _lat, user.GetFirstSeenAt(), user.GetLastLoginAt())
+		}
+	}
+
+	user2.SetProviderId("github", "")
+	if _, ok := user2.GetProviderIds()["github"]; ok {
+		t.Fatalf("%s failed: provider id must be removed", name)
+	}
+}
+
+func TestUser_sessionsRevokedAt(t *testing.T) {
+	name := "TestUser_sessionsRevokedAt"
+	user1 := NewUser(1357, "myid")
+	now := time.Now().Truncate(time.Second)
+	if user1.IsSessionRevoked("app1", now.Add(-time.Hour)) || len(user1.GetSessionsRevokedAt()) != 0 {
+		t.Fatalf("%s failed: new user must not have revoked sessions", name)
+	}
+
+	// sessions of an app created before the revocation are revoked
+	user1.RevokeSessions("App1", now.Add(900*time.Millisecond))
+	if !user1.IsSessionRevoked("app1", now.Add(-time.Second)) || !user1.IsSessionRevoked("app1", now.Add(-time.Hour)) {
+		t.Fatalf("%s failed: sessions of app1 must be revoked", name)
+	}
+	// revocation is stored at second precision: sessions created within the same second (e.g. logging in again right
+	// after logging out) are not revoked
+	if v := user1.GetSessionsRevokedAt()["app1"]; !v.Equal(now) {
+		t.Fatalf("%s failed: expected %s but received %s", name, now, v)
+	}
+	if user1.IsSessionRevoked("app1", now) || user1.IsSessionRevoked("app1", now.Add(time.Second)) || user1.IsSessionRevoked("app2", now.Add(-time.Hour)) {
+		t.Fatalf("%s failed: later sessions and sessions of other apps must not be revoked", name)
+	}
+	// an older revocation does not override a newer one
+	user1.RevokeSessions("app1", now.Add(-time.Hour))
+	if v := user1.GetSessionsRevokedAt()["app1"]; !v.Equal(now) {
+		t.Fatalf("%s failed: expected %s but received %s", name, now, v)
+	}
+
+	js1, _ := json.Marshal(user1)
+	var user2 *User
+	if err := json.Unmarshal(js1, &user2); err != nil {
+		t.Fatalf("%s failed: %e", name, err)
+	}
+	for _, user := range []*User{user2, NewUserFromUbo(user1.UniversalBo)} {
+		if v := user.GetSessionsRevokedAt(); len(v) != 1 || !v["app1"].Equal(now) {
+			t.Fatalf("%s failed: expected %#v but received %#v", name, user1.GetSessionsRevokedAt(), v)
+		}
+	}
+
+	// revoking sessions of all apps supersedes revocations of individual apps
+	user2.RevokeSessions(SessionsRevokedAllApps, now.Add(time.Minute))
+	if !user2.IsSessionRevoked("app2", now.Add(time.Minute-time.Second)) || user2.IsSessionRevoked("app2", now.Add(time.Minute)) {
+		t.Fatalf("%s failed: sessions of all apps must be revoked", name)
+	}
+	if v := user2.GetSessionsRevokedAt(); len(v) != 1 || !v[SessionsRevokedAllApps].Equal(now.Add(time.Minute)) {
+		t.Fatalf("%s failed: received %#v", name, v)
+	}
+}
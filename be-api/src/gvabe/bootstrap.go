@@ -8,17 +8,18 @@ package gvabe
 
 import (
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
-	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/smtp"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
-
-	"golang.org/x/oauth2/google"
+	"time"
 
 	"main/src/goapi"
 )
@@ -43,32 +44,50 @@ func (b *MyBootstrapper) Bootstrap() error {
 	go startUpdateSystemInfo()
 
 	initRsaKeys()
-	initLoginChannels()
 	initExterHomeUrl()
-	initFacebookAppSecret()
-	initGithubClientSecret()
-	initGoogleClientSecret()
-	initLinkedinClientSecret()
+	initMailSender()
+	initPasswordHasher()
+	initMfa()
+	initWebauthn()
+	initLoginIdentitySettings()
+	initLoginWait()
+	initLoginSession()
+	initSessionRevocation()
+	initRefreshToken()
+	initOidcIssuer()
+	initLoginChannels(goapi.AppConfig)
 	// initCaches()
 	initDaos()
+	initKeyset()
+	initJobQueue()
 	initApiHandlers(goapi.ApiRouter)
 	initApiFilters(goapi.ApiRouter)
 	return nil
 }
 
+// initRsaKeys loads Exter's private key. Since v0.8.0, the key can also be an EC (P-256) or Ed25519 key, and the
+// algorithm tokens are signed with is configured at [gvabe.keys.signing_alg].
 func initRsaKeys() {
 	confKeyRsaPrivKeyFile := "gvabe.keys.rsa_privkey_file"
 	confKeyRsaPrivKeyPass := "gvabe.keys.rsa_privkey_passphrase"
+	confKeySigningAlg := "gvabe.keys.signing_alg"
+	signingAlg = normalizeSigningAlg(goapi.AppConfig.GetString(confKeySigningAlg))
+	if signingAlg == "" && goapi.AppConfig.GetString(confKeySigningAlg) != "" {
+		panic(fmt.Sprintf("unsupported signing algorithm [%s] at [%s]", goapi.AppConfig.GetString(confKeySigningAlg), confKeySigningAlg))
+	}
 	rsaPrivKeyFile := goapi.AppConfig.GetString(confKeyRsaPrivKeyFile)
 	if rsaPrivKeyFile == "" {
-		log.Println(fmt.Sprintf("[WARN] No RSA private key file configured at [%s], generating one...", confKeyRsaPrivKeyFile))
-		privKey, err := genRsaKey(2048)
+		if signingAlg == "" {
+			signingAlg = signingAlgRS256
+		}
+		log.Println(fmt.Sprintf("[WARN] No private key file configured at [%s], generating one (%s)...", confKeyRsaPrivKeyFile, signingAlg))
+		privKey, err := genSigningKey(signingAlg)
 		if err != nil {
 			panic(err)
 		}
-		rsaPrivKey = privKey
+		exterPrivKey = privKey
 	} else {
-		log.Println(fmt.Sprintf("[INFO] Loading RSA private key from [%s]...", rsaPrivKeyFile))
+		log.Println(fmt.Sprintf("[INFO] Loading private key from [%s]...", rsaPrivKeyFile))
 		content, err := ioutil.ReadFile(rsaPrivKeyFile)
 		if err != nil {
 			panic(err)
@@ -80,7 +99,7 @@ func initRsaKeys() {
 		var der []byte
 		passphrase := goapi.AppConfig.GetString(confKeyRsaPrivKeyPass)
 		if passphrase != "" {
-			log.Println("[INFO] RSA private key is pass-phrase protected")
+			log.Println("[INFO] Private key is pass-phrase protected")
 			if decrypted, err := x509.DecryptPEMBlock(block, []byte(passphrase)); err != nil {
 				panic(err)
 			} else {
@@ -89,43 +108,34 @@ func initRsaKeys() {
 		} else {
 			der = block.Bytes
 		}
-		if block.Type == "RSA PRIVATE KEY" {
-			if privKey, err := x509.ParsePKCS1PrivateKey(der); err != nil {
-				panic(err)
-			} else {
-				rsaPrivKey = privKey
-			}
-		} else if block.Type == "PRIVATE KEY" {
-			if privKey, err := x509.ParsePKCS8PrivateKey(der); err != nil {
-				panic(err)
-			} else {
-				rsaPrivKey = privKey.(*rsa.PrivateKey)
-			}
+		if privKey, err := parsePrivKeyPem(block.Type, der); err != nil {
+			panic(err)
+		} else {
+			exterPrivKey = privKey
+		}
+		if keyAlg := keySigningAlg(exterPrivKey); keyAlg == "" {
+			panic(fmt.Sprintf("unsupported private key in file [%s], expecting RSA, EC (P-256) or Ed25519 key", rsaPrivKeyFile))
+		} else if signingAlg != "" && signingAlg != keyAlg {
+			panic(fmt.Sprintf("private key in file [%s] cannot sign with algorithm [%s] (%s)", rsaPrivKeyFile, signingAlg, confKeySigningAlg))
 		}
 	}
-
-	rsaPubKey = &rsaPrivKey.PublicKey
-
-	pubBlockPKCS1 := pem.Block{
-		Type:    "RSA PUBLIC KEY",
-		Headers: nil,
-		Bytes:   x509.MarshalPKCS1PublicKey(rsaPubKey),
+	if signingAlg == "" {
+		signingAlg = keySigningAlg(exterPrivKey)
 	}
-	rsaPubKeyPemPKCS1 = pem.EncodeToMemory(&pubBlockPKCS1)
 
-	pubPKIX, _ := x509.MarshalPKIXPublicKey(rsaPubKey)
-	pubBlockPKIX := pem.Block{
-		Type:    "PUBLIC KEY",
-		Headers: nil,
-		Bytes:   pubPKIX,
+	if pubKey, ok := exterPrivKey.Public().(*rsa.PublicKey); ok {
+		rsaPubKey = pubKey
 	}
-	rsaPubKeyPemPKIX = pem.EncodeToMemory(&pubBlockPKIX)
 
 	if DEBUG {
-		log.Printf("[DEBUG] Exter public key: {Size: %d / Exponent: %d / Modulus: %x}",
-			rsaPubKey.Size()*8, rsaPubKey.E, rsaPubKey.N)
-		log.Printf("[DEBUG] Exter public key (PKCS1): %s", string(rsaPubKeyPemPKCS1))
-		log.Printf("[DEBUG] Exter public key (PKIX): %s", string(rsaPubKeyPemPKIX))
+		pubKeyPemPKCS1, pubKeyPemPKIX := publicKeyPems(exterPrivKey.Public())
+		log.Printf("[DEBUG] Exter signing algorithm: %s", signingAlg)
+		if rsaPubKey != nil {
+			log.Printf("[DEBUG] Exter public key: {Size: %d / Exponent: %d / Modulus: %x}",
+				rsaPubKey.Size()*8, rsaPubKey.E, rsaPubKey.N)
+			log.Printf("[DEBUG] Exter public key (PKCS1): %s", string(pubKeyPemPKCS1))
+		}
+		log.Printf("[DEBUG] Exter public key (PKIX): %s", string(pubKeyPemPKIX))
 	}
 }
 
@@ -135,14 +145,6 @@ func initRsaKeys() {
 // preLoginSessionCache = mico.NewMemoryCache(cacheConfig)
 // }
 
-func initLoginChannels() {
-	loginChannels := regexp.MustCompile("[,;\\s]+").Split(goapi.AppConfig.GetString("gvabe.login_channels"), -1)
-	for _, channel := range loginChannels {
-		channel = strings.TrimSpace(strings.ToLower(channel))
-		enabledLoginChannels[channel] = true
-	}
-}
-
 // available since v0.3.0
 func initExterHomeUrl() {
 	exterHomeUrl = strings.TrimSpace(goapi.AppConfig.GetString("gvabe.exter_home_url"))
@@ -154,124 +156,281 @@ func initExterHomeUrl() {
 	}
 }
 
-// available since v0.3.0
-func initFacebookAppSecret() {
-	if !enabledLoginChannels[loginChannelFacebook] {
+// initMailSender initializes the sender of emails sent by Exter (login links, password reset links, etc).
+//
+// available since v0.8.0
+func initMailSender() {
+	from := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.mail.from"))
+	senderType := strings.ToLower(strings.TrimSpace(goapi.AppConfig.GetString("gvabe.mail.sender")))
+	switch senderType {
+	case "":
 		return
+	case emailMailSenderSmtp:
+		host := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.mail.smtp.host"))
+		if host == "" || from == "" {
+			log.Println("[ERROR] No valid SMTP host or sender address defined at [gvabe.mail.smtp.host] and [gvabe.mail.from]")
+			return
+		}
+		port := goapi.AppConfig.GetInt32("gvabe.mail.smtp.port", 587)
+		sender := &smtpMailSender{addr: fmt.Sprintf("%s:%d", host, port), from: from}
+		if username := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.mail.smtp.username")); username != "" {
+			sender.auth = smtp.PlainAuth("", username, goapi.AppConfig.GetString("gvabe.mail.smtp.password"), host)
+		}
+		mailer = sender
+	case emailMailSenderFile, emailMailSenderLog:
+		dir, target := "", "log"
+		if senderType == emailMailSenderFile {
+			if dir = strings.TrimSpace(goapi.AppConfig.GetString("gvabe.mail.file.dir")); dir != "" {
+				target = dir
+			}
+		}
+		log.Println(fmt.Sprintf("[WARN] Emails are not sent but written to [%s], do not use this setting in production", target))
+		mailer = &fileMailSender{from: from, dir: dir}
+	default:
+		log.Println(fmt.Sprintf("[ERROR] Invalid mail sender [%s] at [gvabe.mail.sender], supported senders: %s, %s, %s", senderType, emailMailSenderSmtp, emailMailSenderFile, emailMailSenderLog))
+		return
+	}
+	if DEBUG {
+		log.Printf("[DEBUG] initMailSender: %s/%s", senderType, from)
+	}
+}
+
+// initPasswordHasher configures hashing of local passwords; passwords can be set (e.g. system app owner's)
+// even if the local login channel is disabled.
+//
+// available since v0.8.0
+func initPasswordHasher() {
+	algorithm := strings.ToLower(strings.TrimSpace(goapi.AppConfig.GetString("gvabe.password_hash.algorithm")))
+	if algorithm == "" {
+		algorithm = passwordAlgArgon2id
+	}
+	if algorithm != passwordAlgArgon2id && algorithm != passwordAlgBcrypt {
+		log.Println(fmt.Sprintf("[ERROR] Invalid password hashing algorithm [%s] at [gvabe.password_hash.algorithm], supported algorithms: %s, %s; falling back to %s",
+			algorithm, passwordAlgArgon2id, passwordAlgBcrypt, passwordAlgArgon2id))
+		algorithm = passwordAlgArgon2id
 	}
-	appId := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.facebook.app_id"))
-	if appId == "" {
-		log.Println("[ERROR] No valid Facebook app-id defined at [gvabe.channels.facebook.app_id]")
+	h := newPasswordHasher(algorithm)
+	if v := goapi.AppConfig.GetInt32("gvabe.password_hash.min_length", passwordDefaultMinLength); v > 0 {
+		h.minLength = int(v)
 	}
-	appSecret := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.facebook.app_secret"))
-	if appSecret == "" {
-		log.Println("[ERROR] No valid Facebook app-secret defined at [gvabe.channels.facebook.app_secret]")
+	if v := goapi.AppConfig.GetInt32("gvabe.password_hash.argon2id.time", passwordDefaultArgon2Time); v > 0 {
+		h.argon2Time = uint32(v)
 	}
-	fbOAuthConf.ClientID = appId
-	fbOAuthConf.ClientSecret = appSecret
-	fbOAuthConf.RedirectURL = exterHomeUrl
-	initFacebookApp(appId, appSecret)
-	if DEBUG && appId != "" && appSecret != "" {
-		log.Printf("[DEBUG] initFacebookAppSecret: %s/%s", appId, "***"+appSecret[len(appSecret)-4:])
+	if v := goapi.AppConfig.GetInt32("gvabe.password_hash.argon2id.memory", passwordDefaultArgon2Memory); v >= 8*int32(h.argon2Threads) {
+		h.argon2Memory = uint32(v)
+	}
+	if v := goapi.AppConfig.GetInt32("gvabe.password_hash.argon2id.threads", passwordDefaultArgon2Threads); v > 0 && v <= 255 {
+		h.argon2Threads = uint8(v)
+	}
+	if v := goapi.AppConfig.GetInt32("gvabe.password_hash.bcrypt.cost", int32(h.bcryptCost)); v >= 4 && v <= 31 {
+		h.bcryptCost = int(v)
+	}
+	pwdHasher = h
+	if DEBUG {
+		log.Printf("[DEBUG] initPasswordHasher: %s/%d/%d/%d/%d", h.algorithm, h.argon2Time, h.argon2Memory, h.argon2Threads, h.bcryptCost)
 	}
 }
 
-// available since v0.2.0
-func initGithubClientSecret() {
-	if !enabledLoginChannels[loginChannelGithub] {
-		return
+// initMfa configures multi-factor authentication. TOTP secrets are encrypted with key [gvabe.keys.mfa_key]; if not
+// configured, the key is derived from Exter's RSA private key (TOTP secrets become unreadable if the RSA key changes).
+//
+// available since v0.8.0
+func initMfa() {
+	key := goapi.AppConfig.GetString("gvabe.keys.mfa_key")
+	if key == "" {
+		log.Println("[WARN] No MFA key configured at [gvabe.keys.mfa_key], deriving one from the private key")
+		key = string(privKeyBytes(exterPrivKey))
 	}
-	clientId := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.github.client_id"))
-	if clientId == "" {
-		log.Println("[ERROR] No valid Github OAuth app client-id defined at [gvabe.channels.github.client_id]")
+	sum := sha256.Sum256([]byte(key))
+	mfaKey = sum[:]
+	mfaIssuer = strings.TrimSpace(goapi.AppConfig.GetString("gvabe.mfa.issuer"))
+	if mfaIssuer == "" {
+		mfaIssuer = goapi.AppConfig.GetString("app.name")
 	}
-	clientSecret := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.github.client_secret"))
-	if clientSecret == "" {
-		log.Println("[ERROR] No valid Github OAuth app client-secret defined at [gvabe.channels.github.client_secret]")
+	mfaLimiter = newEmailRateLimiter(
+		int(goapi.AppConfig.GetInt32("gvabe.mfa.failure_limit.max", mfaDefaultFailureMax)),
+		goapi.AppConfig.GetTimeDuration("gvabe.mfa.failure_limit.window", mfaDefaultFailureWindow))
+	if DEBUG {
+		log.Printf("[DEBUG] initMfa: %s", mfaIssuer)
+	}
+}
+
+// initLoginWait configures how API "waitLoginToken" waits for pre-login sessions to complete, see settings
+// [gvabe.login_wait].
+//
+// available since v0.8.0
+func initLoginWait() {
+	loginWaitTimeout = goapi.AppConfig.GetTimeDuration("gvabe.login_wait.timeout", loginWaitDefaultTimeout)
+	if loginWaitTimeout <= 0 {
+		loginWaitTimeout = loginWaitDefaultTimeout
 	}
-	githubOAuthConf.ClientID = clientId
-	githubOAuthConf.ClientSecret = clientSecret
-	// githubOAuthConf.RedirectURL = exterHomeUrl //[btnguyen2k-20200904]: do NOT set RedirectURL, or else we encounter error "oauth2: server response missing access_token"
-	if DEBUG && clientId != "" && clientSecret != "" {
-		log.Printf("[DEBUG] initGithubClientSecret: %s/%s", clientId, "***"+clientSecret[len(clientSecret)-4:])
+	loginWaitPollInterval = goapi.AppConfig.GetTimeDuration("gvabe.login_wait.poll_interval", loginWaitDefaultPollInterval)
+	if loginWaitPollInterval <= 0 {
+		loginWaitPollInterval = loginWaitDefaultPollInterval
+	}
+	if DEBUG {
+		log.Printf("[DEBUG] initLoginWait: %s / %s", loginWaitTimeout, loginWaitPollInterval)
 	}
 }
 
-func initGoogleClientSecret() {
-	if !enabledLoginChannels[loginChannelGoogle] {
-		return
+// initLoginSession configures how long login sessions of Exter's frontend can be renewed, see settings
+// [gvabe.login_session].
+//
+// available since v0.8.0
+func initLoginSession() {
+	loginSessionMaxLifetime = goapi.AppConfig.GetTimeDuration("gvabe.login_session.max_lifetime", loginSessionDefaultMaxLifetime)
+	if loginSessionMaxLifetime < 0 {
+		loginSessionMaxLifetime = 0
+	}
+	if DEBUG {
+		log.Printf("[DEBUG] initLoginSession: %s", loginSessionMaxLifetime)
 	}
-	clientSecretJson := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.google.client_secret_json"))
-	if clientSecretJson == "" {
-		log.Println("[INFO] No valid GoogleAPI client secret defined at [gvabe.channels.google.client_secret_json], falling back to {project_id, client_id, client_secret}")
+}
 
-		projectId := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.google.project_id"))
-		if projectId == "" {
-			log.Println("[ERROR] No valid GoogleAPI project-id defined at [gvabe.channels.google.project_id]")
-		}
-		clientId := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.google.client_id"))
-		if clientId == "" {
-			log.Println("[ERROR] No valid GoogleAPI client-id defined at [gvabe.channels.google.client_id]")
-		}
-		clientSecret := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.google.client_secret"))
-		if clientSecret == "" {
-			log.Println("[ERROR] No valid GoogleAPI client-secret defined at [gvabe.channels.google.client_secret]")
-		}
-		appDomainsJs, _ := json.Marshal([]string{exterHomeUrl})
+// initSessionRevocation configures how results of session revocation checks are cached, see settings
+// [gvabe.session_revocation].
+//
+// available since v0.8.0
+func initSessionRevocation() {
+	sessionRevocationCacheTtl = goapi.AppConfig.GetTimeDuration("gvabe.session_revocation.cache_ttl", sessionRevocationDefaultCacheTtl)
+	if sessionRevocationCacheTtl < 0 {
+		sessionRevocationCacheTtl = 0
+	}
+	if DEBUG {
+		log.Printf("[DEBUG] initSessionRevocation: %s", sessionRevocationCacheTtl)
+	}
+}
 
-		clientSecretJson = fmt.Sprintf(`{
-		  "type":"authorized_user",
-		  "web": {
-			"project_id": "%s",
-			"client_id": "%s",
-			"client_secret": "%s",
-			"auth_uri": "https://accounts.google.com/o/oauth2/auth",
-			"token_uri": "https://oauth2.googleapis.com/token",
-			"auth_provider_x509_cert_url": "https://www.googleapis.com/oauth2/v1/certs",
-			"redirect_uris": %s,
-			"javascript_origins": %s,
-			"access_type": "offline"
-		  }
-		}`, projectId, clientId, clientSecret, appDomainsJs, appDomainsJs)
+// initRefreshToken configures refresh tokens, see settings [gvabe.refresh_token].
+//
+// available since v0.8.0
+func initRefreshToken() {
+	refreshTokenTtl = goapi.AppConfig.GetTimeDuration("gvabe.refresh_token.ttl", refreshTokenDefaultTtl)
+	if refreshTokenTtl < 0 {
+		refreshTokenTtl = 0
+	}
+	refreshTokenMaxLifetime = goapi.AppConfig.GetTimeDuration("gvabe.refresh_token.max_lifetime", refreshTokenDefaultMaxLifetime)
+	if refreshTokenMaxLifetime < 0 {
+		refreshTokenMaxLifetime = 0
 	}
 	if DEBUG {
-		r := regexp.MustCompile(`(?s)"client_secret":\s*"(.*?)"`)
-		f := r.FindSubmatch([]byte(clientSecretJson))
-		if len(f) > 1 {
-			clientSecret := string(f[1])
-			clientSecret = "***" + clientSecret[len(clientSecret)-4:]
-			_clientSecretJson := r.ReplaceAllString(clientSecretJson, `"client_secret": "`+clientSecret+`"`)
-			log.Printf("[DEBUG] initGoogleClientSecret: %s", _clientSecretJson)
-		}
+		log.Printf("[DEBUG] initRefreshToken: %s / %s", refreshTokenTtl, refreshTokenMaxLifetime)
+	}
+}
+
+// initOidcIssuer configures Exter as an OpenID Connect provider, see settings [gvabe.oidc_issuer].
+//
+// available since v0.8.0
+func initOidcIssuer() {
+	oidcIssuerUrl = strings.TrimSuffix(strings.TrimSpace(goapi.AppConfig.GetString("gvabe.oidc_issuer.issuer")), "/")
+	if oidcIssuerUrl == "" {
+		oidcIssuerUrl = strings.TrimSuffix(exterHomeUrl, "/")
+	}
+	oidcIssuerAuthzRequestTtl = goapi.AppConfig.GetTimeDuration("gvabe.oidc_issuer.authorization_request_ttl", oidcIssuerDefaultAuthzRequestTtl)
+	if oidcIssuerAuthzRequestTtl <= 0 {
+		oidcIssuerAuthzRequestTtl = oidcIssuerDefaultAuthzRequestTtl
 	}
-	var err error
-	if googleOAuthConf, err = google.ConfigFromJSON([]byte(clientSecretJson)); err != nil {
-		panic(err)
+	oidcIssuerCodeTtl = goapi.AppConfig.GetTimeDuration("gvabe.oidc_issuer.authorization_code_ttl", oidcIssuerDefaultCodeTtl)
+	if oidcIssuerCodeTtl <= 0 {
+		oidcIssuerCodeTtl = oidcIssuerDefaultCodeTtl
+	}
+	if DEBUG {
+		log.Printf("[DEBUG] initOidcIssuer: %s / %s / %s", oidcIssuerUrl, oidcIssuerAuthzRequestTtl, oidcIssuerCodeTtl)
+	}
+}
+
+// initKeyset loads the keys Exter signs tokens with and starts their scheduled rotation, see settings
+// [gvabe.keys.rotation]. Private keys are stored encrypted with key [gvabe.keys.keyset_key]; if not configured, the key
+// is derived from Exter's RSA private key.
+//
+// available since v0.8.0
+func initKeyset() {
+	key := goapi.AppConfig.GetString("gvabe.keys.keyset_key")
+	if key == "" {
+		log.Println("[WARN] No keyset key configured at [gvabe.keys.keyset_key], deriving one from the private key")
+		key = string(privKeyBytes(exterPrivKey))
+	}
+	sum := sha256.Sum256([]byte(key))
+	signingKeys = newKeyset(signingKeyDao, sum[:], exterPrivKey, signingAlg,
+		goapi.AppConfig.GetBoolean("gvabe.keys.rotation.enabled", false),
+		goapi.AppConfig.GetTimeDuration("gvabe.keys.rotation.interval", keysetDefaultRotationInterval),
+		goapi.AppConfig.GetTimeDuration("gvabe.keys.rotation.grace_period", keysetDefaultGracePeriod),
+		goapi.AppConfig.GetTimeDuration("gvabe.keys.rotation.refresh_interval", keysetDefaultRefreshInterval))
+	if err := signingKeys.init(time.Now()); err != nil {
+		panic("error while loading signing keys: " + err.Error())
+	}
+	signingKeys.start()
+	if DEBUG {
+		log.Printf("[DEBUG] initKeyset: active key [%s] / rotation %v (%s, grace period %s)", signingKeys.signingKey().kid,
+			signingKeys.rotation, signingKeys.interval, signingKeys.gracePeriod)
 	}
 }
 
-// available since v0.5.0
-func initLinkedinClientSecret() {
-	if !enabledLoginChannels[loginChannelLinkedin] {
+// initJobQueue starts the queue running background jobs (e.g. fetching user's profile to complete a login), see
+// settings [gvabe.jobs].
+//
+// available since v0.8.0
+func initJobQueue() {
+	backgroundJobs = newJobQueue(jobDao,
+		int(goapi.AppConfig.GetInt32("gvabe.jobs.workers", jobDefaultWorkers)),
+		int(goapi.AppConfig.GetInt32("gvabe.jobs.max_attempts", jobDefaultMaxAttempts)),
+		goapi.AppConfig.GetTimeDuration("gvabe.jobs.backoff_base", jobDefaultBackoffBase),
+		goapi.AppConfig.GetTimeDuration("gvabe.jobs.backoff_max", jobDefaultBackoffMax),
+		goapi.AppConfig.GetTimeDuration("gvabe.jobs.lease", jobDefaultLease),
+		goapi.AppConfig.GetTimeDuration("gvabe.jobs.poll_interval", jobDefaultPollInterval),
+		goapi.AppConfig.GetTimeDuration("gvabe.jobs.retention", jobDefaultRetention))
+	backgroundJobs.register(jobKindLoginProfile, &jobHandler{run: runLoginProfileJob, onFail: failLoginProfileJob})
+	backgroundJobs.start()
+	if DEBUG {
+		log.Printf("[DEBUG] initJobQueue: %d workers / %d max attempts", cap(backgroundJobs.slots), backgroundJobs.maxAttempts)
+	}
+}
+
+// initWebauthn configures WebAuthn ceremonies, used by the "passkey" login channel and as second factor.
+// The relying party id and allowed origins default to the host and origin of [gvabe.exter_home_url].
+//
+// available since v0.8.0
+func initWebauthn() {
+	homeUrl, err := url.Parse(exterHomeUrl)
+	if err != nil {
+		log.Println(fmt.Sprintf("[ERROR] Cannot parse Exter home url [%s]: %s", exterHomeUrl, err))
 		return
 	}
-	clientId := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.linkedin.client_id"))
-	if clientId == "" {
-		log.Println("[ERROR] No valid LinkedIn OAuth app client-id defined at [gvabe.channels.linkedin.client_id]")
+	conf := &webauthnConfig{
+		rpId:             strings.ToLower(strings.TrimSpace(goapi.AppConfig.GetString("gvabe.webauthn.rp_id"))),
+		rpName:           strings.TrimSpace(goapi.AppConfig.GetString("gvabe.webauthn.rp_name")),
+		userVerification: strings.ToLower(strings.TrimSpace(goapi.AppConfig.GetString("gvabe.webauthn.user_verification"))),
+		timeout:          goapi.AppConfig.GetTimeDuration("gvabe.webauthn.timeout", webauthnDefaultTimeout),
+	}
+	if conf.rpId == "" {
+		conf.rpId = homeUrl.Hostname()
 	}
-	clientSecret := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.linkedin.client_secret"))
-	if clientSecret == "" {
-		log.Println("[ERROR] No valid LinkedIn OAuth app client-secret defined at [gvabe.channels.linkedin.client_secret]")
+	if conf.rpName == "" {
+		conf.rpName = goapi.AppConfig.GetString("app.name")
+	}
+	for _, origin := range regexp.MustCompile("[,;\\s]+").Split(goapi.AppConfig.GetString("gvabe.webauthn.origins"), -1) {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			conf.origins = append(conf.origins, origin)
+		}
 	}
-	redirectUri := strings.TrimSpace(goapi.AppConfig.GetString("gvabe.channels.linkedin.redirect_uri"))
-	if redirectUri == "" {
-		log.Println("[ERROR] No valid LinkedIn OAuth app redirect-uri defined at [gvabe.channels.linkedin.redirect_uri]")
-		redirectUri = exterHomeUrl
+	if len(conf.origins) == 0 {
+		conf.origins = []string{homeUrl.Scheme + "://" + homeUrl.Host}
 	}
-	linkedinOAuthConf.ClientID = clientId
-	linkedinOAuthConf.ClientSecret = clientSecret
-	linkedinOAuthConf.RedirectURL = redirectUri
-	if DEBUG && clientId != "" && clientSecret != "" {
-		log.Printf("[DEBUG] initLinkedinClientSecret: %s/%s/%s", clientId, "***"+clientSecret[len(clientSecret)-4:], redirectUri)
+	switch conf.userVerification {
+	case "":
+		conf.userVerification = webauthnUvPreferred
+	case webauthnUvRequired, webauthnUvPreferred, webauthnUvDiscouraged:
+	default:
+		log.Println(fmt.Sprintf("[ERROR] Invalid WebAuthn user verification [%s] at [gvabe.webauthn.user_verification], supported values: %s, %s, %s; falling back to %s",
+			conf.userVerification, webauthnUvRequired, webauthnUvPreferred, webauthnUvDiscouraged, webauthnUvPreferred))
+		conf.userVerification = webauthnUvPreferred
+	}
+	if conf.rpId == "" {
+		log.Println("[ERROR] No valid WebAuthn relying party id defined at [gvabe.webauthn.rp_id]")
+		return
+	}
+	webauthnConf = conf
+	if DEBUG {
+		log.Printf("[DEBUG] initWebauthn: %s/%s/%v/%s", conf.rpId, conf.rpName, conf.origins, conf.userVerification)
 	}
 }
@@ -1,13 +1,12 @@
 package gvabe
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"reflect"
 	"regexp"
-	"sort"
 	"strings"
 	"time"
 
@@ -16,6 +15,7 @@ import (
 
 	"main/src/goapi"
 	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/session"
 	"main/src/gvabe/bo/user"
 	"main/src/itineris"
 )
@@ -28,8 +28,42 @@ Setup API handlers: application register its api-handlers by calling router.SetH
 func initApiHandlers(router *itineris.ApiRouter) {
 	router.SetHandler("info", apiInfo)
 	router.SetHandler("login", apiLogin)
+	router.SetHandler("loginUrl", apiLoginUrl)
 	router.SetHandler("verifyLoginToken", apiVerifyLoginToken)
+	router.SetHandler("logout", apiLogout)
+	router.SetHandler("logoutAll", apiLogoutAll)
+	router.SetHandler("revokeAppSessions", apiRevokeAppSessions)
+	router.SetHandler("refreshLoginToken", apiRefreshLoginToken)
+	router.SetHandler("waitLoginToken", apiWaitLoginToken)
 	router.SetHandler("systemInfo", apiSystemInfo)
+	router.SetHandler("appleCallback", apiAppleCallback)
+	router.SetHandler("samlMetadata", apiSamlMetadata)
+	router.SetHandler("samlAcs", apiSamlAcs)
+	router.SetHandler("emailLoginVerify", apiEmailLoginVerify)
+	router.SetHandler("localRegister", apiLocalRegister)
+	router.SetHandler("localRegisterVerify", apiLocalRegisterVerify)
+	router.SetHandler("localRequestPasswordReset", apiLocalRequestPasswordReset)
+	router.SetHandler("localResetPassword", apiLocalResetPassword)
+	router.SetHandler("localChangePassword", apiLocalChangePassword)
+	router.SetHandler("verifyMfa", apiVerifyMfa)
+	router.SetHandler("mfaTotpEnroll", apiMfaTotpEnroll)
+	router.SetHandler("mfaTotpConfirm", apiMfaTotpConfirm)
+	router.SetHandler("mfaTotpDisable", apiMfaTotpDisable)
+	router.SetHandler("mfaRecoveryCodes", apiMfaRecoveryCodes)
+	router.SetHandler("webauthnRegisterBegin", apiWebauthnRegisterBegin)
+	router.SetHandler("webauthnRegisterFinish", apiWebauthnRegisterFinish)
+	router.SetHandler("webauthnLoginBegin", apiWebauthnLoginBegin)
+	router.SetHandler("webauthnCredentialList", apiWebauthnCredentialList)
+	router.SetHandler("webauthnCredentialDelete", apiWebauthnCredentialDelete)
+	router.SetHandler("identityList", apiIdentityList)
+	router.SetHandler("identityLink", apiIdentityLink)
+	router.SetHandler("identityUnlink", apiIdentityUnlink)
+	router.SetHandler("oidcDiscovery", apiOidcDiscovery)
+	router.SetHandler("oidcJwks", apiOidcJwks)
+	router.SetHandler("oidcAuthorize", apiOidcAuthorize)
+	router.SetHandler("oidcAuthorizeCallback", apiOidcAuthorizeCallback)
+	router.SetHandler("oidcToken", apiOidcToken)
+	router.SetHandler("oidcUserinfo", apiOidcUserinfo)
 
 	router.SetHandler("getApp", apiGetApp)
 	router.SetHandler("myAppList", apiMyAppList)
@@ -47,10 +81,41 @@ var (
 	// "true" means the API is free for public call
 	publicApis = map[string]bool{
 		"login":            false,
+		"loginUrl":         false, // since v0.8.0
 		"info":             true,
 		"getApp":           false,
 		"verifyLoginToken": true,
+		"waitLoginToken":   true, // since v0.8.0
 		"loginChannelList": true,
+		"appleCallback":    true, // since v0.8.0
+		"samlMetadata":     true, // since v0.8.0
+		"samlAcs":          true, // since v0.8.0
+		"emailLoginVerify": true, // since v0.8.0
+
+		"logout":            true, // since v0.8.0
+		"logoutAll":         true, // since v0.8.0
+		"revokeAppSessions": true, // since v0.8.0
+		"refreshLoginToken": true, // since v0.8.0
+
+		"localRegister":             false, // since v0.8.0
+		"localRegisterVerify":       true,  // since v0.8.0
+		"localRequestPasswordReset": false, // since v0.8.0
+		"localResetPassword":        false, // since v0.8.0
+
+		"verifyMfa":      false, // since v0.8.0
+		"mfaTotpEnroll":  false, // since v0.8.0
+		"mfaTotpConfirm": false, // since v0.8.0
+
+		"webauthnRegisterBegin":  false, // since v0.8.0
+		"webauthnRegisterFinish": false, // since v0.8.0
+		"webauthnLoginBegin":     false, // since v0.8.0
+
+		"oidcDiscovery":         true, // since v0.8.0
+		"oidcJwks":              true, // since v0.8.0
+		"oidcAuthorize":         true, // since v0.8.0
+		"oidcAuthorizeCallback": true, // since v0.8.0
+		"oidcToken":             true, // since v0.8.0
+		"oidcUserinfo":          true, // since v0.8.0
 	}
 )
 
@@ -70,6 +135,12 @@ func _parseLoginTokenFromApi(_token interface{}) (*itineris.ApiResult, *SessionC
 	} else if claim.Type != sessionTypeLogin {
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("invalid session type"), nil, nil
 	}
+	// since v0.8.0: sessions can be revoked before they expire
+	if err = checkSessionRevoked(claim); err == errorSessionRevoked {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error()), nil, nil
+	} else if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error()), nil, nil
+	}
 	if user, err = userDao.Get(claim.UserId); err != nil {
 		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error()), nil, nil
 	} else if user == nil {
@@ -104,20 +175,24 @@ func apiInfo(_ *itineris.ApiContext, _ *itineris.ApiAuth, _ *itineris.ApiParams)
 		"description": goapi.AppConfig.GetString("app.desc"),
 	}
 
-	loginChannels := make([]string, 0)
-	for channel, _ := range enabledLoginChannels {
-		loginChannels = append(loginChannels, channel)
-	}
-	sort.Strings(loginChannels)
+	// since v0.8.0: PEM keys are of the active signing key, all keys (including "next" and "retired" ones) are at "jwks_uri"
+	signingKey := signingKeys.signingKey()
+	pubKeyPemPKCS1, pubKeyPemPKIX := publicKeyPems(signingKey.pubKey)
 	result := map[string]interface{}{
-		"app":                appInfo,
-		"login_channels":     loginChannels,
-		"rsa_public_key":     string(rsaPubKeyPemPKCS1),
-		"public_key":         string(rsaPubKeyPemPKIX),
-		"facebook_app_id":    fbOAuthConf.ClientID,
-		"github_client_id":   githubOAuthConf.ClientID,
-		"google_client_id":   googleOAuthConf.ClientID,
-		"linkedin_client_id": linkedinOAuthConf.ClientID,
+		"app":            appInfo,
+		"login_channels": activeLoginChannelNames(),
+		"public_key":     string(pubKeyPemPKIX),
+		"alg":            signingKey.alg,
+		"kid":            signingKey.kid,
+		"jwks_uri":       oidcIssuerEndpoint(oidcIssuerPathJwks),
+	}
+	if pubKeyPemPKCS1 != nil {
+		// only RSA keys have PKCS1 form
+		result["rsa_public_key"] = string(pubKeyPemPKCS1)
+	}
+	// since v0.8.0: public settings of login channels (e.g. "google_client_id") are provided by the channels themselves
+	for _, name := range activeLoginChannelNames() {
+		mergeLoginChannelInfo(result, loginChannelRegistry[name].Info())
 	}
 
 	return itineris.NewApiResult(itineris.StatusOk).SetData(result)
@@ -131,185 +206,917 @@ func apiSystemInfo(_ *itineris.ApiContext, _ *itineris.ApiAuth, _ *itineris.ApiP
 
 /*------------------------------ login & session APIs ------------------------------*/
 
-func _doLoginFacebook(_ *itineris.ApiContext, _ *itineris.ApiAuth, accessToken string, app *app.App, returnUrl string) *itineris.ApiResult {
-	if DEBUG {
-		log.Printf("[DEBUG] START _doLoginFacebook")
-		t := time.Now().UnixNano()
-		defer func() {
-			d := time.Now().UnixNano() - t
-			log.Printf("[DEBUG] END _doLoginFacebook: %d ms", d/1000000)
-		}()
+/*
+apiAppleCallback handles Apple's form_post callback (API call "appleCallback").
+
+Apple posts the authorization result (code, state, user, error) to the redirect_uri; this API forwards it,
+as query parameters, to the configured callback url where client continues the login flow by calling the "login" API.
+
+Available since v0.8.0
+*/
+func apiAppleCallback(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if appleCallbackUrl == "" {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Apple login channel is not configured")
+	}
+	forwardParams := make(map[string]string)
+	for _, p := range []string{"code", "state", "user", "error"} {
+		forwardParams[p] = _extractParam(params, p, reddo.TypeString, "", nil).(string)
 	}
+	forwardParams["cba"] = loginChannelApple
+	redirectUrl, err := buildAppleCallbackRedirectUrl(appleCallbackUrl, forwardParams)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(redirectUrl)
+}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	// firstly exchange for long-live token
-	if token, err := fbExchangeForLongLiveToken(ctx, accessToken); err != nil {
+/*
+apiSamlMetadata handles API call "samlMetadata": it returns Exter's service provider metadata for a SAML identity provider.
+
+Available since v0.8.0
+*/
+func apiSamlMetadata(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	idpName := _extractParam(params, "idp", reddo.TypeString, "", nil).(string)
+	idp := samlIdps[idpName]
+	if idp == nil {
+		return itineris.NewApiResult(itineris.StatusNotFound).SetMessage(fmt.Sprintf("SAML identity provider [%s] not found", idpName))
+	}
+	data, err := idp.spMetadata()
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(&itineris.ApiResultRawContent{ContentType: "application/samlmetadata+xml", Content: data})
+}
+
+/*
+apiSamlAcs handles API call "samlAcs": SAML identity providers post their responses (SAMLResponse, RelayState) to this API.
+
+- The response is validated against the pre-login session identified by RelayState, which is then upgraded to a login session.
+- Upon successful, user is redirected to the return url passed to the "login" API (or Exter's home if none).
+
+Available since v0.8.0
+*/
+func apiSamlAcs(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	idpName := _extractParam(params, "idp", reddo.TypeString, "", nil).(string)
+	idp := samlIdps[idpName]
+	if idp == nil {
+		return itineris.NewApiResult(itineris.StatusNotFound).SetMessage(fmt.Sprintf("SAML identity provider [%s] not found", idpName))
+	}
+	samlResponse := _extractParam(params, "SAMLResponse", reddo.TypeString, "", nil).(string)
+	sessId := _extractParam(params, "RelayState", reddo.TypeString, "", nil).(string)
+	if samlResponse == "" || sessId == "" {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("SAMLResponse and RelayState are required")
+	}
+
+	// firstly load the pre-login session and validate the response against it
+	sess, sessData, err := loadSamlPreLoginSession(sessId, idpName)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	now := time.Now()
+	info, err := idp.parseResponse(samlResponse, sessData.RequestId, now)
+	if err != nil {
 		if DEBUG {
-			log.Printf("[DEBUG] ERROR _doLoginFacebook: %s / %s", "***"+accessToken[len(accessToken)-4:], err)
+			log.Printf("[DEBUG] ERROR apiSamlAcs(%s): %s", idpName, err)
 		}
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
-	} else if token == nil {
-		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
-	} else {
-		// secondly embed accessToken into exter's session as a JWT
-		js, _ := json.Marshal(token)
-		now := time.Now()
-		claims, err := genPreLoginClaims(&Session{
-			ClientId:  app.GetId(),
-			Channel:   loginChannelFacebook,
-			CreatedAt: now,
-			ExpiredAt: token.Expiry,
-			Data:      js, // JSON-serialization of oauth2.Token
-		})
-		if err != nil {
-			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+
+	// secondly resolve user account from the assertion
+	ident, err := loginIdentityFromSamlAssertion(idp, info)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	u, err := resolveLoginIdentity(ident, sess.ClientId, sess.LinkUserId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+
+	// lastly upgrade the pre-login session to login session
+	js, _ := json.Marshal(info)
+	sess.UserId = u.GetId()
+	sess.DisplayName = u.GetDisplayName()
+	sess.ExpiredAt = now.Add(loginSessionTtl * time.Second)
+	sess.Data = js
+	// if multi-factor authentication is required, client receives the pending token and is told so by API "verifyLoginToken"
+	claims, err := genLoginOrMfaClaims(sessId, sess)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	returnUrl := sessData.ReturnUrl
+	if returnUrl == "" {
+		returnUrl = exterHomeUrl
+	}
+	app, err := appDao.Get(sess.ClientId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if returnUrl, err = returnUrlWithLoginToken(app, returnUrl, jwt); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(returnUrl)
+}
+
+/*
+apiEmailLoginVerify handles API call "emailLoginVerify": user clicks the login link (containing parameter "token") emailed by the "login" API.
+
+- The link token is single-use; the pre-login session it refers to is upgraded to login session.
+- Upon successful, user is redirected to the return url passed to the "login" API (or Exter's home if none).
+
+Available since v0.8.0
+*/
+func apiEmailLoginVerify(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if emailConf == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Email login channel is not configured")
+	}
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	sessId, email, err := consumeLoginLink(token)
+	if err != nil {
+		if err == errorEmailInvalidLink {
+			return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
 		}
-		_, jwt, err := saveSession(claims)
-		if err != nil {
-			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	sess, err := loadPreLoginSession(sessId, loginChannelEmail)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(errorEmailInvalidLink.Error())
+	}
+	sessData := &emailSessionData{}
+	if err := json.Unmarshal(sess.Data, sessData); err != nil || sessData.Email != email {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(errorEmailInvalidLink.Error())
+	}
+
+	u, err := createUserAccountFromEmail(email)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	now := time.Now()
+	sess.UserId = u.GetId()
+	sess.DisplayName = u.GetDisplayName()
+	sess.ExpiredAt = now.Add(loginSessionTtl * time.Second)
+	// if multi-factor authentication is required, client receives the pending token and is told so by API "verifyLoginToken"
+	claims, err := genLoginOrMfaClaims(sessId, sess)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	returnUrl := sessData.ReturnUrl
+	if returnUrl == "" {
+		returnUrl = exterHomeUrl
+	}
+	app, err := appDao.Get(sess.ClientId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if returnUrl, err = returnUrlWithLoginToken(app, returnUrl, jwt); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(returnUrl)
+}
+
+// _localErrorResult converts errors returned by local account functions to API result.
+func _localErrorResult(err error) *itineris.ApiResult {
+	switch err {
+	case errorEmailInvalidAddress, errorPasswordTooShort, errorPasswordTooLong:
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(err.Error())
+	case errorEmailRateLimited:
+		return itineris.NewApiResult(itineris.StatusTooManyRequests).SetMessage(err.Error())
+	case errorEmailInvalidLink, errorLocalInvalidCredentials, errorLocalRegistrationDisabled, errorLocalAccountExists, errorLocalNoPassword:
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+}
+
+/*
+apiLocalRegister handles API call "localRegister": register a local account.
+This API expects an input map:
+
+	{
+		"email": user's email address, used as user id,
+		"password": user's password,
+		"display_name": (optional) user's display name,
+	}
+
+- A verification link is emailed to the address, the account is created when the link is clicked (API "localRegisterVerify").
+
+Available since v0.8.0
+*/
+func apiLocalRegister(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if localConf == nil || mailer == nil || localConf.verifyUrl == "" {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage(errorLocalRegistrationDisabled.Error())
+	}
+	email := _extractParam(params, "email", reddo.TypeString, "", nil).(string)
+	password := _extractParam(params, "password", reddo.TypeString, "", nil).(string)
+	displayName := _extractParam(params, "display_name", reddo.TypeString, "", nil).(string)
+	if err := localConf.register(email, password, strings.TrimSpace(displayName), time.Now()); err != nil {
+		result := _localErrorResult(err)
+		if result.Status == itineris.StatusErrorServer {
+			log.Printf("[ERROR] apiLocalRegister - error registering <%s>: %s", email, err)
 		}
-		// lastly use accessToken to fetch Facebook profile info
-		go goFetchFacebookProfile(claims.Id)
-		returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
-		return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+		return result
 	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Please check your mailbox to complete the registration")
 }
 
-func _doLoginGitHub(_ *itineris.ApiContext, _ *itineris.ApiAuth, authCode string, app *app.App, returnUrl string) *itineris.ApiResult {
-	if DEBUG {
-		log.Printf("[DEBUG] START _doLoginGitHub")
-		t := time.Now().UnixNano()
-		defer func() {
-			d := time.Now().UnixNano() - t
-			log.Printf("[DEBUG] END _doLoginGitHub: %d ms", d/1000000)
-		}()
+/*
+apiLocalRegisterVerify handles API call "localRegisterVerify": user clicks the verification link (containing parameter "token")
+emailed by the "localRegister" API.
+
+- Upon successful, the account is created and user is redirected to Exter's home to login.
+
+Available since v0.8.0
+*/
+func apiLocalRegisterVerify(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if localConf == nil {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("Local login channel is not configured")
 	}
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	if _, err := completeRegistration(token); err != nil {
+		return _localErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(exterHomeUrl)
+}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	// firstly exchange authCode for accessToken
-	if token, err := githubOAuthConf.Exchange(ctx, authCode, oauth2.AccessTypeOnline); err != nil {
-		if DEBUG {
-			log.Printf("[DEBUG] ERROR _doLoginGithub: %s / %s", "***"+authCode[len(authCode)-4:], err)
+/*
+apiLocalRequestPasswordReset handles API call "localRequestPasswordReset".
+This API expects an input map:
+
+	{
+		"email": user's email address,
+	}
+
+- A password reset link is emailed to the address if the account exists; the API returns the same result whether the account exists or not.
+
+Available since v0.8.0
+*/
+func apiLocalRequestPasswordReset(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if localConf == nil || mailer == nil || localConf.resetUrl == "" {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("Password reset is not available")
+	}
+	email := _extractParam(params, "email", reddo.TypeString, "", nil).(string)
+	if err := localConf.requestPasswordReset(email, time.Now()); err != nil {
+		if err != errorEmailInvalidAddress && err != errorEmailRateLimited {
+			log.Printf("[ERROR] apiLocalRequestPasswordReset - error sending password reset email to <%s>: %s", email, err)
+			// do not disclose that the account exists
+			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Cannot send password reset email, please try again later")
 		}
+		return _localErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("If the account exists, a password reset link has been sent to " + email)
+}
+
+/*
+apiLocalResetPassword handles API call "localResetPassword".
+This API expects an input map:
+
+	{
+		"token": the token from password reset link,
+		"password": the new password,
+	}
+
+Available since v0.8.0
+*/
+func apiLocalResetPassword(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if localConf == nil {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("Local login channel is not configured")
+	}
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	password := _extractParam(params, "password", reddo.TypeString, "", nil).(string)
+	if _, err := resetPassword(token, password); err != nil {
+		return _localErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Password has been reset, please login with the new password")
+}
+
+/*
+apiLocalChangePassword handles API call "localChangePassword".
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		"current_password": user's current password,
+		"password": the new password,
+	}
+
+Available since v0.8.0
+*/
+func apiLocalChangePassword(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	currentPassword := _extractParam(params, "current_password", reddo.TypeString, "", nil).(string)
+	password := _extractParam(params, "password", reddo.TypeString, "", nil).(string)
+	if err := changePassword(u, currentPassword, password); err != nil {
+		return _localErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Password has been changed")
+}
+
+// _mfaPendingResult builds the API result telling client that user must pass multi-factor authentication (see API "verifyMfa").
+//
+// available since v0.8.0
+func _mfaPendingResult(userId, appId, jwt string) *itineris.ApiResult {
+	status, err := mfaStatus(userId, appId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if status == "" {
+		// MFA was disabled meanwhile, user still needs to verify the current one
+		status = mfaStatusVerify
+	}
+	methods := make([]string, 0)
+	if u, err := userDao.Get(userId); err == nil && u != nil {
+		methods, _ = mfaMethods(u)
+	}
+	return itineris.NewApiResult(itineris.StatusUnauthorized).SetMessage("multi-factor authentication is required").
+		SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraMfa: status, apiResultExtraMfaMethods: methods})
+}
+
+// _mfaErrorResult converts errors returned by MFA functions to API result.
+func _mfaErrorResult(err error) *itineris.ApiResult {
+	switch err {
+	case errorMfaInvalidOtp, errorMfaNotEnrolled, errorMfaAlreadyEnabled:
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
-	} else if token == nil {
-		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
+	case errorMfaTooManyFailure:
+		return itineris.NewApiResult(itineris.StatusTooManyRequests).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+}
+
+// _completeMfaLogin upgrades a MFA-pending session to login session, returning the login token.
+func _completeMfaLogin(claims *SessionClaims, sess *Session, amr string) (string, *itineris.ApiResult) {
+	sess.Amr = mfaAmr(sess.Amr, amr)
+	sess.Acr = acrMultiFactor
+	loginClaims, err := genLoginClaims(claims.Id, sess)
+	if err != nil {
+		return "", itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(loginClaims)
+	if err != nil {
+		return "", itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return jwt, nil
+}
+
+// _mfaEnrollmentLoginToken returns the login token completing a login upon MFA enrollment, as handed over to the app
+// the session was issued to (see appLoginToken).
+func _mfaEnrollmentLoginToken(sess *Session, jwt string) (string, *itineris.ApiResult) {
+	app, err := appDao.Get(sess.ClientId)
+	if err != nil {
+		return "", itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if jwt, err = appLoginToken(app, jwt); err != nil {
+		return "", itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return jwt, nil
+}
+
+/*
+apiVerifyMfa handles API call "verifyMfa": user passes multi-factor authentication to complete login.
+This API expects an input map:
+
+	{
+		"token": the MFA-pending token (returned by apiLogin/apiVerifyLoginToken with status 401),
+		"otp": the one-time password generated by user's authenticator app,
+		"recovery_code": (alternative to "otp") one of user's unused recovery codes,
+		"credential": (alternative to "otp") WebAuthn assertion, the challenge is obtained from API "webauthnLoginBegin" with the same token,
+		"return_url": (optional) url to return to, must be allowed by the app,
+	}
+
+- Upon successful, this API returns the login token as JWT.
+
+Available since v0.8.0
+*/
+func apiVerifyMfa(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	claims, sess, u, err := loadMfaSession(token)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	otp := _extractParam(params, "otp", reddo.TypeString, "", nil).(string)
+	recoveryCode := _extractParam(params, "recovery_code", reddo.TypeString, "", nil).(string)
+	amr := amrOtp
+	if assertion := params.GetParam("credential"); assertion != nil && assertion != "" {
+		// since v0.8.0: WebAuthn credential as second factor
+		if errResult := _verifyMfaWebauthn(u, sess, assertion); errResult != nil {
+			return errResult
+		}
+		amr = amrHwk
 	} else {
-		now := time.Now()
-		/*
-			GitHub's OAuth token does not set expiry, which causes the token to be expired immediately.
-			Hence we need to explicitly set expiry.
-		*/
-		token.Expiry = now.Add(1 * time.Hour)
-		// secondly embed accessToken into exter's session as a JWT
-		js, _ := json.Marshal(token)
-		claims, err := genPreLoginClaims(&Session{
-			ClientId:  app.GetId(),
-			Channel:   loginChannelGithub,
-			CreatedAt: now,
-			ExpiredAt: token.Expiry,
-			Data:      js, // JSON-serialization of oauth2.Token
-		})
-		if err != nil {
+		if otp == "" && recoveryCode == "" {
+			return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("otp, recovery_code or credential is required")
+		}
+		if !u.IsMfaEnabled() {
+			return _mfaErrorResult(errorMfaNotEnrolled)
+		}
+		if err := mfaVerify(u, otp, recoveryCode, time.Now()); err != nil {
+			return _mfaErrorResult(err)
+		}
+		if otp == "" {
+			// RFC 8176 defines no method for recovery codes, the login is recorded as "mfa" only
+			amr = ""
+		}
+	}
+	jwt, errResult := _completeMfaLogin(claims, sess, amr)
+	if errResult != nil {
+		return errResult
+	}
+	token, returnUrl := jwt, ""
+	if app, err := appDao.Get(sess.ClientId); err == nil && app != nil {
+		returnUrl = _extractParam(params, "return_url", reddo.TypeString, "", nil).(string)
+		if token, returnUrl, err = appLoginResult(app, _appReturnUrl(app, returnUrl), jwt); err != nil {
 			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
 		}
-		_, jwt, err := saveSession(claims)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(token).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
+
+// _parseLoginOrMfaToken parses a login token, or a MFA-pending token of an user who must enroll MFA.
+func _parseLoginOrMfaToken(token string) (*itineris.ApiResult, *SessionClaims, *Session, *user.User) {
+	if claims, err := parseLoginToken(token); err == nil && claims.Type == sessionTypeMfa {
+		claims, sess, u, err := loadMfaSession(token)
 		if err != nil {
-			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+			return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error()), nil, nil, nil
 		}
-		// lastly use accessToken to fetch GitHub profile info
-		go goFetchGitHubProfile(claims.Id)
-		returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
-		return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+		return nil, claims, sess, u
 	}
+	errResult, claims, u := _parseLoginTokenFromApi(token)
+	return errResult, claims, nil, u
 }
 
-func _doLoginGoogle(_ *itineris.ApiContext, _ *itineris.ApiAuth, authCode string, app *app.App, returnUrl string) *itineris.ApiResult {
-	if DEBUG {
-		log.Printf("[DEBUG] START _doLoginGoogle")
-		t := time.Now().UnixNano()
-		defer func() {
-			d := time.Now().UnixNano() - t
-			log.Printf("[DEBUG] END _doLoginGoogle: %d ms", d/1000000)
-		}()
+// _checkMfaEnrollment verifies that a second factor can be enrolled with the token parsed by _parseLoginOrMfaToken:
+// a MFA-pending token can be used only if user has no second factor yet, otherwise user must pass MFA first.
+func _checkMfaEnrollment(claims *SessionClaims, sess *Session) *itineris.ApiResult {
+	if sess == nil {
+		return nil
+	}
+	status, err := mfaStatus(claims.UserId, claims.Audience)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
 	}
+	if status != mfaStatusEnroll {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("multi-factor authentication must be passed first")
+	}
+	return nil
+}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	// firstly exchange authCode for accessToken
-	if token, err := googleOAuthConf.Exchange(ctx, authCode, oauth2.AccessTypeOnline); err != nil {
-		if DEBUG {
-			log.Printf("[DEBUG] ERROR _doLoginGoogle: %s / %s", "***"+authCode[len(authCode)-4:], err)
+/*
+apiMfaTotpEnroll handles API call "mfaTotpEnroll": start enrolling TOTP-based multi-factor authentication.
+This API expects an input map:
+
+	{
+		"token": login token, or MFA-pending token if the app requires MFA but user has not enrolled,
+	}
+
+- Upon successful, this API returns {"secret": base32-encoded TOTP secret, "uri": "otpauth://" uri to render as QR code}.
+- Enrollment is completed by API "mfaTotpConfirm".
+
+Available since v0.8.0
+*/
+func apiMfaTotpEnroll(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	errResult, claims, sess, u := _parseLoginOrMfaToken(token)
+	if errResult != nil {
+		return errResult
+	}
+	if errResult := _checkMfaEnrollment(claims, sess); errResult != nil {
+		return errResult
+	}
+	secret, uri, err := mfaEnroll(u, mfaIssuer)
+	if err != nil {
+		return _mfaErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(map[string]interface{}{"secret": secret, "uri": uri})
+}
+
+/*
+apiMfaTotpConfirm handles API call "mfaTotpConfirm": confirm TOTP enrollment with an one-time password.
+This API expects an input map:
+
+	{
+		"token": the token passed to API "mfaTotpEnroll",
+		"otp": the one-time password generated by user's authenticator app,
+	}
+
+- Upon successful, MFA is enabled and this API returns {"recovery_codes": list of recovery codes, to be shown to user only once}.
+- If called with a MFA-pending token, login is also completed and the login token is returned as field "token".
+
+Available since v0.8.0
+*/
+func apiMfaTotpConfirm(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	errResult, claims, sess, u := _parseLoginOrMfaToken(token)
+	if errResult != nil {
+		return errResult
+	}
+	if errResult := _checkMfaEnrollment(claims, sess); errResult != nil {
+		return errResult
+	}
+	otp := _extractParam(params, "otp", reddo.TypeString, "", nil).(string)
+	codes, err := mfaConfirm(u, otp, time.Now())
+	if err != nil {
+		return _mfaErrorResult(err)
+	}
+	data := map[string]interface{}{"recovery_codes": codes}
+	if sess != nil {
+		jwt, errResult := _completeMfaLogin(claims, sess, amrOtp)
+		if errResult != nil {
+			return errResult
 		}
+		if jwt, errResult = _mfaEnrollmentLoginToken(sess, jwt); errResult != nil {
+			return errResult
+		}
+		data["token"] = jwt
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(data)
+}
+
+/*
+apiMfaTotpDisable handles API call "mfaTotpDisable".
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		"otp": the one-time password generated by user's authenticator app,
+		"recovery_code": (alternative to "otp") one of user's unused recovery codes,
+	}
+
+Available since v0.8.0
+*/
+func apiMfaTotpDisable(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	otp := _extractParam(params, "otp", reddo.TypeString, "", nil).(string)
+	recoveryCode := _extractParam(params, "recovery_code", reddo.TypeString, "", nil).(string)
+	if err := mfaVerify(u, otp, recoveryCode, time.Now()); err != nil {
+		return _mfaErrorResult(err)
+	}
+	u.SetMfa(nil)
+	if _, err := userDao.Update(u); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Multi-factor authentication has been disabled")
+}
+
+/*
+apiMfaRecoveryCodes handles API call "mfaRecoveryCodes": replace user's recovery codes with a new set.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		"otp": the one-time password generated by user's authenticator app,
+	}
+
+- Upon successful, this API returns the list of new recovery codes, to be shown to user only once.
+
+Available since v0.8.0
+*/
+func apiMfaRecoveryCodes(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	otp := _extractParam(params, "otp", reddo.TypeString, "", nil).(string)
+	if otp == "" {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("otp is required")
+	}
+	if err := mfaVerify(u, otp, "", time.Now()); err != nil {
+		return _mfaErrorResult(err)
+	}
+	codes, err := mfaRegenerateRecoveryCodes(u)
+	if err != nil {
+		return _mfaErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(codes)
+}
+
+/*----------------------------------------------------------------------*/
+
+// _webauthnErrorResult converts errors returned by WebAuthn functions to API result.
+func _webauthnErrorResult(err error) *itineris.ApiResult {
+	switch err {
+	case errorWebauthnInvalidCredential, errorWebauthnUnsupportedKey:
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(err.Error())
+	case errorWebauthnInvalidChallenge, errorWebauthnUnknownCredential, errorWebauthnCredentialExists, errorWebauthnSignCount:
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
-	} else if token == nil {
-		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
-	} else {
-		// secondly embed accessToken into exter's session as a JWT
-		js, _ := json.Marshal(token)
-		now := time.Now()
-		claims, err := genPreLoginClaims(&Session{
-			ClientId:  app.GetId(),
-			Channel:   loginChannelGoogle,
-			CreatedAt: now,
-			ExpiredAt: token.Expiry,
-			Data:      js, // JSON-serialization of oauth2.Token
-		})
-		if err != nil {
-			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	// other errors are verification failures (origin, relying party, signature, etc)
+	return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+}
+
+// _verifyMfaWebauthn verifies a WebAuthn assertion as the second factor of a MFA-pending session.
+func _verifyMfaWebauthn(u *user.User, sess *Session, assertion interface{}) *itineris.ApiResult {
+	if webauthnConf == nil {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("WebAuthn is not configured")
+	}
+	now := time.Now()
+	if mfaLimiter.exceeded(u.GetId(), now) {
+		return _mfaErrorResult(errorMfaTooManyFailure)
+	}
+	cred, _, err := webauthnConf.finishLogin(assertion, now)
+	if err == nil && cred.GetOwnerId() != u.GetId() {
+		err = errorWebauthnUnknownCredential
+	}
+	if err == nil && sess.Channel == loginChannelPasskey && cred.GetId() == string(sess.Data) {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("the credential used as first factor can not be used as second factor")
+	}
+	if err != nil {
+		mfaLimiter.allow(u.GetId(), now)
+		return _webauthnErrorResult(err)
+	}
+	return nil
+}
+
+/*
+apiWebauthnRegisterBegin handles API call "webauthnRegisterBegin": start registering a WebAuthn credential (passkey or security key).
+This API expects an input map:
+
+	{
+		"token": login token, or MFA-pending token if the app requires MFA but user has not enrolled,
+	}
+
+- Upon successful, this API returns the PublicKeyCredentialCreationOptions (binary fields base64url-encoded) to be passed to navigator.credentials.create().
+- Registration is completed by API "webauthnRegisterFinish".
+
+Available since v0.8.0
+*/
+func apiWebauthnRegisterBegin(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if webauthnConf == nil {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("WebAuthn is not configured")
+	}
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	errResult, claims, sess, u := _parseLoginOrMfaToken(token)
+	if errResult != nil {
+		return errResult
+	}
+	if errResult := _checkMfaEnrollment(claims, sess); errResult != nil {
+		return errResult
+	}
+	options, err := webauthnConf.beginRegistration(u, claims.Audience, time.Now())
+	if err != nil {
+		return _webauthnErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(options)
+}
+
+/*
+apiWebauthnRegisterFinish handles API call "webauthnRegisterFinish": complete registering a WebAuthn credential.
+This API expects an input map:
+
+	{
+		"token": the token passed to API "webauthnRegisterBegin",
+		"credential": the PublicKeyCredential returned by navigator.credentials.create() (binary fields base64url-encoded),
+		"name": (optional) user-friendly name of the credential,
+	}
+
+- Upon successful, this API returns {"id": credential's id, "name": credential's name}.
+- If called with a MFA-pending token, login is also completed and the login token is returned as field "token".
+
+Available since v0.8.0
+*/
+func apiWebauthnRegisterFinish(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if webauthnConf == nil {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("WebAuthn is not configured")
+	}
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	errResult, claims, sess, u := _parseLoginOrMfaToken(token)
+	if errResult != nil {
+		return errResult
+	}
+	if errResult := _checkMfaEnrollment(claims, sess); errResult != nil {
+		return errResult
+	}
+	name := _extractParam(params, "name", reddo.TypeString, "", nil).(string)
+	cred, err := webauthnConf.finishRegistration(u, params.GetParam("credential"), name, time.Now())
+	if err != nil {
+		return _webauthnErrorResult(err)
+	}
+	data := map[string]interface{}{"id": cred.GetId(), "name": cred.GetName()}
+	if sess != nil {
+		jwt, errResult := _completeMfaLogin(claims, sess, amrHwk)
+		if errResult != nil {
+			return errResult
 		}
-		_, jwt, err := saveSession(claims)
+		if jwt, errResult = _mfaEnrollmentLoginToken(sess, jwt); errResult != nil {
+			return errResult
+		}
+		data["token"] = jwt
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(data)
+}
+
+/*
+apiWebauthnLoginBegin handles API call "webauthnLoginBegin": start an authentication ceremony with a WebAuthn credential.
+This API expects an input map:
+
+	{
+		"token": (optional) MFA-pending token, if the credential is used as second factor (see API "verifyMfa"),
+	}
+
+- Without token, the ceremony is for passkey login (API "login" with source "passkey") and allowCredentials is empty (discoverable credentials).
+- Upon successful, this API returns the PublicKeyCredentialRequestOptions (binary fields base64url-encoded) to be passed to navigator.credentials.get().
+
+Available since v0.8.0
+*/
+func apiWebauthnLoginBegin(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	if webauthnConf == nil {
+		return itineris.NewApiResult(itineris.StatusNotImplemented).SetMessage("WebAuthn is not configured")
+	}
+	var u *user.User
+	appId := ""
+	if token := _extractParam(params, "token", reddo.TypeString, "", nil).(string); token != "" {
+		claims, _, mfaUser, err := loadMfaSession(token)
 		if err != nil {
-			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+			return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
 		}
-		// lastly use accessToken to fetch Google profile info
-		go goFetchGoogleProfile(claims.Id)
-		returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
-		return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+		u, appId = mfaUser, claims.Audience
+	} else if !enabledLoginChannels[loginChannelPasskey] {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Login source is not supported: %s", loginChannelPasskey))
+	}
+	options, err := webauthnConf.beginLogin(u, appId, time.Now())
+	if err != nil {
+		return _webauthnErrorResult(err)
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(options)
+}
+
+/*
+apiWebauthnCredentialList handles API call "webauthnCredentialList": list WebAuthn credentials registered by current user.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+	}
+
+Available since v0.8.0
+*/
+func apiWebauthnCredentialList(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	credList, err := credentialDao.GetUserCredentials(u)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	result := make([]map[string]interface{}, 0, len(credList))
+	for _, cred := range credList {
+		result = append(result, map[string]interface{}{
+			"id":         cred.GetId(),
+			"name":       cred.GetName(),
+			"transports": cred.GetTransports(),
+			"created":    cred.GetTimeCreated(),
+			"last_used":  cred.GetLastUsed(),
+		})
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(result)
+}
+
+/*
+apiWebauthnCredentialDelete handles API call "webauthnCredentialDelete": remove a WebAuthn credential of current user.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		"id": credential's id (returned by API "webauthnCredentialList"),
+	}
+
+Available since v0.8.0
+*/
+func apiWebauthnCredentialDelete(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	id := _extractParam(params, "id", reddo.TypeString, "", nil).(string)
+	cred, err := credentialDao.Get(id)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if cred == nil || cred.GetOwnerId() != u.GetId() {
+		return itineris.NewApiResult(itineris.StatusNotFound).SetMessage(fmt.Sprintf("Credential [%s] not found", id))
+	}
+	if _, err := credentialDao.Delete(cred); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Credential has been removed")
+}
+
+/*
+apiIdentityList handles API call "identityList": list external identities linked to current user.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+	}
+
+Available since v0.8.0
+*/
+func apiIdentityList(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	identList, err := identityDao.GetUserIdentities(u)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	result := make([]map[string]interface{}, 0, len(identList))
+	for _, ident := range identList {
+		result = append(result, map[string]interface{}{
+			"id":        ident.GetId(),
+			"provider":  ident.GetProvider(),
+			"subject":   ident.GetSubject(),
+			"email":     ident.GetEmail(),
+			"linked_at": ident.GetLinkedAt(),
+		})
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(result)
+}
+
+/*
+apiIdentityLink handles API call "identityLink": link an external identity to current user.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		...: parameters of API "login" (app, source, code, etc) to authenticate with the identity to link,
+	}
+
+- The login flow of the specified channel is carried out as with API "login", result of this API is that of API "login".
+- The authenticated identity is linked to current user instead of being looked up.
+- Linking fails if the identity is already linked to another user.
+
+Available since v0.8.0
+*/
+func apiIdentityLink(ctx *itineris.ApiContext, auth *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	source := _extractParam(params, "source", reddo.TypeString, "", nil).(string)
+	switch strings.TrimSpace(strings.ToLower(source)) {
+	case loginChannelEmail, loginChannelLocal, loginChannelPasskey:
+		// these channels authenticate Exter accounts, not external identities
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Login source does not support identity linking: %s", source))
 	}
+	ctx.SetContextValue(ctxFieldLinkUserId, u.GetId())
+	defer ctx.RemoveContextValue(ctxFieldLinkUserId)
+	return apiLogin(ctx, auth, params)
 }
 
-func _doLoginLinkedin(_ *itineris.ApiContext, _ *itineris.ApiAuth, authCode string, app *app.App, returnUrl string) *itineris.ApiResult {
-	if DEBUG {
-		log.Printf("[DEBUG] START _doLoginLinkedin")
-		t := time.Now().UnixNano()
-		defer func() {
-			d := time.Now().UnixNano() - t
-			log.Printf("[DEBUG] END _doLoginLinkedin: %d ms", d/1000000)
-		}()
+/*
+apiIdentityUnlink handles API call "identityUnlink": unlink an external identity from current user.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		"id": identity's id (returned by API "identityList"),
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	// firstly exchange authCode for accessToken
-	if token, err := linkedinOAuthConf.Exchange(ctx, authCode, oauth2.AccessTypeOnline); err != nil {
-		if DEBUG {
-			log.Printf("[DEBUG] ERROR _doLoginLinkedin: %s / %s", "***"+authCode[len(authCode)-4:], err)
-		}
-		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
-	} else if token == nil {
-		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
-	} else {
-		now := time.Now()
-		// secondly embed accessToken into exter's session as a JWT
-		js, _ := json.Marshal(token)
-		claims, err := genPreLoginClaims(&Session{
-			ClientId:  app.GetId(),
-			Channel:   loginChannelLinkedin,
-			CreatedAt: now,
-			ExpiredAt: token.Expiry,
-			Data:      js, // JSON-serialization of oauth2.Token
-		})
-		if err != nil {
-			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
-		}
-		_, jwt, err := saveSession(claims)
-		if err != nil {
+Available since v0.8.0
+*/
+func apiIdentityUnlink(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	id := _extractParam(params, "id", reddo.TypeString, "", nil).(string)
+	ident, err := identityDao.Get(id)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if ident == nil || ident.GetOwnerId() != u.GetId() {
+		return itineris.NewApiResult(itineris.StatusNotFound).SetMessage(fmt.Sprintf("Identity [%s] not found", id))
+	}
+	if _, err := identityDao.Delete(ident); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if u.GetProviderIds()[ident.GetProvider()] == ident.GetSubject() {
+		u.SetProviderId(ident.GetProvider(), "")
+		if _, err := userDao.Update(u); err != nil {
 			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
 		}
-		// lastly use accessToken to fetch LinkedIn profile info
-		go goFetchLinkedInProfile(claims.Id)
-		returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
-		return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
 	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Identity has been unlinked")
 }
 
 /*
@@ -329,7 +1136,7 @@ func apiLogin(ctx *itineris.ApiContext, auth *itineris.ApiAuth, params *itineris
 	}
 
 	requestReturnUrl := _extractParam(params, "return_url", reddo.TypeString, "", nil)
-	if returnUrl := app.GenerateReturnUrl(requestReturnUrl.(string)); returnUrl == "" && requestReturnUrl != "" {
+	if returnUrl := _appReturnUrl(app, requestReturnUrl.(string)); returnUrl == "" && requestReturnUrl != "" {
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Return url [%s] is not allowed for app [%s]", requestReturnUrl, appId))
 	} else {
 		requestReturnUrl = returnUrl
@@ -337,21 +1144,51 @@ func apiLogin(ctx *itineris.ApiContext, auth *itineris.ApiAuth, params *itineris
 
 	source := _extractParam(params, "source", reddo.TypeString, "", nil)
 
-	switch strings.ToLower(source.(string)) {
-	case loginChannelGoogle:
-		authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
-		return _doLoginGoogle(ctx, auth, authCode.(string), app, requestReturnUrl.(string))
-	case loginChannelGithub:
-		authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
-		return _doLoginGitHub(ctx, auth, authCode.(string), app, requestReturnUrl.(string))
-	case loginChannelFacebook:
-		authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
-		return _doLoginFacebook(ctx, auth, authCode.(string), app, requestReturnUrl.(string))
-	case loginChannelLinkedin:
-		authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
-		return _doLoginLinkedin(ctx, auth, authCode.(string), app, requestReturnUrl.(string))
+	ch := lookupLoginChannel(source.(string))
+	if ch == nil {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Login source is not supported: %s", source))
+	}
+	if handler, ok := ch.(LoginHandler); ok {
+		return handler.Login(ctx, auth, params, app, requestReturnUrl.(string))
+	}
+	return _doLoginChannel(ctx, ch, params, app, requestReturnUrl.(string))
+}
+
+/*
+apiLoginUrl handles API call "loginUrl".
+This API expects an input map:
+
+	{
+		"source": name of the login channel,
+		"state": opaque value to be passed back to the redirect uri,
+		"nonce": (optional) nonce to be embedded into the id_token (OpenID Connect channels),
+		"code_challenge": (optional) PKCE code challenge, method S256,
+	}
+
+- Upon successful, this API returns the url user is redirected to in order to authenticate with the login channel.
+
+Available since v0.8.0
+*/
+func apiLoginUrl(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	source := _extractParam(params, "source", reddo.TypeString, "", nil)
+	ch := lookupLoginChannel(source.(string))
+	if ch == nil {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Login source is not supported: %s", source))
 	}
-	return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Login source is not supported: %s", source))
+	state := _extractParam(params, "state", reddo.TypeString, "", nil)
+	opts := make([]oauth2.AuthCodeOption, 0)
+	if nonce := _extractParam(params, "nonce", reddo.TypeString, "", nil); nonce != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce.(string)))
+	}
+	if codeChallenge := _extractParam(params, "code_challenge", reddo.TypeString, "", nil); codeChallenge != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_challenge", codeChallenge.(string)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+	}
+	authUrl := ch.AuthUrl(state.(string), opts...)
+	if authUrl == "" {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Login source does not support redirect: %s", source))
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(authUrl)
 }
 
 /*
@@ -361,55 +1198,497 @@ This API expects an input map:
 	{
 		"token": login token (returned by apiLogin/apiVerifyLoginToken),
 		"app": application's id,
+		"refresh_token": (since v0.8.0, optional) true to also issue a refresh token, see API "refreshLoginToken",
 	}
 
 - Upon successful, this API returns the login-token.
+- (since v0.8.0) The login-token must have been issued to the app.
+- (since v0.8.0) If requested, the refresh token is returned as extra field "refresh_token". A login session can be issued at most one refresh token.
+- (since v0.8.0) If multi-factor authentication is required, this API returns status 401 with the pending token as data and extra field "mfa" ("verify" or "enroll"), see API "verifyMfa".
 */
 func apiVerifyLoginToken(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	claims, app, returnUrl, errResult := _verifyLoginTokenAndApp(params)
+	if errResult != nil {
+		return errResult
+	}
+
+	// thirdly verify the session
+	sess, err := sessionDao.Get(claims.Id)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	withRefreshToken := _extractParam(params, "refresh_token", reddo.TypeBool, false, nil).(bool)
+	return _loginSessionResult(sess, app, returnUrl, withRefreshToken)
+}
+
+/*
+apiWaitLoginToken handles API call "waitLoginToken": same as API "verifyLoginToken", but if the login is still being
+processed (pre-login session), this API does not return immediately and waits until the login completes or fails.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin),
+		"app": application's id,
+		"timeout": (optional) number of seconds to wait, capped by setting [gvabe.login_wait.timeout],
+		"refresh_token": (optional) true to also issue a refresh token, see API "verifyLoginToken",
+	}
+
+- If the login is still being processed when the timeout is reached, this API returns status 302, client should call it again.
+- The session is polled from storage, hence the login can be completed by any Exter instance sharing the same database.
+
+available since v0.8.0
+*/
+func apiWaitLoginToken(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	claims, app, returnUrl, errResult := _verifyLoginTokenAndApp(params)
+	if errResult != nil {
+		return errResult
+	}
+
+	timeout := loginWaitTimeout
+	if v := _extractParam(params, "timeout", reddo.TypeInt, int64(0), nil).(int64); v > 0 && time.Duration(v)*time.Second < timeout {
+		timeout = time.Duration(v) * time.Second
+	}
+	sess, err := waitLoginSession(claims.Id, timeout)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	withRefreshToken := _extractParam(params, "refresh_token", reddo.TypeBool, false, nil).(bool)
+	return _loginSessionResult(sess, app, returnUrl, withRefreshToken)
+}
+
+// _verifyLoginTokenAndApp verifies the login token and the client app passed to APIs "verifyLoginToken" and
+// "waitLoginToken", returns token's claims, the app and the return url. The token must have been issued to the app.
+//
+// available since v0.8.0
+func _verifyLoginTokenAndApp(params *itineris.ApiParams) (*SessionClaims, *app.App, string, *itineris.ApiResult) {
 	// firstly extract JWT token from request and convert it into claims
 	token := _extractParam(params, "token", reddo.TypeString, "", nil)
 	if token == "" {
-		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("empty token")
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("empty token")
+	}
+	if isJwe(token.(string)) {
+		// Exter does not have app's private key: the app decrypts the login token and passes the JWT it contains
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("encrypted login token: decrypt it with app's private key and pass the JWT it contains")
 	}
 	claims, err := parseLoginToken(token.(string))
 	if err != nil {
-		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
 	}
 	if claims.isExpired() {
-		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(errorExpiredJwt.Error())
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(errorExpiredJwt.Error())
 	}
 
 	// secondly verify the client app
 	appId := _extractParam(params, "app", reddo.TypeString, "", nil)
 	app, err := appDao.Get(appId.(string))
 	if err != nil {
-		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
 	} else if app == nil || !app.GetAttrsPublic().IsActive {
-		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("invalid app")
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("invalid app")
+	}
+	// the login token is handed over (encrypted if the app opts in) only to the app it was issued to
+	if claims.Audience != app.GetId() {
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Login token was not issued to app [%s]", app.GetId()))
 	}
 
 	// also verify 'return-url'
 	returnUrl := _extractParam(params, "return_url", reddo.TypeString, "", nil)
-	if returnUrl = app.GenerateReturnUrl(returnUrl.(string)); returnUrl == "" && app.GetId() != systemAppId {
-		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Return url [%s] is not allowed for app [%s]", returnUrl, appId))
+	if returnUrl = _appReturnUrl(app, returnUrl.(string)); returnUrl == "" && app.GetId() != systemAppId {
+		return nil, nil, "", itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Return url [%s] is not allowed for app [%s]", returnUrl, appId))
 	}
+	return claims, app, returnUrl.(string), nil
+}
 
-	// thirdly verify the session
-	sess, err := sessionDao.Get(claims.Id)
-	if err != nil {
-		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
-	}
+// _loginSessionResult builds the result of APIs "verifyLoginToken" and "waitLoginToken" from the session; if
+// withRefreshToken is true, a refresh token is also issued for the login session.
+//
+// available since v0.8.0
+func _loginSessionResult(sess *session.Session, app *app.App, returnUrl string, withRefreshToken bool) *itineris.ApiResult {
 	if sess == nil || sess.IsExpired() {
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Session not exists not expired"))
 	}
+	if sess.GetAppId() != app.GetId() {
+		// since v0.8.0: the login token is encrypted for the app the session was issued to
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Login token was not issued to app [%s]", app.GetId()))
+	}
 
 	// lastly return the session encoded as JWT
 	if sess.GetSessionType() == sessionTypePreLogin {
 		return itineris.NewApiResult(302).SetMessage("please try again after a moment")
+	} else if sess.GetSessionType() == sessionTypeFailed {
+		// since v0.8.0
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(failedSessionReason(sess.GetSessionData()))
+	} else if sess.GetSessionType() == sessionTypeMfa {
+		// since v0.8.0
+		return _mfaPendingResult(sess.GetUserId(), sess.GetAppId(), sess.GetSessionData())
 	} else {
-		returnUrl = strings.ReplaceAll(returnUrl.(string), "${token}", sess.GetSessionData())
+		// since v0.8.0: sessions can be revoked before they expire
+		claims, err := parseLoginToken(sess.GetSessionData())
+		if err == nil {
+			err = checkSessionRevoked(claims)
+		}
+		if err == errorSessionRevoked {
+			return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+		} else if err != nil {
+			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+		}
+		var token string
+		if token, returnUrl, err = appLoginResult(app, returnUrl, sess.GetSessionData()); err != nil {
+			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+		}
+		if withRefreshToken {
+			refreshToken, err := issueRefreshToken(claims, time.Now())
+			if err == errorRefreshTokenDisabled || err == errorRefreshTokenIssued {
+				return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(err.Error())
+			} else if err != nil {
+				return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+			}
+			return itineris.NewApiResult(itineris.StatusOk).SetData(token).SetExtras(map[string]interface{}{
+				apiResultExtraReturnUrl: returnUrl, apiResultExtraRefreshToken: refreshToken})
+		}
+		return itineris.NewApiResult(itineris.StatusOk).SetData(token).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+	}
+}
+
+/*
+apiLogout handles API call "logout": revoke the session of a login token, the token is no longer accepted by Exter.
+Refresh tokens of the session (see API "refreshLoginToken") are also revoked.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+	}
+
+Available since v0.8.0
+*/
+func apiLogout(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, claims, _ := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	if err := revokeLoginSessionAndRefreshFamily(claims); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("Session has been revoked")
+}
+
+/*
+apiLogoutAll handles API call "logoutAll": revoke all sessions of the user, of all apps.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+	}
+
+Available since v0.8.0
+*/
+func apiLogoutAll(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	if err := revokeUserSessions(u, user.SessionsRevokedAllApps, time.Now()); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage("All sessions have been revoked")
+}
+
+/*
+apiRevokeAppSessions handles API call "revokeAppSessions": revoke all sessions of the user for an app.
+This API expects an input map:
+
+	{
+		"token": login token (returned by apiLogin/apiVerifyLoginToken),
+		"app": (optional) id of the app, default is the app the login token was issued to,
+	}
+
+- Only login tokens issued to Exter's own frontend can revoke sessions of other apps.
+
+Available since v0.8.0
+*/
+func apiRevokeAppSessions(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token, _ := params.GetParamAsType("token", reddo.TypeString)
+	errResult, claims, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		return errResult
+	}
+	appId := strings.TrimSpace(strings.ToLower(_extractParam(params, "app", reddo.TypeString, "", nil).(string)))
+	if appId == "" {
+		appId = claims.Audience
+	} else if appId != claims.Audience && claims.Audience != systemAppId {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(fmt.Sprintf("Not allowed to revoke sessions of app [%s]", appId))
+	}
+	if app, err := appDao.Get(appId); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	} else if app == nil {
+		return itineris.NewApiResult(itineris.StatusNotFound).SetMessage(fmt.Sprintf("App [%s] not found", appId))
+	}
+	if err := revokeUserSessions(u, appId, time.Now()); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetMessage(fmt.Sprintf("Sessions of app [%s] have been revoked", appId))
+}
+
+/*
+apiRefreshLoginToken handles API call "refreshLoginToken": exchange a refresh token (issued by apiVerifyLoginToken)
+for a new login token, without user having to login again.
+This API expects an input map:
+
+	{
+		"refresh_token": the refresh token,
+		"app": application's id,
+	}
+
+- Upon successful, this API returns the new login token, and the new refresh token as extra field "refresh_token".
+- Refresh tokens are rotated: a refresh token can be used only once.
+- If a used refresh token is presented again, all refresh tokens rotated from the same login (the "family") are revoked, as well as the latest login token issued with them.
+
+Available since v0.8.0
+*/
+func apiRefreshLoginToken(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token := strings.TrimSpace(_extractParam(params, "refresh_token", reddo.TypeString, "", nil).(string))
+	if token == "" {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("empty refresh token")
+	}
+	appId := _extractParam(params, "app", reddo.TypeString, "", nil).(string)
+	app, err := appDao.Get(appId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	} else if app == nil || !app.GetAttrsPublic().IsActive {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("invalid app")
+	}
+	_, jwt, refreshToken, err := refreshLoginToken(token, appId, time.Now())
+	switch err {
+	case nil:
+		if jwt, err = appLoginToken(app, jwt); err != nil {
+			return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+		}
+		return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraRefreshToken: refreshToken})
+	case errorRefreshTokenDisabled:
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(err.Error())
+	case errorRefreshTokenInvalid, errorRefreshTokenReused, errorSessionRevoked:
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	default:
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+}
+
+/*------------------------------ OpenID Connect provider APIs ------------------------------*/
+
+// _oidcJsonResult builds the result of OpenID Connect endpoints, whose responses are JSON documents defined by the
+// OAuth 2.0/OpenID Connect specs rather than Exter's API result.
+//
+// available since v0.8.0
+func _oidcJsonResult(httpStatus int, data interface{}, headers map[string]string) *itineris.ApiResult {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(httpStatus).SetData(&itineris.ApiResultRawContent{ContentType: "application/json", Content: js, HttpStatus: httpStatus, Headers: headers})
+}
+
+// _oidcRedirectError redirects user back to the OIDC client with an error response.
+func _oidcRedirectError(req *oidcAuthzRequest, e *oidcError) *itineris.ApiResult {
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(oidcRedirectUrl(req.RedirectUri,
+		map[string]string{"error": e.Code, "error_description": e.Description, "state": req.State}))
+}
+
+/*
+apiOidcDiscovery handles API call "oidcDiscovery": OpenID Provider Metadata (/.well-known/openid-configuration).
+
+Available since v0.8.0
+*/
+func apiOidcDiscovery(_ *itineris.ApiContext, _ *itineris.ApiAuth, _ *itineris.ApiParams) *itineris.ApiResult {
+	return _oidcJsonResult(itineris.StatusOk, oidcDiscoveryDocument(), nil)
+}
+
+/*
+apiOidcJwks handles API call "oidcJwks": public keys to verify id_tokens (and login tokens) signed by Exter.
+
+Available since v0.8.0
+*/
+func apiOidcJwks(_ *itineris.ApiContext, _ *itineris.ApiAuth, _ *itineris.ApiParams) *itineris.ApiResult {
+	return _oidcJsonResult(itineris.StatusOk, map[string]interface{}{"keys": signingKeys.jwks()}, nil)
+}
+
+/*
+apiOidcAuthorize handles API call "oidcAuthorize": authorization endpoint of Exter as OpenID Connect provider.
+This API expects the parameters of an OIDC authentication request (authorization code flow with PKCE):
+
+	{
+		"response_type": "code",
+		"client_id": id of a registered app,
+		"redirect_uri": url to return user to, its domain must be whitelisted by the app,
+		"scope": space-delimited scopes, must contain "openid",
+		"state", "nonce": (optional) values returned to the client as-is,
+		"code_challenge": PKCE code challenge,
+		"code_challenge_method": "S256",
 	}
-	return itineris.NewApiResult(itineris.StatusOk).SetData(sess.GetSessionData()).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+
+- Upon successful, user is redirected to Exter's login page, then to the authorization callback (API "oidcAuthorizeCallback") and finally to "redirect_uri" with the authorization code.
+- If "client_id" or "redirect_uri" is invalid, this API returns status 400; other errors are returned to "redirect_uri".
+
+Available since v0.8.0
+*/
+func apiOidcAuthorize(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	req := &oidcAuthzRequest{
+		ClientId:            _extractParam(params, "client_id", reddo.TypeString, "", nil).(string),
+		RedirectUri:         _extractParam(params, "redirect_uri", reddo.TypeString, "", nil).(string),
+		Scope:               _extractParam(params, "scope", reddo.TypeString, "", nil).(string),
+		State:               _extractParam(params, "state", reddo.TypeString, "", nil).(string),
+		Nonce:               _extractParam(params, "nonce", reddo.TypeString, "", nil).(string),
+		CodeChallenge:       _extractParam(params, "code_challenge", reddo.TypeString, "", nil).(string),
+		CodeChallengeMethod: _extractParam(params, "code_challenge_method", reddo.TypeString, "", nil).(string),
+	}
+	app, err := appDao.Get(req.ClientId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	} else if app == nil || !app.GetAttrsPublic().IsActive {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Invalid client [%s]", req.ClientId))
+	}
+	if u, err := url.Parse(req.RedirectUri); err != nil || !u.IsAbs() || app.GenerateReturnUrl(req.RedirectUri) != req.RedirectUri {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("Redirect uri [%s] is not allowed for client [%s]", req.RedirectUri, req.ClientId))
+	}
+
+	responseType := _extractParam(params, "response_type", reddo.TypeString, "", nil).(string)
+	if e := validateOidcAuthzRequest(responseType, req); e != nil {
+		return _oidcRedirectError(req, e)
+	}
+	if prompt := _extractParam(params, "prompt", reddo.TypeString, "", nil).(string); oidcScopeHas(prompt, "none") {
+		// user always interacts with Exter's login page
+		return _oidcRedirectError(req, &oidcError{Code: "login_required", Description: "user must login"})
+	}
+	reqId, err := oidcRandomCode()
+	if err == nil {
+		err = saveOidcAuthzRequest(sessionTypeOidcAuthz, reqId, req, oidcIssuerAuthzRequestTtl)
+	}
+	if err != nil {
+		return _oidcRedirectError(req, &oidcError{Code: "server_error", Description: err.Error()})
+	}
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(oidcLoginPageUrl(req.ClientId, reqId))
+}
+
+/*
+apiOidcAuthorizeCallback handles API call "oidcAuthorizeCallback": Exter's login page returns user to this API once
+logged in to the client app. This API expects an input map:
+
+	{
+		"req": id of the authorization request,
+		"token": login token issued for the client app,
+	}
+
+- Upon successful, user is redirected to the client's "redirect_uri" with the authorization code.
+
+Available since v0.8.0
+*/
+func apiOidcAuthorizeCallback(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	reqId := _extractParam(params, "req", reddo.TypeString, "", nil).(string)
+	req, err := takeOidcAuthzRequest(sessionTypeOidcAuthz, reqId)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	} else if req == nil {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("Authorization request does not exist or has expired")
+	}
+
+	token := _extractParam(params, "token", reddo.TypeString, "", nil).(string)
+	claims, err := parseLoginToken(token)
+	if err != nil || claims.isExpired() || claims.Type != sessionTypeLogin || claims.Audience != req.ClientId {
+		return _oidcRedirectError(req, &oidcError{Code: "access_denied", Description: "invalid login token"})
+	}
+	code, err := oidcRandomCode()
+	if err == nil {
+		req.LoginToken = token
+		err = saveOidcAuthzRequest(sessionTypeOidcCode, code, req, oidcIssuerCodeTtl)
+	}
+	if err != nil {
+		return _oidcRedirectError(req, &oidcError{Code: "server_error", Description: err.Error()})
+	}
+	return itineris.NewApiResult(itineris.StatusSeeOther).SetData(oidcRedirectUrl(req.RedirectUri, map[string]string{"code": code, "state": req.State}))
+}
+
+/*
+apiOidcToken handles API call "oidcToken": token endpoint of Exter as OpenID Connect provider.
+This API expects an input map (usually form-encoded):
+
+	{
+		"grant_type": "authorization_code",
+		"code": the authorization code,
+		"redirect_uri": same as the one passed to the authorization endpoint,
+		"client_id": id of the app,
+		"code_verifier": PKCE code verifier,
+	}
+
+- Upon successful, this API returns the access token (the login token of user for the app) and the id_token.
+- Authorization codes can be used only once.
+
+Available since v0.8.0
+*/
+func apiOidcToken(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	noCache := map[string]string{"Cache-Control": "no-store", "Pragma": "no-cache"}
+	_error := func(httpStatus int, e *oidcError) *itineris.ApiResult {
+		return _oidcJsonResult(httpStatus, e.toMap(), noCache)
+	}
+	if grantType := _extractParam(params, "grant_type", reddo.TypeString, "", nil).(string); grantType != "authorization_code" {
+		return _error(itineris.StatusErrorClient, &oidcError{Code: "unsupported_grant_type", Description: fmt.Sprintf("grant type [%s] is not supported", grantType)})
+	}
+	code := _extractParam(params, "code", reddo.TypeString, "", nil).(string)
+	req, err := takeOidcAuthzRequest(sessionTypeOidcCode, code)
+	if err != nil {
+		return _error(itineris.StatusErrorServer, &oidcError{Code: "server_error", Description: err.Error()})
+	} else if req == nil {
+		return _error(itineris.StatusErrorClient, &oidcError{Code: "invalid_grant", Description: "authorization code is invalid or has expired"})
+	}
+	clientId := _extractParam(params, "client_id", reddo.TypeString, "", nil).(string)
+	redirectUri := _extractParam(params, "redirect_uri", reddo.TypeString, "", nil).(string)
+	if clientId != req.ClientId || redirectUri != req.RedirectUri {
+		return _error(itineris.StatusErrorClient, &oidcError{Code: "invalid_grant", Description: "client_id or redirect_uri does not match the authorization request"})
+	}
+	codeVerifier := _extractParam(params, "code_verifier", reddo.TypeString, "", nil).(string)
+	if !verifyPkce(codeVerifier, req.CodeChallenge, req.CodeChallengeMethod) {
+		return _error(itineris.StatusErrorClient, &oidcError{Code: "invalid_grant", Description: "PKCE verification failed"})
+	}
+
+	errResult, claims, u := _parseLoginTokenFromApi(req.LoginToken)
+	if errResult != nil {
+		return _error(itineris.StatusErrorClient, &oidcError{Code: "invalid_grant", Description: errResult.Message})
+	}
+	if sess, err := sessionDao.Get(claims.Id); err != nil {
+		return _error(itineris.StatusErrorServer, &oidcError{Code: "server_error", Description: err.Error()})
+	} else if sess == nil || sess.IsExpired() {
+		return _error(itineris.StatusErrorClient, &oidcError{Code: "invalid_grant", Description: "login session does not exist or has expired"})
+	}
+	idToken, err := genOidcIdToken(req, claims, u)
+	if err != nil {
+		return _error(itineris.StatusErrorServer, &oidcError{Code: "server_error", Description: err.Error()})
+	}
+	return _oidcJsonResult(itineris.StatusOk, map[string]interface{}{
+		"access_token": req.LoginToken,
+		"token_type":   "Bearer",
+		"expires_in":   claims.ExpiresAt - time.Now().Unix(),
+		"id_token":     idToken,
+		"scope":        req.Scope,
+	}, noCache)
+}
+
+/*
+apiOidcUserinfo handles API call "oidcUserinfo": userinfo endpoint of Exter as OpenID Connect provider.
+The access token is passed via the "Authorization: Bearer" header (or parameter "access_token").
+
+Available since v0.8.0
+*/
+func apiOidcUserinfo(ctx *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams) *itineris.ApiResult {
+	token := _extractParam(params, "access_token", reddo.TypeString, "", nil).(string)
+	if authz, _ := ctx.GetContextValue("authorization").(string); len(authz) > 7 && strings.EqualFold(authz[:7], "bearer ") {
+		token = strings.TrimSpace(authz[7:])
+	}
+	errResult, _, u := _parseLoginTokenFromApi(token)
+	if errResult != nil {
+		e := &oidcError{Code: "invalid_token", Description: errResult.Message}
+		return _oidcJsonResult(itineris.StatusUnauthorized, e.toMap(), map[string]string{"WWW-Authenticate": `Bearer error="invalid_token"`})
+	}
+	return _oidcJsonResult(itineris.StatusOk, oidcUserClaims(u), map[string]string{"Cache-Control": "no-store"})
 }
 
 /* app APIs */
@@ -542,6 +1821,18 @@ func _extractAppParams(ctx *itineris.ApiContext, params *itineris.ApiParams) (*a
 		tags[i] = strings.TrimSpace(tag)
 	}
 	idSources := _extractParam(params, "id_sources", reflect.TypeOf(map[string]bool{}), make(map[string]bool), nil)
+	// since v0.8.0: restrict Microsoft login to specific tenants
+	msTenantIdsStr := _extractParam(params, "microsoft_tenant_ids", reddo.TypeString, "", nil)
+	msTenantIds := make([]string, 0)
+	for _, tid := range regexp.MustCompile(`[,;\s]+`).Split(msTenantIdsStr.(string), -1) {
+		if tid = strings.ToLower(strings.TrimSpace(tid)); tid != "" {
+			msTenantIds = append(msTenantIds, tid)
+		}
+	}
+	// since v0.8.0: require multi-factor authentication
+	requireMfa := _extractParam(params, "require_mfa", reddo.TypeBool, false, nil)
+	// since v0.8.0: require email addresses verified by identity providers
+	requireVerifiedEmail := _extractParam(params, "require_verified_email", reddo.TypeBool, false, nil)
 	rsaPubicKeyPem := _extractParam(params, "rsa_public_key", reddo.TypeString, "", nil)
 	if rsaPubicKeyPem != "" {
 		_, err := parseRsaPublicKeyFromPem(rsaPubicKeyPem.(string))
@@ -549,6 +1840,11 @@ func _extractAppParams(ctx *itineris.ApiContext, params *itineris.ApiParams) (*a
 			return nil, itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(err.Error())
 		}
 	}
+	// since v0.8.0: encrypt login tokens handed over to the app with its RSA public key
+	encryptLoginToken := _extractParam(params, "encrypt_login_token", reddo.TypeBool, false, nil)
+	if encryptLoginToken.(bool) && rsaPubicKeyPem == "" {
+		return nil, itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("RSA public key is required to encrypt login tokens")
+	}
 
 	sessionClaim, ok := ctx.GetContextValue(ctxFieldSession).(*SessionClaims)
 	if !ok || sessionClaim == nil {
@@ -559,13 +1855,17 @@ func _extractAppParams(ctx *itineris.ApiContext, params *itineris.ApiParams) (*a
 	boApp := app.NewApp(goapi.AppVersionNumber, id.(string), ownerId, desc.(string))
 	boApp.SetDomains(domains)
 	boApp.SetAttrsPublic(app.AppAttrsPublic{
-		IsActive:         isActive.(bool),
-		Description:      desc.(string),
-		DefaultReturnUrl: defaultReturnUrl.(string),
-		DefaultCancelUrl: defaultCancelUrl.(string),
-		IdentitySources:  idSources.(map[string]bool),
-		Tags:             tags,
-		RsaPublicKey:     rsaPubicKeyPem.(string),
+		IsActive:             isActive.(bool),
+		Description:          desc.(string),
+		DefaultReturnUrl:     defaultReturnUrl.(string),
+		DefaultCancelUrl:     defaultCancelUrl.(string),
+		IdentitySources:      idSources.(map[string]bool),
+		Tags:                 tags,
+		RsaPublicKey:         rsaPubicKeyPem.(string),
+		MicrosoftTenantIds:   msTenantIds,
+		RequireMfa:           requireMfa.(bool),
+		RequireVerifiedEmail: requireVerifiedEmail.(bool),
+		EncryptLoginToken:    encryptLoginToken.(bool),
 	})
 
 	return boApp, nil
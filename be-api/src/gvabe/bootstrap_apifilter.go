@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"main/src/goapi"
 	"main/src/itineris"
@@ -73,17 +74,20 @@ func (f *GVAFEAuthenticationFilter) Call(handler itineris.IApiHandler, ctx *itin
 		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
 	}
 	ctx.SetContextValue(ctxFieldSession, sessionClaim)
+	var result *itineris.ApiResult
 	if f.NextFilter != nil {
-		return f.NextFilter.Call(handler, ctx, auth, params)
+		result = f.NextFilter.Call(handler, ctx, auth, params)
+	} else {
+		result = handler(ctx, auth, params)
+	}
+	// since v0.8.0: the login session is renewed, up to [gvabe.login_session.max_lifetime] since user logged in
+	if result != nil && sessionClaim != nil && sessionClaim.isGoingExpired(loginSessionNearExpiry) {
+		if jws, err := renewLoginSession(sessionClaim, time.Now()); err != nil {
+			log.Printf("[WARN] Cannot renew login session [%s]: %s", sessionClaim.Id, err)
+		} else if jws != "" {
+			result.AddExtraInfo(apiResultExtraAccessToken, jws)
+		}
 	}
-	result := handler(ctx, auth, params)
-	// if sessionClaim != nil && sessionClaim.isGoingExpired(loginSessionNearExpiry) {
-	// 	// extends login session
-	// 	expiry := time.Now().Add(loginSessionTtl * time.Second)
-	// 	sessionClaim.ExpiresAt = expiry.Unix()
-	// 	jws, _ := genJws(sessionClaim)
-	// 	result.AddExtraInfo(apiResultExtraAccessToken, jws)
-	// }
 	return result
 }
 
@@ -113,5 +117,9 @@ func (f *GVAFEAuthenticationFilter) authenticate(ctx *itineris.ApiContext, auth
 	if sessionClaim.isExpired() {
 		return nil, errorExpiredJwt
 	}
+	// since v0.8.0: sessions can be revoked before they expire
+	if err := checkSessionRevoked(sessionClaim); err != nil {
+		return nil, err
+	}
 	return sessionClaim, nil
 }
@@ -22,7 +22,11 @@ import (
 	"main/src/goapi"
 	"main/src/gvabe/bo"
 	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/credential"
+	"main/src/gvabe/bo/identity"
+	"main/src/gvabe/bo/job"
 	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/signingkey"
 	"main/src/gvabe/bo/user"
 	"main/src/utils"
 )
@@ -125,6 +129,13 @@ func initDaos() {
 		// SQLite, for non-production only!
 		henge.InitSqliteTable(sqlc, user.TableUser, nil)
 		henge.InitSqliteTable(sqlc, app.TableApp, map[string]string{app.SqlColAppUserId: "VARCHAR(32)"})
+		henge.InitSqliteTable(sqlc, credential.TableCredential, map[string]string{credential.SqlColCredentialUserId: "VARCHAR(32)"})
+		henge.InitSqliteTable(sqlc, identity.TableIdentity, map[string]string{identity.SqlColIdentityUserId: "VARCHAR(32)"})
+		henge.InitSqliteTable(sqlc, job.TableJob, map[string]string{
+			job.SqlColJobStatus:    "VARCHAR(32)",
+			job.SqlColJobNextRunAt: "TIMESTAMP",
+		})
+		henge.InitSqliteTable(sqlc, signingkey.TableSigningKey, nil)
 		henge.InitSqliteTable(sqlc, session.TableSession, map[string]string{
 			session.SqlColSessionIdSource:    "VARCHAR(32)",
 			session.SqlColSessionAppId:       "VARCHAR(32)",
@@ -136,6 +147,13 @@ func initDaos() {
 		// MSSQL
 		henge.InitMssqlTable(sqlc, user.TableUser, nil)
 		henge.InitMssqlTable(sqlc, app.TableApp, map[string]string{app.SqlColAppUserId: "NVARCHAR(32)"})
+		henge.InitMssqlTable(sqlc, credential.TableCredential, map[string]string{credential.SqlColCredentialUserId: "NVARCHAR(32)"})
+		henge.InitMssqlTable(sqlc, identity.TableIdentity, map[string]string{identity.SqlColIdentityUserId: "NVARCHAR(32)"})
+		henge.InitMssqlTable(sqlc, job.TableJob, map[string]string{
+			job.SqlColJobStatus:    "NVARCHAR(32)",
+			job.SqlColJobNextRunAt: "DATETIMEOFFSET",
+		})
+		henge.InitMssqlTable(sqlc, signingkey.TableSigningKey, nil)
 		henge.InitMssqlTable(sqlc, session.TableSession, map[string]string{
 			session.SqlColSessionIdSource:    "NVARCHAR(32)",
 			session.SqlColSessionAppId:       "NVARCHAR(32)",
@@ -147,6 +165,13 @@ func initDaos() {
 		// MySQL
 		henge.InitMysqlTable(sqlc, user.TableUser, nil)
 		henge.InitMysqlTable(sqlc, app.TableApp, map[string]string{app.SqlColAppUserId: "VARCHAR(32)"})
+		henge.InitMysqlTable(sqlc, credential.TableCredential, map[string]string{credential.SqlColCredentialUserId: "VARCHAR(32)"})
+		henge.InitMysqlTable(sqlc, identity.TableIdentity, map[string]string{identity.SqlColIdentityUserId: "VARCHAR(32)"})
+		henge.InitMysqlTable(sqlc, job.TableJob, map[string]string{
+			job.SqlColJobStatus:    "VARCHAR(32)",
+			job.SqlColJobNextRunAt: "TIMESTAMP",
+		})
+		henge.InitMysqlTable(sqlc, signingkey.TableSigningKey, nil)
 		henge.InitMysqlTable(sqlc, session.TableSession, map[string]string{
 			session.SqlColSessionIdSource:    "VARCHAR(32)",
 			session.SqlColSessionAppId:       "VARCHAR(32)",
@@ -157,6 +182,13 @@ func initDaos() {
 	case utils.InSlideStr(dbtype, dbTypeOracle):
 		henge.InitOracleTable(sqlc, user.TableUser, nil)
 		henge.InitOracleTable(sqlc, app.TableApp, map[string]string{app.SqlColAppUserId: "NVARCHAR2(32)"})
+		henge.InitOracleTable(sqlc, credential.TableCredential, map[string]string{credential.SqlColCredentialUserId: "NVARCHAR2(32)"})
+		henge.InitOracleTable(sqlc, identity.TableIdentity, map[string]string{identity.SqlColIdentityUserId: "NVARCHAR2(32)"})
+		henge.InitOracleTable(sqlc, job.TableJob, map[string]string{
+			job.SqlColJobStatus:    "NVARCHAR2(32)",
+			job.SqlColJobNextRunAt: "TIMESTAMP WITH TIME ZONE",
+		})
+		henge.InitOracleTable(sqlc, signingkey.TableSigningKey, nil)
 		henge.InitOracleTable(sqlc, session.TableSession, map[string]string{
 			session.SqlColSessionIdSource:    "NVARCHAR2(32)",
 			session.SqlColSessionAppId:       "NVARCHAR2(32)",
@@ -168,6 +200,13 @@ func initDaos() {
 		// PostgreSQL
 		henge.InitPgsqlTable(sqlc, user.TableUser, nil)
 		henge.InitPgsqlTable(sqlc, app.TableApp, map[string]string{app.SqlColAppUserId: "VARCHAR(32)"})
+		henge.InitPgsqlTable(sqlc, credential.TableCredential, map[string]string{credential.SqlColCredentialUserId: "VARCHAR(32)"})
+		henge.InitPgsqlTable(sqlc, identity.TableIdentity, map[string]string{identity.SqlColIdentityUserId: "VARCHAR(32)"})
+		henge.InitPgsqlTable(sqlc, job.TableJob, map[string]string{
+			job.SqlColJobStatus:    "VARCHAR(32)",
+			job.SqlColJobNextRunAt: "TIMESTAMP WITH TIME ZONE",
+		})
+		henge.InitPgsqlTable(sqlc, signingkey.TableSigningKey, nil)
 		henge.InitPgsqlTable(sqlc, session.TableSession, map[string]string{
 			session.SqlColSessionIdSource:    "VARCHAR(32)",
 			session.SqlColSessionAppId:       "VARCHAR(32)",
@@ -186,21 +225,37 @@ func initDaos() {
 			henge.InitDynamodbTables(dync, bo.DynamodbMultitenantTableName, spec)
 
 			appDao = app.NewAppDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
+			credentialDao = credential.NewCredentialDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
+			identityDao = identity.NewIdentityDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
+			jobDao = job.NewJobDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
 			sessionDao = session.NewSessionDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
+			signingKeyDao = signingkey.NewSigningKeyDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
 			userDao = user.NewUserDaoMultitenantAwsDynamodb(dync, bo.DynamodbMultitenantTableName)
 		} else {
 			henge.InitDynamodbTables(dync, app.TableApp, spec)
+			henge.InitDynamodbTables(dync, credential.TableCredential, spec)
+			henge.InitDynamodbTables(dync, identity.TableIdentity, spec)
+			henge.InitDynamodbTables(dync, job.TableJob, spec)
 			henge.InitDynamodbTables(dync, session.TableSession, spec)
+			henge.InitDynamodbTables(dync, signingkey.TableSigningKey, spec)
 			henge.InitDynamodbTables(dync, user.TableUser, spec)
 
 			appDao = app.NewAppDaoAwsDynamodb(dync, app.TableApp)
+			credentialDao = credential.NewCredentialDaoAwsDynamodb(dync, credential.TableCredential)
+			identityDao = identity.NewIdentityDaoAwsDynamodb(dync, identity.TableIdentity)
+			jobDao = job.NewJobDaoAwsDynamodb(dync, job.TableJob)
 			sessionDao = session.NewSessionDaoAwsDynamodb(dync, session.TableSession)
+			signingKeyDao = signingkey.NewSigningKeyDaoAwsDynamodb(dync, signingkey.TableSigningKey)
 			userDao = user.NewUserDaoAwsDynamodb(dync, user.TableUser)
 		}
 	} else if mc != nil {
 		// MongoDB
 		henge.InitMongoCollection(mc, app.TableApp)
+		henge.InitMongoCollection(mc, credential.TableCredential)
+		henge.InitMongoCollection(mc, identity.TableIdentity)
+		henge.InitMongoCollection(mc, job.TableJob)
 		henge.InitMongoCollection(mc, session.TableSession)
+		henge.InitMongoCollection(mc, signingkey.TableSigningKey)
 		henge.InitMongoCollection(mc, user.TableUser)
 
 		mc.CreateCollectionIndexes(app.TableApp, []interface{}{
@@ -209,6 +264,24 @@ func initDaos() {
 				"name": "idx_ownerid",
 			},
 		})
+		mc.CreateCollectionIndexes(credential.TableCredential, []interface{}{
+			map[string]interface{}{
+				"key":  map[string]interface{}{credential.FieldCredentialOwnerId: 1},
+				"name": "idx_ownerid",
+			},
+		})
+		mc.CreateCollectionIndexes(identity.TableIdentity, []interface{}{
+			map[string]interface{}{
+				"key":  map[string]interface{}{identity.FieldIdentityOwnerId: 1},
+				"name": "idx_ownerid",
+			},
+		})
+		mc.CreateCollectionIndexes(job.TableJob, []interface{}{
+			map[string]interface{}{
+				"key":  map[string]interface{}{job.FieldJobStatus: 1, job.FieldJobNextRunAt: 1},
+				"name": "idx_status_nrat",
+			},
+		})
 		mc.CreateCollectionIndexes(session.TableSession, []interface{}{
 			map[string]interface{}{
 				"key":  map[string]interface{}{session.FieldSessionIdSource: 1},
@@ -225,7 +298,11 @@ func initDaos() {
 		})
 
 		appDao = app.NewAppDaoMongo(mc, app.TableApp)
+		credentialDao = credential.NewCredentialDaoMongo(mc, credential.TableCredential)
+		identityDao = identity.NewIdentityDaoMongo(mc, identity.TableIdentity)
+		jobDao = job.NewJobDaoMongo(mc, job.TableJob)
 		sessionDao = session.NewSessionDaoMongo(mc, session.TableSession)
+		signingKeyDao = signingkey.NewSigningKeyDaoMongo(mc, signingkey.TableSigningKey)
 		userDao = user.NewUserDaoMongo(mc, user.TableUser)
 	} else if sqlc != nil && utils.InSlideStr(dbtype, dbTypeCosmosDb) {
 		// Azure Cosmos DB
@@ -235,26 +312,45 @@ func initDaos() {
 			henge.InitCosmosdbCollection(sqlc, bo.CosmosdbMultitenantTableName, spec)
 
 			appDao = app.NewAppDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
+			credentialDao = credential.NewCredentialDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
+			identityDao = identity.NewIdentityDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
+			jobDao = job.NewJobDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
 			sessionDao = session.NewSessionDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
+			signingKeyDao = signingkey.NewSigningKeyDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
 			userDao = user.NewUserDaoMultitenantCosmosdb(sqlc, bo.CosmosdbMultitenantTableName)
 		} else {
 			henge.InitCosmosdbCollection(sqlc, app.TableApp, spec)
+			henge.InitCosmosdbCollection(sqlc, credential.TableCredential, spec)
+			henge.InitCosmosdbCollection(sqlc, identity.TableIdentity, spec)
+			henge.InitCosmosdbCollection(sqlc, job.TableJob, spec)
 			henge.InitCosmosdbCollection(sqlc, session.TableSession, spec)
+			henge.InitCosmosdbCollection(sqlc, signingkey.TableSigningKey, spec)
 			henge.InitCosmosdbCollection(sqlc, user.TableUser, spec)
 
 			appDao = app.NewAppDaoCosmosdb(sqlc, app.TableApp)
+			credentialDao = credential.NewCredentialDaoCosmosdb(sqlc, credential.TableCredential)
+			identityDao = identity.NewIdentityDaoCosmosdb(sqlc, identity.TableIdentity)
+			jobDao = job.NewJobDaoCosmosdb(sqlc, job.TableJob)
 			sessionDao = session.NewSessionDaoCosmosdb(sqlc, session.TableSession)
+			signingKeyDao = signingkey.NewSigningKeyDaoCosmosdb(sqlc, signingkey.TableSigningKey)
 			userDao = user.NewUserDaoCosmosdb(sqlc, user.TableUser)
 		}
 	} else if sqlc != nil {
 		// other RDBMS
 		henge.CreateIndexSql(sqlc, app.TableApp, false, []string{app.SqlColAppUserId})
+		henge.CreateIndexSql(sqlc, credential.TableCredential, false, []string{credential.SqlColCredentialUserId})
+		henge.CreateIndexSql(sqlc, identity.TableIdentity, false, []string{identity.SqlColIdentityUserId})
+		henge.CreateIndexSql(sqlc, job.TableJob, false, []string{job.SqlColJobStatus, job.SqlColJobNextRunAt})
 		henge.CreateIndexSql(sqlc, session.TableSession, false, []string{session.SqlColSessionIdSource})
 		henge.CreateIndexSql(sqlc, session.TableSession, false, []string{session.SqlColSessionAppId})
 		henge.CreateIndexSql(sqlc, session.TableSession, false, []string{session.SqlColSessionExpiry})
 
 		appDao = app.NewAppDaoSql(sqlc, app.TableApp)
+		credentialDao = credential.NewCredentialDaoSql(sqlc, credential.TableCredential)
+		identityDao = identity.NewIdentityDaoSql(sqlc, identity.TableIdentity)
+		jobDao = job.NewJobDaoSql(sqlc, job.TableJob)
 		sessionDao = session.NewSessionDaoSql(sqlc, session.TableSession)
+		signingKeyDao = signingkey.NewSigningKeyDaoSql(sqlc, signingkey.TableSigningKey)
 		userDao = user.NewUserDaoSql(sqlc, user.TableUser)
 	}
 
@@ -283,6 +379,14 @@ func _initUsers() {
 			log.Printf("Cannot create user [%s]", systemAppOwnerId)
 		}
 	}
+
+	// since v0.8.0: break-glass password of the system app owner, to login via the "local" channel when the identity sources are not available
+	if pwd := goapi.AppConfig.GetString("gvabe.init.system_app_owner_password"); pwd != "" && systemAppOwner != nil && !systemAppOwner.HasPassword() {
+		log.Printf("Setting password of system app owner [%s]...", systemAppOwnerId)
+		if err := setUserPassword(systemAppOwner, pwd); err != nil {
+			log.Printf("[ERROR] Cannot set password of system app owner [%s]: %s", systemAppOwnerId, err)
+		}
+	}
 }
 
 func _initApps() {
@@ -307,7 +411,8 @@ func _initApps() {
 	}
 
 	// AB#13: sync the public key with exter app record in database
-	if systemApp != nil {
+	// (since v0.8.0) app's public key must be an RSA key, hence it is not synced if Exter's private key is not an RSA key
+	if systemApp != nil && rsaPubKey != nil {
 		pubBlock := &pem.Block{
 			Type:    "RSA PUBLIC KEY",
 			Headers: nil,
@@ -0,0 +1,369 @@
+package gvabe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	hocon "github.com/go-akka/configuration"
+	"golang.org/x/oauth2"
+
+	"main/src/gvabe/bo/app"
+	"main/src/itineris"
+)
+
+/*
+LoginChannel is a source of identity users login to Exter with (e.g. Google, GitHub, SAML identity providers).
+
+Built-in channels are registered when the package is loaded; third parties register their own channels (or replace
+built-in ones) by calling RegisterLoginChannel from their bootstrapper, which must run before gvabe's bootstrapper.
+A registered channel is active only if its name is listed in setting [gvabe.login_channels] and its Init succeeds.
+
+Login via a channel is carried out in steps: Exchange is called synchronously by the "login" API, the pre-login session
+is then upgraded to login session in background once FetchProfile and MapIdentity succeed. Channels whose flow does not
+fit these steps (e.g. SAML redirects, LDAP binds) implement LoginHandler instead and embed BaseLoginChannel; those
+upgrading their pre-login sessions by themselves (e.g. from verified id_token claims) implement LoginProfileFetcher.
+
+Available since v0.8.0
+*/
+type LoginChannel interface {
+	// Name returns channel's name, which is listed in setting [gvabe.login_channels] and passed as "source" to the "login" API.
+	Name() string
+
+	// Init initializes the channel from application's configuration, the channel is disabled if an error is returned.
+	Init(conf *hocon.Config) error
+
+	// Info returns channel's public settings (e.g. OAuth2 client id) to be included in the result of the "info" API, or nil.
+	Info() map[string]interface{}
+
+	// AuthUrl builds the url user is redirected to in order to authenticate with the channel, empty if not applicable.
+	AuthUrl(state string, opts ...oauth2.AuthCodeOption) string
+
+	// Exchange exchanges the credential passed to the "login" API (e.g. OAuth2 authorization code) for an access token.
+	Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error)
+
+	// FetchProfile fetches user's profile from the channel with the access token returned by Exchange.
+	FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error)
+
+	// MapIdentity maps the profile returned by FetchProfile to a login identity, which is then resolved to an Exter
+	// user account (see resolveLoginIdentity).
+	MapIdentity(profile interface{}) (*LoginIdentity, error)
+}
+
+// LoginHandler is implemented by login channels that carry out the "login" API by themselves.
+//
+// Available since v0.8.0
+type LoginHandler interface {
+	Login(ctx *itineris.ApiContext, auth *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult
+}
+
+// LoginProfileFetcher is implemented by login channels whose pre-login sessions do not carry a plain OAuth2 access
+// token (e.g. verified id_token claims): the channel upgrades the pre-login session to login session by itself.
+//
+// Available since v0.8.0
+type LoginProfileFetcher interface {
+	FetchLoginProfile(sessId string) error
+}
+
+var errorLoginStepNotSupported = errors.New("login step is not supported by this channel")
+
+// BaseLoginChannel provides no-op implementation of LoginChannel, to be embedded by channels implementing LoginHandler.
+//
+// Available since v0.8.0
+type BaseLoginChannel struct {
+	ChannelName string
+}
+
+// Name implements LoginChannel.Name.
+func (ch *BaseLoginChannel) Name() string {
+	return ch.ChannelName
+}
+
+// Init implements LoginChannel.Init.
+func (ch *BaseLoginChannel) Init(_ *hocon.Config) error {
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *BaseLoginChannel) Info() map[string]interface{} {
+	return nil
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *BaseLoginChannel) AuthUrl(_ string, _ ...oauth2.AuthCodeOption) string {
+	return ""
+}
+
+// Exchange implements LoginChannel.Exchange.
+func (ch *BaseLoginChannel) Exchange(_ context.Context, _ *itineris.ApiParams) (*oauth2.Token, error) {
+	return nil, errorLoginStepNotSupported
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+func (ch *BaseLoginChannel) FetchProfile(_ context.Context, _ *oauth2.Token) (interface{}, error) {
+	return nil, errorLoginStepNotSupported
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *BaseLoginChannel) MapIdentity(_ interface{}) (*LoginIdentity, error) {
+	return nil, errorLoginStepNotSupported
+}
+
+var (
+	// registered login channels, indexed by name
+	loginChannelRegistry = make(map[string]LoginChannel)
+)
+
+// RegisterLoginChannel registers a login channel, replacing the one registered with the same name (if any).
+// Login channels must be registered before gvabe's bootstrapper runs.
+//
+// Available since v0.8.0
+func RegisterLoginChannel(ch LoginChannel) {
+	name := strings.TrimSpace(strings.ToLower(ch.Name()))
+	if _, ok := loginChannelRegistry[name]; ok {
+		log.Println(fmt.Sprintf("[INFO] Login channel [%s] is replaced by %T", name, ch))
+	}
+	loginChannelRegistry[name] = ch
+}
+
+func init() {
+	RegisterLoginChannel(&facebookLoginChannel{})
+	RegisterLoginChannel(&githubLoginChannel{})
+	RegisterLoginChannel(&googleLoginChannel{})
+	RegisterLoginChannel(&linkedinLoginChannel{})
+	RegisterLoginChannel(&twitterLoginChannel{})
+	RegisterLoginChannel(&microsoftLoginChannel{BaseLoginChannel{loginChannelMicrosoft}})
+	RegisterLoginChannel(&gitlabLoginChannel{})
+	RegisterLoginChannel(&appleLoginChannel{BaseLoginChannel{loginChannelApple}})
+	RegisterLoginChannel(&samlLoginChannel{BaseLoginChannel{loginChannelSaml}})
+	RegisterLoginChannel(&ldapLoginChannel{BaseLoginChannel{loginChannelLdap}})
+	RegisterLoginChannel(&emailLoginChannel{BaseLoginChannel{loginChannelEmail}})
+	RegisterLoginChannel(&localLoginChannel{BaseLoginChannel{loginChannelLocal}})
+	RegisterLoginChannel(&passkeyLoginChannel{BaseLoginChannel{loginChannelPasskey}})
+}
+
+// lookupLoginChannel returns the active login channel with the specified name, nil is returned if not found.
+//
+// available since v0.8.0
+func lookupLoginChannel(name string) LoginChannel {
+	name = strings.TrimSpace(strings.ToLower(name))
+	if !enabledLoginChannels[name] {
+		return nil
+	}
+	return loginChannelRegistry[name]
+}
+
+// activeLoginChannelNames returns names of active login channels, sorted.
+//
+// available since v0.8.0
+func activeLoginChannelNames() []string {
+	names := make([]string, 0, len(enabledLoginChannels))
+	for name := range enabledLoginChannels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// initLoginChannels initializes login channels listed in setting [gvabe.login_channels]. Generic OpenID Connect channels
+// (setting "type = oidc" under "gvabe.channels.<name>") are registered on the fly unless a channel with the same name exists.
+func initLoginChannels(conf *hocon.Config) {
+	for _, name := range regexp.MustCompile("[,;\\s]+").Split(conf.GetString("gvabe.login_channels"), -1) {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		ch := loginChannelRegistry[name]
+		if ch == nil && strings.ToLower(strings.TrimSpace(conf.GetString("gvabe.channels."+name+".type"))) == loginChannelTypeOidc {
+			ch = &oidcLoginChannel{BaseLoginChannel{name}}
+			RegisterLoginChannel(ch)
+		}
+		if ch == nil {
+			log.Println(fmt.Sprintf("[ERROR] Login channel [%s] is not supported", name))
+			continue
+		}
+		if err := ch.Init(conf); err != nil {
+			log.Println(fmt.Sprintf("[ERROR] Login channel [%s] is disabled: %s", name, err))
+			continue
+		}
+		enabledLoginChannels[name] = true
+	}
+	if DEBUG {
+		log.Printf("[DEBUG] initLoginChannels: %s", activeLoginChannelNames())
+	}
+}
+
+// mergeLoginChannelInfo merges a login channel's public settings into the result of the "info" API.
+// Maps under the same key are merged so that channels can contribute to shared entries (e.g. "oidc_channels").
+//
+// available since v0.8.0
+func mergeLoginChannelInfo(result, info map[string]interface{}) {
+	for k, v := range info {
+		existing, ok1 := result[k].(map[string]interface{})
+		m, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			for mk, mv := range m {
+				existing[mk] = mv
+			}
+		} else {
+			result[k] = v
+		}
+	}
+}
+
+// _doLoginChannel carries out the "login" API for channels following the Exchange/FetchProfile/MapIdentity steps:
+// the credential is exchanged for an access token which is embedded into a pre-login session; the session is upgraded
+// to login session in background once user's profile has been fetched.
+//
+// available since v0.8.0
+func _doLoginChannel(apiCtx *itineris.ApiContext, ch LoginChannel, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	if DEBUG {
+		log.Printf("[DEBUG] START _doLoginChannel(%s)", ch.Name())
+		t := time.Now().UnixNano()
+		defer func() {
+			d := time.Now().UnixNano() - t
+			log.Printf("[DEBUG] END _doLoginChannel(%s): %d ms", ch.Name(), d/1000000)
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// firstly exchange the credential for accessToken
+	token, err := ch.Exchange(ctx, params)
+	if err != nil {
+		if DEBUG {
+			log.Printf("[DEBUG] ERROR _doLoginChannel(%s): %s", ch.Name(), err)
+		}
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	} else if token == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
+	}
+	now := time.Now()
+	if token.Expiry.IsZero() {
+		// some providers (e.g. GitHub) issue non-expiring access tokens, make sure the session does not expire immediately
+		token.Expiry = now.Add(1 * time.Hour)
+	}
+	// secondly embed accessToken into exter's session as a JWT
+	js, _ := json.Marshal(token)
+	claims, err := genPreLoginClaims(&Session{
+		ClientId:   app.GetId(),
+		Channel:    ch.Name(),
+		CreatedAt:  now,
+		ExpiredAt:  token.Expiry,
+		Data:       js, // JSON-serialization of oauth2.Token
+		LinkUserId: _linkUserIdFromContext(apiCtx),
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	// lastly use accessToken to fetch user's profile
+	if err := enqueueLoginProfileJob(ch.Name(), claims.Id); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
+	return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
+
+// fetchLoginChannelProfile fetches user's profile from a login channel and upgrades the pre-login session to login
+// session. Errors fetching the profile are retried (see runLoginProfileJob), those mapping it to a user account are not.
+//
+// available since v0.8.0
+func fetchLoginChannelProfile(ch LoginChannel, sessId string) error {
+	sess, err := loadPreLoginSession(sessId, ch.Name())
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	oauth2Token := &oauth2.Token{}
+	if err := json.Unmarshal(sess.Data, &oauth2Token); err != nil {
+		return jobPermanentError(fmt.Errorf("error unmarshalling oauth2.Token: %s", err))
+	}
+	profile, err := ch.FetchProfile(ctx, oauth2Token)
+	if err != nil {
+		return fmt.Errorf("error fetching %s profile: %s", ch.Name(), err)
+	}
+	ident, err := ch.MapIdentity(profile)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	u, err := resolveLoginIdentity(ident, sess.ClientId, sess.LinkUserId)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	js, _ := json.Marshal(oauth2Token)
+	sess.UserId = u.GetId()
+	sess.DisplayName = u.GetDisplayName()
+	sess.ExpiredAt = oauth2Token.Expiry
+	sess.Data = js
+	return upgradePreLoginSession(sessId, sess)
+}
+
+// kind of jobs upgrading pre-login sessions to login sessions once user's profile has been fetched
+const jobKindLoginProfile = "login_profile"
+
+// loginProfileJob is the payload of jobKindLoginProfile jobs.
+//
+// available since v0.8.0
+type loginProfileJob struct {
+	SessionId string `json:"sid"`  // id of the pre-login session
+	Channel   string `json:"chan"` // name of the login channel
+}
+
+// enqueueLoginProfileJob schedules fetching user's profile to upgrade a pre-login session to login session.
+//
+// available since v0.8.0
+func enqueueLoginProfileJob(channel, sessId string) error {
+	js, _ := json.Marshal(loginProfileJob{SessionId: sessId, Channel: channel})
+	return backgroundJobs.enqueue(jobKindLoginProfile, string(js))
+}
+
+// runLoginProfileJob fetches user's profile from the login channel the pre-login session was created by, see
+// LoginProfileFetcher and fetchLoginChannelProfile.
+//
+// available since v0.8.0
+func runLoginProfileJob(payload string) error {
+	lpj := loginProfileJob{}
+	if err := json.Unmarshal([]byte(payload), &lpj); err != nil {
+		return jobPermanentError(err)
+	}
+	ch := lookupLoginChannel(lpj.Channel)
+	if fetcher, ok := ch.(LoginProfileFetcher); ok {
+		return fetcher.FetchLoginProfile(lpj.SessionId)
+	}
+	if ch != nil {
+		return fetchLoginChannelProfile(ch, lpj.SessionId)
+	}
+	return jobPermanentError(fmt.Errorf("login channel [%s] is not enabled", lpj.Channel))
+}
+
+// failLoginProfileJob marks the pre-login session as failed once its profile job has failed permanently, so that
+// API "verifyLoginToken" reports the reason to client. Sessions no longer pending (e.g. expired) are left untouched.
+//
+// available since v0.8.0
+func failLoginProfileJob(payload string, reason error) {
+	lpj := loginProfileJob{}
+	if err := json.Unmarshal([]byte(payload), &lpj); err != nil {
+		return
+	}
+	if sess, err := loadPreLoginSession(lpj.SessionId, lpj.Channel); err == nil {
+		if err := failPreLoginSession(lpj.SessionId, sess, reason); err != nil {
+			log.Println(fmt.Sprintf("[ERROR] failLoginProfileJob(%s/%s) - error saving failed session: %s", lpj.Channel, lpj.SessionId, err))
+		}
+	}
+}
+
+// errorProfileType is returned by LoginChannel.MapIdentity when the profile is not of the type returned by FetchProfile.
+func errorProfileType(channel string, profile interface{}) error {
+	return fmt.Errorf("unexpected %s profile type: %T", channel, profile)
+}
@@ -0,0 +1,80 @@
+package gvabe
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	hocon "github.com/go-akka/configuration"
+)
+
+type _testLoginChannel struct {
+	BaseLoginChannel
+	initError error
+}
+
+func (ch *_testLoginChannel) Init(_ *hocon.Config) error {
+	return ch.initError
+}
+
+func (ch *_testLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"oidc_channels": map[string]interface{}{ch.ChannelName: true}}
+}
+
+func TestInitLoginChannels(t *testing.T) {
+	name := "TestInitLoginChannels"
+	defer func(registry map[string]LoginChannel, enabled map[string]bool) {
+		loginChannelRegistry, enabledLoginChannels = registry, enabled
+	}(loginChannelRegistry, enabledLoginChannels)
+	loginChannelRegistry, enabledLoginChannels = make(map[string]LoginChannel), make(map[string]bool)
+
+	RegisterLoginChannel(&_testLoginChannel{BaseLoginChannel: BaseLoginChannel{"custom"}})
+	RegisterLoginChannel(&_testLoginChannel{BaseLoginChannel: BaseLoginChannel{"broken"}, initError: errors.New("misconfigured")})
+	RegisterLoginChannel(&_testLoginChannel{BaseLoginChannel: BaseLoginChannel{"unlisted"}})
+	initLoginChannels(hocon.ParseString(`gvabe.login_channels = "Custom, broken; unknown"`))
+
+	if names := activeLoginChannelNames(); !reflect.DeepEqual(names, []string{"custom"}) {
+		t.Fatalf("%s failed: %#v", name, names)
+	}
+	if ch := lookupLoginChannel(" CUSTOM "); ch == nil || ch.Name() != "custom" {
+		t.Fatalf("%s failed: channel [custom] must be active", name)
+	}
+	for _, channel := range []string{"broken", "unlisted", "unknown"} {
+		if lookupLoginChannel(channel) != nil {
+			t.Fatalf("%s failed: channel [%s] must not be active", name, channel)
+		}
+	}
+}
+
+func TestBaseLoginChannel(t *testing.T) {
+	name := "TestBaseLoginChannel"
+	var ch LoginChannel = &BaseLoginChannel{ChannelName: "base"}
+	if ch.Name() != "base" || ch.Init(nil) != nil || ch.Info() != nil || ch.AuthUrl("state") != "" {
+		t.Fatalf("%s failed", name)
+	}
+	if _, err := ch.Exchange(nil, nil); err != errorLoginStepNotSupported {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if _, err := ch.FetchProfile(nil, nil); err != errorLoginStepNotSupported {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if _, err := ch.MapIdentity(nil); err != errorLoginStepNotSupported {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+}
+
+func TestMergeLoginChannelInfo(t *testing.T) {
+	name := "TestMergeLoginChannelInfo"
+	result := map[string]interface{}{"login_channels": []string{"a", "b"}}
+	mergeLoginChannelInfo(result, map[string]interface{}{"a_client_id": "a", "oidc_channels": map[string]interface{}{"a": 1}})
+	mergeLoginChannelInfo(result, map[string]interface{}{"oidc_channels": map[string]interface{}{"b": 2}})
+	mergeLoginChannelInfo(result, nil)
+	expected := map[string]interface{}{
+		"login_channels": []string{"a", "b"},
+		"a_client_id":    "a",
+		"oidc_channels":  map[string]interface{}{"a": 1, "b": 2},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("%s failed: %#v", name, result)
+	}
+}
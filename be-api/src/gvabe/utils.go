@@ -3,13 +3,12 @@ package gvabe
 import (
 	"bytes"
 	"compress/zlib"
+	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha1"
 	"crypto/x509"
-	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"io"
@@ -27,7 +26,11 @@ import (
 	"github.com/shirou/gopsutil/mem"
 
 	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/credential"
+	"main/src/gvabe/bo/identity"
+	"main/src/gvabe/bo/job"
 	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/signingkey"
 	"main/src/gvabe/bo/user"
 )
 
@@ -44,6 +47,11 @@ const (
 const (
 	apiResultExtraAccessToken = "access_token"
 	apiResultExtraReturnUrl   = "return_url"
+	apiResultExtraRedirectUrl = "redirect_url" // available since v0.8.0: url to redirect user to (e.g. SAML identity provider)
+	apiResultExtraMfa         = "mfa"          // available since v0.8.0: multi-factor authentication step user must pass
+	apiResultExtraMfaMethods  = "mfa_methods"  // available since v0.8.0: second factors user can use
+
+	apiResultExtraRefreshToken = "refresh_token" // available since v0.8.0: refresh token, see API "refreshLoginToken"
 
 	loginSessionTtl        = 3600 * 8
 	loginSessionNearExpiry = 3600 * 3
@@ -55,13 +63,17 @@ var (
 	systemAppOwnerId     string
 	enabledLoginChannels = make(map[string]bool)
 
-	appDao     app.AppDao
-	userDao    user.UserDao
-	sessionDao session.SessionDao
+	appDao        app.AppDao
+	userDao       user.UserDao
+	sessionDao    session.SessionDao
+	credentialDao credential.CredentialDao // available since v0.8.0
+	identityDao   identity.IdentityDao     // available since v0.8.0
+	jobDao        job.JobDao               // available since v0.8.0
+	signingKeyDao signingkey.SigningKeyDao // available since v0.8.0
 
-	rsaPrivKey                          *rsa.PrivateKey
-	rsaPubKey                           *rsa.PublicKey
-	rsaPubKeyPemPKCS1, rsaPubKeyPemPKIX []byte
+	exterPrivKey crypto.Signer  // since v0.8.0: Exter's private key, RSA, EC (P-256) or Ed25519
+	signingAlg   string         // since v0.8.0: algorithm Exter signs tokens with, see signingAlgRS256, signingAlgES256 and signingAlgEdDSA
+	rsaPubKey    *rsa.PublicKey // nil if Exter's private key is not an RSA key
 
 	// sessionCache         mico.ICache
 	// preLoginSessionCache mico.ICache
@@ -70,10 +82,19 @@ var (
 )
 
 const (
-	loginChannelFacebook = "facebook"
-	loginChannelGithub   = "github"
-	loginChannelGoogle   = "google"
-	loginChannelLinkedin = "linkedin"
+	loginChannelFacebook  = "facebook"
+	loginChannelGithub    = "github"
+	loginChannelGoogle    = "google"
+	loginChannelLinkedin  = "linkedin"
+	loginChannelTwitter   = "twitter"
+	loginChannelMicrosoft = "microsoft"
+	loginChannelGitlab    = "gitlab"
+	loginChannelApple     = "apple"
+	loginChannelSaml      = "saml"
+	loginChannelLdap      = "ldap"
+	loginChannelEmail     = "email"
+	loginChannelLocal     = "local"
+	loginChannelPasskey   = "passkey"
 )
 
 // available since v0.4.0
@@ -112,12 +133,6 @@ func parseRsaPublicKeyFromPem(pemStr string) (*rsa.PublicKey, error) {
 	return nil, errors.New("not RSA public key")
 }
 
-func encryptPassword(username, rawPassword string) string {
-	saltAndPwd := username + "." + rawPassword
-	out := sha1.Sum([]byte(saltAndPwd))
-	return strings.ToLower(hex.EncodeToString(out[:]))
-}
-
 // padRight adds "0" right right of a string until its length reach a specific value.
 func padRight(str string, l int) string {
 	for len(str) < l {
@@ -0,0 +1,399 @@
+package gvabe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	"github.com/dgrijalva/jwt-go"
+	hocon "github.com/go-akka/configuration"
+	"golang.org/x/oauth2"
+
+	"main/src/gvabe/bo/app"
+	"main/src/itineris"
+)
+
+const (
+	appleIssuer  = "https://appleid.apple.com"
+	appleJwksUri = appleIssuer + "/auth/keys"
+
+	// lifetime of generated client secrets (Apple allows up to 6 months)
+	appleClientSecretTtl = 1 * time.Hour
+)
+
+var (
+	appleOAuthConf = &oauth2.Config{
+		ClientID:     "", // the Services ID (or App ID for native apps)
+		ClientSecret: "", // not used, client secret is generated from the private key for each token request
+		Scopes:       []string{"name", "email"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   appleIssuer + "/auth/authorize",
+			TokenURL:  appleIssuer + "/auth/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+
+	appleTeamId      string
+	appleKeyId       string
+	applePrivKey     *ecdsa.PrivateKey
+	appleCallbackUrl string // Apple's form_post callback is forwarded to this url
+	appleKeySet      = newJwksKeySet(appleJwksUri, nil)
+
+	appleClientSecretLock   sync.Mutex
+	appleClientSecret       string
+	appleClientSecretExpiry time.Time
+)
+
+// parseApplePrivateKey parses the ECDSA private key from the content of the .p8 file downloaded from Apple Developer portal.
+//
+// available since v0.8.0
+func parseApplePrivateKey(pemData []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("cannot decode PEM data")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// genAppleClientSecret generates a client secret, which is an ES256-signed JWT, to authenticate with Apple's token endpoint.
+//
+// available since v0.8.0
+func genAppleClientSecret(teamId, clientId, keyId string, key *ecdsa.PrivateKey, now time.Time, ttl time.Duration) (string, error) {
+	if key == nil {
+		return "", errors.New("no private key to sign Apple client secret")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.StandardClaims{
+		Issuer:    teamId,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		Audience:  appleIssuer,
+		Subject:   clientId,
+	})
+	token.Header["kid"] = keyId
+	return token.SignedString(key)
+}
+
+// getAppleClientSecret returns the cached client secret, a new one is generated if the cached one is about to expire.
+func getAppleClientSecret() (string, error) {
+	appleClientSecretLock.Lock()
+	defer appleClientSecretLock.Unlock()
+	now := time.Now()
+	if appleClientSecret == "" || now.Add(5*time.Minute).After(appleClientSecretExpiry) {
+		secret, err := genAppleClientSecret(appleTeamId, appleOAuthConf.ClientID, appleKeyId, applePrivKey, now, appleClientSecretTtl)
+		if err != nil {
+			return "", err
+		}
+		appleClientSecret = secret
+		appleClientSecretExpiry = now.Add(appleClientSecretTtl)
+	}
+	return appleClientSecret, nil
+}
+
+// appleExchange exchanges the authorization code for tokens.
+//
+// available since v0.8.0
+func appleExchange(ctx context.Context, authCode string) (*oauth2.Token, error) {
+	clientSecret, err := getAppleClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	conf := *appleOAuthConf
+	conf.ClientSecret = clientSecret
+	return conf.Exchange(ctx, authCode, oauth2.AccessTypeOnline)
+}
+
+// verifyAppleIdToken verifies the id_token issued by Apple (signature, issuer, audience, expiry and nonce) and returns its claims.
+//
+// available since v0.8.0
+func verifyAppleIdToken(ctx context.Context, ks *jwksKeySet, clientId, idToken, nonce string) (jwt.MapClaims, error) {
+	claims, err := parseAndVerifyJwtWithKeySet(ctx, ks, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != appleIssuer {
+		return nil, fmt.Errorf("invalid id_token issuer: %s", iss)
+	}
+	if !jwtClaimsHasAudience(claims, clientId) {
+		return nil, fmt.Errorf("id_token is not issued for client [%s]", clientId)
+	}
+	if nonce != "" {
+		if v, _ := claims["nonce"].(string); v != nonce {
+			return nil, errors.New("id_token nonce mismatch")
+		}
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("id_token does not contain subject")
+	}
+	return claims, nil
+}
+
+// appleUser captures the "user" object that Apple posts to the callback url.
+// Note: Apple sends this object only on the first authorization of the user.
+//
+// available since v0.8.0
+type appleUser struct {
+	Name struct {
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"name"`
+	Email string `json:"email"`
+}
+
+// parseAppleUser parses the JSON-encoded "user" object, nil is returned if the input is empty or invalid.
+func parseAppleUser(data string) *appleUser {
+	if strings.TrimSpace(data) == "" {
+		return nil
+	}
+	au := &appleUser{}
+	if err := json.Unmarshal([]byte(data), au); err != nil {
+		return nil
+	}
+	return au
+}
+
+// buildAppleCallbackRedirectUrl builds the url that Apple's form_post callback is forwarded to.
+//
+// available since v0.8.0
+func buildAppleCallbackRedirectUrl(callbackUrl string, params map[string]string) (string, error) {
+	u, err := url.Parse(callbackUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for k, v := range params {
+		if v != "" {
+			query.Set(k, v)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// appleSessionData is stored as Session.Data of pre-login sessions created by Apple login channel.
+//
+// available since v0.8.0
+type appleSessionData struct {
+	Token  *oauth2.Token          `json:"token"`  // tokens returned by Apple
+	Claims map[string]interface{} `json:"claims"` // verified claims of the id_token
+	User   *appleUser             `json:"user"`   // user's info, only available on the first authorization
+}
+
+// fetchAppleProfile builds user's profile from the verified id_token claims and user's name posted by Apple, then
+// upgrades the pre-login session to login session.
+//
+// available since v0.8.0
+func fetchAppleProfile(sessId string) error {
+	sess, err := loadPreLoginSession(sessId, loginChannelApple)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	sessData := &appleSessionData{}
+	if err := json.Unmarshal(sess.Data, &sessData); err != nil || sessData.Token == nil {
+		return jobPermanentError(fmt.Errorf("error unmarshalling session data: %v", err))
+	}
+	ident, err := loginIdentityFromAppleClaims(sessData.Claims, sessData.User)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	u, err := resolveLoginIdentity(ident, sess.ClientId, sess.LinkUserId)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	js, _ := json.Marshal(sessData.Token)
+	sess.UserId = u.GetId()
+	sess.DisplayName = u.GetDisplayName()
+	sess.ExpiredAt = sessData.Token.Expiry
+	sess.Data = js
+	return upgradePreLoginSession(sessId, sess)
+}
+
+// appleUserIdFromClaims returns the user-id built from Apple id_token's claims.
+//
+// User's email address is used as user-id if verified; this includes private relay addresses ("Hide My Email")
+// which are stable per user and application. Otherwise user-id is built from Apple's immutable user-id as
+// "apple:<apple-user-id>" (see userIdFromLoginIdentity).
+func appleUserIdFromClaims(claims map[string]interface{}) (string, error) {
+	email, _ := claims["email"].(string)
+	if email = strings.TrimSpace(email); email != "" && claimIsTrue(claims, "email_verified") {
+		return email, nil
+	}
+	sub, _ := claims["sub"].(string)
+	if sub = strings.TrimSpace(sub); sub == "" {
+		return "", errors.New("apple id_token does not contain subject")
+	}
+	return loginChannelApple + ":" + sub, nil
+}
+
+// available since v0.8.0
+func loginIdentityFromAppleClaims(claims map[string]interface{}, au *appleUser) (*LoginIdentity, error) {
+	userId, err := appleUserIdFromClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	// user id is built from the subject id if Apple does not return a verified email address
+	email, _ := claims["email"].(string)
+	ident := &LoginIdentity{Provider: loginChannelApple, Subject: strings.TrimSpace(sub), Email: strings.TrimSpace(email),
+		EmailVerified: userId == strings.TrimSpace(email)}
+	// Apple sends user's name only on the first authorization, it must be persisted then
+	if au != nil {
+		ident.DisplayName = strings.TrimSpace(au.Name.FirstName + " " + au.Name.LastName)
+		ident.GivenName, ident.FamilyName = au.Name.FirstName, au.Name.LastName
+	}
+	return ident, nil
+}
+
+// appleLoginChannel implements LoginChannel for Sign in with Apple.
+//
+// available since v0.8.0
+type appleLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *appleLoginChannel) Init(conf *hocon.Config) error {
+	teamId := strings.TrimSpace(conf.GetString("gvabe.channels.apple.team_id"))
+	if teamId == "" {
+		log.Println("[ERROR] No valid Apple team-id defined at [gvabe.channels.apple.team_id]")
+	}
+	clientId := strings.TrimSpace(conf.GetString("gvabe.channels.apple.client_id"))
+	if clientId == "" {
+		log.Println("[ERROR] No valid Apple client-id (Services ID) defined at [gvabe.channels.apple.client_id]")
+	}
+	keyId := strings.TrimSpace(conf.GetString("gvabe.channels.apple.key_id"))
+	if keyId == "" {
+		log.Println("[ERROR] No valid Apple key-id defined at [gvabe.channels.apple.key_id]")
+	}
+	privKeyPem := strings.TrimSpace(conf.GetString("gvabe.channels.apple.private_key"))
+	if privKeyFile := strings.TrimSpace(conf.GetString("gvabe.channels.apple.private_key_file")); privKeyPem == "" && privKeyFile != "" {
+		log.Println(fmt.Sprintf("[INFO] Loading Apple private key from [%s]...", privKeyFile))
+		if content, err := ioutil.ReadFile(privKeyFile); err != nil {
+			log.Println(fmt.Sprintf("[ERROR] Cannot read Apple private key from [%s]: %e", privKeyFile, err))
+		} else {
+			privKeyPem = string(content)
+		}
+	}
+	if privKeyPem == "" {
+		log.Println("[ERROR] No valid Apple private key defined at [gvabe.channels.apple.private_key] or [gvabe.channels.apple.private_key_file]")
+	} else if privKey, err := parseApplePrivateKey([]byte(privKeyPem)); err != nil {
+		log.Println(fmt.Sprintf("[ERROR] Cannot parse Apple private key: %e", err))
+	} else {
+		applePrivKey = privKey
+	}
+	redirectUri := strings.TrimSpace(conf.GetString("gvabe.channels.apple.redirect_uri"))
+	if redirectUri == "" {
+		log.Println("[ERROR] No valid Apple redirect-uri defined at [gvabe.channels.apple.redirect_uri]")
+	}
+	callbackUrl := strings.TrimSpace(conf.GetString("gvabe.channels.apple.callback_url"))
+	if callbackUrl == "" {
+		callbackUrl = exterHomeUrl
+	}
+	appleTeamId = teamId
+	appleKeyId = keyId
+	appleCallbackUrl = callbackUrl
+	appleOAuthConf.ClientID = clientId
+	appleOAuthConf.RedirectURL = redirectUri
+	if DEBUG && clientId != "" {
+		log.Printf("[DEBUG] appleLoginChannel.Init: %s/%s/%s/%s/%s", teamId, clientId, keyId, redirectUri, callbackUrl)
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *appleLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"apple_client_id": appleOAuthConf.ClientID, "apple_redirect_uri": appleOAuthConf.RedirectURL}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl: Apple requires response mode "form_post" when name or email is requested.
+func (ch *appleLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	opts = append(opts, oauth2.SetAuthURLParam("response_mode", "form_post"))
+	return appleOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Login implements LoginHandler.Login.
+func (ch *appleLoginChannel) Login(apiCtx *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil).(string)
+	userData := _extractParam(params, "user", reddo.TypeString, "", nil).(string)
+	nonce := _extractParam(params, "nonce", reddo.TypeString, "", nil).(string)
+	if DEBUG {
+		log.Printf("[DEBUG] START appleLoginChannel.Login")
+		t := time.Now().UnixNano()
+		defer func() {
+			d := time.Now().UnixNano() - t
+			log.Printf("[DEBUG] END appleLoginChannel.Login: %d ms", d/1000000)
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// firstly exchange authCode for tokens
+	token, err := appleExchange(ctx, authCode)
+	if err != nil {
+		if DEBUG {
+			log.Printf("[DEBUG] ERROR appleLoginChannel.Login: %s", err)
+		}
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	} else if token == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
+	}
+	// secondly verify the id_token
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("Error: no id_token returned from Apple")
+	}
+	idTokenClaims, err := verifyAppleIdToken(ctx, appleKeySet, appleOAuthConf.ClientID, idToken, nonce)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	now := time.Now()
+	if token.Expiry.IsZero() {
+		token.Expiry = now.Add(1 * time.Hour)
+	}
+	// thirdly embed tokens, verified claims and user's name into exter's session as a JWT
+	js, _ := json.Marshal(appleSessionData{Token: token, Claims: idTokenClaims, User: parseAppleUser(userData)})
+	claims, err := genPreLoginClaims(&Session{
+		ClientId:   app.GetId(),
+		Channel:    loginChannelApple,
+		CreatedAt:  now,
+		ExpiredAt:  token.Expiry,
+		Data:       js, // JSON-serialization of appleSessionData
+		LinkUserId: _linkUserIdFromContext(apiCtx),
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	// lastly build user profile from claims
+	if err := enqueueLoginProfileJob(loginChannelApple, claims.Id); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
+	return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
+
+// FetchLoginProfile implements LoginProfileFetcher.FetchLoginProfile.
+func (ch *appleLoginChannel) FetchLoginProfile(sessId string) error {
+	return fetchAppleProfile(sessId)
+}
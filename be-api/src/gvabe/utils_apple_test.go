@@ -0,0 +1,171 @@
+package gvabe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func _genAppleTestKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate EC key: %s", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal EC key: %s", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestGenAppleClientSecret(t *testing.T) {
+	testName := "TestGenAppleClientSecret"
+	_, pemData := _genAppleTestKey(t)
+	key, err := parseApplePrivateKey(pemData)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	now := time.Now()
+	secret, err := genAppleClientSecret("TEAMID", "com.example.exter", "KEYID", key, now, time.Hour)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	token, err := jwt.Parse(secret, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodES256 {
+			t.Fatalf("%s failed: expected signing method ES256 but received %v", testName, token.Header["alg"])
+		}
+		return &key.PublicKey, nil
+	})
+	if err != nil || !token.Valid {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if kid := token.Header["kid"]; kid != "KEYID" {
+		t.Fatalf("%s failed: expected kid %#v but received %#v", testName, "KEYID", kid)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	expected := map[string]interface{}{"iss": "TEAMID", "sub": "com.example.exter", "aud": appleIssuer}
+	for k, v := range expected {
+		if claims[k] != v {
+			t.Fatalf("%s failed: expected claim %s to be %#v but received %#v", testName, k, v, claims[k])
+		}
+	}
+
+	if _, err := parseApplePrivateKey([]byte("invalid")); err == nil {
+		t.Fatalf("%s failed: expected error for invalid private key", testName)
+	}
+}
+
+func TestVerifyAppleIdToken(t *testing.T) {
+	testName := "TestVerifyAppleIdToken"
+	key, _ := _genAppleTestKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "EC", "use": "sig", "alg": "ES256", "kid": "applekey", "crv": "P-256",
+				"x": base64.RawURLEncoding.EncodeToString(key.PublicKey.X.Bytes()),
+				"y": base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.Bytes()),
+			}},
+		})
+	}))
+	defer server.Close()
+	ks := newJwksKeySet(server.URL, nil)
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = "applekey"
+		jws, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		return jws
+	}
+
+	now := time.Now().Unix()
+	claims := jwt.MapClaims{
+		"iss": appleIssuer, "aud": "com.example.exter", "sub": "001234.abcdef", "iat": now, "exp": now + 600,
+		"nonce": "nonce1", "email": "abc123@privaterelay.appleid.com", "email_verified": "true", "is_private_email": "true",
+	}
+	verifiedClaims, err := verifyAppleIdToken(context.Background(), ks, "com.example.exter", sign(claims), "nonce1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if userId, err := appleUserIdFromClaims(verifiedClaims); err != nil || userId != "abc123@privaterelay.appleid.com" {
+		t.Fatalf("%s failed: expected user-id %#v but received %#v / %s", testName, "abc123@privaterelay.appleid.com", userId, err)
+	}
+
+	if _, err := verifyAppleIdToken(context.Background(), ks, "com.example.another", sign(claims), ""); err == nil {
+		t.Fatalf("%s failed: expected error for wrong audience", testName)
+	}
+	if _, err := verifyAppleIdToken(context.Background(), ks, "com.example.exter", sign(claims), "nonce2"); err == nil {
+		t.Fatalf("%s failed: expected error for nonce mismatch", testName)
+	}
+	claims["iss"] = "https://another.example.com"
+	if _, err := verifyAppleIdToken(context.Background(), ks, "com.example.exter", sign(claims), ""); err == nil {
+		t.Fatalf("%s failed: expected error for wrong issuer", testName)
+	}
+}
+
+func TestAppleUserIdFromClaims(t *testing.T) {
+	testName := "TestAppleUserIdFromClaims"
+	testCases := []struct {
+		claims   map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"sub": "001234.abcdef", "email": "user@example.com", "email_verified": true}, "user@example.com"},
+		{map[string]interface{}{"sub": "001234.abcdef", "email": "user@example.com", "email_verified": "false"}, "apple:001234.abcdef"},
+		{map[string]interface{}{"sub": "001234.abcdef"}, "apple:001234.abcdef"},
+	}
+	for i, testCase := range testCases {
+		if userId, err := appleUserIdFromClaims(testCase.claims); err != nil || userId != testCase.expected {
+			t.Fatalf("%s failed at case #%d: expected %#v but received %#v / %s", testName, i, testCase.expected, userId, err)
+		}
+	}
+	if _, err := appleUserIdFromClaims(map[string]interface{}{}); err == nil {
+		t.Fatalf("%s failed: expected error for missing subject", testName)
+	}
+}
+
+func TestParseAppleUser(t *testing.T) {
+	testName := "TestParseAppleUser"
+	au := parseAppleUser(`{"name":{"firstName":"John","lastName":"Doe"},"email":"john@example.com"}`)
+	if au == nil || au.Name.FirstName != "John" || au.Name.LastName != "Doe" {
+		t.Fatalf("%s failed: %#v", testName, au)
+	}
+	if au := parseAppleUser(""); au != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, au)
+	}
+	if au := parseAppleUser("invalid"); au != nil {
+		t.Fatalf("%s failed: expected nil but received %#v", testName, au)
+	}
+}
+
+func TestBuildAppleCallbackRedirectUrl(t *testing.T) {
+	testName := "TestBuildAppleCallbackRedirectUrl"
+	redirectUrl, err := buildAppleCallbackRedirectUrl("https://exter.example.com/app/xlogin?foo=bar",
+		map[string]string{"cba": "apple", "code": "code1", "state": "state1", "user": `{"name":{"firstName":"John"}}`, "error": ""})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	u, _ := url.Parse(redirectUrl)
+	query := u.Query()
+	expected := map[string]string{"foo": "bar", "cba": "apple", "code": "code1", "state": "state1", "user": `{"name":{"firstName":"John"}}`}
+	for k, v := range expected {
+		if query.Get(k) != v {
+			t.Fatalf("%s failed: expected param %s to be %#v but received %#v", testName, k, v, query.Get(k))
+		}
+	}
+	if _, ok := query["error"]; ok {
+		t.Fatalf("%s failed: empty param should not be forwarded", testName)
+	}
+}
@@ -0,0 +1,391 @@
+package gvabe
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/session"
+	"main/src/itineris"
+)
+
+const (
+	// session type of link token records
+	sessionTypeEmailLink = "email_link"
+
+	emailLinkDefaultTtl          = 15 * time.Minute
+	emailRateLimitDefaultMax     = 5
+	emailRateLimitDefaultWindow  = 1 * time.Hour
+	emailDefaultSubject          = "Your login link"
+	emailMailSenderSmtp          = "smtp"
+	emailMailSenderFile          = "file"
+	emailMailSenderLog           = "log"
+	emailRateLimiterSweepTrigger = 10000
+)
+
+var (
+	errorEmailInvalidAddress = errors.New("invalid email address")
+	errorEmailRateLimited    = errors.New("too many login requests for this email address, please try again later")
+	errorEmailInvalidLink    = errors.New("login link is invalid, expired or has already been used")
+
+	// settings of email login channel, nil if the channel is not enabled
+	emailConf *emailLoginConfig
+
+	// sends emails (login links, password reset links, etc), nil if not configured
+	mailer mailSender
+)
+
+// emailLoginConfig holds settings of the passwordless email login channel.
+//
+// available since v0.8.0
+type emailLoginConfig struct {
+	linkUrl     string        // url of the "emailLoginVerify" API, the link token is appended as query parameter "token"
+	linkTtl     time.Duration // lifetime of link tokens
+	subject     string        // subject of login emails
+	rateLimiter *emailRateLimiter
+}
+
+/*----------------------------------------------------------------------*/
+
+// mailSender sends emails.
+//
+// available since v0.8.0
+type mailSender interface {
+	// SendMail sends a plain-text email.
+	SendMail(to, subject, body string) error
+}
+
+// buildMailMessage builds a RFC 5322 plain-text message.
+func buildMailMessage(from, to, subject, body string, now time.Time) []byte {
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body + "\r\n")
+}
+
+// smtpMailSender sends emails via a SMTP server; STARTTLS is used if the server supports it.
+//
+// available since v0.8.0
+type smtpMailSender struct {
+	addr string // host:port of the SMTP server
+	from string
+	auth smtp.Auth // nil if the server does not require authentication
+}
+
+// SendMail implements mailSender.SendMail.
+func (s *smtpMailSender) SendMail(to, subject, body string) error {
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to}, buildMailMessage(s.from, to, subject, body, time.Now()))
+}
+
+// fileMailSender writes emails to files (or to the log if no directory is specified) instead of sending them; for development only.
+//
+// available since v0.8.0
+type fileMailSender struct {
+	from string
+	dir  string
+}
+
+// SendMail implements mailSender.SendMail.
+func (s *fileMailSender) SendMail(to, subject, body string) error {
+	now := time.Now()
+	msg := buildMailMessage(s.from, to, subject, body, now)
+	if s.dir == "" {
+		log.Println(fmt.Sprintf("[INFO] Email to <%s>:\n%s", to, msg))
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf("%s-%s.eml", now.Format("20060102150405.000000"), strings.NewReplacer("@", "_at_", "/", "_").Replace(to))
+	return ioutil.WriteFile(filepath.Join(s.dir, fileName), msg, 0640)
+}
+
+/*----------------------------------------------------------------------*/
+
+// emailRateLimiter limits the number of login requests per email address within a sliding time window.
+//
+// Note: the counters are kept in memory, i.e. per Exter instance.
+//
+// available since v0.8.0
+type emailRateLimiter struct {
+	lock   sync.Mutex
+	max    int
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+func newEmailRateLimiter(max int, window time.Duration) *emailRateLimiter {
+	return &emailRateLimiter{max: max, window: window, hits: make(map[string][]time.Time)}
+}
+
+// recentHits returns the requests of the key within the window ending at now; caller must hold the lock.
+func (l *emailRateLimiter) recentHits(key string, now time.Time) []time.Time {
+	if len(l.hits) >= emailRateLimiterSweepTrigger {
+		for k, hits := range l.hits {
+			if len(hits) == 0 || !hits[len(hits)-1].After(now.Add(-l.window)) {
+				delete(l.hits, k)
+			}
+		}
+	}
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(now.Add(-l.window)) {
+		i++
+	}
+	return hits[i:]
+}
+
+// allow records a request for the key and returns false if the key has exceeded its quota.
+func (l *emailRateLimiter) allow(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	hits := l.recentHits(key, now)
+	if len(hits) >= l.max {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// exceeded returns true if the key has exhausted its quota, without recording a request.
+//
+// available since v0.8.0
+func (l *emailRateLimiter) exceeded(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	hits := l.recentHits(key, now)
+	if len(hits) == 0 {
+		delete(l.hits, key)
+	} else {
+		l.hits[key] = hits
+	}
+	return len(hits) >= l.max
+}
+
+/*----------------------------------------------------------------------*/
+
+// normalizeEmailAddress validates a bare email address (without display name) and returns its normalized form.
+func normalizeEmailAddress(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", errorEmailInvalidAddress
+	}
+	return email, nil
+}
+
+// hashEmailLinkToken returns the id of the session record storing a link token; only the token's hash is stored.
+func hashEmailLinkToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// emailSessionData is stored as Session.Data of pre-login sessions created by email login channel.
+//
+// available since v0.8.0
+type emailSessionData struct {
+	Email     string `json:"email"`      // email address the login link is sent to
+	ReturnUrl string `json:"return_url"` // url to redirect user to once logged in
+}
+
+// emailLinkData is stored as session data of link token records.
+type emailLinkData struct {
+	SessionId string `json:"sid"` // id of the pre-login session to be upgraded when the link is clicked
+}
+
+// buildEmailLoginLink builds a link from the configured link url and the token.
+func buildEmailLoginLink(linkUrl, token string) (string, error) {
+	u, err := url.Parse(linkUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// issueLinkToken generates a single-use token, stores it (hashed) via sessionDao as a record of the specified type
+// and emails the link built from linkUrl and the token to the user. The mail body is built by bodyFunc from the link.
+//
+// available since v0.8.0
+func issueLinkToken(sessionType, channel, appId, email string, data interface{}, linkUrl string, expiry time.Time,
+	subject string, bodyFunc func(link string) string) error {
+	if mailer == nil {
+		return errors.New("no mail sender configured")
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+	link, err := buildEmailLoginLink(linkUrl, token)
+	if err != nil {
+		return err
+	}
+	js, _ := json.Marshal(data)
+	linkSess := session.NewSession(goapi.AppVersionNumber, hashEmailLinkToken(token), sessionType, channel, appId, email, string(js), expiry)
+	if _, err := sessionDao.Save(linkSess); err != nil {
+		return err
+	}
+	if err := mailer.SendMail(email, subject, bodyFunc(link)); err != nil {
+		sessionDao.Delete(linkSess)
+		return err
+	}
+	return nil
+}
+
+// consumeLinkToken verifies a link token issued by issueLinkToken and deletes it so that it can not be used again.
+// The email address the link was sent to is returned and the token's data is unmarshalled into data.
+//
+// available since v0.8.0
+func consumeLinkToken(sessionType, token string, data interface{}) (string, error) {
+	if token = strings.TrimSpace(token); token == "" {
+		return "", errorEmailInvalidLink
+	}
+	linkSess, err := sessionDao.Get(hashEmailLinkToken(token))
+	if err != nil {
+		return "", err
+	}
+	if linkSess == nil || linkSess.GetSessionType() != sessionType {
+		return "", errorEmailInvalidLink
+	}
+	// delete first: only the request that actually removes the record may use the token
+	if ok, err := sessionDao.Delete(linkSess); err != nil {
+		return "", err
+	} else if !ok || linkSess.IsExpired() {
+		return "", errorEmailInvalidLink
+	}
+	if err := json.Unmarshal([]byte(linkSess.GetSessionData()), data); err != nil {
+		return "", errorEmailInvalidLink
+	}
+	return linkSess.GetUserId(), nil
+}
+
+// issueLoginLink generates a single-use link token for the pre-login session and emails the login link to the user.
+//
+// available since v0.8.0
+func (conf *emailLoginConfig) issueLoginLink(appId, email, preLoginSessId string, now time.Time) error {
+	return issueLinkToken(sessionTypeEmailLink, loginChannelEmail, appId, email, emailLinkData{SessionId: preLoginSessId},
+		conf.linkUrl, now.Add(conf.linkTtl), conf.subject, func(link string) string {
+			return fmt.Sprintf("Click the following link to login to %s:\n\n%s\n\nThe link expires in %d minutes and can be used only once. If you did not request it, please ignore this email.\n",
+				goapi.AppConfig.GetString("app.name"), link, int(conf.linkTtl/time.Minute))
+		})
+}
+
+// consumeLoginLink verifies a login link token and deletes it so that it can not be used again.
+// The id of the pre-login session and the email address the link was sent to are returned.
+//
+// available since v0.8.0
+func consumeLoginLink(token string) (string, string, error) {
+	data := emailLinkData{}
+	email, err := consumeLinkToken(sessionTypeEmailLink, token, &data)
+	if err != nil {
+		return "", "", err
+	}
+	if data.SessionId == "" {
+		return "", "", errorEmailInvalidLink
+	}
+	return data.SessionId, email, nil
+}
+
+// emailLoginChannel implements LoginChannel for passwordless login: users login with links emailed to them.
+//
+// available since v0.8.0
+type emailLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *emailLoginChannel) Init(conf *hocon.Config) error {
+	linkUrl := strings.TrimSpace(conf.GetString("gvabe.channels.email.link_url"))
+	if linkUrl == "" {
+		return errors.New("no valid login link url defined at [gvabe.channels.email.link_url]")
+	}
+	cfg := &emailLoginConfig{
+		linkUrl: linkUrl,
+		linkTtl: conf.GetTimeDuration("gvabe.channels.email.link_ttl", emailLinkDefaultTtl),
+		subject: strings.TrimSpace(conf.GetString("gvabe.channels.email.subject")),
+		rateLimiter: newEmailRateLimiter(
+			int(conf.GetInt32("gvabe.channels.email.rate_limit.max", emailRateLimitDefaultMax)),
+			conf.GetTimeDuration("gvabe.channels.email.rate_limit.window", emailRateLimitDefaultWindow)),
+	}
+	if cfg.subject == "" {
+		cfg.subject = emailDefaultSubject
+	}
+	if mailer == nil {
+		return errors.New("email login channel requires a mail sender defined at [gvabe.mail]")
+	}
+	emailConf = cfg
+	if DEBUG {
+		log.Printf("[DEBUG] emailLoginChannel.Init: %s/%s", linkUrl, cfg.linkTtl)
+	}
+	return nil
+}
+
+// Login implements LoginHandler.Login.
+func (ch *emailLoginChannel) Login(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	email := _extractParam(params, "email", reddo.TypeString, "", nil).(string)
+	if emailConf == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Email login channel is not configured")
+	}
+	email, err := normalizeEmailAddress(email)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(err.Error())
+	}
+	now := time.Now()
+	if !emailConf.rateLimiter.allow(email, now) {
+		return itineris.NewApiResult(itineris.StatusTooManyRequests).SetMessage(errorEmailRateLimited.Error())
+	}
+
+	// firstly create the pre-login session, client polls it with API "verifyLoginToken"
+	js, _ := json.Marshal(emailSessionData{Email: email, ReturnUrl: returnUrl})
+	claims, err := genPreLoginClaims(&Session{
+		ClientId:  app.GetId(),
+		Channel:   loginChannelEmail,
+		CreatedAt: now,
+		ExpiredAt: now.Add(emailConf.linkTtl),
+		Data:      js, // JSON-serialization of emailSessionData
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+
+	// secondly email the login link
+	if err := emailConf.issueLoginLink(app.GetId(), email, claims.Id, now); err != nil {
+		log.Printf("[ERROR] emailLoginChannel.Login - error sending login link to <%s>: %s", email, err)
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Cannot send login link, please try again later")
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetMessage("Login link has been sent to " + email)
+}
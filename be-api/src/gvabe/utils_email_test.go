@@ -0,0 +1,107 @@
+package gvabe
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailRateLimiter_allow(t *testing.T) {
+	name := "TestEmailRateLimiter_allow"
+	limiter := newEmailRateLimiter(2, time.Minute)
+	now := time.Now()
+	if !limiter.allow("a@example.com", now) || !limiter.allow("a@example.com", now.Add(10*time.Second)) {
+		t.Fatalf("%s failed: requests within quota must be allowed", name)
+	}
+	if limiter.allow("a@example.com", now.Add(20*time.Second)) {
+		t.Fatalf("%s failed: requests exceeding quota must be rejected", name)
+	}
+	if !limiter.allow("b@example.com", now.Add(20*time.Second)) {
+		t.Fatalf("%s failed: quota is per key", name)
+	}
+	if !limiter.allow("a@example.com", now.Add(61*time.Second)) {
+		t.Fatalf("%s failed: oldest request has left the window", name)
+	}
+	if limiter.allow("a@example.com", now.Add(62*time.Second)) {
+		t.Fatalf("%s failed: window is sliding", name)
+	}
+}
+
+func TestNormalizeEmailAddress(t *testing.T) {
+	name := "TestNormalizeEmailAddress"
+	if email, err := normalizeEmailAddress("  John.Doe@Example.COM "); err != nil || email != "john.doe@example.com" {
+		t.Fatalf("%s failed: %#v / %s", name, email, err)
+	}
+	for _, input := range []string{"", "john", "john@", "John <john@example.com>", "a@example.com, b@example.com"} {
+		if _, err := normalizeEmailAddress(input); err != errorEmailInvalidAddress {
+			t.Fatalf("%s failed: [%s] must be rejected", name, input)
+		}
+	}
+}
+
+func TestBuildEmailLoginLink(t *testing.T) {
+	name := "TestBuildEmailLoginLink"
+	link, err := buildEmailLoginLink("https://exter.example.com/api/login/email/verify?lang=en", "abc")
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	u, _ := url.Parse(link)
+	if u.Host != "exter.example.com" || u.Query().Get("token") != "abc" || u.Query().Get("lang") != "en" {
+		t.Fatalf("%s failed: %s", name, link)
+	}
+	if hashEmailLinkToken("abc") == "abc" || hashEmailLinkToken("abc") != hashEmailLinkToken("abc") {
+		t.Fatalf("%s failed: invalid token hash", name)
+	}
+}
+
+func TestBuildMailMessage(t *testing.T) {
+	name := "TestBuildMailMessage"
+	msg := string(buildMailMessage("exter@example.com", "john@example.com", "Đăng nhập", "line 1\nline 2", time.Now()))
+	for _, expected := range []string{"From: exter@example.com\r\n", "To: john@example.com\r\n", "Subject: =?utf-8?q?", "\r\n\r\nline 1\r\nline 2\r\n"} {
+		if !strings.Contains(msg, expected) {
+			t.Fatalf("%s failed: expected %#v in message\n%s", name, expected, msg)
+		}
+	}
+}
+
+func TestFileMailSender_SendMail(t *testing.T) {
+	name := "TestFileMailSender_SendMail"
+	dir, err := ioutil.TempDir("", "exter-mails")
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	defer os.RemoveAll(dir)
+	sender := &fileMailSender{from: "exter@example.com", dir: filepath.Join(dir, "mails")}
+	if err := sender.SendMail("john@example.com", "Your login link", "https://exter.example.com/?token=abc"); err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "mails", "*.eml"))
+	if len(files) != 1 || !strings.Contains(files[0], "john_at_example.com") {
+		t.Fatalf("%s failed: %#v", name, files)
+	}
+	content, _ := ioutil.ReadFile(files[0])
+	if !strings.Contains(string(content), "https://exter.example.com/?token=abc") {
+		t.Fatalf("%s failed: %s", name, content)
+	}
+}
+
+func TestEmailRateLimiter_exceeded(t *testing.T) {
+	name := "TestEmailRateLimiter_exceeded"
+	limiter := newEmailRateLimiter(2, time.Minute)
+	now := time.Now()
+	if limiter.exceeded("a@example.com", now) || limiter.exceeded("a@example.com", now) {
+		t.Fatalf("%s failed: exceeded must not record requests", name)
+	}
+	limiter.allow("a@example.com", now)
+	limiter.allow("a@example.com", now)
+	if !limiter.exceeded("a@example.com", now.Add(time.Second)) {
+		t.Fatalf("%s failed: quota has been exhausted", name)
+	}
+	if limiter.exceeded("a@example.com", now.Add(61*time.Second)) {
+		t.Fatalf("%s failed: requests have left the window", name)
+	}
+}
@@ -2,16 +2,18 @@ package gvabe
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/btnguyen2k/consu/reddo"
 	"github.com/btnguyen2k/consu/semita"
+	hocon "github.com/go-akka/configuration"
 	fbv2 "github.com/huandu/facebook/v2"
 	"golang.org/x/oauth2"
 	facebookoauth "golang.org/x/oauth2/facebook"
+
+	"main/src/itineris"
 )
 
 var (
@@ -79,58 +81,66 @@ func fbGetProfile(ctx context.Context, accessToken string) (map[string]interface
 	}
 	return fbApp.Session(accessToken).WithContext(ctx).Get(
 		"/me",
-		fbv2.Params{"access_token": accessToken, "fields": "email,name"},
+		fbv2.Params{"access_token": accessToken, "fields": "id,email,name,first_name,last_name,picture"},
 	)
 }
 
-// routine to fetch Facebook profile in background
-func goFetchFacebookProfile(sessId string) {
-	if bo, err := sessionDao.Get(sessId); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile(%s) - error loading session data: %e", sessId, err))
-	} else if bo == nil {
-		log.Println(fmt.Sprintf("[WARN] goFetchFacebookProfile(%s) - session does not exist", sessId))
-	} else if bo.IsExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchFacebookProfile(%s) - session expired", sessId))
-	} else if claims, err := parseLoginToken(bo.GetSessionData()); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile(%s) - cannot parse JWT token: %e", sessId, err))
-	} else if claims.Type != sessionTypePreLogin || claims.isExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchFacebookProfile(%s) - invalid claims type of JWT expired", sessId))
-	} else {
-		sess := &Session{}
-		if err := json.Unmarshal(claims.Data, &sess); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile(%s) - error decoding session: %e", sessId, err))
-			return
-		}
-		if sess.Channel != loginChannelFacebook {
-			log.Println(fmt.Sprintf("[WARN] goFetchFacebookProfile(%s) - invalid login channel: %s", sessId, sess.Channel))
-			return
-		}
-		oauth2Token := &oauth2.Token{}
-		if err := json.Unmarshal(sess.Data, &oauth2Token); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile - error unmarshalling oauth2.Token: %e", err))
-		} else {
-			ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-			if profile, err := fbGetProfile(ctx, oauth2Token.AccessToken); err != nil {
-				log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile - error fetching Facebook userinfo: %e", err))
-			} else {
-				if u, err := createUserAccountFromFacebookProfile(profile); err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile - error creating user account from Facebook userinfo: %e", err))
-				} else {
-					js, _ := json.Marshal(oauth2Token)
-					sess.UserId = u.GetId()
-					sess.DisplayName = u.GetDisplayName()
-					sess.ExpiredAt = oauth2Token.Expiry
-					sess.Data = js // JSON-serialization of oauth2.Token
-					claims, err := genLoginClaims(sessId, sess)
-					if err != nil {
-						log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile(%s) - error generating login token: %e", sessId, err))
-					}
-					_, _, err = saveSession(claims)
-					if err != nil {
-						log.Println(fmt.Sprintf("[ERROR] goFetchFacebookProfile(%s) - error saving login token: %e", sessId, err))
-					}
-				}
-			}
-		}
+// facebookLoginChannel implements LoginChannel for Facebook Login.
+//
+// available since v0.8.0
+type facebookLoginChannel struct{}
+
+// Name implements LoginChannel.Name.
+func (ch *facebookLoginChannel) Name() string {
+	return loginChannelFacebook
+}
+
+// Init implements LoginChannel.Init.
+func (ch *facebookLoginChannel) Init(conf *hocon.Config) error {
+	appId := strings.TrimSpace(conf.GetString("gvabe.channels.facebook.app_id"))
+	if appId == "" {
+		log.Println("[ERROR] No valid Facebook app-id defined at [gvabe.channels.facebook.app_id]")
+	}
+	appSecret := strings.TrimSpace(conf.GetString("gvabe.channels.facebook.app_secret"))
+	if appSecret == "" {
+		log.Println("[ERROR] No valid Facebook app-secret defined at [gvabe.channels.facebook.app_secret]")
+	}
+	fbOAuthConf.ClientID = appId
+	fbOAuthConf.ClientSecret = appSecret
+	fbOAuthConf.RedirectURL = exterHomeUrl
+	initFacebookApp(appId, appSecret)
+	if DEBUG && appId != "" && appSecret != "" {
+		log.Printf("[DEBUG] facebookLoginChannel.Init: %s/%s", appId, "***"+appSecret[len(appSecret)-4:])
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *facebookLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"facebook_app_id": fbOAuthConf.ClientID}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *facebookLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return fbOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements LoginChannel.Exchange: the "code" passed to the "login" API is a short-live access token,
+// which is exchanged for a long-live one.
+func (ch *facebookLoginChannel) Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error) {
+	accessToken := _extractParam(params, "code", reddo.TypeString, "", nil)
+	return fbExchangeForLongLiveToken(ctx, accessToken.(string))
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+func (ch *facebookLoginChannel) FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error) {
+	return fbGetProfile(ctx, token.AccessToken)
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *facebookLoginChannel) MapIdentity(profile interface{}) (*LoginIdentity, error) {
+	if fbProfile, ok := profile.(map[string]interface{}); ok {
+		return loginIdentityFromFacebookProfile(fbProfile)
 	}
+	return nil, errorProfileType(loginChannelFacebook, profile)
 }
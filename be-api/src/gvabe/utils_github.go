@@ -2,17 +2,20 @@ package gvabe
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 	githuboauth "golang.org/x/oauth2/github"
+
+	"main/src/itineris"
 )
 
 var (
@@ -24,56 +27,83 @@ var (
 	}
 )
 
-// routine to fetch GitHub profile in background
-func goFetchGitHubProfile(sessId string) {
-	if bo, err := sessionDao.Get(sessId); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile(%s) - error loading session data: %e", sessId, err))
-	} else if bo == nil {
-		log.Println(fmt.Sprintf("[WARN] goFetchGitHubProfile(%s) - session does not exist", sessId))
-	} else if bo.IsExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchGitHubProfile(%s) - session expired", sessId))
-	} else if claims, err := parseLoginToken(bo.GetSessionData()); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile(%s) - cannot parse JWT token: %e", sessId, err))
-	} else if claims.Type != sessionTypePreLogin || claims.isExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchGitHubProfile(%s) - invalid claims type of JWT expired", sessId))
-	} else {
-		sess := &Session{}
-		if err := json.Unmarshal(claims.Data, &sess); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile(%s) - error decoding session: %e", sessId, err))
-			return
-		}
-		if sess.Channel != loginChannelGithub {
-			log.Println(fmt.Sprintf("[WARN] goFetchGitHubProfile(%s) - invalid login channel: %s", sessId, sess.Channel))
-			return
-		}
-		ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-		oauth2Token := &oauth2.Token{}
-		if err := json.Unmarshal(sess.Data, &oauth2Token); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile - error unmarshalling oauth2.Token: %e", err))
-		} else if githubClient := github.NewClient(githubOAuthConf.Client(ctx, oauth2Token)); githubClient == nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile - error creating new GitHub API client: nill"))
-		} else if userinfo, _, err := githubClient.Users.Get(ctx, ""); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile - error fetching GitHub userinfo: %e", err))
-		} else {
-			if u, err := createUserAccountFromGitHubProfile(userinfo); err != nil {
-				log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile - error creating user account from GitHub userinfo: %e", err))
-			} else {
-				js, _ := json.Marshal(oauth2Token)
-				sess.UserId = u.GetId()
-				sess.DisplayName = u.GetDisplayName()
-				sess.ExpiredAt = oauth2Token.Expiry
-				sess.Data = js
-				claims, err := genLoginClaims(sessId, sess)
-				if err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile(%s) - error generating login token: %e", sessId, err))
-				}
-				_, _, err = saveSession(claims)
-				if err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchGitHubProfile(%s) - error saving login token: %e", sessId, err))
-				}
-			}
-		}
+// githubLoginChannel implements LoginChannel for GitHub OAuth apps.
+//
+// available since v0.8.0
+type githubLoginChannel struct{}
+
+// Name implements LoginChannel.Name.
+func (ch *githubLoginChannel) Name() string {
+	return loginChannelGithub
+}
+
+// Init implements LoginChannel.Init.
+func (ch *githubLoginChannel) Init(conf *hocon.Config) error {
+	clientId := strings.TrimSpace(conf.GetString("gvabe.channels.github.client_id"))
+	if clientId == "" {
+		log.Println("[ERROR] No valid Github OAuth app client-id defined at [gvabe.channels.github.client_id]")
+	}
+	clientSecret := strings.TrimSpace(conf.GetString("gvabe.channels.github.client_secret"))
+	if clientSecret == "" {
+		log.Println("[ERROR] No valid Github OAuth app client-secret defined at [gvabe.channels.github.client_secret]")
+	}
+	githubOAuthConf.ClientID = clientId
+	githubOAuthConf.ClientSecret = clientSecret
+	// githubOAuthConf.RedirectURL = exterHomeUrl //[btnguyen2k-20200904]: do NOT set RedirectURL, or else we encounter error "oauth2: server response missing access_token"
+	if DEBUG && clientId != "" && clientSecret != "" {
+		log.Printf("[DEBUG] githubLoginChannel.Init: %s/%s", clientId, "***"+clientSecret[len(clientSecret)-4:])
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *githubLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"github_client_id": githubOAuthConf.ClientID}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *githubLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return githubOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements LoginChannel.Exchange.
+func (ch *githubLoginChannel) Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error) {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
+	return githubOAuthConf.Exchange(ctx, authCode.(string), oauth2.AccessTypeOnline)
+}
+
+// githubProfile is user's GitHub profile along with user's email addresses: the profile's email is the public one,
+// which may be unverified or empty if user keeps email addresses private.
+//
+// available since v0.8.0
+type githubProfile struct {
+	User   *github.User
+	Emails []*github.UserEmail // user's email addresses, fetched from "/user/emails" (scope "user:email")
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+//
+// (since v0.8.0) User's email addresses are fetched along with the profile to find out a verified one.
+func (ch *githubLoginChannel) FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error) {
+	client := github.NewClient(githubOAuthConf.Client(ctx, token))
+	userinfo, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	emails, _, err := client.Users.ListEmails(ctx, nil)
+	if err != nil {
+		// the profile's public email address, if any, is used as unverified
+		log.Printf("[WARN] githubLoginChannel.FetchProfile - error fetching user's email addresses: %s", err)
+	}
+	return &githubProfile{User: userinfo, Emails: emails}, nil
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *githubLoginChannel) MapIdentity(profile interface{}) (*LoginIdentity, error) {
+	if gp, ok := profile.(*githubProfile); ok && gp != nil && gp.User != nil {
+		return loginIdentityFromGitHubProfile(gp)
 	}
+	return nil, errorProfileType(loginChannelGithub, profile)
 }
 
 func githubFetchUserProfile(ctx context.Context, accessToken string) (map[string]interface{}, error) {
@@ -0,0 +1,168 @@
+package gvabe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
+	"golang.org/x/oauth2"
+
+	"main/src/itineris"
+)
+
+const (
+	gitlabDefaultBaseUrl = "https://gitlab.com"
+
+	gitlabApiUser = "/api/v4/user"
+)
+
+var (
+	// base url of the GitLab server, either gitlab.com or a self-managed instance
+	gitlabBaseUrl = gitlabDefaultBaseUrl
+
+	gitlabOAuthConf = &oauth2.Config{
+		ClientID:     "",
+		ClientSecret: "",
+		Scopes:       []string{"read_user"},
+		Endpoint:     gitlabEndpoint(gitlabDefaultBaseUrl),
+	}
+)
+
+// gitlabEndpoint builds GitLab's OAuth2 endpoint from the server's base url.
+//
+// available since v0.8.0
+func gitlabEndpoint(baseUrl string) oauth2.Endpoint {
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+	return oauth2.Endpoint{
+		AuthURL:   baseUrl + "/oauth/authorize",
+		TokenURL:  baseUrl + "/oauth/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+}
+
+// gitlabUser captures (part of) user profile returned by GitLab API endpoint "/api/v4/user".
+//
+// available since v0.8.0
+type gitlabUser struct {
+	Id          int64   `json:"id"`
+	Username    string  `json:"username"`
+	Name        string  `json:"name"`
+	State       string  `json:"state"`        // "active", "blocked", etc
+	Email       string  `json:"email"`        // user's primary email address
+	ConfirmedAt *string `json:"confirmed_at"` // timestamp when the primary email address was confirmed, nil if not confirmed
+	AvatarUrl   string  `json:"avatar_url"`
+}
+
+// verifiedEmail returns user's primary email address if it has been confirmed, empty string otherwise.
+func (gu *gitlabUser) verifiedEmail() string {
+	if gu.ConfirmedAt == nil || strings.TrimSpace(*gu.ConfirmedAt) == "" {
+		return ""
+	}
+	return strings.TrimSpace(gu.Email)
+}
+
+// gitlabFetchUserProfile fetches the authenticated user's profile from GitLab API.
+//
+// available since v0.8.0
+func gitlabFetchUserProfile(ctx context.Context, httpClient *http.Client, baseUrl string) (*gitlabUser, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(baseUrl, "/")+gitlabApiUser, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("gitlab API response status: " + resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	gu := &gitlabUser{}
+	if err := json.Unmarshal(body, gu); err != nil {
+		return nil, err
+	}
+	if gu.Id == 0 {
+		return nil, errors.New("gitlab API response does not contain user id")
+	}
+	return gu, nil
+}
+
+// gitlabLoginChannel implements LoginChannel for GitLab (gitlab.com or self-managed) OAuth apps.
+//
+// available since v0.8.0
+type gitlabLoginChannel struct{}
+
+// Name implements LoginChannel.Name.
+func (ch *gitlabLoginChannel) Name() string {
+	return loginChannelGitlab
+}
+
+// Init implements LoginChannel.Init.
+func (ch *gitlabLoginChannel) Init(conf *hocon.Config) error {
+	baseUrl := strings.TrimSuffix(strings.TrimSpace(conf.GetString("gvabe.channels.gitlab.base_url")), "/")
+	if baseUrl == "" {
+		baseUrl = gitlabDefaultBaseUrl
+	}
+	clientId := strings.TrimSpace(conf.GetString("gvabe.channels.gitlab.client_id"))
+	if clientId == "" {
+		log.Println("[ERROR] No valid GitLab OAuth app client-id defined at [gvabe.channels.gitlab.client_id]")
+	}
+	clientSecret := strings.TrimSpace(conf.GetString("gvabe.channels.gitlab.client_secret"))
+	if clientSecret == "" {
+		log.Println("[ERROR] No valid GitLab OAuth app client-secret defined at [gvabe.channels.gitlab.client_secret]")
+	}
+	redirectUri := strings.TrimSpace(conf.GetString("gvabe.channels.gitlab.redirect_uri"))
+	if redirectUri == "" {
+		log.Println("[ERROR] No valid GitLab OAuth app redirect-uri defined at [gvabe.channels.gitlab.redirect_uri]")
+		redirectUri = exterHomeUrl
+	}
+	gitlabBaseUrl = baseUrl
+	gitlabOAuthConf.ClientID = clientId
+	gitlabOAuthConf.ClientSecret = clientSecret
+	gitlabOAuthConf.RedirectURL = redirectUri
+	gitlabOAuthConf.Endpoint = gitlabEndpoint(baseUrl)
+	if DEBUG && clientId != "" && clientSecret != "" {
+		log.Printf("[DEBUG] gitlabLoginChannel.Init: %s/%s/%s/%s", baseUrl, clientId, "***"+clientSecret[len(clientSecret)-4:], redirectUri)
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *gitlabLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"gitlab_client_id": gitlabOAuthConf.ClientID, "gitlab_base_url": gitlabBaseUrl}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *gitlabLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return gitlabOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements LoginChannel.Exchange.
+func (ch *gitlabLoginChannel) Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error) {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
+	return gitlabOAuthConf.Exchange(ctx, authCode.(string), oauth2.AccessTypeOnline)
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+func (ch *gitlabLoginChannel) FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error) {
+	return gitlabFetchUserProfile(ctx, gitlabOAuthConf.Client(ctx, token), gitlabBaseUrl)
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *gitlabLoginChannel) MapIdentity(profile interface{}) (*LoginIdentity, error) {
+	if gu, ok := profile.(*gitlabUser); ok && gu != nil {
+		return loginIdentityFromGitLabProfile(gu)
+	}
+	return nil, errorProfileType(loginChannelGitlab, profile)
+}
@@ -0,0 +1,72 @@
+package gvabe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitlabEndpoint(t *testing.T) {
+	testName := "TestGitlabEndpoint"
+	endpoint := gitlabEndpoint("https://gitlab.example.com/")
+	if expected := "https://gitlab.example.com/oauth/authorize"; endpoint.AuthURL != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, endpoint.AuthURL)
+	}
+	if expected := "https://gitlab.example.com/oauth/token"; endpoint.TokenURL != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, endpoint.TokenURL)
+	}
+}
+
+func TestGitlabFetchUserProfile(t *testing.T) {
+	testName := "TestGitlabFetchUserProfile"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != gitlabApiUser {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Header.Get("Authorization") {
+		case "Bearer confirmed":
+			w.Write([]byte(`{"id":1,"username":"user1","name":"User One","state":"active","email":"user1@example.com","confirmed_at":"2021-01-01T00:00:00.000Z"}`))
+		case "Bearer unconfirmed":
+			w.Write([]byte(`{"id":2,"username":"user2","name":"User Two","state":"active","email":"user2@example.com","confirmed_at":null}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	doRequest := func(accessToken string) (*gitlabUser, error) {
+		client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("Authorization", "Bearer "+accessToken)
+			return http.DefaultTransport.RoundTrip(r)
+		})}
+		return gitlabFetchUserProfile(context.Background(), client, server.URL+"/")
+	}
+
+	gu, err := doRequest("confirmed")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if expected := "user1@example.com"; gu.verifiedEmail() != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, gu.verifiedEmail())
+	}
+
+	gu, err = doRequest("unconfirmed")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if gu.verifiedEmail() != "" {
+		t.Fatalf("%s failed: unconfirmed email should not be returned, received %#v", testName, gu.verifiedEmail())
+	}
+
+	if _, err = doRequest("invalid"); err == nil {
+		t.Fatalf("%s failed: expected error for invalid access token", testName)
+	}
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
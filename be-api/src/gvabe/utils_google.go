@@ -1,36 +1,47 @@
 package gvabe
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha1"
-	"encoding/base64"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
-	"math/big"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/btnguyen2k/consu/reddo"
 	"github.com/btnguyen2k/consu/semita"
+	"github.com/dgrijalva/jwt-go"
+	hocon "github.com/go-akka/configuration"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 	goauthv2 "google.golang.org/api/oauth2/v2"
 	"google.golang.org/api/option"
+
+	"main/src/itineris"
 )
 
 const (
 	urlGoogleServiceTokenInfo = "https://oauth2.googleapis.com/tokeninfo"
+
+	// Google's public keys to verify id_tokens (available since v0.8.0)
+	googleJwksUri = "https://www.googleapis.com/oauth2/v3/certs"
 )
 
 var (
 	googleOAuthConf *oauth2.Config
+
+	// Google issues id_tokens with either of these issuers (available since v0.8.0)
+	googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+	googleKeySet  = newJwksKeySet(googleJwksUri, nil)
+
+	// if not empty, only accounts of this Google Workspace domain are allowed to login (available since v0.8.0)
+	googleHostedDomain string
 )
 
 type IdTokenVerified struct {
@@ -53,80 +64,171 @@ var (
 	mutexCacheAccessTokens sync.Mutex
 )
 
-// routine to fetch Google profile in background
-func goFetchGoogleProfile(sessId string) {
-	if bo, err := sessionDao.Get(sessId); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile(%s) - error loading session data: %e", sessId, err))
-	} else if bo == nil {
-		log.Println(fmt.Sprintf("[WARN] goFetchGoogleProfile(%s) - session does not exist", sessId))
-	} else if bo.IsExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchGoogleProfile(%s) - session expired", sessId))
-	} else if claims, err := parseLoginToken(bo.GetSessionData()); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile(%s) - cannot parse JWT token: %e", sessId, err))
-	} else if claims.Type != sessionTypePreLogin || claims.isExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchGoogleProfile(%s) - invalid claims type of JWT expired", sessId))
-	} else {
-		sess := &Session{}
-		if err := json.Unmarshal(claims.Data, &sess); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile(%s) - error decoding session: %e", sessId, err))
-			return
+// googleLoginChannel implements LoginChannel for Google Sign-In.
+//
+// available since v0.8.0
+type googleLoginChannel struct{}
+
+// Name implements LoginChannel.Name.
+func (ch *googleLoginChannel) Name() string {
+	return loginChannelGoogle
+}
+
+// Init implements LoginChannel.Init.
+func (ch *googleLoginChannel) Init(conf *hocon.Config) error {
+	clientSecretJson := strings.TrimSpace(conf.GetString("gvabe.channels.google.client_secret_json"))
+	if clientSecretJson == "" {
+		log.Println("[INFO] No valid GoogleAPI client secret defined at [gvabe.channels.google.client_secret_json], falling back to {project_id, client_id, client_secret}")
+
+		projectId := strings.TrimSpace(conf.GetString("gvabe.channels.google.project_id"))
+		if projectId == "" {
+			log.Println("[ERROR] No valid GoogleAPI project-id defined at [gvabe.channels.google.project_id]")
 		}
-		if sess.Channel != loginChannelGoogle {
-			log.Println(fmt.Sprintf("[WARN] goFetchGoogleProfile(%s) - invalid login channel: %s", sessId, sess.Channel))
-			return
+		clientId := strings.TrimSpace(conf.GetString("gvabe.channels.google.client_id"))
+		if clientId == "" {
+			log.Println("[ERROR] No valid GoogleAPI client-id defined at [gvabe.channels.google.client_id]")
 		}
-		oauth2Token := &oauth2.Token{}
-		if err := json.Unmarshal(sess.Data, &oauth2Token); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile - error unmarshalling oauth2.Token: %e", err))
-		} else if oauth2Service, err := goauthv2.NewService(context.Background(), option.WithTokenSource(googleOAuthConf.TokenSource(context.Background(), oauth2Token))); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile - error creating new Google Service: %e", err))
-		} else if userinfo, err := oauth2Service.Userinfo.V2.Me.Get().Do(); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile - error fetching Google userinfo: %e", err))
-		} else {
-			if u, err := createUserAccountFromGoogleProfile(userinfo); err != nil {
-				log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile - error creating user account from Google userinfo: %e", err))
-			} else {
-				js, _ := json.Marshal(oauth2Token)
-				sess.UserId = u.GetId()
-				sess.DisplayName = u.GetDisplayName()
-				sess.ExpiredAt = oauth2Token.Expiry
-				sess.Data = js
-				claims, err := genLoginClaims(sessId, sess)
-				if err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile(%s) - error generating login token: %e", sessId, err))
-				}
-				_, _, err = saveSession(claims)
-				if err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchGoogleProfile(%s) - error saving login token: %e", sessId, err))
-				}
-			}
+		clientSecret := strings.TrimSpace(conf.GetString("gvabe.channels.google.client_secret"))
+		if clientSecret == "" {
+			log.Println("[ERROR] No valid GoogleAPI client-secret defined at [gvabe.channels.google.client_secret]")
+		}
+		appDomainsJs, _ := json.Marshal([]string{exterHomeUrl})
+
+		clientSecretJson = fmt.Sprintf(`{
+		  "type":"authorized_user",
+		  "web": {
+			"project_id": "%s",
+			"client_id": "%s",
+			"client_secret": "%s",
+			"auth_uri": "https://accounts.google.com/o/oauth2/auth",
+			"token_uri": "https://oauth2.googleapis.com/token",
+			"auth_provider_x509_cert_url": "https://www.googleapis.com/oauth2/v1/certs",
+			"redirect_uris": %s,
+			"javascript_origins": %s,
+			"access_type": "offline"
+		  }
+		}`, projectId, clientId, clientSecret, appDomainsJs, appDomainsJs)
+	}
+	if DEBUG {
+		r := regexp.MustCompile(`(?s)"client_secret":\s*"(.*?)"`)
+		f := r.FindSubmatch([]byte(clientSecretJson))
+		if len(f) > 1 {
+			clientSecret := string(f[1])
+			clientSecret = "***" + clientSecret[len(clientSecret)-4:]
+			_clientSecretJson := r.ReplaceAllString(clientSecretJson, `"client_secret": "`+clientSecret+`"`)
+			log.Printf("[DEBUG] googleLoginChannel.Init: %s", _clientSecretJson)
 		}
 	}
+	oauthConf, err := google.ConfigFromJSON([]byte(clientSecretJson))
+	if err != nil {
+		return err
+	}
+	googleOAuthConf = oauthConf
+	googleHostedDomain = strings.ToLower(strings.TrimSpace(conf.GetString("gvabe.channels.google.hosted_domain")))
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *googleLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"google_client_id": googleOAuthConf.ClientID}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *googleLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	if googleHostedDomain != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("hd", googleHostedDomain))
+	}
+	return googleOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements LoginChannel.Exchange.
+//
+// (since v0.8.0) The id_token returned along with the access token, if any, is verified offline; it is required if
+// login is restricted to a Google Workspace domain.
+func (ch *googleLoginChannel) Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error) {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
+	token, err := googleOAuthConf.Exchange(ctx, authCode.(string), oauth2.AccessTypeOnline)
+	if err != nil || token == nil {
+		return token, err
+	}
+	if idToken, _ := token.Extra("id_token").(string); idToken != "" {
+		if _, err := parseAndVerifyGoogleIdToken(idToken); err != nil {
+			return nil, err
+		}
+	} else if googleHostedDomain != "" {
+		return nil, errors.New("Google id_token is required to verify hosted domain, \"openid\" scope must be requested")
+	}
+	return token, nil
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+func (ch *googleLoginChannel) FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error) {
+	oauth2Service, err := goauthv2.NewService(ctx, option.WithTokenSource(googleOAuthConf.TokenSource(ctx, token)))
+	if err != nil {
+		return nil, err
+	}
+	return oauth2Service.Userinfo.V2.Me.Get().Do()
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *googleLoginChannel) MapIdentity(profile interface{}) (*LoginIdentity, error) {
+	if userinfo, ok := profile.(*goauthv2.Userinfo); ok && userinfo != nil {
+		return loginIdentityFromGoogleProfile(userinfo)
+	}
+	return nil, errorProfileType(loginChannelGoogle, profile)
 }
 
-// parseAndVerifyGoogleIdToken calls Google's tokeninfo service to parse and verify id_token.
+// verifyGoogleIdToken verifies the id_token issued by Google (RS256 signature, issuer, audience, expiry and, if
+// hostedDomain is not empty, the Google Workspace domain) and returns its claims.
+//
+// available since v0.8.0
+func verifyGoogleIdToken(ctx context.Context, ks *jwksKeySet, clientId, hostedDomain, idToken string) (jwt.MapClaims, error) {
+	if token, _, err := new(jwt.Parser).ParseUnverified(idToken, jwt.MapClaims{}); err != nil {
+		return nil, err
+	} else if token.Method != jwt.SigningMethodRS256 {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	claims, err := parseAndVerifyJwtWithKeySet(ctx, ks, idToken)
+	if err != nil {
+		return nil, err
+	}
+	iss, _ := claims["iss"].(string)
+	validIssuer := false
+	for _, v := range googleIssuers {
+		validIssuer = validIssuer || iss == v
+	}
+	if !validIssuer {
+		return nil, fmt.Errorf("invalid id_token issuer: %s", iss)
+	}
+	if !jwtClaimsHasAudience(claims, clientId) {
+		return nil, fmt.Errorf("id_token is not issued for client [%s]", clientId)
+	}
+	if hd, _ := claims["hd"].(string); hostedDomain != "" && !strings.EqualFold(hd, hostedDomain) {
+		return nil, fmt.Errorf("account does not belong to hosted domain [%s]", hostedDomain)
+	}
+	return claims, nil
+}
+
+// parseAndVerifyGoogleIdToken parses and verifies id_token.
+//
+// (since v0.8.0) id_token is verified offline against Google's public keys instead of calling Google's tokeninfo service.
 func parseAndVerifyGoogleIdToken(idToken string) (*IdTokenVerified, error) {
+	if googleOAuthConf == nil {
+		return nil, errors.New("Google login channel is not enabled")
+	}
 	mutexCacheIdTokens.Lock()
 	defer mutexCacheIdTokens.Unlock()
 	sha := sha1.Sum([]byte(idToken))
 	shaHash := hex.EncodeToString(sha[:])
 	vIdToken := cachedIdTokens[shaHash]
 	if vIdToken == nil || time.Now().After(vIdToken.ExpiredAt) {
-		data := make(map[string]interface{})
-		url := urlGoogleServiceTokenInfo + "?id_token=" + idToken
-		if resp, err := httpClient.Get(url); err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		claims, err := verifyGoogleIdToken(ctx, googleKeySet, googleOAuthConf.ClientID, googleHostedDomain, idToken)
+		if err != nil {
 			return nil, err
-		} else {
-			defer resp.Body.Close()
-			if resp.StatusCode != 200 {
-				return nil, errors.New("error while validating Google id_token: " + resp.Status)
-			}
-			if body, err := ioutil.ReadAll(resp.Body); err != nil {
-				return nil, err
-			} else if err := json.Unmarshal(body, &data); err != nil {
-				return nil, errors.New("error while decoding json data from Google tokeninfo service")
-			}
 		}
+		data := map[string]interface{}(claims)
 		s := semita.NewSemita(data)
 		issueTime, _ := s.GetValueOfType("iat", reddo.TypeInt)
 		expiryTime, _ := s.GetValueOfType("exp", reddo.TypeInt)
@@ -134,9 +236,10 @@ func parseAndVerifyGoogleIdToken(idToken string) (*IdTokenVerified, error) {
 		if expiry.Unix() > expiryTime.(int64) {
 			expiry = time.Unix(expiryTime.(int64), 0)
 		}
+		iat, _ := issueTime.(int64)
 		vIdToken = &IdTokenVerified{
 			Data:      data,
-			IssuedAt:  time.Unix(issueTime.(int64), 0),
+			IssuedAt:  time.Unix(iat, 0),
 			ExpiredAt: expiry,
 			s:         s,
 		}
@@ -197,34 +300,3 @@ func parseAndVerifyGoogleAccessToken(accessToken string) (*AccessTokenVerified,
 	}
 	return vAccessToken, nil
 }
-
-func urlsafeB64decode(str string) []byte {
-	if m := len(str) % 4; m != 0 {
-		str += strings.Repeat("=", 4-m)
-	}
-	bt, _ := base64.URLEncoding.DecodeString(str)
-	return bt
-}
-
-func btrToInt(a io.Reader) int {
-	var e uint64
-	binary.Read(a, binary.BigEndian, &e)
-	return int(e)
-}
-
-func byteToInt(bt []byte) *big.Int {
-	a := big.NewInt(0)
-	a.SetBytes(bt)
-	return a
-}
-
-func byteToBtr(bt0 []byte) *bytes.Reader {
-	var bt1 []byte
-	if len(bt0) < 8 {
-		bt1 = make([]byte, 8-len(bt0), 8)
-		bt1 = append(bt1, bt0...)
-	} else {
-		bt1 = bt0
-	}
-	return bytes.NewReader(bt1)
-}
@@ -0,0 +1,66 @@
+package gvabe
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestVerifyGoogleIdToken(t *testing.T) {
+	testName := "TestVerifyGoogleIdToken"
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]interface{}{_testRsaJwk("googlekey", &key.PublicKey)}})
+	}))
+	defer server.Close()
+	ks := newJwksKeySet(server.URL, nil)
+	sign := func(method jwt.SigningMethod, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = "googlekey"
+		jws, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		return jws
+	}
+
+	now := time.Now().Unix()
+	claims := jwt.MapClaims{
+		"iss": "https://accounts.google.com", "aud": "client.apps.googleusercontent.com", "sub": "1234567890",
+		"iat": now, "exp": now + 600, "email": "user@example.com", "email_verified": true, "hd": "example.com",
+	}
+	verifiedClaims, err := verifyGoogleIdToken(context.Background(), ks, "client.apps.googleusercontent.com", "example.com", sign(jwt.SigningMethodRS256, claims))
+	if err != nil || verifiedClaims["email"] != "user@example.com" {
+		t.Fatalf("%s failed: %#v / %s", testName, verifiedClaims, err)
+	}
+	claims["iss"] = "accounts.google.com"
+	if _, err := verifyGoogleIdToken(context.Background(), ks, "client.apps.googleusercontent.com", "", sign(jwt.SigningMethodRS256, claims)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	if _, err := verifyGoogleIdToken(context.Background(), ks, "another.apps.googleusercontent.com", "", sign(jwt.SigningMethodRS256, claims)); err == nil {
+		t.Fatalf("%s failed: expected error for wrong audience", testName)
+	}
+	if _, err := verifyGoogleIdToken(context.Background(), ks, "client.apps.googleusercontent.com", "another.com", sign(jwt.SigningMethodRS256, claims)); err == nil {
+		t.Fatalf("%s failed: expected error for wrong hosted domain", testName)
+	}
+	if _, err := verifyGoogleIdToken(context.Background(), ks, "client.apps.googleusercontent.com", "", sign(jwt.SigningMethodRS384, claims)); err == nil {
+		t.Fatalf("%s failed: expected error for signing method other than RS256", testName)
+	}
+	claims["iss"] = "https://evil.example.com"
+	if _, err := verifyGoogleIdToken(context.Background(), ks, "client.apps.googleusercontent.com", "", sign(jwt.SigningMethodRS256, claims)); err == nil {
+		t.Fatalf("%s failed: expected error for wrong issuer", testName)
+	}
+	claims["iss"] = "https://accounts.google.com"
+	claims["exp"] = now - 3600
+	if _, err := verifyGoogleIdToken(context.Background(), ks, "client.apps.googleusercontent.com", "", sign(jwt.SigningMethodRS256, claims)); err == nil {
+		t.Fatalf("%s failed: expected error for expired token", testName)
+	}
+}
@@ -0,0 +1,315 @@
+package gvabe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/identity"
+	"main/src/gvabe/bo/user"
+	"main/src/itineris"
+)
+
+const (
+	// identityLinkPolicyEmail: an identity not linked to any user yet is linked to the account with the same email address
+	identityLinkPolicyEmail = "email"
+
+	// identityLinkPolicyNone: identities are never linked by email address, users must link them explicitly
+	identityLinkPolicyNone = "none"
+
+	// name of the ApiContext value holding id of the user the login identity is to be linked to (see API "identityLink")
+	ctxFieldLinkUserId = "_link_uid"
+)
+
+var (
+	identityLinkPolicy = identityLinkPolicyEmail
+
+	// if true, logins via identities whose email address has not been verified by the provider are rejected;
+	// otherwise such identities are logged in to accounts keyed by provider and subject id (see userIdFromLoginIdentity)
+	requireVerifiedEmail = false
+
+	errorIdentityNotLinkable = errors.New("identity can not be linked: login channel does not provide a subject id")
+)
+
+// LoginIdentity is user's identity as asserted by a login channel.
+//
+// Available since v0.8.0
+type LoginIdentity struct {
+	Provider    string // name of the identity provider, usually the login channel's name
+	Subject     string // immutable user id issued by the provider, empty if the provider does not issue one
+	Email       string // user's email address reported by the provider
+	DisplayName string // user's display name reported by the provider
+
+	// EmailVerified is true if the provider asserts that user owns the email address (e.g. Google's "verified_email",
+	// OpenID Connect's "email_verified" claim).
+	EmailVerified bool
+
+	// AuthoritativeName is true if the provider is authoritative for user's display name (e.g. corporate directory),
+	// in which case user's display name is updated upon every login.
+	AuthoritativeName bool
+
+	// (since v0.8.0) optional profile attributes, empty if not reported by the provider
+	AvatarUrl  string // url of user's avatar picture
+	Locale     string // user's preferred locale
+	GivenName  string // user's given name
+	FamilyName string // user's family name
+}
+
+// initLoginIdentitySettings reads settings [gvabe.identity_link_policy] and [gvabe.require_verified_email].
+//
+// available since v0.8.0
+func initLoginIdentitySettings() {
+	policy := strings.ToLower(strings.TrimSpace(goapi.AppConfig.GetString("gvabe.identity_link_policy")))
+	switch policy {
+	case "":
+		policy = identityLinkPolicyEmail
+	case identityLinkPolicyEmail, identityLinkPolicyNone:
+	default:
+		log.Println(fmt.Sprintf("[ERROR] Invalid identity link policy [%s] at [gvabe.identity_link_policy], supported policies: %s, %s; falling back to %s",
+			policy, identityLinkPolicyEmail, identityLinkPolicyNone, identityLinkPolicyNone))
+		policy = identityLinkPolicyNone
+	}
+	identityLinkPolicy = policy
+	requireVerifiedEmail = goapi.AppConfig.GetBoolean("gvabe.require_verified_email", false)
+	if DEBUG {
+		log.Printf("[DEBUG] initLoginIdentitySettings: %s / %v", identityLinkPolicy, requireVerifiedEmail)
+	}
+}
+
+// claimIsTrue checks if a boolean claim is true; some providers (e.g. Apple) return boolean claims as string.
+func claimIsTrue(claims map[string]interface{}, claim string) bool {
+	switch v := claims[claim].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// _linkUserIdFromContext returns id of the user the login identity is to be linked to, empty if the login is not
+// carried out by API "identityLink".
+//
+// available since v0.8.0
+func _linkUserIdFromContext(ctx *itineris.ApiContext) string {
+	if ctx == nil {
+		return ""
+	}
+	v, _ := ctx.GetContextValue(ctxFieldLinkUserId).(string)
+	return v
+}
+
+// verifyIdentityEmail rejects a login identity whose email address has not been verified by the provider, if verified
+// email addresses are required either globally (setting [gvabe.require_verified_email]) or by the application.
+// Note: if not rejected, the unverified email address is still never used as account key (see findOrCreateUser).
+//
+// available since v0.8.0
+func verifyIdentityEmail(ident *LoginIdentity, appId string) error {
+	if ident.EmailVerified {
+		return nil
+	}
+	required := requireVerifiedEmail
+	if !required && appId != "" {
+		app, err := appDao.Get(appId)
+		if err != nil {
+			return err
+		}
+		required = app != nil && app.GetAttrsPublic().RequireVerifiedEmail
+	}
+	if required {
+		return fmt.Errorf("%s did not report a verified email address, please verify your email address with %s and login again", ident.Provider, ident.Provider)
+	}
+	return nil
+}
+
+// resolveLoginIdentity maps a login identity to an Exter user account: the identity is linked to user linkUserId if
+// specified, otherwise the account is looked up (and created if needed) by findOrCreateUser.
+// Identities without a verified email address are rejected if app appId requires so (see verifyIdentityEmail).
+//
+// available since v0.8.0
+func resolveLoginIdentity(ident *LoginIdentity, appId, linkUserId string) (*user.User, error) {
+	if ident == nil {
+		return nil, errors.New("login identity is nil")
+	}
+	if err := verifyIdentityEmail(ident, appId); err != nil {
+		return nil, err
+	}
+	var u *user.User
+	var err error
+	if linkUserId != "" {
+		u, err = linkLoginIdentity(linkUserId, ident)
+	} else {
+		u, err = findOrCreateUser(ident)
+	}
+	if err != nil || u == nil {
+		return nil, err
+	}
+	if updateUserProfile(u, ident) {
+		_, err = userDao.Update(u)
+	}
+	return u, err
+}
+
+// updateUserProfile updates user's profile with attributes reported by the login identity, returns true if the profile
+// has changed. Attributes not reported by the provider are left untouched.
+//
+// available since v0.8.0
+func updateUserProfile(u *user.User, ident *LoginIdentity) bool {
+	changed := false
+	displayName := strings.TrimSpace(ident.DisplayName)
+	if u.GetDisplayName() == "" || (ident.AuthoritativeName && displayName != "" && displayName != u.GetDisplayName()) {
+		if displayName == "" {
+			displayName = extractNameFromEmailAddress(u.GetId())
+		}
+		u.SetDisplayName(displayName)
+		changed = true
+	}
+	getterList := []func() string{u.GetAvatarUrl, u.GetLocale, u.GetGivenName, u.GetFamilyName}
+	setterList := []func(string) *user.User{u.SetAvatarUrl, u.SetLocale, u.SetGivenName, u.SetFamilyName}
+	for i, v := range []string{ident.AvatarUrl, ident.Locale, ident.GivenName, ident.FamilyName} {
+		if v = strings.TrimSpace(v); v != "" && v != getterList[i]() {
+			setterList[i](v)
+			changed = true
+		}
+	}
+	if ident.Subject != "" && u.GetProviderIds()[strings.ToLower(ident.Provider)] != ident.Subject {
+		u.SetProviderId(ident.Provider, ident.Subject)
+		changed = true
+	}
+	if u.GetFirstSeenAt().IsZero() {
+		// accounts created prior to v0.8.0
+		u.SetFirstSeenAt(time.Now())
+		changed = true
+	}
+	return changed
+}
+
+// userIdFromLoginIdentity returns id of the account to be looked up/created for a login identity not linked to any
+// account yet: the email address if verified by the provider, otherwise "<provider>:<subject>" (which can not be an
+// email address). Unverified email addresses are never used as account keys, whatever setting
+// [gvabe.require_verified_email] says.
+//
+// available since v0.8.0
+func userIdFromLoginIdentity(ident *LoginIdentity) (string, error) {
+	if email := strings.TrimSpace(ident.Email); ident.EmailVerified && email != "" {
+		return email, nil
+	}
+	if ident.Subject == "" {
+		if strings.TrimSpace(ident.Email) == "" {
+			return "", fmt.Errorf("%s profile does not contain email address", ident.Provider)
+		}
+		return "", fmt.Errorf("%s did not report a verified email address, please verify your email address with %s and login again", ident.Provider, ident.Provider)
+	}
+	return strings.ToLower(ident.Provider) + ":" + strings.TrimSpace(ident.Subject), nil
+}
+
+// findOrCreateUser looks up the account a login identity belongs to:
+//   - firstly by the identity's provider and subject id;
+//   - then by the identity's email address, only if the provider has verified it and identity link policy is "email" (or the channel does not issue subject ids);
+//   - lastly a new account is created (user id is the verified email address, or "<provider>:<subject>" if the email
+//     address is not verified, see userIdFromLoginIdentity) and the identity is linked to it.
+//
+// available since v0.8.0
+func findOrCreateUser(ident *LoginIdentity) (*user.User, error) {
+	email := strings.TrimSpace(ident.Email)
+	var u *user.User
+	if ident.Subject != "" {
+		link, err := identityDao.Get(identity.IdFromProviderSubject(ident.Provider, ident.Subject))
+		if err != nil {
+			return nil, err
+		}
+		if link != nil {
+			if u, err = userDao.Get(link.GetOwnerId()); err != nil {
+				return nil, err
+			}
+			if u == nil {
+				// the account the identity was linked to no longer exists
+				if _, err = identityDao.Delete(link); err != nil {
+					return nil, err
+				}
+			} else {
+				if email != "" && strings.ToLower(email) != link.GetEmail() {
+					// the provider's email address has changed, user account stays the same
+					link.SetEmail(email)
+					_, err = identityDao.Update(link)
+				}
+				return u, err
+			}
+		}
+	}
+
+	userId, err := userIdFromLoginIdentity(ident)
+	if err != nil {
+		return nil, err
+	}
+	u, err = userDao.Get(userId)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil && ident.Subject != "" && (identityLinkPolicy != identityLinkPolicyEmail || !ident.EmailVerified) {
+		// unverified email addresses are never used to link identities to existing accounts
+		return nil, fmt.Errorf("an account with email address [%s] already exists, login to that account and link the %s identity to it", userId, ident.Provider)
+	}
+	if u == nil {
+		u = user.NewUser(goapi.AppVersionNumber, userId)
+		if userId != email {
+			// display name is taken from the identity, see updateUserProfile
+			u.SetDisplayName("")
+		}
+		if ok, err := userDao.Create(u); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("cannot create user account [%s]", userId)
+		}
+	}
+	if ident.Subject != "" {
+		link := identity.NewIdentity(goapi.AppVersionNumber, ident.Provider, ident.Subject, u.GetId()).SetEmail(email)
+		if _, err := identityDao.Create(link); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// linkLoginIdentity links a login identity to an existing user account.
+// An identity can be linked to at most one account: error is returned if it is already linked to another one.
+//
+// available since v0.8.0
+func linkLoginIdentity(userId string, ident *LoginIdentity) (*user.User, error) {
+	if ident.Subject == "" {
+		return nil, errorIdentityNotLinkable
+	}
+	u, err := userDao.Get(userId)
+	if err != nil {
+		return nil, err
+	} else if u == nil {
+		return nil, fmt.Errorf("user [%s] not found", userId)
+	}
+	email := strings.TrimSpace(ident.Email)
+	link, err := identityDao.Get(identity.IdFromProviderSubject(ident.Provider, ident.Subject))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		link = identity.NewIdentity(goapi.AppVersionNumber, ident.Provider, ident.Subject, u.GetId()).SetEmail(email)
+		_, err = identityDao.Create(link)
+		return u, err
+	}
+	if link.GetOwnerId() != u.GetId() {
+		if owner, err := userDao.Get(link.GetOwnerId()); err != nil {
+			return nil, err
+		} else if owner != nil {
+			return nil, fmt.Errorf("the %s identity is already linked to another account", ident.Provider)
+		}
+		// the account the identity was linked to no longer exists, take the link over
+		link.SetOwnerId(u.GetId()).SetLinkedAt(time.Now())
+	}
+	if email != "" {
+		link.SetEmail(email)
+	}
+	_, err = identityDao.Update(link)
+	return u, err
+}
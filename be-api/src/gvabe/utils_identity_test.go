@@ -0,0 +1,307 @@
+package gvabe
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"main/src/gvabe/bo/identity"
+	"main/src/gvabe/bo/user"
+	"main/src/itineris"
+)
+
+// in-memory implementation of identity.IdentityDao
+type testIdentityDao struct {
+	sync.Mutex
+	identities map[string]*identity.Identity
+}
+
+func (dao *testIdentityDao) Delete(bo *identity.Identity) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	delete(dao.identities, bo.GetId())
+	return true, nil
+}
+
+func (dao *testIdentityDao) Create(bo *identity.Identity) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.identities[bo.GetId()] != nil {
+		return false, nil
+	}
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.identities[bo.GetId()] = identity.NewIdentityFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+func (dao *testIdentityDao) Get(id string) (*identity.Identity, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if ident := dao.identities[id]; ident != nil {
+		return identity.NewIdentityFromUbo(ident.UniversalBo), nil
+	}
+	return nil, nil
+}
+
+func (dao *testIdentityDao) GetUserIdentities(u *user.User) ([]*identity.Identity, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	result := make([]*identity.Identity, 0)
+	for _, ident := range dao.identities {
+		if ident.GetOwnerId() == u.GetId() {
+			result = append(result, identity.NewIdentityFromUbo(ident.UniversalBo))
+		}
+	}
+	return result, nil
+}
+
+func (dao *testIdentityDao) Update(bo *identity.Identity) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.identities[bo.GetId()] = identity.NewIdentityFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+// setupTestIdentities replaces user and identity storages with in-memory ones, the returned function restores the
+// originals.
+func setupTestIdentities() (*testUserDao, *testIdentityDao, func()) {
+	origUserDao, origIdentityDao, origPolicy := userDao, identityDao, identityLinkPolicy
+	uDao := &testUserDao{users: make(map[string]*user.User)}
+	iDao := &testIdentityDao{identities: make(map[string]*identity.Identity)}
+	userDao, identityDao = uDao, iDao
+	return uDao, iDao, func() {
+		userDao, identityDao, identityLinkPolicy = origUserDao, origIdentityDao, origPolicy
+	}
+}
+
+func TestLinkUserIdFromContext(t *testing.T) {
+	testName := "TestLinkUserIdFromContext"
+	if v := _linkUserIdFromContext(nil); v != "" {
+		t.Fatalf("%s failed: expected empty but received %#v", testName, v)
+	}
+	ctx := itineris.NewApiContext()
+	if v := _linkUserIdFromContext(ctx); v != "" {
+		t.Fatalf("%s failed: expected empty but received %#v", testName, v)
+	}
+	ctx.SetContextValue(ctxFieldLinkUserId, "user@domain.com")
+	if v := _linkUserIdFromContext(ctx); v != "user@domain.com" {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, "user@domain.com", v)
+	}
+}
+
+func TestLoginIdentityFromSamlAssertion(t *testing.T) {
+	testName := "TestLoginIdentityFromSamlAssertion"
+	idp := &samlIdp{name: "okta", nameAttribute: "displayName"}
+	info := &samlAssertionInfo{
+		NameId:       "user@domain.com",
+		NameIdFormat: samlNameIdFormatEmail,
+		Attributes:   map[string][]string{"displayName": {"Jane Doe"}},
+	}
+	ident, err := loginIdentityFromSamlAssertion(idp, info)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &LoginIdentity{Provider: "saml:okta", Subject: "user@domain.com", Email: "user@domain.com", EmailVerified: true, DisplayName: "Jane Doe"}
+	if !reflect.DeepEqual(ident, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ident)
+	}
+
+	// transient NameIDs must not be used as subject
+	info = &samlAssertionInfo{
+		NameId:       "_a1b2c3",
+		NameIdFormat: samlNameIdFormatTransient,
+		Attributes:   map[string][]string{"mail": {"user@domain.com"}},
+	}
+	idp.emailAttribute = "mail"
+	if ident, err = loginIdentityFromSamlAssertion(idp, info); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	} else if ident.Subject != "" || ident.Email != "user@domain.com" {
+		t.Fatalf("%s failed: %#v", testName, ident)
+	}
+
+	if _, err = loginIdentityFromSamlAssertion(idp, &samlAssertionInfo{NameId: "user"}); err == nil {
+		t.Fatalf("%s failed: assertion without email address must be rejected", testName)
+	}
+}
+
+func TestLoginIdentityFromOidcClaims(t *testing.T) {
+	testName := "TestLoginIdentityFromOidcClaims"
+	provider := newOidcProvider("keycloak", "https://keycloak.example.com", "client", "", "", nil)
+	claims := map[string]interface{}{"sub": "f47ac10b", "email": "user@domain.com", "given_name": "Jane", "family_name": "Doe"}
+	ident, err := loginIdentityFromOidcClaims(provider, claims)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &LoginIdentity{Provider: "keycloak", Subject: "f47ac10b", Email: "user@domain.com", DisplayName: "Jane Doe",
+		GivenName: "Jane", FamilyName: "Doe"}
+	if !reflect.DeepEqual(ident, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ident)
+	}
+
+	claims["email_verified"] = true
+	if ident, _ = loginIdentityFromOidcClaims(provider, claims); !ident.EmailVerified {
+		t.Fatalf("%s failed: email address should be verified", testName)
+	}
+	delete(claims, "email_verified")
+	provider.trustEmail = true
+	if ident, _ = loginIdentityFromOidcClaims(provider, claims); !ident.EmailVerified {
+		t.Fatalf("%s failed: email address should be trusted", testName)
+	}
+	if _, err = loginIdentityFromOidcClaims(provider, map[string]interface{}{"sub": "f47ac10b"}); err == nil {
+		t.Fatalf("%s failed: claims without email address must be rejected", testName)
+	}
+}
+
+func TestLoginIdentityFromAppleClaims(t *testing.T) {
+	testName := "TestLoginIdentityFromAppleClaims"
+	au := &appleUser{}
+	au.Name.FirstName, au.Name.LastName = "Jane", "Doe"
+	ident, err := loginIdentityFromAppleClaims(map[string]interface{}{"sub": "000123.abc", "email": "user@domain.com", "email_verified": "true"}, au)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &LoginIdentity{Provider: loginChannelApple, Subject: "000123.abc", Email: "user@domain.com", EmailVerified: true, DisplayName: "Jane Doe",
+		GivenName: "Jane", FamilyName: "Doe"}
+	if !reflect.DeepEqual(ident, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ident)
+	}
+
+	// user id built from subject id is not a verified email address
+	if ident, _ = loginIdentityFromAppleClaims(map[string]interface{}{"sub": "000123.abc", "email": "user@domain.com"}, nil); ident.EmailVerified {
+		t.Fatalf("%s failed: %#v", testName, ident)
+	}
+}
+
+func TestLoginIdentityFromLdapUser(t *testing.T) {
+	testName := "TestLoginIdentityFromLdapUser"
+	ident, _ := loginIdentityFromLdapUser(&ldapUser{Dn: "uid=jdoe,ou=people,dc=domain,dc=com", Email: "jdoe@domain.com", DisplayName: "Jane Doe"})
+	if ident.Subject != "uid=jdoe,ou=people,dc=domain,dc=com" || !ident.AuthoritativeName {
+		t.Fatalf("%s failed: %#v", testName, ident)
+	}
+}
+
+func TestLoginIdentityFromGitHubProfile(t *testing.T) {
+	testName := "TestLoginIdentityFromGitHubProfile"
+	_str := func(v string) *string { return &v }
+	_bool := func(v bool) *bool { return &v }
+	_id := int64(42)
+	emails := []*github.UserEmail{
+		{Email: _str("public@domain.com"), Primary: _bool(false), Verified: _bool(false)},
+		{Email: _str("primary@domain.com"), Primary: _bool(true), Verified: _bool(true)},
+		{Email: _str("other@domain.com"), Primary: _bool(false), Verified: _bool(true)},
+	}
+
+	// unverified public email address: the primary verified one is used
+	ident, err := loginIdentityFromGitHubProfile(&githubProfile{User: &github.User{ID: &_id, Email: _str("public@domain.com")}, Emails: emails})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &LoginIdentity{Provider: loginChannelGithub, Subject: "42", Email: "primary@domain.com", EmailVerified: true}
+	if !reflect.DeepEqual(ident, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ident)
+	}
+
+	// verified public email address is kept
+	ident, _ = loginIdentityFromGitHubProfile(&githubProfile{User: &github.User{ID: &_id, Email: _str("Other@domain.com")}, Emails: emails})
+	if ident.Email != "Other@domain.com" || !ident.EmailVerified {
+		t.Fatalf("%s failed: %#v", testName, ident)
+	}
+
+	// private email address
+	ident, _ = loginIdentityFromGitHubProfile(&githubProfile{User: &github.User{ID: &_id}, Emails: emails})
+	if ident.Email != "primary@domain.com" || !ident.EmailVerified {
+		t.Fatalf("%s failed: %#v", testName, ident)
+	}
+
+	// email addresses could not be fetched
+	ident, _ = loginIdentityFromGitHubProfile(&githubProfile{User: &github.User{ID: &_id, Email: _str("public@domain.com")}})
+	if ident.Email != "public@domain.com" || ident.EmailVerified {
+		t.Fatalf("%s failed: %#v", testName, ident)
+	}
+	if _, err = loginIdentityFromGitHubProfile(&githubProfile{User: &github.User{ID: &_id}}); err == nil {
+		t.Fatalf("%s failed: profile without email address must be rejected", testName)
+	}
+}
+
+func TestVerifyIdentityEmail(t *testing.T) {
+	testName := "TestVerifyIdentityEmail"
+	defer func(v bool) { requireVerifiedEmail = v }(requireVerifiedEmail)
+	requireVerifiedEmail = true
+	if err := verifyIdentityEmail(&LoginIdentity{Provider: loginChannelGoogle, Email: "user@domain.com", EmailVerified: true}, ""); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err := verifyIdentityEmail(&LoginIdentity{Provider: loginChannelGoogle, Email: "user@domain.com"}, ""); err == nil {
+		t.Fatalf("%s failed: unverified email address must be rejected", testName)
+	}
+	requireVerifiedEmail = false
+	if err := verifyIdentityEmail(&LoginIdentity{Provider: loginChannelGoogle, Email: "user@domain.com"}, ""); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+func TestFindOrCreateUser(t *testing.T) {
+	testName := "TestFindOrCreateUser"
+	uDao, _, teardown := setupTestIdentities()
+	defer teardown()
+	identityLinkPolicy = identityLinkPolicyEmail
+	uDao.Create(user.NewUser(0, "victim@domain.com"))
+
+	// identities with verified email address are linked to the existing account
+	u, err := findOrCreateUser(&LoginIdentity{Provider: loginChannelGoogle, Subject: "g1", Email: "victim@domain.com", EmailVerified: true})
+	if err != nil || u == nil || u.GetId() != "victim@domain.com" {
+		t.Fatalf("%s failed: %#v / %s", testName, u, err)
+	}
+	// and found by their subject id afterwards
+	if u, err = findOrCreateUser(&LoginIdentity{Provider: loginChannelGoogle, Subject: "g1", Email: "other@domain.com"}); err != nil || u == nil || u.GetId() != "victim@domain.com" {
+		t.Fatalf("%s failed: %#v / %s", testName, u, err)
+	}
+
+	// unverified email addresses are never used to link identities to existing accounts
+	if u, err = findOrCreateUser(&LoginIdentity{Provider: "oidc", Subject: "o1", Email: "victim@domain.com"}); err != nil || u == nil || u.GetId() == "victim@domain.com" {
+		t.Fatalf("%s failed: unverified identity must not be linked to account victim@domain.com: %#v / %s", testName, u, err)
+	}
+
+	// unverified identities are keyed by provider and subject id, the unverified email address is not used as user id
+	if u, err = findOrCreateUser(&LoginIdentity{Provider: "OIDC", Subject: "o2", Email: "new@domain.com", DisplayName: "New User"}); err != nil || u == nil || u.GetId() != "oidc:o2" {
+		t.Fatalf("%s failed: %#v / %s", testName, u, err)
+	} else if existing, _ := uDao.Get("new@domain.com"); existing != nil {
+		t.Fatalf("%s failed: account must not be created for unverified email address", testName)
+	}
+	if u, err = findOrCreateUser(&LoginIdentity{Provider: "OIDC", Subject: "o2", Email: "new@domain.com"}); err != nil || u == nil || u.GetId() != "oidc:o2" {
+		t.Fatalf("%s failed: %#v / %s", testName, u, err)
+	}
+	// unverified identities without subject id can not be keyed
+	if u, err = findOrCreateUser(&LoginIdentity{Provider: "oidc", Email: "new@domain.com"}); err == nil {
+		t.Fatalf("%s failed: expected error but received %#v", testName, u.GetId())
+	}
+
+	// identities are not linked by email address if policy is "none"
+	identityLinkPolicy = identityLinkPolicyNone
+	if u, err = findOrCreateUser(&LoginIdentity{Provider: loginChannelGithub, Subject: "gh1", Email: "victim@domain.com", EmailVerified: true}); err == nil {
+		t.Fatalf("%s failed: identity must not be linked to account %#v", testName, u.GetId())
+	}
+}
+
+func TestUpdateUserProfile(t *testing.T) {
+	testName := "TestUpdateUserProfile"
+	u := user.NewUser(1357, "user@domain.com").SetDisplayName("Jane")
+	ident := &LoginIdentity{Provider: "Google", Subject: "1234567890", Email: "user@domain.com", DisplayName: "Jane Doe",
+		AvatarUrl: "https://domain.com/avatar.png", Locale: "en", GivenName: "Jane", FamilyName: "Doe"}
+	if !updateUserProfile(u, ident) {
+		t.Fatalf("%s failed: profile should have changed", testName)
+	}
+	if u.GetDisplayName() != "Jane" || u.GetAvatarUrl() != ident.AvatarUrl || u.GetLocale() != "en" || u.GetGivenName() != "Jane" ||
+		u.GetFamilyName() != "Doe" || u.GetProviderIds()["google"] != "1234567890" {
+		t.Fatalf("%s failed: %#v", testName, u)
+	}
+	if updateUserProfile(u, ident) {
+		t.Fatalf("%s failed: profile should not have changed", testName)
+	}
+	// attributes not reported by the provider are left untouched
+	if updateUserProfile(u, &LoginIdentity{Provider: "google", Subject: "1234567890", Email: "user@domain.com"}) || u.GetAvatarUrl() != ident.AvatarUrl {
+		t.Fatalf("%s failed: %#v", testName, u)
+	}
+}
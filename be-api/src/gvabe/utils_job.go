@@ -0,0 +1,286 @@
+package gvabe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/job"
+)
+
+const (
+	jobDefaultWorkers      = 4
+	jobDefaultMaxAttempts  = 5
+	jobDefaultBackoffBase  = 2 * time.Second
+	jobDefaultBackoffMax   = 1 * time.Minute
+	jobDefaultLease        = 1 * time.Minute
+	jobDefaultPollInterval = 2 * time.Second
+	jobDefaultRetention    = 7 * 24 * time.Hour
+
+	jobPurgeInterval  = 10 * time.Minute // how often expired jobs are purged
+	jobPurgeBatchSize = 100
+)
+
+var (
+	// queue of background jobs, see initJobQueue
+	backgroundJobs *jobQueue
+)
+
+// jobHandler runs jobs of a kind. Errors returned by run are retried with exponential backoff, except for those wrapped
+// by jobPermanentError. onFail (optional) is called once the job has failed permanently.
+//
+// available since v0.8.0
+type jobHandler struct {
+	run    func(payload string) error
+	onFail func(payload string, reason error)
+}
+
+// permanentJobError is an error that retrying the job would not fix.
+type permanentJobError struct {
+	error
+}
+
+// jobPermanentError marks an error returned by a job handler as permanent: the job fails without being retried.
+func jobPermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentJobError{err}
+}
+
+// jobQueue runs persisted jobs in background with a bounded number of workers. Jobs are stored via JobDao so that they
+// survive restarts and can be picked up by any Exter instance sharing the same database: a worker claims a job (see
+// job.ClaimJob) by marking it "running" for a lease period, the job is run again if the worker does not finish it
+// within the lease. Hence, handlers must be idempotent. Failed jobs are kept for inspection during a retention period,
+// then purged.
+//
+// available since v0.8.0
+type jobQueue struct {
+	dao          job.JobDao
+	handlers     map[string]*jobHandler
+	maxAttempts  int
+	backoffBase  time.Duration
+	backoffMax   time.Duration
+	lease        time.Duration
+	pollInterval time.Duration
+	retention    time.Duration
+
+	slots    chan bool // bounds the number of jobs run concurrently by this instance
+	wakeup   chan bool // signals the dispatcher that a new job has been enqueued
+	inFlight sync.Map  // ids of jobs being run by this instance
+	purgedAt time.Time // last time expired jobs were purged
+}
+
+func newJobQueue(dao job.JobDao, workers, maxAttempts int, backoffBase, backoffMax, lease, pollInterval, retention time.Duration) *jobQueue {
+	if workers <= 0 {
+		workers = jobDefaultWorkers
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = jobDefaultMaxAttempts
+	}
+	if backoffBase <= 0 {
+		backoffBase = jobDefaultBackoffBase
+	}
+	if backoffMax < backoffBase {
+		backoffMax = backoffBase
+	}
+	if lease <= 0 {
+		lease = jobDefaultLease
+	}
+	if pollInterval <= 0 {
+		pollInterval = jobDefaultPollInterval
+	}
+	if retention <= 0 {
+		retention = jobDefaultRetention
+	}
+	return &jobQueue{
+		dao:          dao,
+		handlers:     make(map[string]*jobHandler),
+		maxAttempts:  maxAttempts,
+		backoffBase:  backoffBase,
+		backoffMax:   backoffMax,
+		lease:        lease,
+		pollInterval: pollInterval,
+		retention:    retention,
+		slots:        make(chan bool, workers),
+		wakeup:       make(chan bool, 1),
+	}
+}
+
+// register registers the handler for jobs of a kind.
+func (q *jobQueue) register(kind string, handler *jobHandler) *jobQueue {
+	q.handlers[kind] = handler
+	return q
+}
+
+// enqueue persists a new job, which is run as soon as a worker is available.
+func (q *jobQueue) enqueue(kind, payload string) error {
+	j := job.NewJob(goapi.AppVersionNumber, kind, payload)
+	if ok, err := q.dao.Create(j); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("cannot create job [%s]", kind)
+	}
+	select {
+	case q.wakeup <- true:
+	default:
+	}
+	return nil
+}
+
+// start starts the dispatcher, which polls for due jobs (including those enqueued by other instances and those whose
+// lease has expired) and periodically purges expired jobs.
+func (q *jobQueue) start() {
+	go func() {
+		for {
+			if now := time.Now(); now.Sub(q.purgedAt) >= jobPurgeInterval {
+				q.purge(now)
+				q.purgedAt = now
+			}
+			if q.dispatch(time.Now()) {
+				// more jobs may be due
+				continue
+			}
+			select {
+			case <-q.wakeup:
+			case <-time.After(q.pollInterval):
+			}
+		}
+	}()
+}
+
+// dispatch hands due jobs over to workers, blocks while all workers are busy. Due jobs are loaded in batches of as many
+// jobs as workers; true is returned if the batch is full and at least one of its jobs has been claimed, i.e. more jobs
+// may be due.
+func (q *jobQueue) dispatch(now time.Time) bool {
+	jobList, err := q.dao.GetDueJobs(now, cap(q.slots))
+	if err != nil {
+		log.Println(fmt.Sprintf("[ERROR] jobQueue.dispatch - error loading due jobs: %s", err))
+		return false
+	}
+	claimed := 0
+	for _, j := range jobList {
+		if _, ok := q.inFlight.Load(j.GetId()); ok {
+			continue
+		}
+		q.slots <- true
+		if j = q.claim(j.GetId()); j == nil {
+			<-q.slots
+			continue
+		}
+		claimed++
+		go func(j *job.Job) {
+			defer func() {
+				q.inFlight.Delete(j.GetId())
+				<-q.slots
+			}()
+			q.run(j)
+		}(j)
+	}
+	return claimed > 0 && len(jobList) >= cap(q.slots)
+}
+
+// claim marks a due job as running for a lease period, returns nil if the job is no longer due or it has been claimed
+// by another instance in the meantime (see job.ClaimJob).
+func (q *jobQueue) claim(id string) *job.Job {
+	j, err := q.dao.Get(id)
+	now := time.Now()
+	if err != nil || j == nil || !j.IsDue(now) {
+		return nil
+	}
+	if ok, err := job.ClaimJob(q.dao, j, now.Add(q.lease)); err != nil {
+		log.Println(fmt.Sprintf("[ERROR] jobQueue.claim(%s) - cannot claim job: %s", id, err))
+		return nil
+	} else if !ok {
+		return nil
+	}
+	q.inFlight.Store(id, true)
+	return j
+}
+
+// run runs a claimed job: the job is removed if it succeeds, otherwise it is rescheduled with exponential backoff
+// or marked as failed once it has been attempted maxAttempts times or the error is permanent.
+func (q *jobQueue) run(j *job.Job) {
+	err := q.runHandler(j)
+	if err == nil {
+		if ok, err := job.ReleaseJob(q.dao, j, true); err != nil {
+			log.Println(fmt.Sprintf("[ERROR] jobQueue.run(%s/%s) - error removing finished job: %s", j.GetKind(), j.GetId(), err))
+		} else if !ok {
+			log.Println(fmt.Sprintf("[WARN] jobQueue.run(%s/%s) - lease of attempt #%d expired before the job finished, job has been claimed again", j.GetKind(), j.GetId(), j.GetAttempts()))
+		}
+		return
+	}
+	var permanentErr *permanentJobError
+	permanent := errors.As(err, &permanentErr)
+	j.SetLastError(err.Error())
+	if permanent || j.GetAttempts() >= q.maxAttempts {
+		log.Println(fmt.Sprintf("[ERROR] jobQueue.run(%s/%s) - job failed after %d attempt(s): %s", j.GetKind(), j.GetId(), j.GetAttempts(), err))
+		// failed job is kept until the retention period has passed since it failed
+		j.SetStatus(job.StatusFailed).SetNextRunAt(time.Now())
+	} else {
+		log.Println(fmt.Sprintf("[WARN] jobQueue.run(%s/%s) - attempt #%d failed, retrying: %s", j.GetKind(), j.GetId(), j.GetAttempts(), err))
+		j.SetStatus(job.StatusPending).SetNextRunAt(time.Now().Add(q.backoff(j.GetAttempts())))
+	}
+	if ok, err := job.ReleaseJob(q.dao, j, false); err != nil {
+		log.Println(fmt.Sprintf("[ERROR] jobQueue.run(%s/%s) - error updating job: %s", j.GetKind(), j.GetId(), err))
+	} else if !ok {
+		// the attempt claimed after this one takes over
+		log.Println(fmt.Sprintf("[WARN] jobQueue.run(%s/%s) - lease of attempt #%d expired before the job finished, job has been claimed again", j.GetKind(), j.GetId(), j.GetAttempts()))
+		return
+	}
+	if handler := q.handlers[j.GetKind()]; j.GetStatus() == job.StatusFailed && handler != nil && handler.onFail != nil {
+		if permanent {
+			err = permanentErr.error
+		}
+		handler.onFail(j.GetPayload(), err)
+	}
+}
+
+// purge removes failed jobs and claim records (see job.ClaimJob) whose retention period has passed.
+func (q *jobQueue) purge(now time.Time) {
+	for {
+		jobList, err := q.dao.GetExpiredJobs(now.Add(-q.retention), jobPurgeBatchSize)
+		if err != nil {
+			log.Println(fmt.Sprintf("[ERROR] jobQueue.purge - error loading expired jobs: %s", err))
+			return
+		}
+		for _, j := range jobList {
+			if _, err := q.dao.Delete(j); err != nil {
+				log.Println(fmt.Sprintf("[ERROR] jobQueue.purge(%s/%s) - error removing expired job: %s", j.GetKind(), j.GetId(), err))
+				return
+			}
+		}
+		if len(jobList) < jobPurgeBatchSize {
+			return
+		}
+	}
+}
+
+func (q *jobQueue) runHandler(j *job.Job) (err error) {
+	handler := q.handlers[j.GetKind()]
+	if handler == nil || handler.run == nil {
+		return jobPermanentError(fmt.Errorf("no handler for job kind [%s]", j.GetKind()))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+	return handler.run(j.GetPayload())
+}
+
+// backoff returns the delay before a job is retried after the specified number of attempts: backoffBase is doubled
+// after each attempt, up to backoffMax.
+func (q *jobQueue) backoff(attempts int) time.Duration {
+	d := q.backoffBase
+	for i := 1; i < attempts && d < q.backoffMax; i++ {
+		d *= 2
+	}
+	if d > q.backoffMax {
+		d = q.backoffMax
+	}
+	return d
+}
@@ -0,0 +1,333 @@
+package gvabe
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btnguyen2k/godal"
+
+	"main/src/gvabe/bo/job"
+)
+
+// in-memory implementation of job.JobDao
+type testJobDao struct {
+	sync.Mutex
+	jobs map[string]*job.Job
+}
+
+func (dao *testJobDao) Delete(bo *job.Job) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	delete(dao.jobs, bo.GetId())
+	return true, nil
+}
+
+func (dao *testJobDao) Create(bo *job.Job) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.jobs[bo.GetId()] != nil {
+		return false, godal.ErrGdaoDuplicatedEntry
+	}
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.jobs[bo.GetId()] = job.NewJobFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+func (dao *testJobDao) Get(id string) (*job.Job, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if j := dao.jobs[id]; j != nil {
+		return job.NewJobFromUbo(j.UniversalBo), nil
+	}
+	return nil, nil
+}
+
+func (dao *testJobDao) GetDueJobs(now time.Time, limit int) ([]*job.Job, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	result := make([]*job.Job, 0)
+	for _, j := range dao.jobs {
+		if j.IsDue(now) && (limit <= 0 || len(result) < limit) {
+			result = append(result, job.NewJobFromUbo(j.UniversalBo))
+		}
+	}
+	return result, nil
+}
+
+func (dao *testJobDao) GetExpiredJobs(before time.Time, limit int) ([]*job.Job, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	result := make([]*job.Job, 0)
+	for _, j := range dao.jobs {
+		expired := (j.GetStatus() == job.StatusFailed || j.GetStatus() == job.StatusClaim) && j.GetNextRunAt().Before(before)
+		if expired && (limit <= 0 || len(result) < limit) {
+			result = append(result, job.NewJobFromUbo(j.UniversalBo))
+		}
+	}
+	return result, nil
+}
+
+func (dao *testJobDao) Update(bo *job.Job) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.jobs[bo.GetId()] == nil {
+		return false, nil
+	}
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.jobs[bo.GetId()] = job.NewJobFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+// list returns stored jobs, excluding claim records
+func (dao *testJobDao) list() []*job.Job {
+	dao.Lock()
+	defer dao.Unlock()
+	result := make([]*job.Job, 0)
+	for _, j := range dao.jobs {
+		if j.GetStatus() != job.StatusClaim {
+			result = append(result, j)
+		}
+	}
+	return result
+}
+
+func (dao *testJobDao) only(t *testing.T, testName string) *job.Job {
+	jobList := dao.list()
+	if len(jobList) != 1 {
+		t.Fatalf("%s failed: expected 1 job but found %#v", testName, len(jobList))
+	}
+	return jobList[0]
+}
+
+func TestJobQueue_backoff(t *testing.T) {
+	testName := "TestJobQueue_backoff"
+	q := newJobQueue(&testJobDao{jobs: make(map[string]*job.Job)}, 1, 10, time.Second, 10*time.Second, 0, 0, 0)
+	for attempts, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 20: 10 * time.Second} {
+		if v := q.backoff(attempts); v != expected {
+			t.Fatalf("%s failed: expected backoff after %d attempts to be %s but received %s", testName, attempts, expected, v)
+		}
+	}
+}
+
+func TestJobQueue_retry(t *testing.T) {
+	testName := "TestJobQueue_retry"
+	dao := &testJobDao{jobs: make(map[string]*job.Job)}
+	q := newJobQueue(dao, 1, 3, time.Minute, time.Hour, time.Minute, 0, 0)
+	var failedPayload string
+	var failedReason error
+	numRuns := 0
+	q.register("test", &jobHandler{
+		run: func(payload string) error {
+			numRuns++
+			return errors.New("provider is not available")
+		},
+		onFail: func(payload string, reason error) {
+			failedPayload, failedReason = payload, reason
+		},
+	})
+	if err := q.enqueue("test", "payload"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// failed attempts are rescheduled with exponential backoff
+	now := time.Now()
+	for i := 1; i < 3; i++ {
+		j := q.claim(dao.only(t, testName).GetId())
+		if j == nil {
+			t.Fatalf("%s failed: job should be claimable (attempt #%d)", testName, i)
+		}
+		if q.claim(j.GetId()) != nil {
+			t.Fatalf("%s failed: a running job must not be claimed again", testName)
+		}
+		q.run(j)
+		j = dao.only(t, testName)
+		if j.GetStatus() != job.StatusPending || j.GetAttempts() != i || j.GetLastError() != "provider is not available" {
+			t.Fatalf("%s failed: %#v / %#v / %#v", testName, j.GetStatus(), j.GetAttempts(), j.GetLastError())
+		}
+		if delay := j.GetNextRunAt().Sub(now); delay < q.backoff(i)-2*time.Second {
+			t.Fatalf("%s failed: job rescheduled too early (%s)", testName, delay)
+		}
+		// pretend time has passed
+		dao.Update(j.SetNextRunAt(now))
+	}
+
+	// job fails permanently after maxAttempts
+	q.run(q.claim(dao.only(t, testName).GetId()))
+	if j := dao.only(t, testName); j.GetStatus() != job.StatusFailed || j.GetAttempts() != 3 || j.IsDue(now.Add(time.Hour)) {
+		t.Fatalf("%s failed: %#v / %#v", testName, j.GetStatus(), j.GetAttempts())
+	}
+	if numRuns != 3 || failedPayload != "payload" || failedReason == nil || failedReason.Error() != "provider is not available" {
+		t.Fatalf("%s failed: %#v / %#v / %#v", testName, numRuns, failedPayload, failedReason)
+	}
+}
+
+func TestJobQueue_permanentError(t *testing.T) {
+	testName := "TestJobQueue_permanentError"
+	dao := &testJobDao{jobs: make(map[string]*job.Job)}
+	q := newJobQueue(dao, 1, 5, time.Second, time.Minute, time.Minute, 0, 0)
+	var failedReason error
+	q.register("test", &jobHandler{
+		run: func(payload string) error {
+			return jobPermanentError(errors.New("email address is not verified"))
+		},
+		onFail: func(payload string, reason error) {
+			failedReason = reason
+		},
+	})
+	q.enqueue("test", "payload")
+	q.run(q.claim(dao.only(t, testName).GetId()))
+	if j := dao.only(t, testName); j.GetStatus() != job.StatusFailed || j.GetAttempts() != 1 {
+		t.Fatalf("%s failed: %#v / %#v", testName, j.GetStatus(), j.GetAttempts())
+	}
+	if failedReason == nil || failedReason.Error() != "email address is not verified" {
+		t.Fatalf("%s failed: %#v", testName, failedReason)
+	}
+
+	// jobs without handler fail permanently
+	dao.jobs = make(map[string]*job.Job)
+	q.enqueue("unknown", "payload")
+	q.run(q.claim(dao.only(t, testName).GetId()))
+	if j := dao.only(t, testName); j.GetStatus() != job.StatusFailed {
+		t.Fatalf("%s failed: %#v", testName, j.GetStatus())
+	}
+}
+
+func TestJobQueue_dispatch(t *testing.T) {
+	testName := "TestJobQueue_dispatch"
+	dao := &testJobDao{jobs: make(map[string]*job.Job)}
+	q := newJobQueue(dao, 2, 5, time.Second, time.Minute, time.Minute, 0, 0)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	q.register("test", &jobHandler{
+		run: func(payload string) error {
+			defer wg.Done()
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return nil
+		},
+	})
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		q.enqueue("test", "payload")
+	}
+	for q.dispatch(time.Now().Add(time.Second)) {
+		// due jobs are loaded in batches
+	}
+	wg.Wait()
+	time.Sleep(20 * time.Millisecond)
+	if maxRunning > 2 {
+		t.Fatalf("%s failed: expected at most %d concurrent jobs but received %d", testName, 2, maxRunning)
+	}
+	if jobList := dao.list(); len(jobList) != 0 {
+		t.Fatalf("%s failed: finished jobs must be removed, %d remaining", testName, len(jobList))
+	}
+}
+
+func TestJobQueue_claimRace(t *testing.T) {
+	testName := "TestJobQueue_claimRace"
+	dao := &testJobDao{jobs: make(map[string]*job.Job)}
+	q := newJobQueue(dao, 1, 5, time.Second, time.Minute, time.Minute, 0, 0)
+	q.enqueue("test", "payload")
+
+	// both instances have loaded the job before any of them claims it
+	id := dao.only(t, testName).GetId()
+	j1, _ := dao.Get(id)
+	j2, _ := dao.Get(id)
+	leaseUntil := time.Now().Add(time.Minute)
+	if ok, err := job.ClaimJob(dao, j1, leaseUntil); err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+	if ok, err := job.ClaimJob(dao, j2, leaseUntil); err != nil || ok {
+		t.Fatalf("%s failed: an attempt claimed elsewhere must not be claimed again: %#v / %s", testName, ok, err)
+	}
+	if j := dao.only(t, testName); j.GetStatus() != job.StatusRunning || j.GetAttempts() != 1 {
+		t.Fatalf("%s failed: %#v / %#v", testName, j.GetStatus(), j.GetAttempts())
+	}
+
+	// the next attempt can be claimed once the lease has expired
+	j, _ := dao.Get(id)
+	if ok, err := job.ClaimJob(dao, j, leaseUntil.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+
+	// removed jobs can not be claimed
+	dao.Delete(j)
+	if ok, err := job.ClaimJob(dao, j, leaseUntil); err != nil || ok {
+		t.Fatalf("%s failed: removed job must not be claimed: %#v / %s", testName, ok, err)
+	}
+}
+
+func TestJobQueue_staleWorker(t *testing.T) {
+	testName := "TestJobQueue_staleWorker"
+	dao := &testJobDao{jobs: make(map[string]*job.Job)}
+	q := newJobQueue(dao, 1, 5, time.Second, time.Minute, time.Minute, 0, 0)
+	numFails := 0
+	q.register("test", &jobHandler{
+		run:    func(payload string) error { return errors.New("provider is not available") },
+		onFail: func(payload string, reason error) { numFails++ },
+	})
+	q.enqueue("test", "payload")
+	id := dao.only(t, testName).GetId()
+
+	// the lease of the first worker expires while its attempt is still running, the job is claimed again
+	stale := q.claim(id)
+	j, _ := dao.Get(id)
+	if ok, err := job.ClaimJob(dao, j, time.Now().Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+
+	// the first worker must not overwrite the attempt claimed after it
+	q.run(stale)
+	if j := dao.only(t, testName); j.GetStatus() != job.StatusRunning || j.GetAttempts() != 2 {
+		t.Fatalf("%s failed: %#v / %#v", testName, j.GetStatus(), j.GetAttempts())
+	}
+	if ok, err := job.ReleaseJob(dao, stale, true); err != nil || ok {
+		t.Fatalf("%s failed: stale worker must not remove the job: %#v / %s", testName, ok, err)
+	}
+	if dao.only(t, testName); numFails != 0 {
+		t.Fatalf("%s failed: stale worker must not report the job as failed", testName)
+	}
+
+	// the latest attempt is persisted
+	j, _ = dao.Get(id)
+	if ok, err := job.ReleaseJob(dao, j.SetStatus(job.StatusPending), false); err != nil || !ok {
+		t.Fatalf("%s failed: %#v / %s", testName, ok, err)
+	}
+	if j := dao.only(t, testName); j.GetStatus() != job.StatusPending || j.GetAttempts() != 2 {
+		t.Fatalf("%s failed: %#v / %#v", testName, j.GetStatus(), j.GetAttempts())
+	}
+}
+
+func TestJobQueue_purge(t *testing.T) {
+	testName := "TestJobQueue_purge"
+	dao := &testJobDao{jobs: make(map[string]*job.Job)}
+	q := newJobQueue(dao, 1, 1, time.Second, time.Minute, time.Minute, 0, time.Hour)
+	q.register("test", &jobHandler{run: func(payload string) error { return errors.New("provider is not available") }})
+	q.enqueue("test", "payload")
+	q.enqueue("test", "payload")
+	jobList := dao.list()
+	q.run(q.claim(jobList[0].GetId()))
+
+	// failed job and claim records are kept during the retention period
+	q.purge(time.Now())
+	if len(dao.jobs) != 3 {
+		t.Fatalf("%s failed: expected %#v records but found %#v", testName, 3, len(dao.jobs))
+	}
+
+	// pending job is kept after the retention period
+	q.purge(time.Now().Add(2 * time.Hour))
+	if j := dao.only(t, testName); j.GetId() != jobList[1].GetId() || len(dao.jobs) != 1 {
+		t.Fatalf("%s failed: only the pending job should be kept, %#v record(s) found", testName, len(dao.jobs))
+	}
+}
@@ -0,0 +1,117 @@
+package gvabe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"main/src/gvabe/bo/app"
+)
+
+// Algorithms of JWEs Exter issues: the content encryption key is encrypted with the recipient's RSA public key using
+// RSA-OAEP-256, the content is encrypted with AES-256-GCM.
+//
+// available since v0.8.0
+const (
+	jweAlgRsaOaep256 = "RSA-OAEP-256"
+	jweEncA256Gcm    = "A256GCM"
+)
+
+// isJwe checks if the token is in JWE compact serialization (5 dot-separated parts) rather than JWS (3 parts).
+func isJwe(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// encryptJwe encrypts the payload with the recipient's RSA public key and returns the JWE in compact serialization
+// (RFC 7516). cty is the payload's content type, e.g. "JWT" for nested JWTs.
+//
+// available since v0.8.0
+func encryptJwe(payload []byte, cty string, pubKey *rsa.PublicKey) (string, error) {
+	header := map[string]string{"alg": jweAlgRsaOaep256, "enc": jweEncA256Gcm}
+	if cty != "" {
+		header["cty"] = cty
+	}
+	js, _ := json.Marshal(header)
+	protected := base64.RawURLEncoding.EncodeToString(js)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pubKey, cek, nil)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return "", err
+	}
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, aesgcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	// the protected header is the additional authenticated data; GCM appends the authentication tag to the ciphertext
+	sealed := aesgcm.Seal(nil, iv, payload, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-aesgcm.Overhead()], sealed[len(sealed)-aesgcm.Overhead():]
+	return strings.Join([]string{
+		protected,
+		base64.RawURLEncoding.EncodeToString(encryptedKey),
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(ciphertext),
+		base64.RawURLEncoding.EncodeToString(tag),
+	}, "."), nil
+}
+
+// appLoginToken returns the login token handed over to an app: if the app opts in (AppAttrsPublic.EncryptLoginToken),
+// the JWT is wrapped in a JWE encrypted with app's RSA public key so that only the app can read its claims. Otherwise
+// the JWT is returned as-is.
+//
+// available since v0.8.0
+func appLoginToken(app *app.App, jwt string) (string, error) {
+	if app == nil || !app.GetAttrsPublic().EncryptLoginToken {
+		return jwt, nil
+	}
+	pubKey, err := parseRsaPublicKeyFromPem(app.GetAttrsPublic().RsaPublicKey)
+	if err != nil {
+		return "", fmt.Errorf("cannot encrypt login token for app [%s]: %s", app.GetId(), err)
+	}
+	return encryptJwe([]byte(jwt), "JWT", pubKey)
+}
+
+// returnUrlWithLoginToken replaces occurrences of "${token}" in the return url with the login token handed over to the
+// app (see appLoginToken). Exter's authorization callback receives the JWT as-is.
+//
+// available since v0.8.0
+func returnUrlWithLoginToken(app *app.App, returnUrl, jwt string) (string, error) {
+	if !strings.Contains(returnUrl, "${token}") {
+		return returnUrl, nil
+	}
+	_, returnUrl, err := appLoginResult(app, returnUrl, jwt)
+	return returnUrl, err
+}
+
+// appLoginResult returns the login token returned by login APIs and the return url with occurrences of "${token}"
+// replaced by the same token: the token handed over to the app (see appLoginToken), or the JWT as-is if the login
+// continues at Exter's authorization callback.
+//
+// available since v0.8.0
+func appLoginResult(app *app.App, returnUrl, jwt string) (string, string, error) {
+	token := jwt
+	if !isOidcAuthorizeCallbackUrl(returnUrl) {
+		var err error
+		if token, err = appLoginToken(app, jwt); err != nil {
+			return "", "", err
+		}
+	}
+	return token, strings.ReplaceAll(returnUrl, "${token}", token), nil
+}
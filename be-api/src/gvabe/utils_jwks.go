@@ -0,0 +1,289 @@
+package gvabe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// JWKS is not re-fetched more often than this interval when an unknown key-id is encountered
+	jwksMinRefreshInterval = 60 * time.Second
+
+	// allowed clock skew when validating time-based claims (exp, iat, nbf)
+	jwtClockSkew = 60 * time.Second
+)
+
+// jwksKeySet caches public keys, indexed by key-id, fetched from a JWKS endpoint.
+// Keys are kept until the expiry advertised by the endpoint (Cache-Control/Expires headers), or until an unknown key-id
+// is encountered if the endpoint does not advertise one.
+//
+// available since v0.8.0
+type jwksKeySet struct {
+	jwksUri     string
+	httpClient  *http.Client
+	lock        sync.RWMutex
+	keys        map[string]interface{}
+	lastRefresh time.Time // last time keys were fetched, successful or not
+	expiry      time.Time // zero if keys do not expire
+}
+
+func newJwksKeySet(jwksUri string, client *http.Client) *jwksKeySet {
+	if client == nil {
+		client = httpClient
+	}
+	return &jwksKeySet{jwksUri: jwksUri, httpClient: client, keys: make(map[string]interface{})}
+}
+
+// refresh re-fetches keys from the JWKS endpoint.
+func (ks *jwksKeySet) refresh(ctx context.Context) error {
+	ks.lock.Lock()
+	ks.lastRefresh = time.Now()
+	ks.lock.Unlock()
+	req, err := http.NewRequest("GET", ks.jwksUri, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ks.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.New("JWKS response status: " + resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJwks(body)
+	if err != nil {
+		return err
+	}
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	ks.keys = keys
+	ks.expiry = jwksCacheExpiry(resp.Header, time.Now())
+	return nil
+}
+
+// getKey returns the public key associated with the key-id, keys are re-fetched if key-id is not found in cache or
+// cached keys have expired.
+func (ks *jwksKeySet) getKey(ctx context.Context, kid string) (interface{}, error) {
+	ks.lock.RLock()
+	key, ok := ks.keys[kid]
+	lastRefresh, expiry := ks.lastRefresh, ks.expiry
+	ks.lock.RUnlock()
+	expired := !expiry.IsZero() && time.Now().After(expiry)
+	if ok && !expired {
+		return key, nil
+	}
+	if time.Since(lastRefresh) < jwksMinRefreshInterval {
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("key [%s] not found in JWKS", kid)
+	}
+	if err := ks.refresh(ctx); err != nil {
+		if ok {
+			// stale key is still better than failing all verifications while the JWKS endpoint is unavailable
+			log.Printf("[WARN] Cannot refresh JWKS [%s], using cached key [%s]: %s", ks.jwksUri, kid, err)
+			return key, nil
+		}
+		return nil, err
+	}
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	if key, ok = ks.keys[kid]; !ok {
+		return nil, fmt.Errorf("key [%s] not found in JWKS", kid)
+	}
+	return key, nil
+}
+
+// jwksCacheExpiry calculates the time a JWKS response expires from its headers: Cache-Control (no-cache, no-store,
+// max-age, adjusted by Age) or Expires. Zero time is returned if the response does not specify one.
+func jwksCacheExpiry(header http.Header, now time.Time) time.Time {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return now
+		case strings.HasPrefix(directive, "max-age="):
+			maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err != nil {
+				continue
+			}
+			age, _ := strconv.Atoi(strings.TrimSpace(header.Get("Age")))
+			return now.Add(time.Duration(maxAge-age) * time.Second)
+		}
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+	return time.Time{}
+}
+
+// parseJwks parses a JWK Set document (RFC 7517) and returns the map of {key-id: public-key}.
+// Only RSA, EC and (since v0.8.0) Ed25519 signing keys are supported, other keys are silently ignored.
+func parseJwks(data []byte) (map[string]interface{}, error) {
+	jwks := struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if use, _ := jwk["use"].(string); use != "" && use != "sig" {
+			continue
+		}
+		kid, _ := jwk["kid"].(string)
+		if key, err := parseJwk(jwk); err == nil && key != nil {
+			result[kid] = key
+		}
+	}
+	return result, nil
+}
+
+func _jwkBigInt(jwk map[string]interface{}, field string) (*big.Int, error) {
+	v, _ := jwk[field].(string)
+	if v == "" {
+		return nil, fmt.Errorf("JWK field [%s] is missing", field)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// parseJwk parses a single JWK and returns the public key (*rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey).
+func parseJwk(jwk map[string]interface{}) (interface{}, error) {
+	kty, _ := jwk["kty"].(string)
+	switch kty {
+	case "RSA":
+		n, err := _jwkBigInt(jwk, "n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := _jwkBigInt(jwk, "e")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		crv, _ := jwk["crv"].(string)
+		switch crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve: %s", crv)
+		}
+		x, err := _jwkBigInt(jwk, "x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := _jwkBigInt(jwk, "y")
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		// since v0.8.0: Ed25519 keys (RFC 8037)
+		if crv, _ := jwk["crv"].(string); crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve: %s", crv)
+		}
+		x, _ := jwk["x"].(string)
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(x, "="))
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key: %v", err)
+		}
+		return ed25519.PublicKey(b), nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", kty)
+}
+
+// parseAndVerifyJwtWithKeySet parses a JWT and verifies its signature using key from the supplied key set.
+//
+// Only signature and time-based claims (exp, nbf, iat) are verified, caller is responsible for verifying other claims.
+func parseAndVerifyJwtWithKeySet(ctx context.Context, ks *jwksKeySet, jwtStr string) (jwt.MapClaims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(jwtStr, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := ks.getKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			if _, ok := key.(*rsa.PublicKey); ok {
+				return key, nil
+			}
+		case *jwt.SigningMethodECDSA:
+			if _, ok := key.(*ecdsa.PublicKey); ok {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid claim")
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(jwtClockSkew)) {
+		return nil, errorExpiredJwt
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-jwtClockSkew)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if iat, ok := claims["iat"].(float64); ok && now.Before(time.Unix(int64(iat), 0).Add(-jwtClockSkew)) {
+		return nil, errors.New("token used before issued")
+	}
+	return claims, nil
+}
+
+// jwtClaimsHasAudience checks if the "aud" claim (either a string or an array of strings) contains the specified audience.
+func jwtClaimsHasAudience(claims jwt.MapClaims, aud string) bool {
+	switch v := claims["aud"].(type) {
+	case string:
+		return v == aud
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == aud {
+				return true
+			}
+		}
+	case []string:
+		for _, s := range v {
+			if s == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
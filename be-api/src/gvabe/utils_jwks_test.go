@@ -0,0 +1,86 @@
+package gvabe
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func _testRsaJwk(kid string, key *rsa.PublicKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "RSA", "use": "sig", "alg": "RS256", "kid": kid,
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestJwksCacheExpiry(t *testing.T) {
+	testName := "TestJwksCacheExpiry"
+	now := time.Now()
+	testCases := []struct {
+		header   http.Header
+		expected time.Time
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=19302, must-revalidate, no-transform"}}, now.Add(19302 * time.Second)},
+		{http.Header{"Cache-Control": {"max-age=600"}, "Age": {"100"}}, now.Add(500 * time.Second)},
+		{http.Header{"Cache-Control": {"no-cache"}}, now},
+		{http.Header{"Cache-Control": {"no-store, max-age=600"}}, now},
+		{http.Header{"Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)}}, now.Add(time.Hour).Truncate(time.Second)},
+		{http.Header{"Cache-Control": {"max-age=invalid"}}, time.Time{}},
+		{http.Header{}, time.Time{}},
+	}
+	for _, tc := range testCases {
+		if expiry := jwksCacheExpiry(tc.header, now); !expiry.Equal(tc.expected) {
+			t.Fatalf("%s failed: expected %s but received %s for %#v", testName, tc.expected, expiry, tc.header)
+		}
+	}
+}
+
+func TestJwksKeySet_getKey(t *testing.T) {
+	testName := "TestJwksKeySet_getKey"
+	key1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks := []map[string]interface{}{_testRsaJwk("key1", &key1.PublicKey)}
+	numFetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numFetches++
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": jwks})
+	}))
+	defer server.Close()
+	ks := newJwksKeySet(server.URL, nil)
+
+	if key, err := ks.getKey(context.Background(), "key1"); err != nil || key.(*rsa.PublicKey).N.Cmp(key1.N) != 0 {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err := ks.getKey(context.Background(), "key1"); err != nil || numFetches != 1 {
+		t.Fatalf("%s failed: cached key must be used (%d fetches) / %s", testName, numFetches, err)
+	}
+
+	// keys are rotated: cached keys expire, new key is fetched, removed key is gone
+	jwks = []map[string]interface{}{_testRsaJwk("key2", &key2.PublicKey)}
+	ks.expiry = time.Now().Add(-time.Second)
+	ks.lastRefresh = time.Now().Add(-jwksMinRefreshInterval)
+	if _, err := ks.getKey(context.Background(), "key2"); err != nil || numFetches != 2 {
+		t.Fatalf("%s failed: expired keys must be re-fetched (%d fetches) / %s", testName, numFetches, err)
+	}
+	if _, err := ks.getKey(context.Background(), "key1"); err == nil || numFetches != 2 {
+		t.Fatalf("%s failed: JWKS must not be re-fetched too often (%d fetches) / %s", testName, numFetches, err)
+	}
+
+	// stale key is used if JWKS endpoint is not available
+	ks.expiry = time.Now().Add(-time.Second)
+	ks.lastRefresh = time.Now().Add(-jwksMinRefreshInterval)
+	ks.jwksUri = server.URL + "/notfound"
+	server.Close()
+	if _, err := ks.getKey(context.Background(), "key2"); err != nil {
+		t.Fatalf("%s failed: stale key must be used / %s", testName, err)
+	}
+}
@@ -0,0 +1,397 @@
+package gvabe
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/signingkey"
+)
+
+const (
+	keysetDefaultRotationInterval = 30 * 24 * time.Hour
+	keysetDefaultGracePeriod      = 24 * time.Hour
+	keysetDefaultRefreshInterval  = 1 * time.Minute
+
+	// an unknown "kid" triggers a reload of the keyset (e.g. the key has just been created by another Exter instance),
+	// at most once per this interval
+	keysetReloadMinInterval = 10 * time.Second
+)
+
+var (
+	// keys Exter signs tokens with, see initKeyset
+	signingKeys *keyset
+)
+
+// keysetKey is a signing key loaded in memory.
+//
+// available since v0.8.0
+type keysetKey struct {
+	kid, alg, status       string
+	privKey                crypto.Signer
+	pubKey                 crypto.PublicKey
+	createdAt              time.Time
+	activatedAt, retiredAt time.Time
+}
+
+// keyset holds the keys Exter signs tokens with: the "active" key signs new tokens, "next" keys are published ahead of
+// their activation so that clients caching the JWKS know them before they are used, and "retired" keys are kept for a
+// grace period so that tokens signed by them can still be verified.
+//
+// Keys are stored via SigningKeyDao (private keys are encrypted) so that they are shared by all Exter instances using
+// the same database. The key configured at [gvabe.keys.rsa_privkey_file] is imported as the first active key; if
+// rotation is disabled, it remains the active key (a newly configured key replaces the active key upon start).
+//
+// available since v0.8.0
+type keyset struct {
+	dao             signingkey.SigningKeyDao
+	encKey          []byte     // key to encrypt private keys before storing them
+	initKey         *keysetKey // the configured key
+	alg             string     // signing algorithm of keys generated by rotation
+	rotation        bool       // rotate keys on schedule?
+	interval        time.Duration
+	gracePeriod     time.Duration
+	refreshInterval time.Duration
+
+	lock       sync.RWMutex
+	keys       map[string]*keysetKey
+	active     *keysetKey
+	lastReload time.Time
+}
+
+func newKeyset(dao signingkey.SigningKeyDao, encKey []byte, privKey crypto.Signer, alg string, rotation bool, interval, gracePeriod, refreshInterval time.Duration) *keyset {
+	if alg == "" {
+		alg = keySigningAlg(privKey)
+	}
+	if interval <= 0 {
+		interval = keysetDefaultRotationInterval
+	}
+	if gracePeriod <= 0 {
+		gracePeriod = keysetDefaultGracePeriod
+	}
+	if minGracePeriod := loginSessionTtl * time.Second; gracePeriod < minGracePeriod {
+		// tokens signed by a retired key must remain verifiable until they expire
+		log.Printf("[WARN] Grace period of retired signing keys is increased from %s to %s (login session ttl)", gracePeriod, minGracePeriod)
+		gracePeriod = minGracePeriod
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = keysetDefaultRefreshInterval
+	}
+	return &keyset{
+		dao:             dao,
+		encKey:          encKey,
+		initKey:         &keysetKey{kid: jwkThumbprint(privKey.Public()), alg: keySigningAlg(privKey), privKey: privKey, pubKey: privKey.Public()},
+		alg:             alg,
+		rotation:        rotation,
+		interval:        interval,
+		gracePeriod:     gracePeriod,
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]*keysetKey),
+	}
+}
+
+// encodePrivKey encrypts a private key (in PKCS8 form) to be stored via SigningKeyDao.
+func (ks *keyset) encodePrivKey(privKey crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return "", err
+	}
+	encdata, err := zipAndEncrypt(der, ks.encKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encdata), nil
+}
+
+// decodePrivKey decrypts a private key stored via SigningKeyDao.
+func (ks *keyset) decodePrivKey(keyData string) (crypto.Signer, error) {
+	encdata, err := base64.StdEncoding.DecodeString(keyData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decryptAndUnzip(encdata, ks.encKey)
+	if err != nil {
+		return nil, err
+	}
+	if privKey, err := x509.ParsePKCS1PrivateKey(data); err == nil {
+		// RSA keys stored before other algorithms were supported
+		return privKey, nil
+	}
+	return parsePrivKeyPem("PRIVATE KEY", data)
+}
+
+// reload loads all keys from storage.
+func (ks *keyset) reload() error {
+	boList, err := ks.dao.GetAll()
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]*keysetKey)
+	var active *keysetKey
+	for _, bo := range boList {
+		privKey, err := ks.decodePrivKey(bo.GetKeyData())
+		if err != nil {
+			log.Printf("[WARN] Cannot decrypt signing key [%s] (was [gvabe.keys.keyset_key] changed?): %s", bo.GetId(), err)
+			continue
+		}
+		key := &keysetKey{kid: bo.GetId(), alg: bo.GetAlg(), status: bo.GetStatus(), privKey: privKey, pubKey: privKey.Public(),
+			createdAt: bo.GetTimeCreated(), activatedAt: bo.GetActivatedAt(), retiredAt: bo.GetRetiredAt()}
+		keys[key.kid] = key
+		// if instances have raced to rotate keys, the latest activated key wins
+		if key.status == signingkey.StatusActive && (active == nil || key.activatedAt.After(active.activatedAt)) {
+			active = key
+		}
+	}
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	ks.keys, ks.active, ks.lastReload = keys, active, time.Now()
+	return nil
+}
+
+// createKey stores a new key.
+func (ks *keyset) createKey(privKey crypto.Signer, status string, now time.Time) error {
+	keyData, err := ks.encodePrivKey(privKey)
+	if err != nil {
+		return err
+	}
+	bo := signingkey.NewSigningKey(0, jwkThumbprint(privKey.Public()), keySigningAlg(privKey), keyData).SetStatus(status)
+	if status == signingkey.StatusActive {
+		bo.SetActivatedAt(now)
+	}
+	if ok, err := ks.dao.Create(bo); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("cannot create signing key [%s]", bo.GetId())
+	}
+	return nil
+}
+
+// setStatus updates status of a stored key.
+func (ks *keyset) setStatus(kid, status string, now time.Time) error {
+	bo, err := ks.dao.Get(kid)
+	if err != nil || bo == nil {
+		return err
+	}
+	bo.SetStatus(status)
+	switch status {
+	case signingkey.StatusActive:
+		bo.SetActivatedAt(now)
+	case signingkey.StatusRetired:
+		bo.SetRetiredAt(now)
+	}
+	if ok, err := ks.dao.Update(bo); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("cannot update signing key [%s]", kid)
+	}
+	return nil
+}
+
+// init loads the keyset from storage. The configured key is imported as the active key if there is no active key, or
+// if rotation is disabled and the configured key is not the active one (the replaced key is retired).
+func (ks *keyset) init(now time.Time) error {
+	if err := ks.reload(); err != nil {
+		return err
+	}
+	active := ks.getActive()
+	if active != nil && (ks.rotation || active.kid == ks.initKey.kid) {
+		return nil
+	}
+	if ks.getKey(ks.initKey.kid) != nil {
+		log.Printf("[WARN] Configured signing key [%s] is reactivated", ks.initKey.kid)
+		if err := ks.setStatus(ks.initKey.kid, signingkey.StatusActive, now); err != nil {
+			return err
+		}
+	} else if err := ks.createKey(ks.initKey.privKey, signingkey.StatusActive, now); err != nil {
+		return err
+	}
+	if active != nil {
+		log.Printf("[INFO] Signing key [%s] has been replaced by the configured key [%s]", active.kid, ks.initKey.kid)
+		if err := ks.setStatus(active.kid, signingkey.StatusRetired, now); err != nil {
+			return err
+		}
+	}
+	return ks.reload()
+}
+
+// rotate runs one step of the scheduled rotation:
+//   - there is always one "next" key, so that clients know it before it becomes active
+//   - once the active key is older than the rotation interval, the oldest "next" key becomes active and the active key is retired
+//   - retired keys are removed once their grace period has passed
+//
+// If rotation is disabled, only the last step is run.
+func (ks *keyset) rotate(now time.Time) error {
+	if err := ks.reload(); err != nil {
+		return err
+	}
+	active, nextKeys, retiredKeys := ks.getActive(), make([]*keysetKey, 0), make([]*keysetKey, 0)
+	for _, key := range ks.getKeys() {
+		switch key.status {
+		case signingkey.StatusNext:
+			nextKeys = append(nextKeys, key)
+		case signingkey.StatusRetired:
+			retiredKeys = append(retiredKeys, key)
+		}
+	}
+	if ks.rotation && active != nil && len(nextKeys) > 0 && !active.activatedAt.Add(ks.interval).After(now) {
+		// the oldest "next" key is the one clients are most likely to know already
+		next := nextKeys[0]
+		for _, key := range nextKeys {
+			if key.createdAt.Before(next.createdAt) {
+				next = key
+			}
+		}
+		if err := ks.setStatus(next.kid, signingkey.StatusActive, now); err != nil {
+			return err
+		}
+		if err := ks.setStatus(active.kid, signingkey.StatusRetired, now); err != nil {
+			return err
+		}
+		log.Printf("[INFO] Signing key [%s] has been retired, key [%s] is now active", active.kid, next.kid)
+		nextKeys = nextKeys[:0]
+	}
+	if ks.rotation && len(nextKeys) == 0 {
+		privKey, err := genSigningKey(ks.alg)
+		if err != nil {
+			return err
+		}
+		if err := ks.createKey(privKey, signingkey.StatusNext, now); err != nil {
+			return err
+		}
+	}
+	for _, key := range retiredKeys {
+		if !key.retiredAt.Add(ks.gracePeriod).After(now) {
+			if _, err := ks.dao.Delete(signingkey.NewSigningKey(0, key.kid, key.alg, "")); err != nil {
+				return err
+			}
+			log.Printf("[INFO] Signing key [%s] has been removed (retired at %s)", key.kid, key.retiredAt)
+		}
+	}
+	return ks.reload()
+}
+
+// start periodically reloads the keyset (keys may be rotated by another Exter instance) and runs the rotation.
+func (ks *keyset) start() {
+	go func() {
+		for {
+			<-time.After(ks.refreshInterval)
+			if err := ks.rotate(time.Now()); err != nil {
+				log.Printf("[ERROR] Error while refreshing signing keys: %s", err)
+			}
+		}
+	}()
+}
+
+func (ks *keyset) getActive() *keysetKey {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	return ks.active
+}
+
+func (ks *keyset) getKey(kid string) *keysetKey {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	return ks.keys[kid]
+}
+
+// getKeys returns all keys of the keyset, sorted by kid.
+func (ks *keyset) getKeys() []*keysetKey {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	result := make([]*keysetKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].kid < result[j].kid })
+	return result
+}
+
+// signingKey returns the key to sign new tokens with.
+func (ks *keyset) signingKey() *keysetKey {
+	if active := ks.getActive(); active != nil {
+		return active
+	}
+	return ks.initKey
+}
+
+// verificationKey returns the key to verify a token signed with key "kid". Tokens without "kid" were issued before
+// the keyset was introduced and are verified with the configured RSA key.
+func (ks *keyset) verificationKey(kid string) *keysetKey {
+	if kid == "" {
+		kid = ks.initKey.kid
+	}
+	if key := ks.getKey(kid); key != nil {
+		return key
+	}
+	ks.lock.RLock()
+	canReload := time.Since(ks.lastReload) >= keysetReloadMinInterval
+	ks.lock.RUnlock()
+	if canReload {
+		if err := ks.reload(); err != nil {
+			log.Printf("[ERROR] Error while reloading signing keys: %s", err)
+		}
+		return ks.getKey(kid)
+	}
+	return nil
+}
+
+// jwks returns the JSON Web Key Set of all keys: the active key first, followed by "next" and "retired" keys.
+func (ks *keyset) jwks() []interface{} {
+	active := ks.signingKey()
+	result := []interface{}{publicJwk(active.pubKey)}
+	for _, key := range ks.getKeys() {
+		if key.kid != active.kid {
+			result = append(result, publicJwk(key.pubKey))
+		}
+	}
+	return result
+}
+
+// algs returns the signing algorithms of all keys, the active key's first.
+func (ks *keyset) algs() []string {
+	result := []string{ks.signingKey().alg}
+	for _, key := range ks.getKeys() {
+		found := false
+		for _, alg := range result {
+			found = found || alg == key.alg
+		}
+		if !found {
+			result = append(result, key.alg)
+		}
+	}
+	return result
+}
+
+// signJwt signs a token with the active key, the key's id is put in the "kid" header.
+//
+// available since v0.8.0
+func signJwt(claims jwt.Claims) (string, error) {
+	key := signingKeys.signingKey()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.alg), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.privKey)
+}
+
+// jwtVerificationKey is a jwt.Keyfunc that looks up the key to verify a token signed by Exter, by the "kid" header.
+// The token's algorithm is pinned to the key's: e.g. a token claiming "HS256" or "none" is never verified with a public key.
+//
+// available since v0.8.0
+func jwtVerificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := signingKeys.verificationKey(kid)
+	if key == nil {
+		return nil, errors.New("unknown signing key [" + kid + "]")
+	}
+	if token.Method == nil || token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.pubKey, nil
+}
@@ -0,0 +1,266 @@
+package gvabe
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/signingkey"
+)
+
+type testSigningKeyDao struct {
+	sync.Mutex
+	keys map[string]*signingkey.SigningKey
+}
+
+func (dao *testSigningKeyDao) Delete(bo *signingkey.SigningKey) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	delete(dao.keys, bo.GetId())
+	return true, nil
+}
+
+func (dao *testSigningKeyDao) Create(bo *signingkey.SigningKey) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.keys[bo.GetId()] != nil {
+		return false, nil
+	}
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.keys[bo.GetId()] = signingkey.NewSigningKeyFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+func (dao *testSigningKeyDao) Get(id string) (*signingkey.SigningKey, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if key := dao.keys[id]; key != nil {
+		return signingkey.NewSigningKeyFromUbo(key.UniversalBo), nil
+	}
+	return nil, nil
+}
+
+func (dao *testSigningKeyDao) GetAll() ([]*signingkey.SigningKey, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	result := make([]*signingkey.SigningKey, 0, len(dao.keys))
+	for _, key := range dao.keys {
+		result = append(result, signingkey.NewSigningKeyFromUbo(key.UniversalBo))
+	}
+	return result, nil
+}
+
+func (dao *testSigningKeyDao) Update(bo *signingkey.SigningKey) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.keys[bo.GetId()] == nil {
+		return false, nil
+	}
+	bo.MarshalJSON()
+	dao.keys[bo.GetId()] = signingkey.NewSigningKeyFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+// setupTestKeyset replaces the global keyset with one backed by an in-memory SigningKeyDao; the returned function
+// restores the original keyset.
+func setupTestKeyset(t *testing.T, testName string) (*keyset, *testSigningKeyDao, func()) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	dao := &testSigningKeyDao{keys: make(map[string]*signingkey.SigningKey)}
+	encKey := sha256.Sum256([]byte("keyset-key"))
+	ks := newKeyset(dao, encKey[:], privKey, "", true, time.Hour, 10*time.Hour, time.Minute)
+	if err := ks.init(time.Now()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	orig := signingKeys
+	signingKeys = ks
+	return ks, dao, func() { signingKeys = orig }
+}
+
+func TestKeyset_init(t *testing.T) {
+	testName := "TestKeyset_init"
+	ks, dao, teardown := setupTestKeyset(t, testName)
+	defer teardown()
+
+	// the configured key is imported as the active key
+	active := ks.signingKey()
+	if active.kid != jwkThumbprint(ks.initKey.pubKey) || active.status != signingkey.StatusActive || len(dao.keys) != 1 {
+		t.Fatalf("%s failed: %#v", testName, active)
+	}
+	// private keys are stored encrypted
+	if bo := dao.keys[active.kid]; bo == nil || bo.GetKeyData() == "" {
+		t.Fatalf("%s failed: key not stored", testName)
+	} else if privKey, err := ks.decodePrivKey(bo.GetKeyData()); err != nil || jwkThumbprint(privKey.Public()) != active.kid {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// another instance sharing the same database loads the same keys
+	other := newKeyset(dao, ks.encKey, ks.initKey.privKey, "", false, 0, time.Hour, 0)
+	if err := other.init(time.Now()); err != nil || other.signingKey().kid != active.kid || len(dao.keys) != 1 {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// grace period is never shorter than the login session ttl
+	if other.gracePeriod != loginSessionTtl*time.Second || ks.gracePeriod != 10*time.Hour {
+		t.Fatalf("%s failed: %s / %s", testName, other.gracePeriod, ks.gracePeriod)
+	}
+}
+
+func TestKeyset_rotate(t *testing.T) {
+	testName := "TestKeyset_rotate"
+	ks, dao, teardown := setupTestKeyset(t, testName)
+	defer teardown()
+	now := time.Now()
+	initKid := ks.signingKey().kid
+
+	// a "next" key is published, but not used to sign tokens yet
+	if err := ks.rotate(now); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if len(dao.keys) != 2 || ks.signingKey().kid != initKid || len(ks.jwks()) != 2 {
+		t.Fatalf("%s failed: expected 1 active and 1 next key", testName)
+	}
+	var nextKid string
+	for _, key := range ks.getKeys() {
+		if key.status == signingkey.StatusNext {
+			nextKid = key.kid
+		}
+	}
+	oldToken, _ := signJwt(jwt.MapClaims{"sub": "user@domain.com"})
+
+	// rotation interval has passed: the "next" key becomes active, the old key is retired and a new "next" key is published
+	now = now.Add(ks.interval)
+	if err := ks.rotate(now); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if active := ks.signingKey(); active.kid != nextKid || len(dao.keys) != 3 {
+		t.Fatalf("%s failed: expected key [%s] to be active but received [%s]", testName, nextKid, active.kid)
+	}
+	if retired := ks.getKey(initKid); retired == nil || retired.status != signingkey.StatusRetired {
+		t.Fatalf("%s failed: key [%s] should be retired", testName, initKid)
+	}
+	newToken, _ := signJwt(jwt.MapClaims{"sub": "user@domain.com"})
+	if token, _, _ := new(jwt.Parser).ParseUnverified(newToken, jwt.MapClaims{}); token.Header["kid"] != nextKid {
+		t.Fatalf("%s failed: new tokens should be signed with key [%s]", testName, nextKid)
+	}
+	for _, tokenStr := range []string{oldToken, newToken} {
+		if _, err := jwt.Parse(tokenStr, jwtVerificationKey); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+
+	// grace period has passed: the retired key is removed and tokens signed with it are rejected
+	now = now.Add(ks.gracePeriod)
+	if err := ks.rotate(now); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if ks.getKey(initKid) != nil || len(dao.keys) != 3 {
+		t.Fatalf("%s failed: key [%s] should be removed", testName, initKid)
+	}
+	if _, err := jwt.Parse(oldToken, jwtVerificationKey); err == nil {
+		t.Fatalf("%s failed: tokens signed with a removed key should be rejected", testName)
+	}
+}
+
+func TestJwtVerificationKey(t *testing.T) {
+	testName := "TestJwtVerificationKey"
+	ks, _, teardown := setupTestKeyset(t, testName)
+	defer teardown()
+
+	// tokens issued before the keyset was introduced have no "kid" and are verified with the configured key
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user@domain.com"})
+	tokenStr, _ := token.SignedString(ks.initKey.privKey)
+	if _, err := jwt.Parse(tokenStr, jwtVerificationKey); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// tokens signed with an unknown key are rejected
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token = jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user@domain.com"})
+	token.Header["kid"] = jwkThumbprint(&otherKey.PublicKey)
+	tokenStr, _ = token.SignedString(otherKey)
+	if _, err := jwt.Parse(tokenStr, jwtVerificationKey); err == nil {
+		t.Fatalf("%s failed: tokens signed with unknown key should be rejected", testName)
+	}
+
+	// a key created by another instance is picked up by reloading the keyset
+	ks.lastReload = time.Time{}
+	if err := ks.createKey(otherKey, signingkey.StatusNext, time.Now()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err := jwt.Parse(tokenStr, jwtVerificationKey); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+func TestKeyset_signingAlgs(t *testing.T) {
+	testName := "TestKeyset_signingAlgs"
+	for _, alg := range []string{signingAlgES256, signingAlgEdDSA} {
+		ks, dao, teardown := setupTestKeyset(t, testName)
+		rsaKid := ks.signingKey().kid
+
+		// keys generated by rotation use the configured algorithm
+		ks.alg = alg
+		now := time.Now()
+		ks.rotate(now)
+		if err := ks.rotate(now.Add(ks.interval)); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		active := ks.signingKey()
+		if active.alg != alg || keySigningAlg(active.privKey) != alg || dao.keys[active.kid].GetAlg() != alg {
+			t.Fatalf("%s failed: expected %s key but received %#v", testName, alg, active)
+		}
+		if algs := ks.algs(); len(algs) != 2 || algs[0] != alg || algs[1] != signingAlgRS256 {
+			t.Fatalf("%s failed: %#v", testName, algs)
+		}
+		tokenStr, err := signJwt(jwt.MapClaims{"sub": "user@domain.com"})
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		if token, err := jwt.Parse(tokenStr, jwtVerificationKey); err != nil || token.Method.Alg() != alg {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+
+		// algorithm is pinned to the key: a token signed by the RSA key but claiming the EC/Ed25519 key id is rejected
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user@domain.com"})
+		token.Header["kid"] = active.kid
+		tokenStr, _ = token.SignedString(ks.getKey(rsaKid).privKey)
+		if _, err := jwt.Parse(tokenStr, jwtVerificationKey); err == nil {
+			t.Fatalf("%s failed: token with mismatched algorithm should be rejected", testName)
+		}
+		teardown()
+	}
+}
+
+func TestKeyset_configuredKeyReplacesActiveKey(t *testing.T) {
+	testName := "TestKeyset_configuredKeyReplacesActiveKey"
+	ks, dao, teardown := setupTestKeyset(t, testName)
+	defer teardown()
+	oldKid := ks.signingKey().kid
+
+	// rotation is disabled: a newly configured key (e.g. switching to ES256) becomes active upon start
+	privKey, _ := genSigningKey(signingAlgES256)
+	other := newKeyset(dao, ks.encKey, privKey, "", false, 0, 0, 0)
+	if err := other.init(time.Now()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if active := other.signingKey(); active.kid != jwkThumbprint(privKey.Public()) || active.alg != signingAlgES256 {
+		t.Fatalf("%s failed: %#v", testName, active)
+	}
+	if old := other.getKey(oldKid); old == nil || old.status != signingkey.StatusRetired {
+		t.Fatalf("%s failed: key [%s] should be retired", testName, oldKid)
+	}
+
+	// rotation is enabled: the configured key is only the initial key
+	ks.rotation = true
+	if err := ks.init(time.Now()); err != nil || ks.signingKey().kid != other.signingKey().kid {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
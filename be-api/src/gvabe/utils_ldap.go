@@ -0,0 +1,356 @@
+package gvabe
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
+	"github.com/go-ldap/ldap/v3"
+
+	"main/src/gvabe/bo/app"
+	"main/src/itineris"
+)
+
+const (
+	ldapModeSearch = "search" // search user's entry with a service account, then bind as the user
+	ldapModeDirect = "direct" // bind as the user directly, bind DN is built from a template
+
+	ldapPlaceholderUsername = "{username}"
+	ldapPlaceholderDn       = "{dn}"
+
+	ldapDefaultTimeout        = 10 * time.Second
+	ldapDefaultUserFilter     = "(uid={username})"
+	ldapDefaultEmailAttribute = "mail"
+	ldapDefaultNameAttribute  = "displayName"
+)
+
+var (
+	errorLdapInvalidCredentials = errors.New("invalid username or password")
+	errorLdapNotInGroup         = errors.New("user is not allowed to login")
+	errorLdapNoEmail            = errors.New("directory entry does not contain an email address")
+
+	// settings of LDAP login channel, nil if the channel is not enabled
+	ldapConf *ldapConfig
+)
+
+// ldapConfig holds settings of the LDAP/Active Directory server to authenticate users against.
+//
+// available since v0.8.0
+type ldapConfig struct {
+	url                string        // ldap:// or ldaps:// url of the server
+	startTls           bool          // upgrade ldap:// connections with StartTLS
+	insecureSkipVerify bool          // skip verifying server's certificate (for testing only!)
+	timeout            time.Duration // timeout of network operations
+	mode               string        // ldapModeSearch or ldapModeDirect
+	bindDn             string        // service account to search users and groups (search mode), anonymous if empty
+	bindPassword       string        // password of the service account
+	baseDn             string        // where to search for users
+	userFilter         string        // filter to search for user's entry, "{username}" is replaced by the (escaped) login username
+	userDnTemplate     string        // template of user's bind DN (direct mode), e.g. "uid={username},ou=people,dc=example,dc=com" or "{username}@corp.example.com"
+	groupBaseDn        string        // where to search for groups, baseDn is used if empty
+	groupFilter        string        // (optional) filter a group entry must match for the user to login, "{dn}" and "{username}" are replaced
+	emailAttribute     string        // attribute holding user's email address
+	nameAttribute      string        // attribute holding user's display name
+}
+
+// ldapUser captures user's info looked up from the directory.
+//
+// available since v0.8.0
+type ldapUser struct {
+	Dn          string `json:"dn"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"name"`
+}
+
+// ldapEscapeDnValue escapes a value to be used as an attribute value in a DN (RFC 4514).
+func ldapEscapeDnValue(value string) string {
+	sb := strings.Builder{}
+	for i, r := range value {
+		switch {
+		case r == 0:
+			sb.WriteString("\\00")
+			continue
+		case strings.ContainsRune(",+\"\\<>;=", r),
+			(r == ' ' || r == '#') && i == 0,
+			r == ' ' && i == len(value)-1:
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// dial opens a connection to the LDAP server, upgrading it with StartTLS if configured.
+func (conf *ldapConfig) dial() (*ldap.Conn, error) {
+	tlsConf := &tls.Config{InsecureSkipVerify: conf.insecureSkipVerify}
+	if u, err := url.Parse(conf.url); err == nil {
+		tlsConf.ServerName = u.Hostname()
+	}
+	conn, err := ldap.DialURL(conf.url, ldap.DialWithDialer(&net.Dialer{Timeout: conf.timeout}), ldap.DialWithTLSConfig(tlsConf))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(conf.timeout)
+	if conf.startTls {
+		if err := conn.StartTLS(tlsConf); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// bindServiceAccount binds the connection as the configured service account (or stays anonymous if none).
+func (conf *ldapConfig) bindServiceAccount(conn *ldap.Conn) error {
+	if conf.bindDn == "" {
+		return nil
+	}
+	return conn.Bind(conf.bindDn, conf.bindPassword)
+}
+
+// searchOne searches for exactly one entry, nil is returned if no or more than one entry is found.
+func (conf *ldapConfig) searchOne(conn *ldap.Conn, baseDn string, scope int, filter string) (*ldap.Entry, error) {
+	req := ldap.NewSearchRequest(baseDn, scope, ldap.NeverDerefAliases, 2, int(conf.timeout/time.Second), false,
+		filter, []string{conf.emailAttribute, conf.nameAttribute}, nil)
+	result, err := conn.Search(req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) || ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+// authenticate verifies user's credentials against the directory and looks up user's email address and display name.
+//
+// available since v0.8.0
+func (conf *ldapConfig) authenticate(username, password string) (*ldapUser, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		// a simple bind with empty password is an "unauthenticated" bind which always succeeds
+		return nil, errorLdapInvalidCredentials
+	}
+	conn, err := conf.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var entry *ldap.Entry
+	if conf.mode == ldapModeDirect {
+		userDn := strings.ReplaceAll(conf.userDnTemplate, ldapPlaceholderUsername, ldapEscapeDnValue(username))
+		if err := conn.Bind(userDn, password); err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+				return nil, errorLdapInvalidCredentials
+			}
+			return nil, err
+		}
+		if conf.userFilter != "" {
+			// bind DN is not necessarily a DN (e.g. user principal name of Active Directory), user's entry is searched for
+			filter := strings.ReplaceAll(conf.userFilter, ldapPlaceholderUsername, ldap.EscapeFilter(username))
+			entry, err = conf.searchOne(conn, conf.baseDn, ldap.ScopeWholeSubtree, filter)
+		} else {
+			entry, err = conf.searchOne(conn, userDn, ldap.ScopeBaseObject, "(objectClass=*)")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("cannot find directory entry of user [%s]", username)
+		}
+	} else {
+		if err := conf.bindServiceAccount(conn); err != nil {
+			return nil, fmt.Errorf("cannot bind LDAP service account: %s", err)
+		}
+		filter := strings.ReplaceAll(conf.userFilter, ldapPlaceholderUsername, ldap.EscapeFilter(username))
+		if entry, err = conf.searchOne(conn, conf.baseDn, ldap.ScopeWholeSubtree, filter); err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, errorLdapInvalidCredentials
+		}
+		if err := conn.Bind(entry.DN, password); err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+				return nil, errorLdapInvalidCredentials
+			}
+			return nil, err
+		}
+	}
+
+	if conf.groupFilter != "" {
+		if conf.mode != ldapModeDirect {
+			// user might not have permission to read groups
+			if err := conf.bindServiceAccount(conn); err != nil {
+				return nil, fmt.Errorf("cannot bind LDAP service account: %s", err)
+			}
+		}
+		groupBaseDn := conf.groupBaseDn
+		if groupBaseDn == "" {
+			groupBaseDn = conf.baseDn
+		}
+		filter := strings.ReplaceAll(conf.groupFilter, ldapPlaceholderDn, ldap.EscapeFilter(entry.DN))
+		filter = strings.ReplaceAll(filter, ldapPlaceholderUsername, ldap.EscapeFilter(username))
+		req := ldap.NewSearchRequest(groupBaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, int(conf.timeout/time.Second), false,
+			filter, []string{"dn"}, nil)
+		result, err := conn.Search(req)
+		if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+				return nil, errorLdapNotInGroup
+			}
+			return nil, err
+		}
+		if result == nil || len(result.Entries) == 0 {
+			return nil, errorLdapNotInGroup
+		}
+	}
+
+	lu := &ldapUser{
+		Dn:          entry.DN,
+		Username:    username,
+		Email:       strings.TrimSpace(entry.GetEqualFoldAttributeValue(conf.emailAttribute)),
+		DisplayName: strings.TrimSpace(entry.GetEqualFoldAttributeValue(conf.nameAttribute)),
+	}
+	if !strings.Contains(lu.Email, "@") {
+		return nil, errorLdapNoEmail
+	}
+	return lu, nil
+}
+
+// ldapLoginChannel implements LoginChannel for LDAP / Active Directory: users login with username and password.
+//
+// available since v0.8.0
+type ldapLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *ldapLoginChannel) Init(conf *hocon.Config) error {
+	cfg := &ldapConfig{
+		url:                strings.TrimSpace(conf.GetString("gvabe.channels.ldap.url")),
+		startTls:           conf.GetBoolean("gvabe.channels.ldap.start_tls", false),
+		insecureSkipVerify: conf.GetBoolean("gvabe.channels.ldap.insecure_skip_verify", false),
+		timeout:            conf.GetTimeDuration("gvabe.channels.ldap.timeout", ldapDefaultTimeout),
+		mode:               strings.ToLower(strings.TrimSpace(conf.GetString("gvabe.channels.ldap.mode"))),
+		bindDn:             strings.TrimSpace(conf.GetString("gvabe.channels.ldap.bind_dn")),
+		bindPassword:       conf.GetString("gvabe.channels.ldap.bind_password"),
+		baseDn:             strings.TrimSpace(conf.GetString("gvabe.channels.ldap.base_dn")),
+		userFilter:         strings.TrimSpace(conf.GetString("gvabe.channels.ldap.user_filter")),
+		userDnTemplate:     strings.TrimSpace(conf.GetString("gvabe.channels.ldap.user_dn_template")),
+		groupBaseDn:        strings.TrimSpace(conf.GetString("gvabe.channels.ldap.group_base_dn")),
+		groupFilter:        strings.TrimSpace(conf.GetString("gvabe.channels.ldap.group_filter")),
+		emailAttribute:     strings.TrimSpace(conf.GetString("gvabe.channels.ldap.email_attribute")),
+		nameAttribute:      strings.TrimSpace(conf.GetString("gvabe.channels.ldap.name_attribute")),
+	}
+	if cfg.url == "" {
+		return errors.New("no valid LDAP server url defined at [gvabe.channels.ldap.url]")
+	}
+	if cfg.mode == "" {
+		cfg.mode = ldapModeSearch
+	}
+	switch cfg.mode {
+	case ldapModeSearch:
+		if cfg.baseDn == "" {
+			return errors.New("no valid LDAP base DN defined at [gvabe.channels.ldap.base_dn]")
+		}
+		if cfg.userFilter == "" {
+			cfg.userFilter = ldapDefaultUserFilter
+		}
+	case ldapModeDirect:
+		if !strings.Contains(cfg.userDnTemplate, ldapPlaceholderUsername) {
+			return fmt.Errorf("no valid LDAP user DN template (containing %s) defined at [gvabe.channels.ldap.user_dn_template]", ldapPlaceholderUsername)
+		}
+	default:
+		return fmt.Errorf("invalid LDAP mode [%s] at [gvabe.channels.ldap.mode], supported modes: %s, %s", cfg.mode, ldapModeSearch, ldapModeDirect)
+	}
+	if cfg.emailAttribute == "" {
+		cfg.emailAttribute = ldapDefaultEmailAttribute
+	}
+	if cfg.nameAttribute == "" {
+		cfg.nameAttribute = ldapDefaultNameAttribute
+	}
+	if cfg.insecureSkipVerify {
+		log.Println("[WARN] LDAP server's certificate is not verified, do not use [gvabe.channels.ldap.insecure_skip_verify] in production")
+	}
+	ldapConf = cfg
+	if DEBUG {
+		log.Printf("[DEBUG] ldapLoginChannel.Init: %s/%s/%v/%s/%s", cfg.url, cfg.mode, cfg.startTls, cfg.bindDn, cfg.baseDn)
+	}
+	return nil
+}
+
+// Login implements LoginHandler.Login.
+func (ch *ldapLoginChannel) Login(ctx *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	username := _extractParam(params, "username", reddo.TypeString, "", nil).(string)
+	password := _extractParam(params, "password", reddo.TypeString, "", nil).(string)
+	if DEBUG {
+		log.Printf("[DEBUG] START ldapLoginChannel.Login")
+		t := time.Now().UnixNano()
+		defer func() {
+			d := time.Now().UnixNano() - t
+			log.Printf("[DEBUG] END ldapLoginChannel.Login: %d ms", d/1000000)
+		}()
+	}
+	if ldapConf == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("LDAP login channel is not configured")
+	}
+
+	// firstly verify user's credentials against the directory
+	lu, err := ldapConf.authenticate(username, password)
+	if err != nil {
+		if DEBUG {
+			log.Printf("[DEBUG] ERROR ldapLoginChannel.Login: %s / %s", username, err)
+		}
+		if err == errorLdapInvalidCredentials || err == errorLdapNotInGroup || err == errorLdapNoEmail {
+			return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+		}
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+
+	// secondly create/update user account
+	ident, _ := loginIdentityFromLdapUser(lu)
+	u, err := resolveLoginIdentity(ident, app.GetId(), _linkUserIdFromContext(ctx))
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+
+	// lastly create the login session
+	now := time.Now()
+	js, _ := json.Marshal(lu)
+	claims, err := genLoginOrMfaClaims("", &Session{
+		ClientId:    app.GetId(),
+		Channel:     loginChannelLdap,
+		UserId:      u.GetId(),
+		DisplayName: u.GetDisplayName(),
+		CreatedAt:   now,
+		ExpiredAt:   now.Add(loginSessionTtl * time.Second),
+		Data:        js, // JSON-serialization of ldapUser
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if claims.Type == sessionTypeMfa {
+		return _mfaPendingResult(claims.UserId, claims.Audience, jwt)
+	}
+	token, returnUrl, err := appLoginResult(app, returnUrl, jwt)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(token).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
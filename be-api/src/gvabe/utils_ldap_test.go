@@ -0,0 +1,297 @@
+package gvabe
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// _ldapTestEntry is an entry served by the in-process LDAP server.
+type _ldapTestEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// _ldapTestServer is a minimal in-process LDAP server supporting simple bind (by DN or userPrincipalName), search (and/or/not, equality and presence filters) and unbind.
+type _ldapTestServer struct {
+	listener net.Listener
+	entries  []*_ldapTestEntry
+}
+
+func _newLdapTestServer(t *testing.T, entries []*_ldapTestEntry) *_ldapTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start LDAP test server: %s", err)
+	}
+	server := &_ldapTestServer{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *_ldapTestServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *_ldapTestServer) close() {
+	s.listener.Close()
+}
+
+func _ldapTestResult(messageId int64, appTag ber.Tag, resultCode int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, appTag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, resultCode, "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(result)
+	return packet
+}
+
+func (s *_ldapTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageId, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := ber.DecodeString(op.Children[1].Data.Bytes())
+			password := ber.DecodeString(op.Children[2].Data.Bytes())
+			resultCode := int64(ldap.LDAPResultInvalidCredentials)
+			for _, entry := range s.entries {
+				upn := entry.attrs["userPrincipalName"]
+				if (strings.EqualFold(entry.dn, dn) || (len(upn) > 0 && strings.EqualFold(upn[0], dn))) && entry.password != "" && entry.password == password {
+					resultCode = ldap.LDAPResultSuccess
+				}
+			}
+			conn.Write(_ldapTestResult(messageId, ldap.ApplicationBindResponse, resultCode).Bytes())
+		case ldap.ApplicationSearchRequest:
+			baseDn := strings.ToLower(ber.DecodeString(op.Children[0].Data.Bytes()))
+			for _, entry := range s.entries {
+				if !strings.HasSuffix(strings.ToLower(entry.dn), baseDn) || !_ldapTestMatch(op.Children[6], entry) {
+					continue
+				}
+				packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+				packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "MessageID"))
+				result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+				result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "objectName"))
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+				for name, values := range entry.attrs {
+					attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+					vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+					for _, value := range values {
+						vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+					}
+					attr.AppendChild(vals)
+					attrs.AppendChild(attr)
+				}
+				result.AppendChild(attrs)
+				packet.AppendChild(result)
+				conn.Write(packet.Bytes())
+			}
+			conn.Write(_ldapTestResult(messageId, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			conn.Write(_ldapTestResult(messageId, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError).Bytes())
+		}
+	}
+}
+
+func _ldapTestMatch(filter *ber.Packet, entry *_ldapTestEntry) bool {
+	values := func(name string) []string {
+		for k, v := range entry.attrs {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+		return nil
+	}
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !_ldapTestMatch(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if _ldapTestMatch(child, entry) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !_ldapTestMatch(filter.Children[0], entry)
+	case ldap.FilterEqualityMatch:
+		name := ber.DecodeString(filter.Children[0].Data.Bytes())
+		expected := ber.DecodeString(filter.Children[1].Data.Bytes())
+		for _, v := range values(name) {
+			if strings.EqualFold(v, expected) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		name := ber.DecodeString(filter.Data.Bytes())
+		return strings.EqualFold(name, "objectClass") || len(values(name)) > 0
+	}
+	return false
+}
+
+func _newLdapTestDirectory(t *testing.T) *_ldapTestServer {
+	return _newLdapTestServer(t, []*_ldapTestEntry{
+		{dn: "cn=admin,dc=example,dc=com", password: "adminpwd", attrs: map[string][]string{"objectClass": {"person"}}},
+		{dn: "uid=alice,ou=people,dc=example,dc=com", password: "alicepwd", attrs: map[string][]string{
+			"objectClass": {"inetOrgPerson"}, "uid": {"alice"}, "mail": {"alice@example.com"}, "displayName": {"Alice Doe"}}},
+		{dn: "uid=bob,ou=people,dc=example,dc=com", password: "bobpwd", attrs: map[string][]string{
+			"objectClass": {"inetOrgPerson"}, "uid": {"bob"}, "mail": {"bob@example.com"}, "userPrincipalName": {"bob@corp.example.com"}}},
+		{dn: "uid=carol,ou=people,dc=example,dc=com", password: "carolpwd", attrs: map[string][]string{
+			"objectClass": {"inetOrgPerson"}, "uid": {"carol"}}},
+		{dn: "cn=exter-users,ou=groups,dc=example,dc=com", attrs: map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"exter-users"}, "member": {"uid=alice,ou=people,dc=example,dc=com"}}},
+	})
+}
+
+func TestLdapConfig_authenticate_searchMode(t *testing.T) {
+	testName := "TestLdapConfig_authenticate_searchMode"
+	server := _newLdapTestDirectory(t)
+	defer server.close()
+	conf := &ldapConfig{
+		url:            server.url(),
+		timeout:        5 * time.Second,
+		mode:           ldapModeSearch,
+		bindDn:         "cn=admin,dc=example,dc=com",
+		bindPassword:   "adminpwd",
+		baseDn:         "ou=people,dc=example,dc=com",
+		userFilter:     "(&(objectClass=inetOrgPerson)(uid={username}))",
+		emailAttribute: ldapDefaultEmailAttribute,
+		nameAttribute:  ldapDefaultNameAttribute,
+	}
+
+	lu, err := conf.authenticate("alice", "alicepwd")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if lu.Dn != "uid=alice,ou=people,dc=example,dc=com" || lu.Email != "alice@example.com" || lu.DisplayName != "Alice Doe" {
+		t.Fatalf("%s failed: %#v", testName, lu)
+	}
+	if lu, err = conf.authenticate("bob", "bobpwd"); err != nil || lu.Email != "bob@example.com" || lu.DisplayName != "" {
+		t.Fatalf("%s failed: %#v / %s", testName, lu, err)
+	}
+
+	testCases := []struct {
+		username, password string
+		expected           error
+	}{
+		{"alice", "wrongpwd", errorLdapInvalidCredentials},
+		{"alice", "", errorLdapInvalidCredentials},
+		{"nobody", "alicepwd", errorLdapInvalidCredentials},
+		{"*", "alicepwd", errorLdapInvalidCredentials},
+		{"carol", "carolpwd", errorLdapNoEmail},
+	}
+	for i, testCase := range testCases {
+		if _, err := conf.authenticate(testCase.username, testCase.password); err != testCase.expected {
+			t.Fatalf("%s failed at case #%d: expected error %#v but received %#v", testName, i, testCase.expected, err)
+		}
+	}
+
+	conf.bindPassword = "wrongpwd"
+	if _, err := conf.authenticate("alice", "alicepwd"); err == nil || err == errorLdapInvalidCredentials {
+		t.Fatalf("%s failed: expected service account error but received %#v", testName, err)
+	}
+}
+
+func TestLdapConfig_authenticate_groupFilter(t *testing.T) {
+	testName := "TestLdapConfig_authenticate_groupFilter"
+	server := _newLdapTestDirectory(t)
+	defer server.close()
+	conf := &ldapConfig{
+		url:            server.url(),
+		timeout:        5 * time.Second,
+		mode:           ldapModeSearch,
+		bindDn:         "cn=admin,dc=example,dc=com",
+		bindPassword:   "adminpwd",
+		baseDn:         "ou=people,dc=example,dc=com",
+		userFilter:     ldapDefaultUserFilter,
+		groupBaseDn:    "ou=groups,dc=example,dc=com",
+		groupFilter:    "(&(cn=exter-users)(member={dn}))",
+		emailAttribute: ldapDefaultEmailAttribute,
+		nameAttribute:  ldapDefaultNameAttribute,
+	}
+	if _, err := conf.authenticate("alice", "alicepwd"); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err := conf.authenticate("bob", "bobpwd"); err != errorLdapNotInGroup {
+		t.Fatalf("%s failed: expected error %#v but received %#v", testName, errorLdapNotInGroup, err)
+	}
+}
+
+func TestLdapConfig_authenticate_directMode(t *testing.T) {
+	testName := "TestLdapConfig_authenticate_directMode"
+	server := _newLdapTestDirectory(t)
+	defer server.close()
+	conf := &ldapConfig{
+		url:            server.url(),
+		timeout:        5 * time.Second,
+		mode:           ldapModeDirect,
+		userDnTemplate: "uid={username},ou=people,dc=example,dc=com",
+		emailAttribute: ldapDefaultEmailAttribute,
+		nameAttribute:  ldapDefaultNameAttribute,
+	}
+	lu, err := conf.authenticate("alice", "alicepwd")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if lu.Email != "alice@example.com" || lu.DisplayName != "Alice Doe" {
+		t.Fatalf("%s failed: %#v", testName, lu)
+	}
+	if _, err := conf.authenticate("alice", "bobpwd"); err != errorLdapInvalidCredentials {
+		t.Fatalf("%s failed: expected error %#v but received %#v", testName, errorLdapInvalidCredentials, err)
+	}
+	if _, err := conf.authenticate("bob,ou=people", "bobpwd"); err != errorLdapInvalidCredentials {
+		t.Fatalf("%s failed: expected error %#v but received %#v", testName, errorLdapInvalidCredentials, err)
+	}
+
+	// bind DN is not an entry's DN (e.g. Active Directory's user principal name): user's entry is searched for
+	conf.userDnTemplate = "{username}@corp.example.com"
+	conf.baseDn = "dc=example,dc=com"
+	conf.userFilter = "(userPrincipalName={username}@corp.example.com)"
+	if lu, err = conf.authenticate("bob", "bobpwd"); err != nil || lu.Dn != "uid=bob,ou=people,dc=example,dc=com" {
+		t.Fatalf("%s failed: %#v / %s", testName, lu, err)
+	}
+}
+
+func TestLdapEscapeDnValue(t *testing.T) {
+	testName := "TestLdapEscapeDnValue"
+	testCases := map[string]string{
+		"alice":           "alice",
+		"doe, john":       "doe\\, john",
+		" #admin":         "\\ #admin",
+		"#admin ":         "\\#admin\\ ",
+		"a+b=c;d<e>\"f\\": "a\\+b\\=c\\;d\\<e\\>\\\"f\\\\",
+	}
+	for input, expected := range testCases {
+		if output := ldapEscapeDnValue(input); output != expected {
+			t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, output)
+		}
+	}
+}
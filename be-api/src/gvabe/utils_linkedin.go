@@ -2,14 +2,17 @@ package gvabe
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
-	"time"
+	"strings"
 
 	"github.com/btnguyen2k/consu/gjrc"
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
 	"golang.org/x/oauth2"
 	linkedinoauth "golang.org/x/oauth2/linkedin"
+
+	"main/src/itineris"
 )
 
 var (
@@ -21,52 +24,104 @@ var (
 	}
 )
 
-// routine to fetch LinkedIn profile in background
-func goFetchLinkedInProfile(sessId string) {
-	if bo, err := sessionDao.Get(sessId); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile(%s) - error loading session data: %e", sessId, err))
-	} else if bo == nil {
-		log.Println(fmt.Sprintf("[WARN] goFetchLinkedInProfile(%s) - session does not exist", sessId))
-	} else if bo.IsExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchLinkedInProfile(%s) - session expired", sessId))
-	} else if claims, err := parseLoginToken(bo.GetSessionData()); err != nil {
-		log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile(%s) - cannot parse JWT token: %e", sessId, err))
-	} else if claims.Type != sessionTypePreLogin || claims.isExpired() {
-		log.Println(fmt.Sprintf("[WARN] goFetchLinkedInProfile(%s) - invalid claims type of JWT expired", sessId))
-	} else {
-		sess := &Session{}
-		if err := json.Unmarshal(claims.Data, &sess); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile(%s) - error decoding session: %e", sessId, err))
-			return
-		}
-		if sess.Channel != loginChannelLinkedin {
-			log.Println(fmt.Sprintf("[WARN] goFetchLinkedInProfile(%s) - invalid login channel: %s", sessId, sess.Channel))
-			return
-		}
-		ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-		oauth2Token := &oauth2.Token{}
-		if err := json.Unmarshal(sess.Data, &oauth2Token); err != nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile - error unmarshalling oauth2.Token: %e", err))
-		} else if httpClient := linkedinOAuthConf.Client(ctx, oauth2Token); httpClient == nil {
-			log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile - error creating new LinkedIn API httpClient: nill"))
-		} else {
-			if u, err := createUserAccountFromLinkedInProfile(gjrc.NewGjrc(httpClient, 0)); err != nil {
-				log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile - error creating user account from LinkedIn profile: %e", err))
-			} else {
-				js, _ := json.Marshal(oauth2Token)
-				sess.UserId = u.GetId()
-				sess.DisplayName = u.GetDisplayName()
-				sess.ExpiredAt = oauth2Token.Expiry
-				sess.Data = js
-				claims, err := genLoginClaims(sessId, sess)
-				if err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile(%s) - error generating login token: %e", sessId, err))
-				}
-				_, _, err = saveSession(claims)
-				if err != nil {
-					log.Println(fmt.Sprintf("[ERROR] goFetchLinkedInProfile(%s) - error saving login token: %e", sessId, err))
-				}
-			}
-		}
+// linkedinUser captures user profile returned by LinkedIn API endpoints "/v2/emailAddress" and "/v2/me".
+//
+// available since v0.8.0
+type linkedinUser struct {
+	Id        string // (since v0.8.0) LinkedIn's member id
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// linkedinFetchUserProfile fetches the authenticated user's email address and name from LinkedIn API.
+//
+// available since v0.8.0
+func linkedinFetchUserProfile(gjrcClient *gjrc.Gjrc) (*linkedinUser, error) {
+	respEmail := gjrcClient.Get("https://api.linkedin.com/v2/emailAddress?q=members&projection=(elements*(handle~))")
+	if respEmail.Error() != nil {
+		return nil, respEmail.Error()
+	}
+	email, err := respEmail.GetValueAsType("elements[0].handle~.emailAddress", reddo.TypeString)
+	if err != nil {
+		return nil, err
+	} else if email == nil || email == "" {
+		return nil, errors.New("linkedin profile does not contain email address")
+	}
+	lu := &linkedinUser{Email: email.(string)}
+	// public lite profile (name & id) is optional
+	respMe := gjrcClient.Get("https://api.linkedin.com/v2/me")
+	if id, err := respMe.GetValueAsType("id", reddo.TypeString); err == nil && id != nil {
+		lu.Id = id.(string)
+	}
+	if firstName, err := respMe.GetValueAsType("localizedFirstName", reddo.TypeString); err == nil && firstName != nil {
+		lu.FirstName = firstName.(string)
+	}
+	if lastName, err := respMe.GetValueAsType("localizedLastName", reddo.TypeString); err == nil && lastName != nil {
+		lu.LastName = lastName.(string)
+	}
+	return lu, nil
+}
+
+// linkedinLoginChannel implements LoginChannel for Sign In with LinkedIn.
+//
+// available since v0.8.0
+type linkedinLoginChannel struct{}
+
+// Name implements LoginChannel.Name.
+func (ch *linkedinLoginChannel) Name() string {
+	return loginChannelLinkedin
+}
+
+// Init implements LoginChannel.Init.
+func (ch *linkedinLoginChannel) Init(conf *hocon.Config) error {
+	clientId := strings.TrimSpace(conf.GetString("gvabe.channels.linkedin.client_id"))
+	if clientId == "" {
+		log.Println("[ERROR] No valid LinkedIn OAuth app client-id defined at [gvabe.channels.linkedin.client_id]")
+	}
+	clientSecret := strings.TrimSpace(conf.GetString("gvabe.channels.linkedin.client_secret"))
+	if clientSecret == "" {
+		log.Println("[ERROR] No valid LinkedIn OAuth app client-secret defined at [gvabe.channels.linkedin.client_secret]")
+	}
+	redirectUri := strings.TrimSpace(conf.GetString("gvabe.channels.linkedin.redirect_uri"))
+	if redirectUri == "" {
+		log.Println("[ERROR] No valid LinkedIn OAuth app redirect-uri defined at [gvabe.channels.linkedin.redirect_uri]")
+		redirectUri = exterHomeUrl
+	}
+	linkedinOAuthConf.ClientID = clientId
+	linkedinOAuthConf.ClientSecret = clientSecret
+	linkedinOAuthConf.RedirectURL = redirectUri
+	if DEBUG && clientId != "" && clientSecret != "" {
+		log.Printf("[DEBUG] linkedinLoginChannel.Init: %s/%s/%s", clientId, "***"+clientSecret[len(clientSecret)-4:], redirectUri)
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *linkedinLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"linkedin_client_id": linkedinOAuthConf.ClientID}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *linkedinLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return linkedinOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements LoginChannel.Exchange.
+func (ch *linkedinLoginChannel) Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error) {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
+	return linkedinOAuthConf.Exchange(ctx, authCode.(string), oauth2.AccessTypeOnline)
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+func (ch *linkedinLoginChannel) FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error) {
+	return linkedinFetchUserProfile(gjrc.NewGjrc(linkedinOAuthConf.Client(ctx, token), 0))
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *linkedinLoginChannel) MapIdentity(profile interface{}) (*LoginIdentity, error) {
+	if lu, ok := profile.(*linkedinUser); ok && lu != nil {
+		return loginIdentityFromLinkedInProfile(lu)
 	}
+	return nil, errorProfileType(loginChannelLinkedin, profile)
 }
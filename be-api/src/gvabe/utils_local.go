@@ -0,0 +1,346 @@
+package gvabe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/user"
+	"main/src/itineris"
+)
+
+const (
+	// session types of link token records
+	sessionTypeLocalRegister = "local_register"
+	sessionTypeLocalReset    = "local_reset"
+
+	localDefaultLinkTtl            = 1 * time.Hour
+	localDefaultLoginFailureMax    = 10
+	localDefaultLoginFailureWindow = 15 * time.Minute
+)
+
+var (
+	errorLocalInvalidCredentials   = errors.New("invalid email or password")
+	errorLocalRegistrationDisabled = errors.New("registration of local accounts is disabled")
+	errorLocalAccountExists        = errors.New("account already has a password, please login or reset the password")
+	errorLocalNoPassword           = errors.New("account has no password, please use password reset to set one")
+
+	// settings of local (first-party) account login channel, nil if the channel is not enabled
+	localConf *localLoginConfig
+)
+
+// localLoginConfig holds settings of the local (username/password) login channel.
+//
+// available since v0.8.0
+type localLoginConfig struct {
+	allowRegistration bool              // users can register local accounts by themselves
+	verifyUrl         string            // url of the "localRegisterVerify" API, the link token is appended as query parameter "token"
+	resetUrl          string            // url of the page to set new password, the link token is appended as query parameter "token"
+	linkTtl           time.Duration     // lifetime of registration/password reset links
+	loginLimiter      *emailRateLimiter // limits failed login attempts per account
+	mailLimiter       *emailRateLimiter // limits registration/password reset emails per address
+}
+
+// localRegisterData is stored as session data of registration link token records.
+type localRegisterData struct {
+	DisplayName string             `json:"name"`
+	Password    *user.PasswordHash `json:"pwd"` // password is hashed right away, it is never stored in plain text
+}
+
+// localResetData is stored as session data of password reset link token records.
+type localResetData struct {
+	// when the password was last set at the time the link was issued; the link is void once the password has changed
+	PasswordUpdatedAt int64 `json:"pwd_uat"`
+}
+
+func passwordUpdatedAt(u *user.User) int64 {
+	if pwd := u.GetPassword(); pwd != nil {
+		return pwd.UpdatedAt.Unix()
+	}
+	return 0
+}
+
+// setUserPassword validates, hashes and stores user's new password.
+//
+// available since v0.8.0
+func setUserPassword(u *user.User, password string) error {
+	if err := pwdHasher.validate(password); err != nil {
+		return err
+	}
+	pwd, err := pwdHasher.hash(password)
+	if err != nil {
+		return err
+	}
+	u.SetPassword(pwd)
+	_, err = userDao.Update(u)
+	return err
+}
+
+// authenticate verifies a local account's credentials; the password is re-hashed if it was hashed with outdated settings.
+//
+// available since v0.8.0
+func (conf *localLoginConfig) authenticate(email, password string, now time.Time) (*user.User, error) {
+	email, err := normalizeEmailAddress(email)
+	if err != nil || password == "" {
+		return nil, errorLocalInvalidCredentials
+	}
+	if conf.loginLimiter.exceeded(email, now) {
+		return nil, errorEmailRateLimited
+	}
+	u, err := userDao.Get(email)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || !u.HasPassword() {
+		pwdHasher.dummyVerify(password)
+		conf.loginLimiter.allow(email, now)
+		return nil, errorLocalInvalidCredentials
+	}
+	match, needRehash, err := pwdHasher.verify(u.GetPassword(), password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		conf.loginLimiter.allow(email, now)
+		return nil, errorLocalInvalidCredentials
+	}
+	if needRehash {
+		if pwd, err := pwdHasher.hash(password); err == nil {
+			u.SetPassword(pwd)
+			if _, err := userDao.Update(u); err != nil {
+				log.Printf("[WARN] Cannot update password hash of user [%s]: %s", u.GetId(), err)
+			}
+		}
+	}
+	return u, nil
+}
+
+// register starts registration of a local account: a verification link is emailed to the address, the account is
+// created when the link is clicked (see completeRegistration).
+//
+// available since v0.8.0
+func (conf *localLoginConfig) register(email, password, displayName string, now time.Time) error {
+	if !conf.allowRegistration {
+		return errorLocalRegistrationDisabled
+	}
+	email, err := normalizeEmailAddress(email)
+	if err != nil {
+		return err
+	}
+	if err := pwdHasher.validate(password); err != nil {
+		return err
+	}
+	if !conf.mailLimiter.allow(email, now) {
+		return errorEmailRateLimited
+	}
+	u, err := userDao.Get(email)
+	if err != nil {
+		return err
+	}
+	appName := goapi.AppConfig.GetString("app.name")
+	if u != nil && u.HasPassword() {
+		// do not disclose that the account exists to the requester, tell the owner instead
+		return mailer.SendMail(email, "Your "+appName+" account", fmt.Sprintf(
+			"Someone (hopefully you) tried to register a %s account with this email address, but the account already exists.\n\nIf you forgot your password, please use the password reset function.\n", appName))
+	}
+	pwd, err := pwdHasher.hash(password)
+	if err != nil {
+		return err
+	}
+	data := localRegisterData{DisplayName: displayName, Password: pwd}
+	return issueLinkToken(sessionTypeLocalRegister, loginChannelLocal, systemAppId, email, data, conf.verifyUrl, now.Add(conf.linkTtl),
+		"Verify your email address", func(link string) string {
+			return fmt.Sprintf("Click the following link to verify your email address and complete the registration of your %s account:\n\n%s\n\nThe link expires in %d minutes. If you did not register, please ignore this email.\n",
+				appName, link, int(conf.linkTtl/time.Minute))
+		})
+}
+
+// completeRegistration verifies a registration link token and creates the local account. An existing account without
+// password (e.g. created by social login) gets the registered password, as ownership of the email address is proven.
+//
+// available since v0.8.0
+func completeRegistration(token string) (*user.User, error) {
+	data := localRegisterData{}
+	email, err := consumeLinkToken(sessionTypeLocalRegister, token, &data)
+	if err != nil {
+		return nil, err
+	}
+	if data.Password == nil {
+		return nil, errorEmailInvalidLink
+	}
+	u, err := userDao.Get(email)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		u = user.NewUser(goapi.AppVersionNumber, email)
+		if data.DisplayName != "" {
+			u.SetDisplayName(data.DisplayName)
+		} else {
+			u.SetDisplayName(extractNameFromEmailAddress(email))
+		}
+		u.SetPassword(data.Password)
+		if ok, err := userDao.Create(u); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("cannot create user [%s]", email)
+		}
+		return u, nil
+	}
+	if u.HasPassword() {
+		return nil, errorLocalAccountExists
+	}
+	u.SetPassword(data.Password)
+	_, err = userDao.Update(u)
+	return u, err
+}
+
+// requestPasswordReset emails a password reset link to the account's address. Nothing is sent if the account does not
+// exist, but no error is returned either so that existence of accounts is not disclosed.
+//
+// available since v0.8.0
+func (conf *localLoginConfig) requestPasswordReset(email string, now time.Time) error {
+	email, err := normalizeEmailAddress(email)
+	if err != nil {
+		return err
+	}
+	if !conf.mailLimiter.allow(email, now) {
+		return errorEmailRateLimited
+	}
+	u, err := userDao.Get(email)
+	if err != nil || u == nil {
+		return err
+	}
+	appName := goapi.AppConfig.GetString("app.name")
+	return issueLinkToken(sessionTypeLocalReset, loginChannelLocal, systemAppId, email, localResetData{PasswordUpdatedAt: passwordUpdatedAt(u)},
+		conf.resetUrl, now.Add(conf.linkTtl), "Reset your password", func(link string) string {
+			return fmt.Sprintf("Click the following link to set a new password for your %s account:\n\n%s\n\nThe link expires in %d minutes and can be used only once. If you did not request it, please ignore this email.\n",
+				appName, link, int(conf.linkTtl/time.Minute))
+		})
+}
+
+// resetPassword verifies a password reset link token and sets the account's new password.
+//
+// available since v0.8.0
+func resetPassword(token, newPassword string) (*user.User, error) {
+	if err := pwdHasher.validate(newPassword); err != nil {
+		// validate first, so that the link is not consumed by an invalid request
+		return nil, err
+	}
+	data := localResetData{}
+	email, err := consumeLinkToken(sessionTypeLocalReset, token, &data)
+	if err != nil {
+		return nil, err
+	}
+	u, err := userDao.Get(email)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || passwordUpdatedAt(u) != data.PasswordUpdatedAt {
+		return nil, errorEmailInvalidLink
+	}
+	return u, setUserPassword(u, newPassword)
+}
+
+// changePassword changes password of a logged-in user, who must provide the current password.
+//
+// available since v0.8.0
+func changePassword(u *user.User, currentPassword, newPassword string) error {
+	if !u.HasPassword() {
+		return errorLocalNoPassword
+	}
+	match, _, err := pwdHasher.verify(u.GetPassword(), currentPassword)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return errorLocalInvalidCredentials
+	}
+	return setUserPassword(u, newPassword)
+}
+
+// localLoginChannel implements LoginChannel for local accounts: users login with email address and password.
+//
+// available since v0.8.0
+type localLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *localLoginChannel) Init(conf *hocon.Config) error {
+	cfg := &localLoginConfig{
+		allowRegistration: conf.GetBoolean("gvabe.channels.local.allow_registration", true),
+		verifyUrl:         strings.TrimSpace(conf.GetString("gvabe.channels.local.verify_url")),
+		resetUrl:          strings.TrimSpace(conf.GetString("gvabe.channels.local.reset_url")),
+		linkTtl:           conf.GetTimeDuration("gvabe.channels.local.link_ttl", localDefaultLinkTtl),
+		loginLimiter: newEmailRateLimiter(
+			int(conf.GetInt32("gvabe.channels.local.login_failure_limit.max", localDefaultLoginFailureMax)),
+			conf.GetTimeDuration("gvabe.channels.local.login_failure_limit.window", localDefaultLoginFailureWindow)),
+		mailLimiter: newEmailRateLimiter(
+			int(conf.GetInt32("gvabe.channels.local.rate_limit.max", emailRateLimitDefaultMax)),
+			conf.GetTimeDuration("gvabe.channels.local.rate_limit.window", emailRateLimitDefaultWindow)),
+	}
+	if mailer == nil || (cfg.allowRegistration && cfg.verifyUrl == "") || cfg.resetUrl == "" {
+		// users can still login, but can not register or reset password by themselves
+		log.Println("[WARN] Registration and password reset of local accounts require [gvabe.mail], [gvabe.channels.local.verify_url] and [gvabe.channels.local.reset_url]")
+	}
+	localConf = cfg
+	if DEBUG {
+		log.Printf("[DEBUG] localLoginChannel.Init: %v/%s/%s", cfg.allowRegistration, cfg.verifyUrl, cfg.resetUrl)
+	}
+	return nil
+}
+
+// Login implements LoginHandler.Login.
+func (ch *localLoginChannel) Login(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	email := _extractParam(params, "email", reddo.TypeString, "", nil).(string)
+	password := _extractParam(params, "password", reddo.TypeString, "", nil).(string)
+	if localConf == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Local login channel is not configured")
+	}
+	now := time.Now()
+	u, err := localConf.authenticate(email, password, now)
+	if err != nil {
+		if DEBUG {
+			log.Printf("[DEBUG] ERROR localLoginChannel.Login: %s / %s", email, err)
+		}
+		switch err {
+		case errorLocalInvalidCredentials:
+			return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+		case errorEmailRateLimited:
+			return itineris.NewApiResult(itineris.StatusTooManyRequests).SetMessage("too many failed login attempts, please try again later")
+		}
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+
+	claims, err := genLoginOrMfaClaims("", &Session{
+		ClientId:    app.GetId(),
+		Channel:     loginChannelLocal,
+		UserId:      u.GetId(),
+		DisplayName: u.GetDisplayName(),
+		CreatedAt:   now,
+		ExpiredAt:   now.Add(loginSessionTtl * time.Second),
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if claims.Type == sessionTypeMfa {
+		return _mfaPendingResult(claims.UserId, claims.Audience, jwt)
+	}
+	token, returnUrl, err := appLoginResult(app, returnUrl, jwt)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(token).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
@@ -0,0 +1,466 @@
+package gvabe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/user"
+	"main/src/utils"
+)
+
+const (
+	mfaSessionTtl = 5 * time.Minute // time user has to enter the OTP
+
+	totpDigits     = 6
+	totpPeriod     = 30 // seconds
+	totpSkew       = 1  // number of time-steps before/after the current one that are also accepted
+	totpSecretSize = 20 // bytes, as recommended by RFC 4226
+
+	mfaRecoveryCodeCount = 10
+
+	mfaDefaultFailureMax    = 5
+	mfaDefaultFailureWindow = 5 * time.Minute
+
+	// authentication methods references (RFC 8176) and authentication context class references put into login tokens
+	amrPassword  = "pwd"
+	amrOtp       = "otp"
+	amrMfa       = "mfa"
+	amrFederated = "fed"   // authenticated by an external identity provider
+	amrEmail     = "email" // authenticated by a link sent to user's mailbox
+	amrHwk       = "hwk"   // proof-of-possession of a hardware-secured key (WebAuthn credential)
+
+	acrSingleFactor = "1fa"
+	acrMultiFactor  = "mfa"
+
+	mfaStatusVerify = "verify" // user must enter an OTP
+	mfaStatusEnroll = "enroll" // app requires MFA but user has not enrolled, user must enroll first
+
+	mfaMethodTotp     = "totp"     // one-time password generated by an authenticator app
+	mfaMethodWebauthn = "webauthn" // WebAuthn credential (passkey or security key)
+)
+
+var (
+	errorMfaInvalidOtp     = errors.New("invalid one-time password or recovery code")
+	errorMfaNotEnrolled    = errors.New("multi-factor authentication is not enabled")
+	errorMfaAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	errorMfaTooManyFailure = errors.New("too many failed attempts, please try again later")
+
+	// key to encrypt users' TOTP secrets
+	mfaKey []byte
+
+	// issuer name displayed by authenticator apps
+	mfaIssuer string
+
+	// limits failed OTP attempts per user
+	mfaLimiter = newEmailRateLimiter(mfaDefaultFailureMax, mfaDefaultFailureWindow)
+)
+
+/*----------------------------------------------------------------------*/
+
+// mfaEncrypt encrypts data with AES-256-GCM using the server's MFA key; result is base64(nonce || ciphertext).
+//
+// available since v0.8.0
+func mfaEncrypt(data []byte) (string, error) {
+	block, err := aes.NewCipher(mfaKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, data, nil)), nil
+}
+
+// mfaDecrypt decrypts data encrypted by mfaEncrypt.
+//
+// available since v0.8.0
+func mfaDecrypt(encrypted string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(mfaKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted data")
+	}
+	return gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+}
+
+/*----------------------------------------------------------------------*/
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode calculates the TOTP code (RFC 6238, HMAC-SHA1) of a time-step.
+func totpCode(secret []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, code%mod)
+}
+
+// totpVerify verifies a TOTP code, accepting time-steps within the allowed clock skew. Time-steps not after lastCounter
+// are rejected to prevent replay. The accepted time-step is returned.
+func totpVerify(secret []byte, code string, now time.Time, lastCounter int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+		if counter > lastCounter && hmac.Equal([]byte(totpCode(secret, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// totpUri builds the "otpauth://" uri to be rendered as QR code for authenticator apps.
+func totpUri(issuer, account string, secret []byte) string {
+	query := url.Values{}
+	query.Set("secret", totpEncoding.EncodeToString(secret))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprintf("%d", totpDigits))
+	query.Set("period", fmt.Sprintf("%d", totpPeriod))
+	return (&url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + issuer + ":" + account, RawQuery: query.Encode()}).String()
+}
+
+// hashRecoveryCode returns the hash of a recovery code; only hashes are stored.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+// newRecoveryCodes generates a set of single-use recovery codes, returning the codes and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, hashes := make([]string, mfaRecoveryCodeCount), make([]string, mfaRecoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 6)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		s := strings.ToLower(totpEncoding.EncodeToString(buf)[:10])
+		codes[i] = s[:5] + "-" + s[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// mfaEnroll generates a new TOTP secret for the user, waiting for confirmation (see mfaConfirm).
+// The secret (base32-encoded) and the "otpauth://" uri are returned.
+//
+// available since v0.8.0
+func mfaEnroll(u *user.User, issuer string) (string, string, error) {
+	if u.IsMfaEnabled() {
+		return "", "", errorMfaAlreadyEnabled
+	}
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encrypted, err := mfaEncrypt(secret)
+	if err != nil {
+		return "", "", err
+	}
+	u.SetMfa(&user.MfaSettings{TotpSecret: encrypted})
+	if _, err := userDao.Update(u); err != nil {
+		return "", "", err
+	}
+	return totpEncoding.EncodeToString(secret), totpUri(issuer, u.GetId(), secret), nil
+}
+
+// mfaConfirm confirms a pending enrollment with an OTP and enables MFA; the recovery codes are returned.
+//
+// available since v0.8.0
+func mfaConfirm(u *user.User, otp string, now time.Time) ([]string, error) {
+	mfa := u.GetMfa()
+	if mfa == nil || mfa.TotpSecret == "" {
+		return nil, errorMfaNotEnrolled
+	}
+	if mfa.Enabled {
+		return nil, errorMfaAlreadyEnabled
+	}
+	if err := mfaVerify(u, otp, "", now); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa = u.GetMfa()
+	mfa.Enabled, mfa.EnabledAt, mfa.RecoveryCodes = true, now, hashes
+	u.SetMfa(mfa)
+	_, err = userDao.Update(u)
+	return codes, err
+}
+
+// mfaVerify verifies an OTP (or, if otp is empty, a recovery code) of the user. Accepted OTPs and recovery codes
+// can not be used again.
+//
+// available since v0.8.0
+func mfaVerify(u *user.User, otp, recoveryCode string, now time.Time) error {
+	mfa := u.GetMfa()
+	if mfa == nil || mfa.TotpSecret == "" {
+		return errorMfaNotEnrolled
+	}
+	if mfaLimiter.exceeded(u.GetId(), now) {
+		return errorMfaTooManyFailure
+	}
+	if otp != "" {
+		secret, err := mfaDecrypt(mfa.TotpSecret)
+		if err != nil {
+			return err
+		}
+		if counter, ok := totpVerify(secret, otp, now, mfa.LastCounter); ok {
+			mfa.LastCounter = counter
+			u.SetMfa(mfa)
+			_, err := userDao.Update(u)
+			return err
+		}
+	} else if recoveryCode != "" && mfa.Enabled {
+		hash := hashRecoveryCode(recoveryCode)
+		for i, h := range mfa.RecoveryCodes {
+			if hmac.Equal([]byte(h), []byte(hash)) {
+				mfa.RecoveryCodes = append(mfa.RecoveryCodes[:i], mfa.RecoveryCodes[i+1:]...)
+				u.SetMfa(mfa)
+				_, err := userDao.Update(u)
+				return err
+			}
+		}
+	}
+	mfaLimiter.allow(u.GetId(), now)
+	return errorMfaInvalidOtp
+}
+
+// mfaRegenerateRecoveryCodes replaces user's recovery codes with a new set.
+//
+// available since v0.8.0
+func mfaRegenerateRecoveryCodes(u *user.User) ([]string, error) {
+	mfa := u.GetMfa()
+	if !u.IsMfaEnabled() {
+		return nil, errorMfaNotEnrolled
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa.RecoveryCodes = hashes
+	u.SetMfa(mfa)
+	_, err = userDao.Update(u)
+	return codes, err
+}
+
+/*----------------------------------------------------------------------*/
+
+// channelAmr returns the authentication method of a login channel.
+func channelAmr(channel string) string {
+	switch channel {
+	case loginChannelLocal, loginChannelLdap:
+		return amrPassword
+	case loginChannelEmail:
+		return amrEmail
+	case loginChannelPasskey:
+		return amrHwk
+	}
+	return amrFederated
+}
+
+// mfaAmr returns the authentication methods of a login completed with a second factor: the second factor's method
+// (empty if RFC 8176 does not define one, e.g. recovery codes) and "mfa" are added, each method is listed once.
+//
+// available since v0.8.0
+func mfaAmr(amr []string, method string) []string {
+	result := make([]string, 0, len(amr)+2)
+	seen := make(map[string]bool)
+	for _, v := range append(append(append([]string{}, amr...), method), amrMfa) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// mfaMethods returns the second factors user can use: TOTP if enabled, WebAuthn if user has registered credentials.
+//
+// available since v0.8.0
+func mfaMethods(u *user.User) ([]string, error) {
+	methods := make([]string, 0)
+	if u.IsMfaEnabled() {
+		methods = append(methods, mfaMethodTotp)
+	}
+	if credentialDao != nil && webauthnConf != nil {
+		credList, err := credentialDao.GetUserCredentials(u)
+		if err != nil {
+			return nil, err
+		}
+		if len(credList) > 0 {
+			methods = append(methods, mfaMethodWebauthn)
+		}
+	}
+	return methods, nil
+}
+
+// mfaStatus returns the MFA step user must pass to login to the app, empty if MFA is not required.
+// Registered WebAuthn credentials do not make MFA required on their own, but satisfy apps requiring MFA.
+//
+// available since v0.8.0
+func mfaStatus(userId, appId string) (string, error) {
+	u, err := userDao.Get(userId)
+	if err != nil {
+		return "", err
+	}
+	if u == nil {
+		return "", fmt.Errorf("user [%s] not found", userId)
+	}
+	if u.IsMfaEnabled() {
+		return mfaStatusVerify, nil
+	}
+	app, err := appDao.Get(appId)
+	if err != nil {
+		return "", err
+	}
+	if app != nil && app.GetAttrsPublic().RequireMfa {
+		if methods, err := mfaMethods(u); err != nil {
+			return "", err
+		} else if len(methods) > 0 {
+			return mfaStatusVerify, nil
+		}
+		return mfaStatusEnroll, nil
+	}
+	return "", nil
+}
+
+// genMfaClaims generates a MFA-pending token as SessionClaims: the user has passed the first factor and
+// must pass MFA before the session can be upgraded to login session (see genLoginClaims).
+//   - the SessionClaims is created with type=mfa and populated with data from supplied session (encrypted)
+//
+// available since v0.8.0
+func genMfaClaims(id string, sess *Session) (*SessionClaims, error) {
+	u, err := userDao.Get(sess.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, fmt.Errorf("user [%s] not found", sess.UserId)
+	}
+	if len(sess.Amr) == 0 {
+		sess.Amr = []string{channelAmr(sess.Channel)}
+	}
+	sessData, err := json.Marshal(sess)
+	if err != nil {
+		return nil, err
+	}
+	sessData, err = zipAndEncrypt(sessData, []byte(u.GetAesKey()))
+	now := time.Now()
+	expiry := now.Add(mfaSessionTtl)
+	if sess.ExpiredAt.Before(expiry) {
+		expiry = sess.ExpiredAt
+	}
+	return &SessionClaims{
+		Type:            sessionTypeMfa,
+		UserId:          sess.UserId,
+		UserDisplayName: sess.DisplayName,
+		Data:            sessData,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  sess.ClientId,
+			ExpiresAt: expiry.Unix(),
+			Id:        id,
+			IssuedAt:  now.Unix(),
+			Subject:   sess.Channel,
+		},
+	}, err
+}
+
+// genLoginOrMfaClaims generates a login token (see genLoginClaims) if user can login right away, or a MFA-pending token
+// (see genMfaClaims) if user has enabled MFA or the app requires MFA.
+//
+// available since v0.8.0
+func genLoginOrMfaClaims(id string, sess *Session) (*SessionClaims, error) {
+	if id == "" {
+		id = utils.UniqueId()
+	}
+	status, err := mfaStatus(sess.UserId, sess.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	if status != "" {
+		return genMfaClaims(id, sess)
+	}
+	return genLoginClaims(id, sess)
+}
+
+// loadMfaSession decodes a MFA-pending token, verifying that it has not been used yet.
+//
+// available since v0.8.0
+func loadMfaSession(token string) (*SessionClaims, *Session, *user.User, error) {
+	claims, err := parseLoginToken(token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if claims.Type != sessionTypeMfa || claims.isExpired() {
+		return nil, nil, nil, errors.New("token is not a pending multi-factor authentication token")
+	}
+	bo, err := sessionDao.Get(claims.Id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if bo == nil || bo.IsExpired() || bo.GetSessionType() != sessionTypeMfa {
+		return nil, nil, nil, errors.New("session does not exist or has expired")
+	}
+	u, err := userDao.Get(claims.UserId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if u == nil {
+		return nil, nil, nil, fmt.Errorf("user [%s] not found", claims.UserId)
+	}
+	sessData, err := decryptAndUnzip(claims.Data, []byte(u.GetAesKey()))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sess := &Session{}
+	if err := json.Unmarshal(sessData, sess); err != nil {
+		return nil, nil, nil, err
+	}
+	return claims, sess, u, nil
+}
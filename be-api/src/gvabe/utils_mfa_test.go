@@ -0,0 +1,142 @@
+package gvabe
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTotpCode(t *testing.T) {
+	name := "TestTotpCode"
+	// test vectors from RFC 6238 (SHA1), truncated to 6 digits
+	secret := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for ts, expected := range vectors {
+		if code := totpCode(secret, ts/totpPeriod); code != expected {
+			t.Fatalf("%s failed: expected %#v at %d but received %#v", name, expected, ts, code)
+		}
+	}
+}
+
+func TestTotpVerify(t *testing.T) {
+	name := "TestTotpVerify"
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	if counter, ok := totpVerify(secret, "050471", now, 0); !ok || counter != current {
+		t.Fatalf("%s failed: current code must be accepted", name)
+	}
+	if _, ok := totpVerify(secret, totpCode(secret, current-1), now, 0); !ok {
+		t.Fatalf("%s failed: code of previous time-step must be accepted", name)
+	}
+	if _, ok := totpVerify(secret, totpCode(secret, current-2), now, 0); ok {
+		t.Fatalf("%s failed: code out of allowed skew must be rejected", name)
+	}
+	if _, ok := totpVerify(secret, "050471", now, current); ok {
+		t.Fatalf("%s failed: used code must be rejected", name)
+	}
+	if _, ok := totpVerify(secret, "12345", now, 0); ok {
+		t.Fatalf("%s failed: code with invalid length must be rejected", name)
+	}
+}
+
+func TestTotpUri(t *testing.T) {
+	name := "TestTotpUri"
+	uri, err := url.Parse(totpUri("Exter", "john@example.com", []byte("12345678901234567890")))
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Exter:john@example.com" {
+		t.Fatalf("%s failed: %s", name, uri)
+	}
+	if uri.Query().Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || uri.Query().Get("issuer") != "Exter" {
+		t.Fatalf("%s failed: %s", name, uri)
+	}
+}
+
+func TestMfaEncrypt(t *testing.T) {
+	name := "TestMfaEncrypt"
+	mfaKey = []byte("0123456789abcdef0123456789abcdef")
+	encrypted, err := mfaEncrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if strings.Contains(encrypted, "secret") {
+		t.Fatalf("%s failed: data is not encrypted", name)
+	}
+	if another, _ := mfaEncrypt([]byte("secret")); another == encrypted {
+		t.Fatalf("%s failed: nonce must be random", name)
+	}
+	if decrypted, err := mfaDecrypt(encrypted); err != nil || string(decrypted) != "secret" {
+		t.Fatalf("%s failed: %#v / %s", name, string(decrypted), err)
+	}
+	mfaKey = []byte("fedcba9876543210fedcba9876543210")
+	if _, err := mfaDecrypt(encrypted); err == nil {
+		t.Fatalf("%s failed: decryption with another key must fail", name)
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	name := "TestNewRecoveryCodes"
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if len(codes) != mfaRecoveryCodeCount || len(hashes) != mfaRecoveryCodeCount {
+		t.Fatalf("%s failed: expected %d codes", name, mfaRecoveryCodeCount)
+	}
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if len(code) != 11 || seen[code] {
+			t.Fatalf("%s failed: invalid or duplicated code %#v", name, code)
+		}
+		seen[code] = true
+		if hashes[i] == code || hashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))) != hashes[i] {
+			t.Fatalf("%s failed: invalid hash of code %#v", name, code)
+		}
+	}
+}
+
+func TestMfaAmr(t *testing.T) {
+	name := "TestMfaAmr"
+	testCases := []struct {
+		amr      []string
+		method   string
+		expected []string
+	}{
+		{[]string{amrPassword}, amrOtp, []string{amrPassword, amrOtp, amrMfa}},
+		{[]string{amrFederated}, amrHwk, []string{amrFederated, amrHwk, amrMfa}},
+		{[]string{amrPassword}, "", []string{amrPassword, amrMfa}}, // recovery code
+		{[]string{amrPassword, amrMfa}, amrMfa, []string{amrPassword, amrMfa}},
+		{nil, amrOtp, []string{amrOtp, amrMfa}},
+	}
+	for i, testCase := range testCases {
+		if v := mfaAmr(testCase.amr, testCase.method); !reflect.DeepEqual(v, testCase.expected) {
+			t.Fatalf("%s failed at case #%d: expected %#v but received %#v", name, i, testCase.expected, v)
+		}
+	}
+}
+
+func TestChannelAmr(t *testing.T) {
+	name := "TestChannelAmr"
+	expected := map[string]string{
+		loginChannelLocal:   amrPassword,
+		loginChannelLdap:    amrPassword,
+		loginChannelEmail:   amrEmail,
+		loginChannelGoogle:  amrFederated,
+		loginChannelPasskey: amrHwk,
+	}
+	for channel, amr := range expected {
+		if v := channelAmr(channel); v != amr {
+			t.Fatalf("%s failed: expected %#v for channel %s but received %#v", name, amr, channel, v)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package gvabe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	hocon "github.com/go-akka/configuration"
+	"golang.org/x/oauth2"
+
+	"main/src/gvabe/bo/app"
+	"main/src/itineris"
+)
+
+const (
+	microsoftAuthorityBaseUrl = "https://login.microsoftonline.com"
+
+	// "common": both work/school and personal Microsoft accounts
+	// "organizations": work/school accounts only
+	// "consumers": personal Microsoft accounts only
+	// otherwise: tenant-id (or domain name) of a single tenant
+	microsoftDefaultTenant = "common"
+
+	// placeholder in the issuer of multi-tenant discovery documents, to be replaced by the "tid" claim
+	microsoftIssuerTenantPlaceholder = "{tenantid}"
+)
+
+var (
+	microsoftTenant = microsoftDefaultTenant
+
+	// Microsoft Entra ID (Azure AD) is an OpenID Connect provider
+	microsoftOidcProvider *oidcProvider
+)
+
+// newMicrosoftOidcProvider creates an OpenID Connect provider for Microsoft identity platform (v2.0 endpoint).
+//
+// available since v0.8.0
+func newMicrosoftOidcProvider(tenant, clientId, clientSecret, redirectUri string) *oidcProvider {
+	if tenant = strings.TrimSpace(tenant); tenant == "" {
+		tenant = microsoftDefaultTenant
+	}
+	issuer := microsoftAuthorityBaseUrl + "/" + tenant + "/v2.0"
+	provider := newOidcProvider(loginChannelMicrosoft, issuer, clientId, clientSecret, redirectUri, nil)
+	// "preferred_username" is usually the user's UPN (email address) of work/school accounts,
+	// "email" is an optional claim and is not always present
+	provider.emailClaim = "preferred_username"
+	provider.altEmailClaims = []string{"email"}
+	// Microsoft does not issue "email_verified", optional claim "xms_edov" tells if the email domain owner is verified
+	provider.verifiedClaim = "xms_edov"
+	provider.issuerValidator = microsoftValidateIssuer
+	provider.appClaimsValidator = microsoftVerifyTenant
+	return provider
+}
+
+// microsoftValidateIssuer validates the "iss" claim of id_token issued by Microsoft identity platform.
+//
+// Discovery documents of multi-tenant authorities ("common", "organizations", "consumers") return issuer in format
+// "https://login.microsoftonline.com/{tenantid}/v2.0"; the placeholder is replaced by the "tid" claim before comparing.
+//
+// available since v0.8.0
+func microsoftValidateIssuer(metadataIssuer string, claims jwt.MapClaims) error {
+	tid, _ := claims["tid"].(string)
+	if tid == "" {
+		return errors.New("id_token does not contain tenant id")
+	}
+	iss, _ := claims["iss"].(string)
+	expected := strings.ReplaceAll(metadataIssuer, microsoftIssuerTenantPlaceholder, tid)
+	if iss == "" || iss != expected {
+		return fmt.Errorf("invalid id_token issuer: %s", iss)
+	}
+	return nil
+}
+
+// microsoftVerifyTenant checks if the user's tenant (the "tid" claim) is allowed to login to the application.
+//
+// available since v0.8.0
+func microsoftVerifyTenant(app *app.App, claims jwt.MapClaims) error {
+	allowedTenantIds := app.GetAttrsPublic().MicrosoftTenantIds
+	if len(allowedTenantIds) == 0 {
+		return nil
+	}
+	tid, _ := claims["tid"].(string)
+	for _, allowedTid := range allowedTenantIds {
+		if tid != "" && strings.EqualFold(tid, strings.TrimSpace(allowedTid)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("tenant [%s] is not allowed to login to app [%s]", tid, app.GetId())
+}
+
+// microsoftLoginChannel implements LoginChannel for Microsoft identity platform, which is an OpenID Connect provider.
+//
+// available since v0.8.0
+type microsoftLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *microsoftLoginChannel) Init(conf *hocon.Config) error {
+	tenant := strings.TrimSpace(conf.GetString("gvabe.channels.microsoft.tenant"))
+	if tenant == "" {
+		tenant = microsoftDefaultTenant
+	}
+	clientId := strings.TrimSpace(conf.GetString("gvabe.channels.microsoft.client_id"))
+	if clientId == "" {
+		log.Println("[ERROR] No valid Microsoft app client-id defined at [gvabe.channels.microsoft.client_id]")
+	}
+	clientSecret := strings.TrimSpace(conf.GetString("gvabe.channels.microsoft.client_secret"))
+	if clientSecret == "" {
+		log.Println("[ERROR] No valid Microsoft app client-secret defined at [gvabe.channels.microsoft.client_secret]")
+	}
+	redirectUri := strings.TrimSpace(conf.GetString("gvabe.channels.microsoft.redirect_uri"))
+	if redirectUri == "" {
+		log.Println("[ERROR] No valid Microsoft app redirect-uri defined at [gvabe.channels.microsoft.redirect_uri]")
+		redirectUri = exterHomeUrl
+	}
+	microsoftTenant = tenant
+	microsoftOidcProvider = newMicrosoftOidcProvider(tenant, clientId, clientSecret, redirectUri)
+	// single-tenant apps usually trust email addresses (UPNs) managed by the tenant's administrators
+	microsoftOidcProvider.trustEmail = conf.GetBoolean("gvabe.channels.microsoft.trust_email", false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if _, err := microsoftOidcProvider.discover(ctx); err != nil {
+		// discovery will be retried upon login
+		log.Println(fmt.Sprintf("[ERROR] Cannot load Microsoft OpenID Connect discovery document for tenant [%s]: %e", tenant, err))
+	}
+	cancel()
+	if DEBUG && clientId != "" && clientSecret != "" {
+		log.Printf("[DEBUG] microsoftLoginChannel.Init: %s/%s/%s/%s", tenant, clientId, "***"+clientSecret[len(clientSecret)-4:], redirectUri)
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *microsoftLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"microsoft_client_id": microsoftOidcProvider.oauthConf.ClientID, "microsoft_tenant": microsoftTenant}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *microsoftLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return microsoftOidcProvider.authUrl(state, opts...)
+}
+
+// Login implements LoginHandler.Login.
+func (ch *microsoftLoginChannel) Login(ctx *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	return loginOidc(ctx, microsoftOidcProvider, params, app, returnUrl)
+}
+
+// FetchLoginProfile implements LoginProfileFetcher.FetchLoginProfile.
+func (ch *microsoftLoginChannel) FetchLoginProfile(sessId string) error {
+	return fetchOidcProfile(microsoftOidcProvider, sessId)
+}
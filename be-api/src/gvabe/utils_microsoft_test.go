@@ -0,0 +1,102 @@
+package gvabe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/app"
+)
+
+func TestMicrosoftValidateIssuer(t *testing.T) {
+	testName := "TestMicrosoftValidateIssuer"
+	multiTenantIssuer := "https://login.microsoftonline.com/{tenantid}/v2.0"
+	testCases := []struct {
+		metadataIssuer string
+		claims         jwt.MapClaims
+		valid          bool
+	}{
+		{multiTenantIssuer, jwt.MapClaims{"iss": "https://login.microsoftonline.com/tenant1/v2.0", "tid": "tenant1"}, true},
+		{multiTenantIssuer, jwt.MapClaims{"iss": "https://login.microsoftonline.com/tenant1/v2.0", "tid": "tenant2"}, false},
+		{multiTenantIssuer, jwt.MapClaims{"iss": "https://login.microsoftonline.com/tenant1/v2.0"}, false},
+		{multiTenantIssuer, jwt.MapClaims{"tid": "tenant1"}, false},
+		{"https://login.microsoftonline.com/tenant1/v2.0", jwt.MapClaims{"iss": "https://login.microsoftonline.com/tenant1/v2.0", "tid": "tenant1"}, true},
+		{"https://login.microsoftonline.com/tenant1/v2.0", jwt.MapClaims{"iss": "https://login.microsoftonline.com/tenant2/v2.0", "tid": "tenant2"}, false},
+	}
+	for i, testCase := range testCases {
+		err := microsoftValidateIssuer(testCase.metadataIssuer, testCase.claims)
+		if testCase.valid && err != nil {
+			t.Fatalf("%s failed at case #%d: %s", testName, i, err)
+		}
+		if !testCase.valid && err == nil {
+			t.Fatalf("%s failed at case #%d: expected error", testName, i)
+		}
+	}
+}
+
+func TestMicrosoftVerifyTenant(t *testing.T) {
+	testName := "TestMicrosoftVerifyTenant"
+	myApp := app.NewApp(1, "myapp", "owner", "")
+	claims := jwt.MapClaims{"tid": "Tenant1"}
+	if err := microsoftVerifyTenant(myApp, claims); err != nil {
+		t.Fatalf("%s failed: all tenants should be allowed by default, but received error %s", testName, err)
+	}
+
+	attrs := myApp.GetAttrsPublic()
+	attrs.MicrosoftTenantIds = []string{"tenant1", "tenant2"}
+	myApp.SetAttrsPublic(attrs)
+	if err := microsoftVerifyTenant(myApp, claims); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err := microsoftVerifyTenant(myApp, jwt.MapClaims{"tid": "tenant3"}); err == nil {
+		t.Fatalf("%s failed: expected error for tenant not in allowed list", testName)
+	}
+	if err := microsoftVerifyTenant(myApp, jwt.MapClaims{}); err == nil {
+		t.Fatalf("%s failed: expected error for missing tenant id", testName)
+	}
+}
+
+func TestMicrosoftOidcProvider_verifyIdToken(t *testing.T) {
+	testName := "TestMicrosoftOidcProvider_verifyIdToken"
+	issuer := newStubOidcIssuer(t, "client1")
+	defer issuer.server.Close()
+	issuer.metadataIssuer = issuer.server.URL + "/" + microsoftIssuerTenantPlaceholder + "/v2.0"
+
+	provider := newMicrosoftOidcProvider("common", "client1", "secret1", "http://localhost/callback")
+	provider.issuer = issuer.server.URL
+	ctx := context.Background()
+	if _, err := provider.discover(ctx); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	now := time.Now().Unix()
+	claims := jwt.MapClaims{
+		"iss": issuer.server.URL + "/tenant1/v2.0", "aud": "client1", "sub": "user1", "tid": "tenant1",
+		"iat": now, "exp": now + 3600, "preferred_username": "user1@contoso.com", "email": "user1@example.com",
+	}
+	verifiedClaims, err := provider.verifyIdToken(ctx, issuer.signIdToken(t, claims), "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if email := provider.extractEmail(verifiedClaims); email != "user1@contoso.com" {
+		t.Fatalf("%s failed: expected email %#v but received %#v", testName, "user1@contoso.com", email)
+	}
+
+	// preferred_username is not always an email address
+	claims["preferred_username"] = "+84123456789"
+	verifiedClaims, err = provider.verifyIdToken(ctx, issuer.signIdToken(t, claims), "")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if email := provider.extractEmail(verifiedClaims); email != "user1@example.com" {
+		t.Fatalf("%s failed: expected email %#v but received %#v", testName, "user1@example.com", email)
+	}
+
+	// token issued by another tenant
+	claims["tid"] = "tenant2"
+	if _, err := provider.verifyIdToken(ctx, issuer.signIdToken(t, claims), ""); err == nil {
+		t.Fatalf("%s failed: expected error for issuer/tenant mismatch", testName)
+	}
+}
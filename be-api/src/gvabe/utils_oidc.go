@@ -0,0 +1,464 @@
+package gvabe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btnguyen2k/consu/reddo"
+	"github.com/dgrijalva/jwt-go"
+	hocon "github.com/go-akka/configuration"
+	"golang.org/x/oauth2"
+
+	"main/src/gvabe/bo/app"
+	"main/src/itineris"
+)
+
+const (
+	// login channels of this type are configured under "gvabe.channels.<name>" with setting "type = oidc"
+	loginChannelTypeOidc = "oidc"
+
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+)
+
+var (
+	oidcDefaultScopes = []string{"openid", "email", "profile"}
+
+	// generic OpenID Connect providers, indexed by login channel name
+	oidcProviders = make(map[string]*oidcProvider)
+)
+
+// oidcProviderMetadata captures (part of) the OpenID Provider's discovery document.
+//
+// available since v0.8.0
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// oidcProvider is a config-driven, generic OpenID Connect login channel.
+//
+// available since v0.8.0
+type oidcProvider struct {
+	name           string   // name of the login channel
+	issuer         string   // issuer url, discovery document is loaded from <issuer>/.well-known/openid-configuration
+	emailClaim     string   // name of the claim that holds user's email address (default "email")
+	altEmailClaims []string // claims to look for user's email address if emailClaim is not available
+	nameClaim      string   // name of the claim that holds user's display name (default "name")
+	verifiedClaim  string   // name of the claim telling if user's email address has been verified (default "email_verified")
+	trustEmail     bool     // if true, email addresses reported by the provider are considered verified
+	httpClient     *http.Client
+	oauthConf      *oauth2.Config
+	lock           sync.RWMutex
+	metadata       *oidcProviderMetadata
+	keySet         *jwksKeySet
+
+	// (optional) if set, the discovery document's issuer is not required to match the configured issuer,
+	// instead the id_token's "iss" claim is verified by this function (e.g. multi-tenant providers)
+	issuerValidator func(metadataIssuer string, claims jwt.MapClaims) error
+
+	// (optional) if set, the id_token's claims are verified against the application's settings upon login
+	appClaimsValidator func(app *app.App, claims jwt.MapClaims) error
+}
+
+func newOidcProvider(name, issuer, clientId, clientSecret, redirectUri string, scopes []string) *oidcProvider {
+	if len(scopes) == 0 {
+		scopes = oidcDefaultScopes
+	}
+	return &oidcProvider{
+		name:          name,
+		issuer:        strings.TrimSuffix(issuer, "/"),
+		emailClaim:    "email",
+		nameClaim:     "name",
+		verifiedClaim: "email_verified",
+		httpClient:    httpClient,
+		oauthConf: &oauth2.Config{
+			ClientID:     clientId,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectUri,
+			Scopes:       scopes,
+		},
+	}
+}
+
+func (p *oidcProvider) _httpGetJson(ctx context.Context, url, accessToken string, result interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := p.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("[%s] response status: %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+// discover loads the provider's discovery document, once loaded successfully the metadata is cached.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcProviderMetadata, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	metadata := &oidcProviderMetadata{}
+	if err := p._httpGetJson(ctx, p.issuer+oidcDiscoveryPath, "", metadata); err != nil {
+		return nil, err
+	}
+	if p.issuerValidator == nil && strings.TrimSuffix(metadata.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("issuer mismatch, expected [%s] but discovery document returned [%s]", p.issuer, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksUri == "" {
+		return nil, errors.New("discovery document does not contain authorization_endpoint/token_endpoint/jwks_uri")
+	}
+	p.oauthConf.Endpoint = oauth2.Endpoint{AuthURL: metadata.AuthorizationEndpoint, TokenURL: metadata.TokenEndpoint}
+	p.keySet = newJwksKeySet(metadata.JwksUri, p.httpClient)
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// exchange exchanges the authorization code for tokens, codeVerifier is optional (used with PKCE).
+func (p *oidcProvider) exchange(ctx context.Context, authCode, codeVerifier string) (*oauth2.Token, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOnline}
+	if codeVerifier != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	return p.oauthConf.Exchange(ctx, authCode, opts...)
+}
+
+// authUrl builds the url of the provider's authorization endpoint, empty string is returned if discovery fails.
+func (p *oidcProvider) authUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := p.discover(ctx); err != nil {
+		return ""
+	}
+	return p.oauthConf.AuthCodeURL(state, opts...)
+}
+
+// verifyIdToken verifies the id_token (signature, issuer, audience, expiry and nonce) and returns its claims.
+func (p *oidcProvider) verifyIdToken(ctx context.Context, idToken, nonce string) (jwt.MapClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := parseAndVerifyJwtWithKeySet(ctx, p.keySet, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if p.issuerValidator != nil {
+		if err := p.issuerValidator(metadata.Issuer, claims); err != nil {
+			return nil, err
+		}
+	} else if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.issuer {
+		return nil, fmt.Errorf("invalid id_token issuer: %s", iss)
+	}
+	if !jwtClaimsHasAudience(claims, p.oauthConf.ClientID) {
+		return nil, fmt.Errorf("id_token is not issued for client [%s]", p.oauthConf.ClientID)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != "" && azp != p.oauthConf.ClientID {
+		return nil, fmt.Errorf("invalid id_token authorized party: %s", azp)
+	}
+	if nonce != "" {
+		if v, _ := claims["nonce"].(string); v != nonce {
+			return nil, errors.New("id_token nonce mismatch")
+		}
+	}
+	return claims, nil
+}
+
+// verifyAppClaims verifies the id_token's claims against the application's settings.
+func (p *oidcProvider) verifyAppClaims(app *app.App, claims jwt.MapClaims) error {
+	if p.appClaimsValidator == nil {
+		return nil
+	}
+	return p.appClaimsValidator(app, claims)
+}
+
+// extractEmail returns user's email address from the claims, empty string is returned if not found.
+func (p *oidcProvider) extractEmail(claims map[string]interface{}) string {
+	for _, claim := range append([]string{p.emailClaim}, p.altEmailClaims...) {
+		if email, _ := claims[claim].(string); strings.Contains(email, "@") {
+			return strings.TrimSpace(email)
+		}
+	}
+	return ""
+}
+
+// isEmailVerified checks if the provider asserts that user's email address has been verified.
+func (p *oidcProvider) isEmailVerified(claims map[string]interface{}) bool {
+	return p.trustEmail || claimIsTrue(claims, p.verifiedClaim)
+}
+
+// fetchUserinfo calls the provider's userinfo endpoint and returns the claims.
+func (p *oidcProvider) fetchUserinfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.UserinfoEndpoint == "" {
+		return nil, errors.New("provider does not support userinfo endpoint")
+	}
+	result := make(map[string]interface{})
+	return result, p._httpGetJson(ctx, metadata.UserinfoEndpoint, accessToken, &result)
+}
+
+// loginOidc carries out the "login" API for OpenID Connect login channels: the authorization code is exchanged for tokens,
+// the id_token is verified and embedded into a pre-login session which is upgraded to login session in background
+// (see fetchOidcProfile).
+//
+// available since v0.8.0
+func loginOidc(apiCtx *itineris.ApiContext, provider *oidcProvider, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil).(string)
+	codeVerifier := _extractParam(params, "code_verifier", reddo.TypeString, "", nil).(string)
+	nonce := _extractParam(params, "nonce", reddo.TypeString, "", nil).(string)
+	if DEBUG {
+		log.Printf("[DEBUG] START loginOidc(%s)", provider.name)
+		t := time.Now().UnixNano()
+		defer func() {
+			d := time.Now().UnixNano() - t
+			log.Printf("[DEBUG] END loginOidc(%s): %d ms", provider.name, d/1000000)
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// firstly exchange authCode for tokens
+	token, err := provider.exchange(ctx, authCode, codeVerifier)
+	if err != nil {
+		if DEBUG {
+			log.Printf("[DEBUG] ERROR loginOidc(%s): %s", provider.name, err)
+		}
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	} else if token == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Error: exchanged token is nil")
+	}
+	// secondly verify the id_token
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage("Error: no id_token returned from provider")
+	}
+	idTokenClaims, err := provider.verifyIdToken(ctx, idToken, nonce)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	if err := provider.verifyAppClaims(app, idTokenClaims); err != nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(err.Error())
+	}
+	now := time.Now()
+	if token.Expiry.IsZero() {
+		token.Expiry = now.Add(1 * time.Hour)
+	}
+	// thirdly embed tokens and verified claims into exter's session as a JWT
+	js, _ := json.Marshal(oidcSessionData{Token: token, Claims: idTokenClaims})
+	claims, err := genPreLoginClaims(&Session{
+		ClientId:   app.GetId(),
+		Channel:    provider.name,
+		CreatedAt:  now,
+		ExpiredAt:  token.Expiry,
+		Data:       js, // JSON-serialization of oidcSessionData
+		LinkUserId: _linkUserIdFromContext(apiCtx),
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	// lastly build user profile from claims
+	if err := enqueueLoginProfileJob(provider.name, claims.Id); err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	returnUrl = strings.ReplaceAll(returnUrl, "${token}", jwt)
+	return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
+
+// oidcSessionData is stored as Session.Data of pre-login sessions created by an OpenID Connect login channel.
+//
+// available since v0.8.0
+type oidcSessionData struct {
+	Token  *oauth2.Token          `json:"token"`  // tokens returned by the provider
+	Claims map[string]interface{} `json:"claims"` // verified claims of the id_token
+}
+
+// fetchOidcProfile builds user's profile from the verified id_token claims (and the userinfo endpoint if the id_token
+// does not contain email address), then upgrades the pre-login session to login session.
+//
+// available since v0.8.0
+func fetchOidcProfile(provider *oidcProvider, sessId string) error {
+	sess, err := loadPreLoginSession(sessId, provider.name)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	sessData := &oidcSessionData{}
+	if err := json.Unmarshal(sess.Data, &sessData); err != nil || sessData.Token == nil {
+		return jobPermanentError(fmt.Errorf("error unmarshalling session data: %v", err))
+	}
+	profile := sessData.Claims
+	if profile == nil {
+		profile = make(map[string]interface{})
+	}
+	if provider.extractEmail(profile) == "" {
+		// id_token does not contain email address, try the userinfo endpoint
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userinfo, err := provider.fetchUserinfo(ctx, sessData.Token.AccessToken)
+		if err != nil {
+			return fmt.Errorf("error fetching %s userinfo: %s", provider.name, err)
+		}
+		if sub, _ := userinfo["sub"].(string); sub == profile["sub"] {
+			for k, v := range userinfo {
+				profile[k] = v
+			}
+		}
+	}
+	ident, err := loginIdentityFromOidcClaims(provider, profile)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	u, err := resolveLoginIdentity(ident, sess.ClientId, sess.LinkUserId)
+	if err != nil {
+		return jobPermanentError(err)
+	}
+	js, _ := json.Marshal(sessData.Token)
+	sess.UserId = u.GetId()
+	sess.DisplayName = u.GetDisplayName()
+	sess.ExpiredAt = sessData.Token.Expiry
+	sess.Data = js
+	return upgradePreLoginSession(sessId, sess)
+}
+
+// available since v0.8.0
+func loginIdentityFromOidcClaims(provider *oidcProvider, claims map[string]interface{}) (*LoginIdentity, error) {
+	email := provider.extractEmail(claims)
+	if email == "" {
+		return nil, fmt.Errorf("%s profile does not contain email address", provider.name)
+	}
+	sub, _ := claims["sub"].(string)
+	givenName, _ := claims["given_name"].(string)
+	familyName, _ := claims["family_name"].(string)
+	name, _ := claims[provider.nameClaim].(string)
+	if strings.TrimSpace(name) == "" {
+		name = strings.TrimSpace(givenName + " " + familyName)
+	}
+	picture, _ := claims["picture"].(string)
+	locale, _ := claims["locale"].(string)
+	return &LoginIdentity{Provider: provider.name, Subject: sub, Email: email, EmailVerified: provider.isEmailVerified(claims), DisplayName: name,
+		AvatarUrl: picture, Locale: locale, GivenName: givenName, FamilyName: familyName}, nil
+}
+
+// oidcLoginChannel implements LoginChannel for generic OpenID Connect providers, configured under "gvabe.channels.<name>"
+// with setting "type = oidc".
+//
+// available since v0.8.0
+type oidcLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *oidcLoginChannel) Init(conf *hocon.Config) error {
+	name := ch.Name()
+	confPrefix := "gvabe.channels." + name
+	issuer := strings.TrimSpace(conf.GetString(confPrefix + ".issuer"))
+	if issuer == "" {
+		return fmt.Errorf("no valid OpenID Connect issuer defined at [%s.issuer]", confPrefix)
+	}
+	clientId := strings.TrimSpace(conf.GetString(confPrefix + ".client_id"))
+	if clientId == "" {
+		log.Println(fmt.Sprintf("[ERROR] No valid OpenID Connect client-id defined at [%s.client_id]", confPrefix))
+	}
+	clientSecret := strings.TrimSpace(conf.GetString(confPrefix + ".client_secret"))
+	if clientSecret == "" {
+		log.Println(fmt.Sprintf("[WARN] No OpenID Connect client-secret defined at [%s.client_secret], assuming public client", confPrefix))
+	}
+	redirectUri := strings.TrimSpace(conf.GetString(confPrefix + ".redirect_uri"))
+	if redirectUri == "" {
+		redirectUri = exterHomeUrl
+	}
+	var scopes []string
+	for _, scope := range regexp.MustCompile("[,;\\s]+").Split(conf.GetString(confPrefix+".scopes"), -1) {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	provider := newOidcProvider(name, issuer, clientId, clientSecret, redirectUri, scopes)
+	if emailClaim := strings.TrimSpace(conf.GetString(confPrefix + ".email_claim")); emailClaim != "" {
+		provider.emailClaim = emailClaim
+	}
+	if nameClaim := strings.TrimSpace(conf.GetString(confPrefix + ".name_claim")); nameClaim != "" {
+		provider.nameClaim = nameClaim
+	}
+	provider.trustEmail = conf.GetBoolean(confPrefix+".trust_email", false)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if _, err := provider.discover(ctx); err != nil {
+		// discovery will be retried upon login
+		log.Println(fmt.Sprintf("[ERROR] Cannot load OpenID Connect discovery document for channel [%s] from [%s]: %e", name, issuer, err))
+	}
+	cancel()
+	oidcProviders[name] = provider
+	if DEBUG {
+		secret := ""
+		if len(clientSecret) > 4 {
+			secret = "***" + clientSecret[len(clientSecret)-4:]
+		}
+		log.Printf("[DEBUG] oidcLoginChannel.Init - %s: %s/%s/%s/%s", name, issuer, clientId, secret, redirectUri)
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *oidcLoginChannel) Info() map[string]interface{} {
+	provider := oidcProviders[ch.Name()]
+	info := map[string]interface{}{
+		"client_id":    provider.oauthConf.ClientID,
+		"redirect_uri": provider.oauthConf.RedirectURL,
+		"scopes":       provider.oauthConf.Scopes,
+	}
+	provider.lock.RLock()
+	if metadata := provider.metadata; metadata != nil {
+		info["authorization_endpoint"] = metadata.AuthorizationEndpoint
+	}
+	provider.lock.RUnlock()
+	return map[string]interface{}{"oidc_channels": map[string]interface{}{ch.Name(): info}}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *oidcLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return oidcProviders[ch.Name()].authUrl(state, opts...)
+}
+
+// Login implements LoginHandler.Login.
+func (ch *oidcLoginChannel) Login(ctx *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	return loginOidc(ctx, oidcProviders[ch.Name()], params, app, returnUrl)
+}
+
+// FetchLoginProfile implements LoginProfileFetcher.FetchLoginProfile.
+func (ch *oidcLoginChannel) FetchLoginProfile(sessId string) error {
+	return fetchOidcProfile(oidcProviders[ch.Name()], sessId)
+}
@@ -0,0 +1,276 @@
+package gvabe
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/user"
+)
+
+// Exter as an OpenID Connect provider: registered apps are OIDC clients using the authorization code flow with PKCE.
+// The end-user authenticates with Exter's usual login page; the login token issued for the client app is the access
+// token, and is exchanged along with an id_token at the token endpoint.
+
+const (
+	sessionTypeOidcAuthz = "oidc_authz" // available since v0.8.0: authorization request of an OIDC client, waiting for user to login
+	sessionTypeOidcCode  = "oidc_code"  // available since v0.8.0: authorization code issued to an OIDC client
+
+	oidcIssuerDefaultAuthzRequestTtl = 10 * time.Minute
+	oidcIssuerDefaultCodeTtl         = 1 * time.Minute
+
+	oidcIssuerPathAuthorize         = "/authorize"
+	oidcIssuerPathAuthorizeCallback = "/authorize/callback"
+	oidcIssuerPathToken             = "/token"
+	oidcIssuerPathUserinfo          = "/userinfo"
+	oidcIssuerPathJwks              = "/jwks"
+	oidcIssuerPathDiscovery         = "/.well-known/openid-configuration"
+
+	oidcPkceMethodS256 = "S256"
+)
+
+var (
+	// OIDC issuer identifier, endpoints are relative to it (available since v0.8.0)
+	oidcIssuerUrl string
+
+	// how long an authorization request waits for user to login (available since v0.8.0)
+	oidcIssuerAuthzRequestTtl = oidcIssuerDefaultAuthzRequestTtl
+
+	// how long an authorization code is valid (available since v0.8.0)
+	oidcIssuerCodeTtl = oidcIssuerDefaultCodeTtl
+)
+
+// oidcAuthzRequest captures an authorization request of an OIDC client. Once user has logged in, the request is stored
+// along with the login token under the authorization code.
+//
+// available since v0.8.0
+type oidcAuthzRequest struct {
+	ClientId            string `json:"client_id"`
+	RedirectUri         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	LoginToken          string `json:"login_token,omitempty"`
+}
+
+// oidcError is an error response defined by OAuth 2.0/OpenID Connect (e.g. "invalid_request", "invalid_grant").
+//
+// available since v0.8.0
+type oidcError struct {
+	Code        string
+	Description string
+}
+
+func (e *oidcError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// toMap returns the error in the format of OAuth 2.0 error responses.
+func (e *oidcError) toMap() map[string]interface{} {
+	return map[string]interface{}{"error": e.Code, "error_description": e.Description}
+}
+
+// oidcIssuerEndpoint returns the absolute url of an endpoint of Exter as OpenID Connect provider.
+func oidcIssuerEndpoint(path string) string {
+	return strings.TrimSuffix(oidcIssuerUrl, "/") + path
+}
+
+// isOidcAuthorizeCallbackUrl checks if the url is Exter's authorization callback, the url user is returned to after
+// logging in to complete an authorization request. The callback is a valid return url for all apps.
+func isOidcAuthorizeCallbackUrl(returnUrl string) bool {
+	return oidcIssuerUrl != "" && strings.HasPrefix(returnUrl, oidcIssuerEndpoint(oidcIssuerPathAuthorizeCallback)+"?")
+}
+
+// _appReturnUrl validates the return url requested for an app (see App.GenerateReturnUrl), Exter's authorization
+// callback is allowed for all apps.
+//
+// available since v0.8.0
+func _appReturnUrl(app *app.App, preferredReturnUrl string) string {
+	if isOidcAuthorizeCallbackUrl(preferredReturnUrl) {
+		return preferredReturnUrl
+	}
+	return app.GenerateReturnUrl(preferredReturnUrl)
+}
+
+// validateOidcAuthzRequest validates an authorization request whose client and redirect_uri have been verified.
+func validateOidcAuthzRequest(responseType string, req *oidcAuthzRequest) *oidcError {
+	if responseType != "code" {
+		return &oidcError{Code: "unsupported_response_type", Description: "only the authorization code flow (response_type=code) is supported"}
+	}
+	if !oidcScopeHas(req.Scope, "openid") {
+		return &oidcError{Code: "invalid_scope", Description: "scope [openid] is required"}
+	}
+	if req.CodeChallenge == "" {
+		return &oidcError{Code: "invalid_request", Description: "PKCE is required: missing code_challenge"}
+	}
+	if req.CodeChallengeMethod != oidcPkceMethodS256 {
+		return &oidcError{Code: "invalid_request", Description: "code_challenge_method must be " + oidcPkceMethodS256}
+	}
+	return nil
+}
+
+// oidcScopeHas checks if a space-delimited scope string contains the specified scope.
+func oidcScopeHas(scope, target string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcRedirectUrl appends parameters to the client's redirect_uri.
+func oidcRedirectUrl(redirectUri string, params map[string]string) string {
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		return redirectUri
+	}
+	q := u.Query()
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// oidcLoginPageUrl returns the url of Exter's login page for user to login to the client app, user is then returned to
+// the authorization callback.
+func oidcLoginPageUrl(clientId, authzRequestId string) string {
+	callbackUrl := oidcIssuerEndpoint(oidcIssuerPathAuthorizeCallback) + "?req=" + url.QueryEscape(authzRequestId) + "&token=${token}"
+	return strings.TrimSuffix(exterHomeUrl, "/") + "/app/xlogin?" + url.Values{"app": {clientId}, "returnUrl": {callbackUrl}}.Encode()
+}
+
+// oidcRandomCode generates a random, unguessable code (e.g. authorization code).
+func oidcRandomCode() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// saveOidcAuthzRequest persists an authorization request (or an authorization code) as a session.
+func saveOidcAuthzRequest(sessType, id string, req *oidcAuthzRequest, ttl time.Duration) error {
+	js, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	sess := session.NewSession(goapi.AppVersionNumber, id, sessType, "oidc", req.ClientId, "", string(js), time.Now().Add(ttl))
+	if ok, err := sessionDao.Save(sess); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("cannot save authorization request")
+	}
+	return nil
+}
+
+// takeOidcAuthzRequest loads and removes an authorization request (or an authorization code): each can be used only once.
+// This function returns nil if the request does not exist, has expired or has been taken by a concurrent call.
+func takeOidcAuthzRequest(sessType, id string) (*oidcAuthzRequest, error) {
+	if id == "" {
+		return nil, nil
+	}
+	sess, err := sessionDao.Get(id)
+	if err != nil || sess == nil || sess.GetSessionType() != sessType {
+		return nil, err
+	}
+	if ok, err := sessionDao.Delete(sess); err != nil || !ok {
+		// removed in the meantime: the request has been taken by a concurrent call
+		return nil, err
+	}
+	if sess.IsExpired() {
+		return nil, nil
+	}
+	req := &oidcAuthzRequest{}
+	return req, json.Unmarshal([]byte(sess.GetSessionData()), req)
+}
+
+// verifyPkce verifies the code_verifier sent to the token endpoint against the code_challenge of the authorization request.
+func verifyPkce(codeVerifier, codeChallenge, codeChallengeMethod string) bool {
+	if codeVerifier == "" || codeChallengeMethod != oidcPkceMethodS256 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(codeChallenge)) == 1
+}
+
+// oidcUserClaims returns the standard claims about the user (scopes "profile" and "email").
+func oidcUserClaims(u *user.User) map[string]interface{} {
+	claims := map[string]interface{}{"sub": u.GetId()}
+	if strings.Contains(u.GetId(), "@") {
+		claims["email"] = u.GetId()
+	}
+	optionalClaims := map[string]string{
+		"name":        u.GetDisplayName(),
+		"picture":     u.GetAvatarUrl(),
+		"locale":      u.GetLocale(),
+		"given_name":  u.GetGivenName(),
+		"family_name": u.GetFamilyName(),
+	}
+	for k, v := range optionalClaims {
+		if v != "" {
+			claims[k] = v
+		}
+	}
+	return claims
+}
+
+// genOidcIdToken generates the id_token issued to an OIDC client, from the login token of the user.
+func genOidcIdToken(req *oidcAuthzRequest, loginClaims *SessionClaims, u *user.User) (string, error) {
+	claims := jwt.MapClaims{}
+	for k, v := range oidcUserClaims(u) {
+		claims[k] = v
+	}
+	now := time.Now()
+	claims["iss"] = oidcIssuerUrl
+	claims["aud"] = req.ClientId
+	claims["iat"] = now.Unix()
+	claims["exp"] = loginClaims.ExpiresAt
+	claims["auth_time"] = loginClaims.IssuedAt
+	if req.Nonce != "" {
+		claims["nonce"] = req.Nonce
+	}
+	if len(loginClaims.Amr) > 0 {
+		claims["amr"] = loginClaims.Amr
+	}
+	if loginClaims.Acr != "" {
+		claims["acr"] = loginClaims.Acr
+	}
+	return signJwt(claims)
+}
+
+// oidcDiscoveryDocument returns the OpenID Provider Metadata of Exter.
+func oidcDiscoveryDocument() map[string]interface{} {
+	return map[string]interface{}{
+		"issuer":                                oidcIssuerUrl,
+		"authorization_endpoint":                oidcIssuerEndpoint(oidcIssuerPathAuthorize),
+		"token_endpoint":                        oidcIssuerEndpoint(oidcIssuerPathToken),
+		"userinfo_endpoint":                     oidcIssuerEndpoint(oidcIssuerPathUserinfo),
+		"jwks_uri":                              oidcIssuerEndpoint(oidcIssuerPathJwks),
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": signingKeys.algs(),
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+		"token_endpoint_auth_methods_supported": []string{"none"},
+		"code_challenge_methods_supported":      []string{oidcPkceMethodS256},
+		"claims_supported": []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "acr",
+			"email", "name", "picture", "locale", "given_name", "family_name"},
+	}
+}
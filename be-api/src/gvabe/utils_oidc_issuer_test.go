@@ -0,0 +1,151 @@
+package gvabe
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/user"
+)
+
+func TestVerifyPkce(t *testing.T) {
+	testName := "TestVerifyPkce"
+	// challenge = BASE64URL(SHA256(verifier))
+	verifier, challenge := "dBjftJeZ4CVP-mJ92K1U_j98Tyr4e8ddWVP4Qf_lrGk", "2CyPtohthms7JT2Qaj_E1LtLbWkxiMWICMmg688EmYc"
+	if !verifyPkce(verifier, challenge, oidcPkceMethodS256) {
+		t.Fatalf("%s failed: code verifier should match", testName)
+	}
+	if verifyPkce("another-verifier", challenge, oidcPkceMethodS256) || verifyPkce("", challenge, oidcPkceMethodS256) {
+		t.Fatalf("%s failed: code verifier should not match", testName)
+	}
+	if verifyPkce(challenge, challenge, "plain") {
+		t.Fatalf("%s failed: method plain is not supported", testName)
+	}
+}
+
+func TestValidateOidcAuthzRequest(t *testing.T) {
+	testName := "TestValidateOidcAuthzRequest"
+	req := &oidcAuthzRequest{ClientId: "app", RedirectUri: "https://app.com/cb", Scope: "openid profile", CodeChallenge: "challenge", CodeChallengeMethod: oidcPkceMethodS256}
+	if e := validateOidcAuthzRequest("code", req); e != nil {
+		t.Fatalf("%s failed: %s", testName, e)
+	}
+	if e := validateOidcAuthzRequest("token", req); e == nil || e.Code != "unsupported_response_type" {
+		t.Fatalf("%s failed: %#v", testName, e)
+	}
+	req.Scope = "profile email"
+	if e := validateOidcAuthzRequest("code", req); e == nil || e.Code != "invalid_scope" {
+		t.Fatalf("%s failed: %#v", testName, e)
+	}
+	req.Scope, req.CodeChallenge = "openid", ""
+	if e := validateOidcAuthzRequest("code", req); e == nil || e.Code != "invalid_request" {
+		t.Fatalf("%s failed: PKCE should be required", testName)
+	}
+}
+
+func TestIsOidcAuthorizeCallbackUrl(t *testing.T) {
+	testName := "TestIsOidcAuthorizeCallbackUrl"
+	defer func(issuer, home string) { oidcIssuerUrl, exterHomeUrl = issuer, home }(oidcIssuerUrl, exterHomeUrl)
+	oidcIssuerUrl, exterHomeUrl = "https://exter.domain.com", "https://exter.domain.com/"
+
+	loginUrl, _ := url.Parse(oidcLoginPageUrl("app", "req123"))
+	if loginUrl.Path != "/app/xlogin" || loginUrl.Query().Get("app") != "app" {
+		t.Fatalf("%s failed: %s", testName, loginUrl)
+	}
+	returnUrl := loginUrl.Query().Get("returnUrl")
+	if returnUrl != "https://exter.domain.com/authorize/callback?req=req123&token=${token}" || !isOidcAuthorizeCallbackUrl(returnUrl) {
+		t.Fatalf("%s failed: %s", testName, returnUrl)
+	}
+	if isOidcAuthorizeCallbackUrl("https://evil.com/authorize/callback?req=req123") || isOidcAuthorizeCallbackUrl("https://exter.domain.com/authorize/callbackx?") {
+		t.Fatalf("%s failed: only Exter's callback is allowed", testName)
+	}
+}
+
+func TestTakeOidcAuthzRequest(t *testing.T) {
+	testName := "TestTakeOidcAuthzRequest"
+	defer func(dao session.SessionDao) { sessionDao = dao }(sessionDao)
+	sessionDao = &testSessionDao{sessions: make(map[string]*session.Session)}
+	req := &oidcAuthzRequest{ClientId: "app", RedirectUri: "https://app.com/cb", Scope: "openid", State: "xyz", CodeChallenge: "challenge", CodeChallengeMethod: oidcPkceMethodS256}
+	if err := saveOidcAuthzRequest(sessionTypeOidcCode, "code", req, time.Minute); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if v, err := takeOidcAuthzRequest(sessionTypeOidcAuthz, "code"); err != nil || v != nil {
+		t.Fatalf("%s failed: session type must match", testName)
+	}
+	if v, err := takeOidcAuthzRequest(sessionTypeOidcCode, "code"); err != nil || !reflect.DeepEqual(v, req) {
+		t.Fatalf("%s failed: expected %#v but received %#v / %s", testName, req, v, err)
+	}
+	// authorization codes can be used only once
+	if v, err := takeOidcAuthzRequest(sessionTypeOidcCode, "code"); err != nil || v != nil {
+		t.Fatalf("%s failed: authorization code must be used only once", testName)
+	}
+	// expired authorization codes are rejected
+	saveOidcAuthzRequest(sessionTypeOidcCode, "code", req, -time.Minute)
+	if v, err := takeOidcAuthzRequest(sessionTypeOidcCode, "code"); err != nil || v != nil {
+		t.Fatalf("%s failed: expired authorization code must be rejected", testName)
+	}
+	// authorization code taken by a concurrent call (between loading and removing it) is rejected
+	saveOidcAuthzRequest(sessionTypeOidcCode, "code", req, time.Minute)
+	sessionDao = &racingSessionDao{sessionDao.(*testSessionDao)}
+	if v, err := takeOidcAuthzRequest(sessionTypeOidcCode, "code"); err != nil || v != nil {
+		t.Fatalf("%s failed: authorization code must be used only once", testName)
+	}
+}
+
+// racingSessionDao removes sessions right after they are loaded, as if they were taken by a concurrent call.
+type racingSessionDao struct {
+	*testSessionDao
+}
+
+func (dao *racingSessionDao) Get(id string) (*session.Session, error) {
+	sess, err := dao.testSessionDao.Get(id)
+	if sess != nil {
+		dao.testSessionDao.Delete(sess)
+	}
+	return sess, err
+}
+
+func TestGenOidcIdToken(t *testing.T) {
+	testName := "TestGenOidcIdToken"
+	defer func(issuer string) { oidcIssuerUrl = issuer }(oidcIssuerUrl)
+	oidcIssuerUrl = "https://exter.domain.com"
+	ks, _, teardown := setupTestKeyset(t, testName)
+	defer teardown()
+
+	// id_tokens are verifiable with the published JWKS
+	js, _ := json.Marshal(map[string]interface{}{"keys": ks.jwks()})
+	keys, err := parseJwks(js)
+	if err != nil || len(keys) != 1 || keys[ks.signingKey().kid] == nil {
+		t.Fatalf("%s failed: %#v / %s", testName, keys, err)
+	}
+
+	u := user.NewUser(0, "user@domain.com").SetDisplayName("Jane Doe").SetLocale("en")
+	now := time.Now()
+	loginClaims := &SessionClaims{Type: sessionTypeLogin, UserId: u.GetId(), Amr: []string{"pwd"}, Acr: acrSingleFactor,
+		StandardClaims: jwt.StandardClaims{Audience: "app", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}}
+	idToken, err := genOidcIdToken(&oidcAuthzRequest{ClientId: "app", Nonce: "n-0S6_WzA2Mj"}, loginClaims, u)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		return keys[token.Header["kid"].(string)], nil
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	expected := map[string]interface{}{"iss": oidcIssuerUrl, "aud": "app", "sub": "user@domain.com", "email": "user@domain.com",
+		"name": "Jane Doe", "locale": "en", "nonce": "n-0S6_WzA2Mj", "acr": acrSingleFactor}
+	for k, v := range expected {
+		if claims[k] != v {
+			t.Fatalf("%s failed: expected claim %s to be %#v but received %#v", testName, k, v, claims[k])
+		}
+	}
+	if int64(claims["auth_time"].(float64)) != now.Unix() || claims["picture"] != nil {
+		t.Fatalf("%s failed: %#v", testName, claims)
+	}
+}
@@ -0,0 +1,217 @@
+package gvabe
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// stubOidcIssuer is a minimal OpenID Provider used for testing.
+type stubOidcIssuer struct {
+	server   *httptest.Server
+	kid      string
+	privKey  *rsa.PrivateKey
+	clientId string
+	// if not empty, returned as "issuer" in the discovery document
+	metadataIssuer string
+	// claims of the id_token returned by token endpoint
+	idTokenClaims jwt.MapClaims
+	// claims returned by userinfo endpoint
+	userinfo map[string]interface{}
+}
+
+func newStubOidcIssuer(t *testing.T, clientId string) *stubOidcIssuer {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate RSA key: %s", err)
+	}
+	issuer := &stubOidcIssuer{kid: "key1", privKey: privKey, clientId: clientId}
+	mux := http.NewServeMux()
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		metadataIssuer := issuer.metadataIssuer
+		if metadataIssuer == "" {
+			metadataIssuer = issuer.server.URL
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 metadataIssuer,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"userinfo_endpoint":      issuer.server.URL + "/userinfo",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		pubKey := issuer.privKey.PublicKey
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]interface{}{{
+				"kty": "RSA", "use": "sig", "alg": "RS256", "kid": issuer.kid,
+				"n": base64.RawURLEncoding.EncodeToString(pubKey.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pubKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     issuer.signIdToken(t, issuer.idTokenClaims),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(issuer.userinfo)
+	})
+	issuer.server = httptest.NewServer(mux)
+	now := time.Now().Unix()
+	issuer.idTokenClaims = jwt.MapClaims{
+		"iss": issuer.server.URL, "aud": clientId, "sub": "user1",
+		"iat": now, "exp": now + 3600, "email": "user1@example.com", "name": "User One",
+	}
+	issuer.userinfo = map[string]interface{}{"sub": "user1", "email": "user1@example.com", "name": "User One"}
+	return issuer
+}
+
+func (issuer *stubOidcIssuer) signIdToken(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = issuer.kid
+	jws, err := token.SignedString(issuer.privKey)
+	if err != nil {
+		t.Fatalf("cannot sign id_token: %s", err)
+	}
+	return jws
+}
+
+func TestOidcProvider_discover(t *testing.T) {
+	testName := "TestOidcProvider_discover"
+	issuer := newStubOidcIssuer(t, "client1")
+	defer issuer.server.Close()
+
+	provider := newOidcProvider("stub", issuer.server.URL+"/", "client1", "secret1", "http://localhost/callback", nil)
+	metadata, err := provider.discover(context.Background())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if metadata.TokenEndpoint != issuer.server.URL+"/token" || provider.oauthConf.Endpoint.TokenURL != issuer.server.URL+"/token" {
+		t.Fatalf("%s failed: invalid token endpoint %#v", testName, metadata.TokenEndpoint)
+	}
+	if strings.Join(provider.oauthConf.Scopes, " ") != "openid email profile" {
+		t.Fatalf("%s failed: invalid default scopes %#v", testName, provider.oauthConf.Scopes)
+	}
+
+	provider = newOidcProvider("stub", issuer.server.URL+"/another", "client1", "secret1", "http://localhost/callback", nil)
+	if _, err := provider.discover(context.Background()); err == nil {
+		t.Fatalf("%s failed: expected error for invalid issuer", testName)
+	}
+}
+
+func TestOidcProvider_exchangeAndVerify(t *testing.T) {
+	testName := "TestOidcProvider_exchangeAndVerify"
+	issuer := newStubOidcIssuer(t, "client1")
+	defer issuer.server.Close()
+	provider := newOidcProvider("stub", issuer.server.URL, "client1", "secret1", "http://localhost/callback", nil)
+	ctx := context.Background()
+
+	if _, err := provider.exchange(ctx, "invalid-code", ""); err == nil {
+		t.Fatalf("%s failed: expected error for invalid code", testName)
+	}
+	issuer.idTokenClaims["nonce"] = "nonce1"
+	token, err := provider.exchange(ctx, "valid-code", "verifier1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	claims, err := provider.verifyIdToken(ctx, idToken, "nonce1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if claims["email"] != "user1@example.com" {
+		t.Fatalf("%s failed: expected email %#v but received %#v", testName, "user1@example.com", claims["email"])
+	}
+	if _, err := provider.verifyIdToken(ctx, idToken, "another-nonce"); err == nil {
+		t.Fatalf("%s failed: expected error for nonce mismatch", testName)
+	}
+}
+
+func TestOidcProvider_verifyIdTokenInvalid(t *testing.T) {
+	testName := "TestOidcProvider_verifyIdTokenInvalid"
+	issuer := newStubOidcIssuer(t, "client1")
+	defer issuer.server.Close()
+	provider := newOidcProvider("stub", issuer.server.URL, "client1", "secret1", "http://localhost/callback", nil)
+	ctx := context.Background()
+
+	now := time.Now().Unix()
+	testCases := map[string]jwt.MapClaims{
+		"wrong audience": {"iss": issuer.server.URL, "aud": "client2", "sub": "user1", "iat": now, "exp": now + 3600},
+		"wrong issuer":   {"iss": "http://another", "aud": "client1", "sub": "user1", "iat": now, "exp": now + 3600},
+		"expired":        {"iss": issuer.server.URL, "aud": "client1", "sub": "user1", "iat": now - 7200, "exp": now - 3600},
+		"no expiry":      {"iss": issuer.server.URL, "aud": "client1", "sub": "user1", "iat": now},
+		"wrong azp":      {"iss": issuer.server.URL, "aud": []string{"client1", "client2"}, "azp": "client2", "sub": "user1", "iat": now, "exp": now + 3600},
+	}
+	for desc, claims := range testCases {
+		if _, err := provider.verifyIdToken(ctx, issuer.signIdToken(t, claims), ""); err == nil {
+			t.Fatalf("%s failed: expected error for case <%s>", testName, desc)
+		}
+	}
+
+	// multiple audiences
+	claims := jwt.MapClaims{"iss": issuer.server.URL, "aud": []string{"client2", "client1"}, "azp": "client1", "sub": "user1", "iat": now, "exp": now + 3600}
+	if _, err := provider.verifyIdToken(ctx, issuer.signIdToken(t, claims), ""); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// signed by an unknown key
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.idTokenClaims)
+	token.Header["kid"] = issuer.kid
+	jws, _ := token.SignedString(otherKey)
+	if _, err := provider.verifyIdToken(ctx, jws, ""); err == nil {
+		t.Fatalf("%s failed: expected error for invalid signature", testName)
+	}
+
+	// HMAC-signed token must be rejected
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.idTokenClaims)
+	token.Header["kid"] = issuer.kid
+	jws, _ = token.SignedString([]byte("secret1"))
+	if _, err := provider.verifyIdToken(ctx, jws, ""); err == nil {
+		t.Fatalf("%s failed: expected error for HMAC-signed token", testName)
+	}
+}
+
+func TestOidcProvider_fetchUserinfo(t *testing.T) {
+	testName := "TestOidcProvider_fetchUserinfo"
+	issuer := newStubOidcIssuer(t, "client1")
+	defer issuer.server.Close()
+	provider := newOidcProvider("stub", issuer.server.URL, "client1", "secret1", "http://localhost/callback", nil)
+
+	userinfo, err := provider.fetchUserinfo(context.Background(), "access-token")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if userinfo["email"] != "user1@example.com" {
+		t.Fatalf("%s failed: expected email %#v but received %#v", testName, "user1@example.com", userinfo["email"])
+	}
+	if _, err := provider.fetchUserinfo(context.Background(), "invalid-token"); err == nil {
+		t.Fatalf("%s failed: expected error for invalid access token", testName)
+	}
+}
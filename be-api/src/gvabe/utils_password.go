@@ -0,0 +1,169 @@
+package gvabe
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"main/src/gvabe/bo/user"
+)
+
+const (
+	passwordAlgArgon2id = "argon2id"
+	passwordAlgBcrypt   = "bcrypt"
+
+	// names of PasswordHash.Params
+	passwordParamArgon2Time    = "t" // number of iterations
+	passwordParamArgon2Memory  = "m" // memory in KiB
+	passwordParamArgon2Threads = "p" // degree of parallelism
+	passwordParamArgon2KeyLen  = "k" // length of the hash in bytes
+	passwordParamBcryptCost    = "cost"
+
+	// defaults follow OWASP's recommendation for argon2id
+	passwordDefaultArgon2Time    = 3
+	passwordDefaultArgon2Memory  = 64 * 1024
+	passwordDefaultArgon2Threads = 2
+	passwordArgon2KeyLen         = 32
+	passwordArgon2SaltLen        = 16
+
+	passwordDefaultMinLength = 8
+	passwordMaxLength        = 256
+	passwordBcryptMaxLength  = 72 // bcrypt ignores bytes beyond the 72nd
+)
+
+var (
+	errorPasswordTooShort  = errors.New("password is too short")
+	errorPasswordTooLong   = errors.New("password is too long")
+	errorPasswordAlgorithm = errors.New("unsupported password hashing algorithm")
+
+	// hasher of local passwords, always available (local login channel might be disabled but passwords can still be set, e.g. system app owner's)
+	pwdHasher = newPasswordHasher(passwordAlgArgon2id)
+)
+
+// passwordHasher hashes passwords with the configured algorithm and settings.
+//
+// available since v0.8.0
+type passwordHasher struct {
+	algorithm     string // passwordAlgArgon2id or passwordAlgBcrypt
+	minLength     int    // minimum length of passwords, in characters
+	argon2Time    uint32
+	argon2Memory  uint32
+	argon2Threads uint8
+	bcryptCost    int
+}
+
+func newPasswordHasher(algorithm string) *passwordHasher {
+	return &passwordHasher{
+		algorithm:     algorithm,
+		minLength:     passwordDefaultMinLength,
+		argon2Time:    passwordDefaultArgon2Time,
+		argon2Memory:  passwordDefaultArgon2Memory,
+		argon2Threads: passwordDefaultArgon2Threads,
+		bcryptCost:    bcrypt.DefaultCost,
+	}
+}
+
+// validate checks a new password against the password policy.
+func (h *passwordHasher) validate(password string) error {
+	if utf8.RuneCountInString(password) < h.minLength {
+		return errorPasswordTooShort
+	}
+	if len(password) > passwordMaxLength || (h.algorithm == passwordAlgBcrypt && len(password) > passwordBcryptMaxLength) {
+		return errorPasswordTooLong
+	}
+	return nil
+}
+
+// hash hashes a password with the current settings.
+func (h *passwordHasher) hash(password string) (*user.PasswordHash, error) {
+	switch h.algorithm {
+	case passwordAlgArgon2id:
+		salt := make([]byte, passwordArgon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		hash := argon2.IDKey([]byte(password), salt, h.argon2Time, h.argon2Memory, h.argon2Threads, passwordArgon2KeyLen)
+		return &user.PasswordHash{
+			Algorithm: passwordAlgArgon2id,
+			Params: map[string]int{
+				passwordParamArgon2Time:    int(h.argon2Time),
+				passwordParamArgon2Memory:  int(h.argon2Memory),
+				passwordParamArgon2Threads: int(h.argon2Threads),
+				passwordParamArgon2KeyLen:  passwordArgon2KeyLen,
+			},
+			Salt:      base64.RawStdEncoding.EncodeToString(salt),
+			Hash:      base64.RawStdEncoding.EncodeToString(hash),
+			UpdatedAt: time.Now(),
+		}, nil
+	case passwordAlgBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		return &user.PasswordHash{
+			Algorithm: passwordAlgBcrypt,
+			Params:    map[string]int{passwordParamBcryptCost: h.bcryptCost},
+			Hash:      string(hash),
+			UpdatedAt: time.Now(),
+		}, nil
+	}
+	return nil, errorPasswordAlgorithm
+}
+
+// verify checks a password against a stored hash. If the password matches but the hash was made with a different
+// algorithm or weaker settings than the current ones, needRehash is true: caller should re-hash and store the password.
+func (h *passwordHasher) verify(ph *user.PasswordHash, password string) (match bool, needRehash bool, err error) {
+	if ph == nil || ph.Hash == "" {
+		return false, false, nil
+	}
+	switch ph.Algorithm {
+	case passwordAlgArgon2id:
+		salt, err := base64.RawStdEncoding.DecodeString(ph.Salt)
+		if err != nil {
+			return false, false, err
+		}
+		expected, err := base64.RawStdEncoding.DecodeString(ph.Hash)
+		if err != nil {
+			return false, false, err
+		}
+		t, m, p := ph.Params[passwordParamArgon2Time], ph.Params[passwordParamArgon2Memory], ph.Params[passwordParamArgon2Threads]
+		if t <= 0 || m <= 0 || p <= 0 || p > 255 || len(expected) == 0 {
+			return false, false, fmt.Errorf("invalid argon2id parameters %v", ph.Params)
+		}
+		hash := argon2.IDKey([]byte(password), salt, uint32(t), uint32(m), uint8(p), uint32(len(expected)))
+		if subtle.ConstantTimeCompare(hash, expected) != 1 {
+			return false, false, nil
+		}
+		needRehash = h.algorithm != passwordAlgArgon2id || uint32(t) < h.argon2Time || uint32(m) < h.argon2Memory ||
+			uint8(p) < h.argon2Threads || len(expected) < passwordArgon2KeyLen
+		return true, needRehash, nil
+	case passwordAlgBcrypt:
+		if err := bcrypt.CompareHashAndPassword([]byte(ph.Hash), []byte(password)); err != nil {
+			if err == bcrypt.ErrMismatchedHashAndPassword {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, _ := bcrypt.Cost([]byte(ph.Hash))
+		return true, h.algorithm != passwordAlgBcrypt || cost < h.bcryptCost, nil
+	}
+	return false, false, errorPasswordAlgorithm
+}
+
+// dummyVerify burns roughly the same time as verifying a password, so that responses for non-existing accounts
+// can not be told apart by timing.
+func (h *passwordHasher) dummyVerify(password string) {
+	switch h.algorithm {
+	case passwordAlgBcrypt:
+		bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	default:
+		argon2.IDKey([]byte(password), make([]byte, passwordArgon2SaltLen), h.argon2Time, h.argon2Memory, h.argon2Threads, passwordArgon2KeyLen)
+	}
+}
@@ -0,0 +1,78 @@
+package gvabe
+
+import (
+	"testing"
+)
+
+func _newTestPasswordHasher(algorithm string) *passwordHasher {
+	h := newPasswordHasher(algorithm)
+	h.argon2Time, h.argon2Memory, h.argon2Threads = 1, 1024, 1
+	h.bcryptCost = 4
+	return h
+}
+
+func TestPasswordHasher_validate(t *testing.T) {
+	name := "TestPasswordHasher_validate"
+	h := _newTestPasswordHasher(passwordAlgArgon2id)
+	if err := h.validate("short"); err != errorPasswordTooShort {
+		t.Fatalf("%s failed: expected %s but received %v", name, errorPasswordTooShort, err)
+	}
+	if err := h.validate("mật khẩu dài"); err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	long := string(make([]byte, passwordBcryptMaxLength+1))
+	if err := h.validate(long); err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if err := _newTestPasswordHasher(passwordAlgBcrypt).validate(long); err != errorPasswordTooLong {
+		t.Fatalf("%s failed: expected %s but received %v", name, errorPasswordTooLong, err)
+	}
+}
+
+func TestPasswordHasher_hashAndVerify(t *testing.T) {
+	name := "TestPasswordHasher_hashAndVerify"
+	for _, algorithm := range []string{passwordAlgArgon2id, passwordAlgBcrypt} {
+		h := _newTestPasswordHasher(algorithm)
+		pwd, err := h.hash("s3cr3t-p4ssw0rd")
+		if err != nil {
+			t.Fatalf("%s failed [%s]: %s", name, algorithm, err)
+		}
+		if pwd.Algorithm != algorithm || pwd.Hash == "" || pwd.UpdatedAt.IsZero() {
+			t.Fatalf("%s failed [%s]: %#v", name, algorithm, pwd)
+		}
+		if match, needRehash, err := h.verify(pwd, "s3cr3t-p4ssw0rd"); err != nil || !match || needRehash {
+			t.Fatalf("%s failed [%s]: %v/%v/%v", name, algorithm, match, needRehash, err)
+		}
+		if match, _, err := h.verify(pwd, "wrong-password"); err != nil || match {
+			t.Fatalf("%s failed [%s]: wrong password must not match", name, algorithm)
+		}
+		if pwd2, _ := h.hash("s3cr3t-p4ssw0rd"); pwd2.Hash == pwd.Hash {
+			t.Fatalf("%s failed [%s]: hashes must be salted", name, algorithm)
+		}
+	}
+	if match, _, _ := _newTestPasswordHasher(passwordAlgArgon2id).verify(nil, ""); match {
+		t.Fatalf("%s failed: nil hash must not match", name)
+	}
+}
+
+func TestPasswordHasher_needRehash(t *testing.T) {
+	name := "TestPasswordHasher_needRehash"
+	weak := _newTestPasswordHasher(passwordAlgArgon2id)
+	pwd, _ := weak.hash("s3cr3t-p4ssw0rd")
+
+	stronger := _newTestPasswordHasher(passwordAlgArgon2id)
+	stronger.argon2Time = 2
+	if match, needRehash, _ := stronger.verify(pwd, "s3cr3t-p4ssw0rd"); !match || !needRehash {
+		t.Fatalf("%s failed: hash with fewer iterations must be upgraded", name)
+	}
+
+	bcryptHasher := _newTestPasswordHasher(passwordAlgBcrypt)
+	if match, needRehash, _ := bcryptHasher.verify(pwd, "s3cr3t-p4ssw0rd"); !match || !needRehash {
+		t.Fatalf("%s failed: hash must be upgraded when algorithm changes", name)
+	}
+	pwd, _ = bcryptHasher.hash("s3cr3t-p4ssw0rd")
+	bcryptHasher.bcryptCost = 5
+	if match, needRehash, _ := bcryptHasher.verify(pwd, "s3cr3t-p4ssw0rd"); !match || !needRehash {
+		t.Fatalf("%s failed: hash with lower cost must be upgraded", name)
+	}
+}
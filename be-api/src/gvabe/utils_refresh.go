@@ -0,0 +1,267 @@
+package gvabe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/session"
+)
+
+const (
+	sessionTypeRefresh       = "refresh"        // available since v0.8.0: refresh token, see API "refreshLoginToken"
+	sessionTypeRefreshFamily = "refresh_family" // available since v0.8.0: family of refresh tokens rotated from the same login
+)
+
+const (
+	refreshTokenDefaultTtl         = 30 * 24 * time.Hour
+	refreshTokenDefaultMaxLifetime = 90 * 24 * time.Hour
+)
+
+var (
+	// how long a refresh token is valid, 0 disables refresh tokens (available since v0.8.0)
+	refreshTokenTtl = refreshTokenDefaultTtl
+
+	// how long a family of refresh tokens can be refreshed since user logged in, 0 means no limit (available since v0.8.0)
+	refreshTokenMaxLifetime = refreshTokenDefaultMaxLifetime
+)
+
+var (
+	errorRefreshTokenDisabled = errors.New("refresh tokens are disabled")
+	errorRefreshTokenIssued   = errors.New("refresh token has already been issued for the login session")
+	errorRefreshTokenInvalid  = errors.New("invalid or expired refresh token")
+	errorRefreshTokenReused   = errors.New("refresh token has already been used, all tokens of its family have been revoked")
+)
+
+// refreshTokenData is stored as data of a refresh token record.
+//
+// available since v0.8.0
+type refreshTokenData struct {
+	FamilyId string `json:"fid"`            // id of the family the token belongs to
+	Used     bool   `json:"used,omitempty"` // the token has been rotated, presenting it again is a reuse
+}
+
+// refreshFamilyData is stored as data of a refresh token family record. The family record gates all tokens of the
+// family: removing it revokes them all.
+//
+// available since v0.8.0
+type refreshFamilyData struct {
+	SessionId string    `json:"sid"`  // id of the latest login session issued with the family's tokens
+	CreatedAt time.Time `json:"cat"`  // timestamp when user logged in
+	Data      []byte    `json:"data"` // login session's data, encrypted with user's key (see SessionClaims.Data)
+}
+
+// refreshFamilyId returns id of the refresh token family started from a login session: a login session can start
+// at most one family.
+func refreshFamilyId(loginSessId string) string {
+	return sessionTypeRefreshFamily + ":" + loginSessId
+}
+
+// hashRefreshToken returns the id of the record storing a refresh token; only the token's hash is stored.
+func hashRefreshToken(token string) string {
+	return hashEmailLinkToken(token)
+}
+
+// _saveRefreshToken generates a new refresh token of the family and stores it, valid until the expiry.
+func _saveRefreshToken(family *session.Session, expiry time.Time) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	js, _ := json.Marshal(refreshTokenData{FamilyId: family.GetId()})
+	sess := session.NewSession(goapi.AppVersionNumber, hashRefreshToken(token), sessionTypeRefresh, family.GetIdSource(),
+		family.GetAppId(), family.GetUserId(), string(js), expiry)
+	_, err := sessionDao.Save(sess)
+	return token, err
+}
+
+// _refreshTokenExpiry returns the expiry of a refresh token issued at the timestamp: [gvabe.refresh_token.ttl] from
+// now, capped by [gvabe.refresh_token.max_lifetime] since user logged in.
+func _refreshTokenExpiry(loginAt, now time.Time) time.Time {
+	expiry := now.Add(refreshTokenTtl)
+	if refreshTokenMaxLifetime > 0 && expiry.After(loginAt.Add(refreshTokenMaxLifetime)) {
+		expiry = loginAt.Add(refreshTokenMaxLifetime)
+	}
+	return expiry
+}
+
+// issueRefreshToken starts a new family of refresh tokens from a login session and returns its first token.
+// Login tokens issued by refreshLoginToken belong to a family already and can not start another one.
+//
+// available since v0.8.0
+func issueRefreshToken(claims *SessionClaims, now time.Time) (string, error) {
+	if refreshTokenTtl <= 0 {
+		return "", errorRefreshTokenDisabled
+	}
+	if claims.RefreshFamilyId != "" {
+		return "", errorRefreshTokenIssued
+	}
+	fid := refreshFamilyId(claims.Id)
+	if existing, err := sessionDao.Get(fid); err != nil {
+		return "", err
+	} else if existing != nil {
+		return "", errorRefreshTokenIssued
+	}
+	loginAt := time.Unix(claims.IssuedAt, 0)
+	expiry := _refreshTokenExpiry(loginAt, now)
+	js, _ := json.Marshal(refreshFamilyData{SessionId: claims.Id, CreatedAt: loginAt, Data: claims.Data})
+	family := session.NewSession(goapi.AppVersionNumber, fid, sessionTypeRefreshFamily, claims.Subject, claims.Audience, claims.UserId, string(js), expiry)
+	if _, err := sessionDao.Save(family); err != nil {
+		return "", err
+	}
+	return _saveRefreshToken(family, expiry)
+}
+
+// refreshLoginToken exchanges a refresh token for a new login token and a new refresh token of the same family (the
+// presented token is rotated). Presenting a token that has already been rotated revokes the whole family, including
+// the latest login session issued with it. The presented token is rotated only once the new login session has been
+// issued: it remains valid if refreshing fails.
+// The family is also revoked if user has revoked sessions of the app since the login (APIs "logoutAll" and
+// "revokeAppSessions").
+//
+// available since v0.8.0
+func refreshLoginToken(token, appId string, now time.Time) (*SessionClaims, string, string, error) {
+	if refreshTokenTtl <= 0 {
+		return nil, "", "", errorRefreshTokenDisabled
+	}
+	tokenSess, err := sessionDao.Get(hashRefreshToken(token))
+	if err != nil {
+		return nil, "", "", err
+	}
+	if tokenSess == nil || tokenSess.GetSessionType() != sessionTypeRefresh || tokenSess.GetAppId() != appId || tokenSess.IsExpired() {
+		return nil, "", "", errorRefreshTokenInvalid
+	}
+	var tokenData refreshTokenData
+	if err := json.Unmarshal([]byte(tokenSess.GetSessionData()), &tokenData); err != nil {
+		return nil, "", "", errorRefreshTokenInvalid
+	}
+	if tokenData.Used {
+		return nil, "", "", _revokeRefreshFamilyOnError(tokenData.FamilyId, errorRefreshTokenReused)
+	}
+
+	family, err := sessionDao.Get(tokenData.FamilyId)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if family == nil || family.GetSessionType() != sessionTypeRefreshFamily || family.IsExpired() {
+		return nil, "", "", errorRefreshTokenInvalid
+	}
+	var familyData refreshFamilyData
+	if err := json.Unmarshal([]byte(family.GetSessionData()), &familyData); err != nil {
+		return nil, "", "", err
+	}
+	u, err := userDao.Get(family.GetUserId())
+	if err != nil {
+		return nil, "", "", err
+	}
+	if u == nil || u.IsSessionRevoked(appId, familyData.CreatedAt) {
+		return nil, "", "", _revokeRefreshFamilyOnError(family.GetId(), errorSessionRevoked)
+	}
+
+	// a new login session, carrying the authentication of the original login
+	sessData, err := decryptAndUnzip(familyData.Data, []byte(u.GetAesKey()))
+	if err != nil {
+		return nil, "", "", err
+	}
+	sess := &Session{}
+	if err := json.Unmarshal(sessData, sess); err != nil {
+		return nil, "", "", err
+	}
+	sess.CreatedAt = now
+	sess.ExpiredAt = now.Add(loginSessionTtl * time.Second)
+	claims, err := buildLoginClaims("", sess, u)
+	if err != nil {
+		return nil, "", "", err
+	}
+	claims.RefreshFamilyId = family.GetId()
+
+	// delete first: only the request that actually removes the record may rotate the token, concurrent requests
+	// presenting the same token are reuses
+	if ok, err := sessionDao.Delete(tokenSess); err != nil {
+		return nil, "", "", err
+	} else if !ok {
+		return nil, "", "", _revokeRefreshFamilyOnError(tokenData.FamilyId, errorRefreshTokenReused)
+	}
+	_, loginToken, err := saveSession(claims)
+	if err != nil {
+		return nil, "", "", _restoreRefreshTokenOnError(tokenSess, err)
+	}
+	// the rotated token is kept (marked as used) until it expires, so that its reuse can be detected
+	unusedData := tokenSess.GetSessionData()
+	tokenData.Used = true
+	js, _ := json.Marshal(tokenData)
+	if _, err := sessionDao.Save(tokenSess.SetSessionData(string(js))); err != nil {
+		return nil, "", "", _restoreRefreshTokenOnError(tokenSess.SetSessionData(unusedData), err)
+	}
+
+	familyData.SessionId = claims.Id
+	js, _ = json.Marshal(familyData)
+	expiry := _refreshTokenExpiry(familyData.CreatedAt, now)
+	if _, err := sessionDao.Save(family.SetSessionData(string(js)).SetExpiry(expiry)); err != nil {
+		return nil, "", "", _restoreRefreshTokenOnError(tokenSess.SetSessionData(unusedData), err)
+	}
+	refreshToken, err := _saveRefreshToken(family, expiry)
+	if err != nil {
+		return nil, "", "", _restoreRefreshTokenOnError(tokenSess.SetSessionData(unusedData), err)
+	}
+	return claims, loginToken, refreshToken, nil
+}
+
+// _restoreRefreshTokenOnError puts back a refresh token that has been taken for rotation but could not be exchanged,
+// so that client can retry with the same token, and returns the error.
+func _restoreRefreshTokenOnError(tokenSess *session.Session, reason error) error {
+	if _, err := sessionDao.Save(tokenSess); err != nil {
+		log.Println(fmt.Sprintf("[ERROR] Cannot restore refresh token [%s]: %s", tokenSess.GetId(), err))
+	}
+	return reason
+}
+
+// _revokeRefreshFamilyOnError revokes the refresh token family and returns the reason, or the error that prevented the
+// family from being revoked.
+func _revokeRefreshFamilyOnError(fid string, reason error) error {
+	if err := revokeRefreshFamily(fid); err != nil {
+		return err
+	}
+	return reason
+}
+
+// revokeRefreshFamily revokes all refresh tokens of a family, as well as the latest login session issued with them.
+//
+// available since v0.8.0
+func revokeRefreshFamily(fid string) error {
+	family, err := sessionDao.Get(fid)
+	if err != nil || family == nil || family.GetSessionType() != sessionTypeRefreshFamily {
+		return err
+	}
+	if _, err := sessionDao.Delete(family); err != nil {
+		return err
+	}
+	var familyData refreshFamilyData
+	if err := json.Unmarshal([]byte(family.GetSessionData()), &familyData); err != nil || familyData.SessionId == "" {
+		return nil
+	}
+	return revokeSession(&SessionClaims{UserId: family.GetUserId(), StandardClaims: jwt.StandardClaims{Id: familyData.SessionId}})
+}
+
+// revokeLoginSessionAndRefreshFamily revokes the session of a login token and the refresh token family the session
+// belongs to (API "logout").
+//
+// available since v0.8.0
+func revokeLoginSessionAndRefreshFamily(claims *SessionClaims) error {
+	fid := claims.RefreshFamilyId
+	if fid == "" {
+		fid = refreshFamilyId(claims.Id)
+	}
+	if err := revokeRefreshFamily(fid); err != nil {
+		return err
+	}
+	return revokeSession(claims)
+}
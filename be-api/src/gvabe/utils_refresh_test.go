@@ -0,0 +1,186 @@
+package gvabe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/user"
+)
+
+// _testLoginWithRefreshToken saves a login session of the user for the app, created at the timestamp.
+func _testLoginWithRefreshToken(t *testing.T, testName string, u *user.User, appId string, createdAt time.Time) *SessionClaims {
+	claims, err := buildLoginClaims("", &Session{ClientId: appId, Channel: loginChannelLocal, UserId: u.GetId(),
+		DisplayName: "User", CreatedAt: createdAt, ExpiredAt: createdAt.Add(time.Hour)}, u)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, _, err := saveSession(claims); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	return claims
+}
+
+func TestRefreshLoginToken(t *testing.T) {
+	testName := "TestRefreshLoginToken"
+	_, _, teardownKeyset := setupTestKeyset(t, testName)
+	defer teardownKeyset()
+	_, uDao, teardown := setupTestSessionRevocation(time.Minute)
+	defer teardown()
+	u := user.NewUser(0, "user@domain.com")
+	uDao.Create(u)
+	now := time.Now()
+	loginClaims := _testLoginWithRefreshToken(t, testName, u, "app1", now.Add(-time.Minute))
+
+	// a login session can be issued at most one refresh token
+	token1, err := issueRefreshToken(loginClaims, now)
+	if err != nil || token1 == "" {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err := issueRefreshToken(loginClaims, now); err != errorRefreshTokenIssued {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenIssued, err)
+	}
+
+	// refresh tokens are bound to the app
+	if _, _, _, err := refreshLoginToken(token1, "app2", now); err != errorRefreshTokenInvalid {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenInvalid, err)
+	}
+
+	// refreshing returns a new login token and a new refresh token
+	claims2, jwt2, token2, err := refreshLoginToken(token1, "app1", now)
+	if err != nil || token2 == "" || token2 == token1 {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if parsed, err := parseLoginToken(jwt2); err != nil || parsed.Id != claims2.Id || parsed.Id == loginClaims.Id ||
+		parsed.Type != sessionTypeLogin || parsed.UserId != u.GetId() || parsed.Audience != "app1" ||
+		parsed.RefreshFamilyId != refreshFamilyId(loginClaims.Id) || parsed.Subject != loginChannelLocal {
+		t.Fatalf("%s failed: %#v / %s", testName, parsed, err)
+	}
+	if err := checkSessionRevoked(claims2); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	// refreshed login sessions can not start another family
+	if _, err := issueRefreshToken(claims2, now); err != errorRefreshTokenIssued {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenIssued, err)
+	}
+	claims3, _, token3, err := refreshLoginToken(token2, "app1", now)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+
+	// reusing a rotated token revokes the whole family, including the latest login session
+	if _, _, _, err := refreshLoginToken(token1, "app1", now); err != errorRefreshTokenReused {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenReused, err)
+	}
+	if _, _, _, err := refreshLoginToken(token3, "app1", now); err != errorRefreshTokenInvalid {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenInvalid, err)
+	}
+	if err := checkSessionRevoked(claims3); err != errorSessionRevoked {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorSessionRevoked, err)
+	}
+}
+
+func TestRefreshLoginToken_revocation(t *testing.T) {
+	testName := "TestRefreshLoginToken_revocation"
+	_, _, teardownKeyset := setupTestKeyset(t, testName)
+	defer teardownKeyset()
+	_, uDao, teardown := setupTestSessionRevocation(time.Minute)
+	defer teardown()
+	u := user.NewUser(0, "user@domain.com")
+	uDao.Create(u)
+	now := time.Now()
+
+	// logout revokes refresh tokens of the session
+	loginClaims := _testLoginWithRefreshToken(t, testName, u, "app1", now.Add(-time.Minute))
+	token, _ := issueRefreshToken(loginClaims, now)
+	claims, _, token, _ := refreshLoginToken(token, "app1", now)
+	if err := revokeLoginSessionAndRefreshFamily(claims); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, _, _, err := refreshLoginToken(token, "app1", now); err != errorRefreshTokenInvalid {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenInvalid, err)
+	}
+
+	// logoutAll revokes refresh tokens issued from earlier logins
+	loginClaims = _testLoginWithRefreshToken(t, testName, u, "app1", now.Add(-time.Minute))
+	token, _ = issueRefreshToken(loginClaims, now)
+	if err := revokeUserSessions(u, user.SessionsRevokedAllApps, now); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, _, _, err := refreshLoginToken(token, "app1", now); err != errorSessionRevoked {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorSessionRevoked, err)
+	}
+
+	// refresh tokens are disabled
+	defer func(ttl time.Duration) { refreshTokenTtl = ttl }(refreshTokenTtl)
+	refreshTokenTtl = 0
+	loginClaims = _testLoginWithRefreshToken(t, testName, u, "app1", now.Add(time.Minute))
+	if _, err := issueRefreshToken(loginClaims, now); err != errorRefreshTokenDisabled {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorRefreshTokenDisabled, err)
+	}
+}
+
+// failingSessionDao fails to save the next session of a type.
+type failingSessionDao struct {
+	*testSessionDao
+	sessType string
+}
+
+func (dao *failingSessionDao) Save(bo *session.Session) (bool, error) {
+	if bo.GetSessionType() == dao.sessType {
+		dao.sessType = ""
+		return false, errors.New("storage is not available")
+	}
+	return dao.testSessionDao.Save(bo)
+}
+
+func TestRefreshLoginToken_failure(t *testing.T) {
+	testName := "TestRefreshLoginToken_failure"
+	_, _, teardownKeyset := setupTestKeyset(t, testName)
+	defer teardownKeyset()
+	sessDao, uDao, teardown := setupTestSessionRevocation(time.Minute)
+	defer teardown()
+	u := user.NewUser(0, "user@domain.com")
+	uDao.Create(u)
+	now := time.Now()
+	loginClaims := _testLoginWithRefreshToken(t, testName, u, "app1", now.Add(-time.Minute))
+	token, _ := issueRefreshToken(loginClaims, now)
+
+	// the token is not rotated if the new login session can not be saved
+	sessionDao = &failingSessionDao{testSessionDao: sessDao, sessType: sessionTypeLogin}
+	if _, _, _, err := refreshLoginToken(token, "app1", now); err == nil {
+		t.Fatalf("%s failed: expected error", testName)
+	}
+	// nor if the rotated token can not be issued
+	sessionDao = &failingSessionDao{testSessionDao: sessDao, sessType: sessionTypeRefresh}
+	if _, _, _, err := refreshLoginToken(token, "app1", now); err == nil {
+		t.Fatalf("%s failed: expected error", testName)
+	}
+
+	// client retries with the same token
+	sessionDao = sessDao
+	if _, _, _, err := refreshLoginToken(token, "app1", now); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	testName := "TestRefreshTokenExpiry"
+	defer func(ttl, maxLifetime time.Duration) {
+		refreshTokenTtl, refreshTokenMaxLifetime = ttl, maxLifetime
+	}(refreshTokenTtl, refreshTokenMaxLifetime)
+	refreshTokenTtl, refreshTokenMaxLifetime = 24*time.Hour, 72*time.Hour
+	loginAt := time.Now()
+	if v := _refreshTokenExpiry(loginAt, loginAt.Add(time.Hour)); !v.Equal(loginAt.Add(25 * time.Hour)) {
+		t.Fatalf("%s failed: %s", testName, v)
+	}
+	// capped by the maximum lifetime since user logged in
+	if v := _refreshTokenExpiry(loginAt, loginAt.Add(60*time.Hour)); !v.Equal(loginAt.Add(72 * time.Hour)) {
+		t.Fatalf("%s failed: %s", testName, v)
+	}
+	refreshTokenMaxLifetime = 0
+	if v := _refreshTokenExpiry(loginAt, loginAt.Add(60*time.Hour)); !v.Equal(loginAt.Add(84 * time.Hour)) {
+		t.Fatalf("%s failed: %s", testName, v)
+	}
+}
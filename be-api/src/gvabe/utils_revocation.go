@@ -0,0 +1,146 @@
+package gvabe
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"main/src/gvabe/bo/user"
+)
+
+const sessionRevocationDefaultCacheTtl = 30 * time.Second
+
+var (
+	// how long results of session revocation checks are cached, 0 disables caching (available since v0.8.0)
+	sessionRevocationCacheTtl = sessionRevocationDefaultCacheTtl
+
+	// caches results of session revocation checks, indexed by session id (available since v0.8.0)
+	sessionRevocations = &sessionRevocationCache{entries: make(map[string]*sessionRevocationCacheEntry)}
+
+	errorSessionRevoked = errors.New("session has been revoked")
+)
+
+type sessionRevocationCacheEntry struct {
+	userId  string
+	revoked bool
+	expiry  time.Time
+}
+
+// sessionRevocationCache caches results of session revocation checks so that authenticating API calls does not hit the
+// database every time. Revocations made by this instance take effect immediately, those made by other instances
+// sharing the same database take effect once cached results expire.
+//
+// available since v0.8.0
+type sessionRevocationCache struct {
+	lock      sync.RWMutex
+	entries   map[string]*sessionRevocationCacheEntry
+	lastPurge time.Time
+}
+
+// get returns the cached result of the session's revocation check; found is false if there is no (unexpired) result.
+func (c *sessionRevocationCache) get(sessId string, now time.Time) (revoked, found bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if entry := c.entries[sessId]; entry != nil && now.Before(entry.expiry) {
+		return entry.revoked, true
+	}
+	return false, false
+}
+
+// put caches the result of the session's revocation check, expired results are purged along the way.
+func (c *sessionRevocationCache) put(sessId, userId string, revoked bool, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if now.Sub(c.lastPurge) >= ttl {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiry) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPurge = now
+	}
+	c.entries[sessId] = &sessionRevocationCacheEntry{userId: userId, revoked: revoked, expiry: now.Add(ttl)}
+}
+
+// evictUser removes cached results of user's sessions.
+func (c *sessionRevocationCache) evictUser(userId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for k, entry := range c.entries {
+		if entry.userId == userId {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// checkSessionRevoked returns errorSessionRevoked if the session of a login token has been revoked: it has been removed
+// (API "logout"), or user has revoked sessions of the app or all apps (APIs "revokeAppSessions" and "logoutAll")
+// after the session was created. Results are cached for [gvabe.session_revocation.cache_ttl].
+//
+// available since v0.8.0
+func checkSessionRevoked(claims *SessionClaims) error {
+	now := time.Now()
+	revoked, found := sessionRevocations.get(claims.Id, now)
+	if !found {
+		var err error
+		if revoked, err = _isSessionRevoked(claims); err != nil {
+			return err
+		}
+		sessionRevocations.put(claims.Id, claims.UserId, revoked, now, sessionRevocationCacheTtl)
+	}
+	if revoked {
+		return errorSessionRevoked
+	}
+	return nil
+}
+
+func _isSessionRevoked(claims *SessionClaims) (bool, error) {
+	sess, err := sessionDao.Get(claims.Id)
+	if err != nil {
+		return false, err
+	}
+	if sess == nil || sess.IsExpired() {
+		return true, nil
+	}
+	if claims.UserId == "" {
+		return false, nil
+	}
+	u, err := userDao.Get(claims.UserId)
+	if err != nil {
+		return false, err
+	}
+	return u == nil || u.IsSessionRevoked(claims.Audience, time.Unix(claims.IssuedAt, 0)), nil
+}
+
+// revokeSession revokes the session of a login token by removing it from storage.
+//
+// available since v0.8.0
+func revokeSession(claims *SessionClaims) error {
+	sess, err := sessionDao.Get(claims.Id)
+	if err != nil {
+		return err
+	}
+	if sess != nil {
+		if _, err := sessionDao.Delete(sess); err != nil {
+			return err
+		}
+	}
+	sessionRevocations.put(claims.Id, claims.UserId, true, time.Now(), sessionRevocationCacheTtl)
+	return nil
+}
+
+// revokeUserSessions revokes user's sessions of an app (all apps if appId is user.SessionsRevokedAllApps) created at or
+// before the timestamp.
+//
+// available since v0.8.0
+func revokeUserSessions(u *user.User, appId string, now time.Time) error {
+	u.RevokeSessions(appId, now)
+	if _, err := userDao.Update(u); err != nil {
+		return err
+	}
+	sessionRevocations.evictUser(u.GetId())
+	return nil
+}
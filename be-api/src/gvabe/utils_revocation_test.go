@@ -0,0 +1,166 @@
+package gvabe
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/user"
+	"main/src/utils"
+)
+
+// in-memory implementation of user.UserDao
+type testUserDao struct {
+	sync.Mutex
+	users map[string]*user.User
+}
+
+func (dao *testUserDao) Delete(bo *user.User) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	delete(dao.users, bo.GetId())
+	return true, nil
+}
+
+func (dao *testUserDao) Create(bo *user.User) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.users[bo.GetId()] != nil {
+		return false, nil
+	}
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.users[bo.GetId()] = user.NewUserFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+func (dao *testUserDao) Get(id string) (*user.User, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if u := dao.users[id]; u != nil {
+		return user.NewUserFromUbo(u.UniversalBo), nil
+	}
+	return nil, nil
+}
+
+func (dao *testUserDao) Update(bo *user.User) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.users[bo.GetId()] == nil {
+		return false, nil
+	}
+	bo.MarshalJSON()
+	dao.users[bo.GetId()] = user.NewUserFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+// setupTestSessionRevocation replaces session and user storages with in-memory ones and resets the revocation cache;
+// the returned function restores the originals.
+func setupTestSessionRevocation(cacheTtl time.Duration) (*testSessionDao, *testUserDao, func()) {
+	origSessionDao, origUserDao, origTtl, origCache := sessionDao, userDao, sessionRevocationCacheTtl, sessionRevocations
+	sessDao := &testSessionDao{sessions: make(map[string]*session.Session)}
+	uDao := &testUserDao{users: make(map[string]*user.User)}
+	sessionDao, userDao, sessionRevocationCacheTtl = sessDao, uDao, cacheTtl
+	sessionRevocations = &sessionRevocationCache{entries: make(map[string]*sessionRevocationCacheEntry)}
+	return sessDao, uDao, func() {
+		sessionDao, userDao, sessionRevocationCacheTtl, sessionRevocations = origSessionDao, origUserDao, origTtl, origCache
+	}
+}
+
+// _testLoginSession stores a login session of the user for the app, created at the timestamp.
+func _testLoginSession(dao *testSessionDao, userId, appId string, createdAt time.Time) *SessionClaims {
+	claims := &SessionClaims{
+		Type:   sessionTypeLogin,
+		UserId: userId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        utils.UniqueId(),
+			Audience:  appId,
+			IssuedAt:  createdAt.Unix(),
+			ExpiresAt: createdAt.Add(time.Hour).Unix(),
+		},
+	}
+	dao.Save(session.NewSession(0, claims.Id, sessionTypeLogin, "local", appId, userId, "login-token", time.Unix(claims.ExpiresAt, 0)))
+	return claims
+}
+
+func TestCheckSessionRevoked(t *testing.T) {
+	testName := "TestCheckSessionRevoked"
+	sessDao, uDao, teardown := setupTestSessionRevocation(time.Minute)
+	defer teardown()
+	u := user.NewUser(0, "user@domain.com")
+	uDao.Create(u)
+	now := time.Now()
+	claims1 := _testLoginSession(sessDao, u.GetId(), "app1", now.Add(-time.Minute))
+	claims2 := _testLoginSession(sessDao, u.GetId(), "app2", now.Add(-time.Minute))
+	for _, claims := range []*SessionClaims{claims1, claims2} {
+		if err := checkSessionRevoked(claims); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+
+	// logout: the session is removed
+	if err := revokeSession(claims1); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if sess, _ := sessDao.Get(claims1.Id); sess != nil {
+		t.Fatalf("%s failed: session should be removed", testName)
+	}
+	if err := checkSessionRevoked(claims1); err != errorSessionRevoked {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorSessionRevoked, err)
+	}
+
+	// revoking sessions of an app does not affect other apps, nor later sessions
+	claims3 := _testLoginSession(sessDao, u.GetId(), "app1", now.Add(-time.Minute))
+	if err := revokeUserSessions(u, "app1", now); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err := checkSessionRevoked(claims3); err != errorSessionRevoked {
+		t.Fatalf("%s failed: expected %s but received %s", testName, errorSessionRevoked, err)
+	}
+	claims4 := _testLoginSession(sessDao, u.GetId(), "app1", now.Add(time.Minute))
+	for _, claims := range []*SessionClaims{claims2, claims4} {
+		if err := checkSessionRevoked(claims); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+	}
+
+	// logoutAll: sessions of all apps are revoked, cached results are evicted
+	if err := revokeUserSessions(u, user.SessionsRevokedAllApps, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	for _, claims := range []*SessionClaims{claims2, claims4} {
+		if err := checkSessionRevoked(claims); err != errorSessionRevoked {
+			t.Fatalf("%s failed: expected %s but received %s", testName, errorSessionRevoked, err)
+		}
+	}
+}
+
+func TestCheckSessionRevoked_cache(t *testing.T) {
+	testName := "TestCheckSessionRevoked_cache"
+	for _, ttl := range []time.Duration{time.Minute, 0} {
+		sessDao, uDao, teardown := setupTestSessionRevocation(ttl)
+		u := user.NewUser(0, "user@domain.com")
+		uDao.Create(u)
+		claims := _testLoginSession(sessDao, u.GetId(), "app1", time.Now().Add(-time.Minute))
+		if err := checkSessionRevoked(claims); err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+
+		// session revoked by another instance: seen once the cached result expires (immediately if caching is disabled)
+		sessDao.Delete(session.NewSession(0, claims.Id, "", "", "", "", "", time.Time{}))
+		if err := checkSessionRevoked(claims); (ttl > 0 && err != nil) || (ttl == 0 && err != errorSessionRevoked) {
+			t.Fatalf("%s failed: [%s] %s", testName, ttl, err)
+		}
+		if ttl > 0 {
+			sessionRevocations.entries[claims.Id].expiry = time.Now()
+			if err := checkSessionRevoked(claims); err != errorSessionRevoked {
+				t.Fatalf("%s failed: expected %s but received %s", testName, errorSessionRevoked, err)
+			}
+		} else if len(sessionRevocations.entries) != 0 {
+			t.Fatalf("%s failed: results should not be cached", testName)
+		}
+		teardown()
+	}
+}
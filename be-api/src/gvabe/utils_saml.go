@@ -0,0 +1,683 @@
+package gvabe
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
+	dsig "github.com/russellhaering/goxmldsig"
+
+	"main/src/gvabe/bo/app"
+	"main/src/itineris"
+	"main/src/utils"
+)
+
+const (
+	samlNsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlNsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+
+	samlBindingHttpRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	samlBindingHttpPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	samlNameIdFormatEmail       = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	samlNameIdFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	samlNameIdFormatTransient   = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+	samlStatusSuccess           = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlConfirmationBearer      = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	samlTimeFormat = "2006-01-02T15:04:05Z"
+
+	// lifetime of pre-login sessions waiting for the identity provider's response
+	samlRequestTtl = 10 * time.Minute
+
+	// tolerated clock difference between Exter and identity providers
+	samlClockSkew = 3 * time.Minute
+)
+
+var (
+	// SAML identity providers, configured under "gvabe.channels.saml.<name>"
+	samlIdps = make(map[string]*samlIdp)
+)
+
+// samlIdp holds settings of a SAML identity provider and of Exter as the service provider federated with it.
+//
+// available since v0.8.0
+type samlIdp struct {
+	name           string              // name of the identity provider, i.e. <name> in "gvabe.channels.saml.<name>"
+	entityId       string              // identity provider's entity id, expected as issuer of responses and assertions
+	ssoUrl         string              // identity provider's SingleSignOnService url (HTTP-Redirect binding)
+	certs          []*x509.Certificate // identity provider's signing certificates
+	spEntityId     string              // Exter's entity id, expected as audience of assertions
+	acsUrl         string              // Exter's AssertionConsumerService url
+	emailAttribute string              // attribute mapped to user's email, NameID is used if empty
+	nameAttribute  string              // attribute mapped to user's display name (optional)
+}
+
+// samlSessionData is stored as Session.Data of pre-login sessions created by SAML login channel.
+//
+// available since v0.8.0
+type samlSessionData struct {
+	Idp       string `json:"idp"`        // name of the identity provider
+	RequestId string `json:"request_id"` // id of the AuthnRequest sent to the identity provider
+	ReturnUrl string `json:"return_url"` // url to redirect user to once logged in
+}
+
+// samlAssertionInfo captures user's info extracted from a validated SAML assertion.
+//
+// available since v0.8.0
+type samlAssertionInfo struct {
+	Issuer       string              `json:"issuer"`
+	NameId       string              `json:"name_id"`
+	NameIdFormat string              `json:"name_id_format"`
+	SessionIndex string              `json:"session_index"`
+	Attributes   map[string][]string `json:"attributes"`
+}
+
+/*----------------------------------------------------------------------*/
+
+// parseSamlCertificates parses X.509 certificates, either PEM-encoded (one or more blocks) or a single base64-encoded DER certificate
+// as found in metadata documents.
+func parseSamlCertificates(data string) ([]*x509.Certificate, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, errors.New("empty certificate data")
+	}
+	var certs []*x509.Certificate
+	if strings.Contains(data, "-----BEGIN") {
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	} else {
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// samlIdpMetadata captures (part of) the metadata document published by an identity provider.
+type samlIdpMetadata struct {
+	XMLName          xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityId         string   `xml:"entityID,attr"`
+	IdpSsoDescriptor *struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// loadMetadata populates identity provider's entity id, SingleSignOnService url and signing certificates from its metadata document.
+func (idp *samlIdp) loadMetadata(data []byte) error {
+	md := samlIdpMetadata{}
+	if err := xml.Unmarshal(data, &md); err != nil {
+		return err
+	}
+	if md.IdpSsoDescriptor == nil {
+		return errors.New("metadata does not contain IDPSSODescriptor")
+	}
+	idp.entityId = md.EntityId
+	for _, sso := range md.IdpSsoDescriptor.SingleSignOnServices {
+		if sso.Binding == samlBindingHttpRedirect {
+			idp.ssoUrl = sso.Location
+			break
+		}
+	}
+	for _, kd := range md.IdpSsoDescriptor.KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, certData := range kd.Certificates {
+			certs, err := parseSamlCertificates(certData)
+			if err != nil {
+				return err
+			}
+			idp.certs = append(idp.certs, certs...)
+		}
+	}
+	return nil
+}
+
+// fetchSamlIdpMetadata downloads identity provider's metadata document.
+func fetchSamlIdpMetadata(ctx context.Context, metadataUrl string) ([]byte, error) {
+	req, err := http.NewRequest("GET", metadataUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("metadata response status: " + resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// samlSpMetadata is the metadata document that Exter publishes as a service provider.
+type samlSpMetadata struct {
+	XMLName         xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityId        string   `xml:"entityID,attr"`
+	SpSsoDescriptor struct {
+		AuthnRequestsSigned        bool     `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool     `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string   `xml:"protocolSupportEnumeration,attr"`
+		NameIdFormats              []string `xml:"NameIDFormat"`
+		AssertionConsumerService   struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+			Index    int    `xml:"index,attr"`
+		} `xml:"AssertionConsumerService"`
+	} `xml:"SPSSODescriptor"`
+}
+
+// spMetadata generates Exter's service provider metadata document for the identity provider.
+//
+// available since v0.8.0
+func (idp *samlIdp) spMetadata() ([]byte, error) {
+	md := samlSpMetadata{EntityId: idp.spEntityId}
+	md.SpSsoDescriptor.WantAssertionsSigned = true
+	md.SpSsoDescriptor.ProtocolSupportEnumeration = samlNsProtocol
+	md.SpSsoDescriptor.NameIdFormats = []string{samlNameIdFormatEmail, samlNameIdFormatUnspecified}
+	md.SpSsoDescriptor.AssertionConsumerService.Binding = samlBindingHttpPost
+	md.SpSsoDescriptor.AssertionConsumerService.Location = idp.acsUrl
+	data, err := xml.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// samlAuthnRequest is the AuthnRequest message sent to identity providers.
+type samlAuthnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	Id                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	AssertionConsumerServiceUrl string   `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer                      struct {
+		XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+		Value   string   `xml:",chardata"`
+	}
+	NameIdPolicy struct {
+		XMLName     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+		AllowCreate bool     `xml:"AllowCreate,attr"`
+	}
+}
+
+// newSamlRequestId generates a new id for AuthnRequest messages (xs:ID values must not start with a digit).
+func newSamlRequestId() string {
+	return "_" + utils.UniqueId()
+}
+
+// buildAuthnRequestUrl builds the url to send an AuthnRequest to the identity provider using HTTP-Redirect binding.
+//
+// available since v0.8.0
+func (idp *samlIdp) buildAuthnRequestUrl(requestId, relayState string, now time.Time) (string, error) {
+	req := samlAuthnRequest{
+		Id:                          requestId,
+		Version:                     "2.0",
+		IssueInstant:                now.UTC().Format(samlTimeFormat),
+		Destination:                 idp.ssoUrl,
+		ProtocolBinding:             samlBindingHttpPost,
+		AssertionConsumerServiceUrl: idp.acsUrl,
+	}
+	req.Issuer.Value = idp.spEntityId
+	req.NameIdPolicy.AllowCreate = true
+	data, err := xml.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	w, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	if _, err = w.Write(data); err != nil {
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(idp.ssoUrl)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// samlChildElements returns children of an element that match the namespace and (local) tag.
+func samlChildElements(el *etree.Element, ns, tag string) []*etree.Element {
+	var result []*etree.Element
+	for _, child := range el.ChildElements() {
+		if child.Tag == tag && child.NamespaceURI() == ns {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// samlChildElement returns the first child of an element that matches the namespace and (local) tag, nil if not found.
+func samlChildElement(el *etree.Element, ns, tag string) *etree.Element {
+	if el == nil {
+		return nil
+	}
+	if children := samlChildElements(el, ns, tag); len(children) > 0 {
+		return children[0]
+	}
+	return nil
+}
+
+// samlDetachElement returns a copy of an element carrying the namespace declarations inherited from its ancestors,
+// so that the element's signature can be validated independently of the enclosing document.
+func samlDetachElement(el *etree.Element) *etree.Element {
+	detached := el.Copy()
+	declared := make(map[string]bool)
+	for _, attr := range detached.Attr {
+		if attr.Space == "xmlns" || (attr.Space == "" && attr.Key == "xmlns") {
+			declared[attr.FullKey()] = true
+		}
+	}
+	for p := el.Parent(); p != nil; p = p.Parent() {
+		for _, attr := range p.Attr {
+			if (attr.Space == "xmlns" || (attr.Space == "" && attr.Key == "xmlns")) && !declared[attr.FullKey()] {
+				declared[attr.FullKey()] = true
+				detached.CreateAttr(attr.FullKey(), attr.Value)
+			}
+		}
+	}
+	return detached
+}
+
+// parseSamlTime parses a xs:dateTime value, zero time is returned if the input is empty.
+func parseSamlTime(value string) (time.Time, error) {
+	if value = strings.TrimSpace(value); value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseResponse decodes the (base64-encoded) SAML response posted to the ACS url, verifies its signature and conditions,
+// and returns user's info extracted from the assertion.
+//
+// Either the response or the assertion must be signed by one of the identity provider's certificates; only the signed
+// part of the document is used. Encrypted assertions and unsolicited responses are not supported.
+//
+// available since v0.8.0
+func (idp *samlIdp) parseResponse(samlResponse, requestId string, now time.Time) (*samlAssertionInfo, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(samlResponse), ""))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode SAML response: %s", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, fmt.Errorf("cannot parse SAML response: %s", err)
+	}
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" || response.NamespaceURI() != samlNsProtocol {
+		return nil, errors.New("document is not a SAML response")
+	}
+
+	validationCtx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: idp.certs})
+	validationCtx.Clock = dsig.NewFakeClockAt(now)
+	responseSigned := false
+	if samlChildElement(response, dsig.Namespace, "Signature") != nil {
+		if response, err = validationCtx.Validate(response); err != nil {
+			return nil, fmt.Errorf("invalid SAML response signature: %s", err)
+		}
+		responseSigned = true
+	}
+
+	statusCode := samlChildElement(samlChildElement(response, samlNsProtocol, "Status"), samlNsProtocol, "StatusCode")
+	if statusCode == nil || statusCode.SelectAttrValue("Value", "") != samlStatusSuccess {
+		status := ""
+		if statusCode != nil {
+			status = statusCode.SelectAttrValue("Value", "")
+		}
+		return nil, fmt.Errorf("SAML response status is not success: %s", status)
+	}
+	if dest := response.SelectAttrValue("Destination", ""); dest != "" && dest != idp.acsUrl {
+		return nil, fmt.Errorf("SAML response is destined for another recipient: %s", dest)
+	}
+	if inResponseTo := response.SelectAttrValue("InResponseTo", ""); requestId == "" || inResponseTo != requestId {
+		return nil, errors.New("SAML response does not match the authentication request")
+	}
+	if issuer := samlChildElement(response, samlNsAssertion, "Issuer"); issuer != nil && strings.TrimSpace(issuer.Text()) != idp.entityId {
+		return nil, fmt.Errorf("SAML response is issued by unexpected issuer: %s", strings.TrimSpace(issuer.Text()))
+	}
+	if len(samlChildElements(response, samlNsAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted SAML assertions are not supported")
+	}
+	assertions := samlChildElements(response, samlNsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("SAML response must contain exactly one assertion, found %d", len(assertions))
+	}
+	assertion := assertions[0]
+	if samlChildElement(assertion, dsig.Namespace, "Signature") != nil {
+		if assertion, err = validationCtx.Validate(samlDetachElement(assertion)); err != nil {
+			return nil, fmt.Errorf("invalid SAML assertion signature: %s", err)
+		}
+	} else if !responseSigned {
+		return nil, errors.New("neither SAML response nor assertion is signed")
+	}
+	return idp.validateAssertion(assertion, requestId, now)
+}
+
+// validateAssertion verifies issuer, subject confirmation, time conditions and audience of a (signature-verified) assertion.
+func (idp *samlIdp) validateAssertion(assertion *etree.Element, requestId string, now time.Time) (*samlAssertionInfo, error) {
+	info := &samlAssertionInfo{Attributes: make(map[string][]string)}
+	if issuer := samlChildElement(assertion, samlNsAssertion, "Issuer"); issuer != nil {
+		info.Issuer = strings.TrimSpace(issuer.Text())
+	}
+	if info.Issuer != idp.entityId {
+		return nil, fmt.Errorf("SAML assertion is issued by unexpected issuer: %s", info.Issuer)
+	}
+
+	// subject and its bearer confirmation
+	subject := samlChildElement(assertion, samlNsAssertion, "Subject")
+	if subject == nil {
+		return nil, errors.New("SAML assertion does not contain subject")
+	}
+	if nameId := samlChildElement(subject, samlNsAssertion, "NameID"); nameId != nil {
+		info.NameId = strings.TrimSpace(nameId.Text())
+		info.NameIdFormat = nameId.SelectAttrValue("Format", "")
+	}
+	confirmed := false
+	for _, sc := range samlChildElements(subject, samlNsAssertion, "SubjectConfirmation") {
+		scd := samlChildElement(sc, samlNsAssertion, "SubjectConfirmationData")
+		if sc.SelectAttrValue("Method", "") != samlConfirmationBearer || scd == nil {
+			continue
+		}
+		notOnOrAfter, err := parseSamlTime(scd.SelectAttrValue("NotOnOrAfter", ""))
+		if err != nil || notOnOrAfter.IsZero() || !now.Add(-samlClockSkew).Before(notOnOrAfter) {
+			continue
+		}
+		if recipient := scd.SelectAttrValue("Recipient", ""); recipient != idp.acsUrl {
+			continue
+		}
+		if inResponseTo := scd.SelectAttrValue("InResponseTo", ""); inResponseTo != "" && inResponseTo != requestId {
+			continue
+		}
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, errors.New("SAML assertion does not contain a valid bearer subject confirmation")
+	}
+
+	// time and audience conditions
+	conditions := samlChildElement(assertion, samlNsAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("SAML assertion does not contain conditions")
+	}
+	notBefore, err := parseSamlTime(conditions.SelectAttrValue("NotBefore", ""))
+	if err != nil {
+		return nil, err
+	}
+	if !notBefore.IsZero() && now.Add(samlClockSkew).Before(notBefore) {
+		return nil, errors.New("SAML assertion is not yet valid")
+	}
+	notOnOrAfter, err := parseSamlTime(conditions.SelectAttrValue("NotOnOrAfter", ""))
+	if err != nil {
+		return nil, err
+	}
+	if !notOnOrAfter.IsZero() && !now.Add(-samlClockSkew).Before(notOnOrAfter) {
+		return nil, errors.New("SAML assertion has expired")
+	}
+	audienceRestrictions := samlChildElements(conditions, samlNsAssertion, "AudienceRestriction")
+	if len(audienceRestrictions) == 0 {
+		return nil, errors.New("SAML assertion does not contain audience restriction")
+	}
+	for _, ar := range audienceRestrictions {
+		// all audience restrictions must be satisfied
+		found := false
+		for _, audience := range samlChildElements(ar, samlNsAssertion, "Audience") {
+			if strings.TrimSpace(audience.Text()) == idp.spEntityId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("SAML assertion is not issued for audience [%s]", idp.spEntityId)
+		}
+	}
+
+	// session index and attributes
+	if authnStatement := samlChildElement(assertion, samlNsAssertion, "AuthnStatement"); authnStatement != nil {
+		info.SessionIndex = authnStatement.SelectAttrValue("SessionIndex", "")
+	}
+	for _, as := range samlChildElements(assertion, samlNsAssertion, "AttributeStatement") {
+		for _, attr := range samlChildElements(as, samlNsAssertion, "Attribute") {
+			name := attr.SelectAttrValue("Name", "")
+			for _, value := range samlChildElements(attr, samlNsAssertion, "AttributeValue") {
+				info.Attributes[name] = append(info.Attributes[name], strings.TrimSpace(value.Text()))
+			}
+		}
+	}
+	return info, nil
+}
+
+// extractEmail returns user's email address from the assertion, either the NameID or the configured attribute.
+//
+// available since v0.8.0
+func (idp *samlIdp) extractEmail(info *samlAssertionInfo) string {
+	candidates := []string{info.NameId}
+	if idp.emailAttribute != "" {
+		candidates = info.Attributes[idp.emailAttribute]
+	}
+	for _, v := range candidates {
+		if v = strings.TrimSpace(v); strings.Contains(v, "@") {
+			return v
+		}
+	}
+	return ""
+}
+
+// extractName returns user's display name from the configured attribute, empty string if not available.
+func (idp *samlIdp) extractName(info *samlAssertionInfo) string {
+	if idp.nameAttribute == "" {
+		return ""
+	}
+	for _, v := range info.Attributes[idp.nameAttribute] {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// loadSamlPreLoginSession loads the pre-login session created when the AuthnRequest was sent to the identity provider.
+// The session's id is passed through the identity provider as RelayState.
+//
+// available since v0.8.0
+func loadSamlPreLoginSession(sessId, idpName string) (*Session, *samlSessionData, error) {
+	sess, err := loadPreLoginSession(sessId, loginChannelSaml)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessData := &samlSessionData{}
+	if err := json.Unmarshal(sess.Data, sessData); err != nil {
+		return nil, nil, err
+	}
+	if sessData.Idp != idpName {
+		return nil, nil, fmt.Errorf("session is not created for SAML identity provider [%s]", idpName)
+	}
+	return sess, sessData, nil
+}
+
+// samlLoginChannel implements LoginChannel for SAML identity providers, each is configured under "gvabe.channels.saml.<name>".
+//
+// available since v0.8.0
+type samlLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *samlLoginChannel) Init(conf *hocon.Config) error {
+	confV := conf.GetValue("gvabe.channels.saml")
+	if confV == nil || !confV.IsObject() {
+		return errors.New("no SAML identity provider defined at [gvabe.channels.saml]")
+	}
+	for name, idpV := range confV.GetObject().Items() {
+		if !idpV.IsObject() {
+			continue
+		}
+		confPrefix := "gvabe.channels.saml." + name
+		idp := &samlIdp{name: name}
+		metadata := strings.TrimSpace(conf.GetString(confPrefix + ".idp_metadata"))
+		if metadataUrl := strings.TrimSpace(conf.GetString(confPrefix + ".idp_metadata_url")); metadata == "" && metadataUrl != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if data, err := fetchSamlIdpMetadata(ctx, metadataUrl); err != nil {
+				log.Println(fmt.Sprintf("[ERROR] Cannot load SAML metadata of identity provider [%s] from [%s]: %e", name, metadataUrl, err))
+			} else {
+				metadata = string(data)
+			}
+			cancel()
+		}
+		if metadata != "" {
+			if err := idp.loadMetadata([]byte(metadata)); err != nil {
+				log.Println(fmt.Sprintf("[ERROR] Cannot parse SAML metadata of identity provider [%s]: %e", name, err))
+				continue
+			}
+		}
+		// explicit settings take precedence over metadata
+		if entityId := strings.TrimSpace(conf.GetString(confPrefix + ".idp_entity_id")); entityId != "" {
+			idp.entityId = entityId
+		}
+		if ssoUrl := strings.TrimSpace(conf.GetString(confPrefix + ".idp_sso_url")); ssoUrl != "" {
+			idp.ssoUrl = ssoUrl
+		}
+		if certData := strings.TrimSpace(conf.GetString(confPrefix + ".idp_certificate")); certData != "" {
+			certs, err := parseSamlCertificates(certData)
+			if err != nil {
+				log.Println(fmt.Sprintf("[ERROR] Cannot parse SAML certificate at [%s.idp_certificate]: %e", confPrefix, err))
+				continue
+			}
+			idp.certs = certs
+		}
+		idp.acsUrl = strings.TrimSpace(conf.GetString(confPrefix + ".acs_url"))
+		idp.spEntityId = strings.TrimSpace(conf.GetString(confPrefix + ".sp_entity_id"))
+		if idp.spEntityId == "" {
+			idp.spEntityId = idp.acsUrl
+		}
+		idp.emailAttribute = strings.TrimSpace(conf.GetString(confPrefix + ".email_attribute"))
+		idp.nameAttribute = strings.TrimSpace(conf.GetString(confPrefix + ".name_attribute"))
+		if idp.entityId == "" {
+			log.Println(fmt.Sprintf("[ERROR] No valid SAML identity provider's entity-id defined at [%s.idp_entity_id] or in metadata", confPrefix))
+			continue
+		}
+		if idp.ssoUrl == "" {
+			log.Println(fmt.Sprintf("[ERROR] No valid SAML identity provider's SSO url defined at [%s.idp_sso_url] or in metadata", confPrefix))
+			continue
+		}
+		if len(idp.certs) == 0 {
+			log.Println(fmt.Sprintf("[ERROR] No valid SAML identity provider's certificate defined at [%s.idp_certificate] or in metadata", confPrefix))
+			continue
+		}
+		if idp.acsUrl == "" {
+			log.Println(fmt.Sprintf("[ERROR] No valid SAML ACS url defined at [%s.acs_url]", confPrefix))
+			continue
+		}
+		samlIdps[name] = idp
+		if DEBUG {
+			log.Printf("[DEBUG] samlLoginChannel.Init - %s: %s/%s/%s/%s", name, idp.entityId, idp.ssoUrl, idp.spEntityId, idp.acsUrl)
+		}
+	}
+	if len(samlIdps) == 0 {
+		return errors.New("no valid SAML identity provider defined at [gvabe.channels.saml]")
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *samlLoginChannel) Info() map[string]interface{} {
+	samlIdpNames := make([]string, 0)
+	for name := range samlIdps {
+		samlIdpNames = append(samlIdpNames, name)
+	}
+	sort.Strings(samlIdpNames)
+	return map[string]interface{}{"saml_idps": samlIdpNames}
+}
+
+// Login implements LoginHandler.Login.
+func (ch *samlLoginChannel) Login(ctx *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	idpName := _extractParam(params, "idp", reddo.TypeString, "", nil).(string)
+	idp := samlIdps[idpName]
+	if idp == nil {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage(fmt.Sprintf("SAML identity provider is not supported: %s", idpName))
+	}
+	now := time.Now()
+	requestId := newSamlRequestId()
+	js, _ := json.Marshal(samlSessionData{Idp: idpName, RequestId: requestId, ReturnUrl: returnUrl})
+	claims, err := genPreLoginClaims(&Session{
+		ClientId:   app.GetId(),
+		Channel:    loginChannelSaml,
+		CreatedAt:  now,
+		ExpiredAt:  now.Add(samlRequestTtl),
+		Data:       js, // JSON-serialization of samlSessionData
+		LinkUserId: _linkUserIdFromContext(ctx),
+	})
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	// pre-login session's id is passed through the identity provider as RelayState
+	redirectUrl, err := idp.buildAuthnRequestUrl(requestId, claims.Id, now)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(jwt).SetExtras(map[string]interface{}{apiResultExtraRedirectUrl: redirectUrl})
+}
@@ -0,0 +1,246 @@
+package gvabe
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const _samlTestResponseTemplate = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp1" Version="2.0" IssueInstant="%[1]s" Destination="%[2]s" InResponseTo="%[3]s">
+<saml:Issuer>https://idp.example.com</saml:Issuer>
+<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_assert1" Version="2.0" IssueInstant="%[1]s">
+<saml:Issuer>https://idp.example.com</saml:Issuer>
+<saml:Subject>
+<saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">user1@example.com</saml:NameID>
+<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData NotOnOrAfter="%[4]s" Recipient="%[2]s" InResponseTo="%[3]s"/></saml:SubjectConfirmation>
+</saml:Subject>
+<saml:Conditions NotBefore="%[1]s" NotOnOrAfter="%[4]s"><saml:AudienceRestriction><saml:Audience>%[5]s</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+<saml:AuthnStatement AuthnInstant="%[1]s" SessionIndex="_session1"/>
+<saml:AttributeStatement>
+<saml:Attribute Name="mail"><saml:AttributeValue>user1.alt@example.com</saml:AttributeValue></saml:Attribute>
+<saml:Attribute Name="displayName"><saml:AttributeValue>User One</saml:AttributeValue></saml:Attribute>
+</saml:AttributeStatement>
+</saml:Assertion>
+</samlp:Response>`
+
+func _newSamlTestIdp(t *testing.T) (*samlIdp, dsig.X509KeyStore) {
+	ks := dsig.RandomKeyStoreForTest()
+	_, certDer, err := ks.GetKeyPair()
+	if err != nil {
+		t.Fatalf("cannot get test key pair: %s", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("cannot parse test certificate: %s", err)
+	}
+	idp := &samlIdp{
+		name:       "test",
+		entityId:   "https://idp.example.com",
+		ssoUrl:     "https://idp.example.com/sso?tenant=1",
+		certs:      []*x509.Certificate{cert},
+		spEntityId: "https://exter.example.com/api/saml/test/metadata",
+		acsUrl:     "https://exter.example.com/api/saml/test/acs",
+	}
+	return idp, ks
+}
+
+// _genSamlTestResponse builds a base64-encoded SAML response; signTarget is one of "assertion", "response" or "" (unsigned).
+func _genSamlTestResponse(t *testing.T, ks dsig.X509KeyStore, signTarget, requestId, audience string, issueInstant time.Time) string {
+	doc := etree.NewDocument()
+	xmlStr := fmt.Sprintf(_samlTestResponseTemplate, issueInstant.UTC().Format(samlTimeFormat), "https://exter.example.com/api/saml/test/acs",
+		requestId, issueInstant.Add(5*time.Minute).UTC().Format(samlTimeFormat), audience)
+	if err := doc.ReadFromString(xmlStr); err != nil {
+		t.Fatalf("cannot parse test response: %s", err)
+	}
+	signingCtx := dsig.NewDefaultSigningContext(ks)
+	signingCtx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	switch signTarget {
+	case "assertion":
+		response := doc.Root()
+		assertion := response.SelectElement("Assertion")
+		signed, err := signingCtx.SignEnveloped(assertion)
+		if err != nil {
+			t.Fatalf("cannot sign test assertion: %s", err)
+		}
+		response.RemoveChild(assertion)
+		response.AddChild(signed)
+	case "response":
+		signed, err := signingCtx.SignEnveloped(doc.Root())
+		if err != nil {
+			t.Fatalf("cannot sign test response: %s", err)
+		}
+		doc.SetRoot(signed)
+	}
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatalf("cannot serialize test response: %s", err)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestSamlIdp_parseResponse(t *testing.T) {
+	testName := "TestSamlIdp_parseResponse"
+	idp, ks := _newSamlTestIdp(t)
+	now := time.Now()
+	for _, signTarget := range []string{"assertion", "response"} {
+		samlResponse := _genSamlTestResponse(t, ks, signTarget, "_req1", idp.spEntityId, now)
+		info, err := idp.parseResponse(samlResponse, "_req1", now)
+		if err != nil {
+			t.Fatalf("%s failed (signed %s): %s", testName, signTarget, err)
+		}
+		if info.NameId != "user1@example.com" || info.SessionIndex != "_session1" {
+			t.Fatalf("%s failed (signed %s): %#v", testName, signTarget, info)
+		}
+		if email := idp.extractEmail(info); email != "user1@example.com" {
+			t.Fatalf("%s failed: expected email %#v but received %#v", testName, "user1@example.com", email)
+		}
+	}
+
+	samlResponse := _genSamlTestResponse(t, ks, "assertion", "_req1", idp.spEntityId, now)
+	if _, err := idp.parseResponse(samlResponse, "_req2", now); err == nil {
+		t.Fatalf("%s failed: expected error for request id mismatch", testName)
+	}
+	if _, err := idp.parseResponse(samlResponse, "_req1", now.Add(10*time.Minute)); err == nil {
+		t.Fatalf("%s failed: expected error for expired assertion", testName)
+	}
+	if _, err := idp.parseResponse(samlResponse, "_req1", now.Add(-10*time.Minute)); err == nil {
+		t.Fatalf("%s failed: expected error for not yet valid assertion", testName)
+	}
+	if _, err := idp.parseResponse(_genSamlTestResponse(t, ks, "assertion", "_req1", "https://another.example.com", now), "_req1", now); err == nil {
+		t.Fatalf("%s failed: expected error for wrong audience", testName)
+	}
+	if _, err := idp.parseResponse(_genSamlTestResponse(t, ks, "", "_req1", idp.spEntityId, now), "_req1", now); err == nil {
+		t.Fatalf("%s failed: expected error for unsigned response", testName)
+	}
+	anotherIdp, _ := _newSamlTestIdp(t)
+	if _, err := anotherIdp.parseResponse(samlResponse, "_req1", now); err == nil {
+		t.Fatalf("%s failed: expected error for signature by unknown certificate", testName)
+	}
+
+	// tampered assertion must be rejected
+	raw, _ := base64.StdEncoding.DecodeString(samlResponse)
+	tampered := strings.Replace(string(raw), "user1@example.com", "admin@example.com", 1)
+	if _, err := idp.parseResponse(base64.StdEncoding.EncodeToString([]byte(tampered)), "_req1", now); err == nil {
+		t.Fatalf("%s failed: expected error for tampered assertion", testName)
+	}
+}
+
+func TestSamlIdp_extractEmail(t *testing.T) {
+	testName := "TestSamlIdp_extractEmail"
+	info := &samlAssertionInfo{
+		NameId:     "user1",
+		Attributes: map[string][]string{"mail": {"user1@example.com"}, "displayName": {"User One"}},
+	}
+	idp := &samlIdp{}
+	if email := idp.extractEmail(info); email != "" {
+		t.Fatalf("%s failed: NameID is not an email address, but received %#v", testName, email)
+	}
+	idp.emailAttribute = "mail"
+	idp.nameAttribute = "displayName"
+	if email := idp.extractEmail(info); email != "user1@example.com" {
+		t.Fatalf("%s failed: expected email %#v but received %#v", testName, "user1@example.com", email)
+	}
+	if name := idp.extractName(info); name != "User One" {
+		t.Fatalf("%s failed: expected name %#v but received %#v", testName, "User One", name)
+	}
+}
+
+func TestSamlIdp_buildAuthnRequestUrl(t *testing.T) {
+	testName := "TestSamlIdp_buildAuthnRequestUrl"
+	idp, _ := _newSamlTestIdp(t)
+	redirectUrl, err := idp.buildAuthnRequestUrl("_req1", "sess1", time.Now())
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	u, _ := url.Parse(redirectUrl)
+	query := u.Query()
+	if query.Get("tenant") != "1" || query.Get("RelayState") != "sess1" {
+		t.Fatalf("%s failed: %s", testName, redirectUrl)
+	}
+	deflated, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	req := doc.Root()
+	if req.Tag != "AuthnRequest" || req.NamespaceURI() != samlNsProtocol {
+		t.Fatalf("%s failed: %s", testName, data)
+	}
+	expected := map[string]string{"ID": "_req1", "Destination": idp.ssoUrl, "AssertionConsumerServiceURL": idp.acsUrl}
+	for k, v := range expected {
+		if req.SelectAttrValue(k, "") != v {
+			t.Fatalf("%s failed: expected attribute %s to be %#v but received %#v", testName, k, v, req.SelectAttrValue(k, ""))
+		}
+	}
+	if issuer := samlChildElement(req, samlNsAssertion, "Issuer"); issuer == nil || issuer.Text() != idp.spEntityId {
+		t.Fatalf("%s failed: %s", testName, data)
+	}
+}
+
+func TestSamlIdp_loadMetadata(t *testing.T) {
+	testName := "TestSamlIdp_loadMetadata"
+	idp, _ := _newSamlTestIdp(t)
+	certB64 := base64.StdEncoding.EncodeToString(idp.certs[0].Raw)
+	metadata := `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.example.com/metadata">
+<md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+<md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>invalid</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+<md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + certB64 + `</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+<md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso/redirect"/>
+</md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+	loaded := &samlIdp{}
+	if err := loaded.loadMetadata([]byte(metadata)); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if loaded.entityId != "https://idp.example.com/metadata" || loaded.ssoUrl != "https://idp.example.com/sso/redirect" {
+		t.Fatalf("%s failed: %#v", testName, loaded)
+	}
+	if len(loaded.certs) != 1 || !loaded.certs[0].Equal(idp.certs[0]) {
+		t.Fatalf("%s failed: expected signing certificate to be loaded", testName)
+	}
+
+	pemCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.certs[0].Raw}))
+	if certs, err := parseSamlCertificates(pemCert); err != nil || len(certs) != 1 {
+		t.Fatalf("%s failed: cannot parse PEM certificate: %s", testName, err)
+	}
+}
+
+func TestSamlIdp_spMetadata(t *testing.T) {
+	testName := "TestSamlIdp_spMetadata"
+	idp, _ := _newSamlTestIdp(t)
+	data, err := idp.spMetadata()
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if entityId := doc.Root().SelectAttrValue("entityID", ""); entityId != idp.spEntityId {
+		t.Fatalf("%s failed: expected entityID %#v but received %#v", testName, idp.spEntityId, entityId)
+	}
+	acs := doc.FindElement("//AssertionConsumerService")
+	if acs == nil || acs.SelectAttrValue("Location", "") != idp.acsUrl || acs.SelectAttrValue("Binding", "") != samlBindingHttpPost {
+		t.Fatalf("%s failed: %s", testName, data)
+	}
+}
@@ -4,14 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/btnguyen2k/consu/gjrc"
 	"github.com/btnguyen2k/consu/reddo"
 	"github.com/btnguyen2k/consu/semita"
 	"github.com/dgrijalva/jwt-go"
-	"github.com/google/go-github/github"
 	goauthv2 "google.golang.org/api/oauth2/v2"
 
 	"main/src/goapi"
@@ -22,7 +23,31 @@ import (
 
 const (
 	sessionTypePreLogin = "pre_login"
+	sessionTypeMfa      = "mfa" // available since v0.8.0: first factor passed, waiting for multi-factor authentication
 	sessionTypeLogin    = "login"
+	sessionTypeFailed   = "failed" // available since v0.8.0: login failed while the pre-login session was being upgraded
+)
+
+const (
+	loginWaitDefaultTimeout      = 30 * time.Second
+	loginWaitDefaultPollInterval = 1 * time.Second
+
+	loginSessionDefaultMaxLifetime = 24 * time.Hour
+)
+
+var (
+	// maximum time API "waitLoginToken" waits for a pre-login session to complete (available since v0.8.0)
+	loginWaitTimeout = loginWaitDefaultTimeout
+
+	// how often API "waitLoginToken" checks the pre-login session (available since v0.8.0)
+	loginWaitPollInterval = loginWaitDefaultPollInterval
+
+	// wakes up API "waitLoginToken" calls as soon as this instance saves the session they wait for (available since v0.8.0)
+	loginWaiters = &sessionWaiters{waiters: make(map[string]map[chan bool]bool)}
+
+	// login sessions of Exter's frontend are renewed up to this period since user logged in, 0 disables renewal
+	// (available since v0.8.0)
+	loginSessionMaxLifetime = loginSessionDefaultMaxLifetime
 )
 
 var (
@@ -33,21 +58,36 @@ var (
 
 // Session captures a user-login-session. Session object is to be serialized and embedded into a SessionClaims.
 type Session struct {
-	ClientId    string    `json:"cid"`  // application's id
-	Channel     string    `json:"chan"` // login source/channel (Google, Facebook, etc)
-	UserId      string    `json:"uid"`  // id of logged-in user
-	DisplayName string    `json:"name"` // display name of logged-in user
-	CreatedAt   time.Time `json:"cat"`  // timestamp when the session is created
-	ExpiredAt   time.Time `json:"eat"`  // timestamp when the session expires
-	Data        []byte    `json:"data"` // session's arbitrary data
+	ClientId    string    `json:"cid"`            // application's id
+	Channel     string    `json:"chan"`           // login source/channel (Google, Facebook, etc)
+	UserId      string    `json:"uid"`            // id of logged-in user
+	DisplayName string    `json:"name"`           // display name of logged-in user
+	CreatedAt   time.Time `json:"cat"`            // timestamp when the session is created
+	ExpiredAt   time.Time `json:"eat"`            // timestamp when the session expires
+	Data        []byte    `json:"data"`           // session's arbitrary data
+	Amr         []string  `json:"amr,omitempty"`  // (since v0.8.0) authentication methods used to login
+	Acr         string    `json:"acr,omitempty"`  // (since v0.8.0) authentication context class
+	LinkUserId  string    `json:"luid,omitempty"` // (since v0.8.0) id of user the login identity is to be linked to (see API "identityLink")
+	Error       string    `json:"err,omitempty"`  // (since v0.8.0) reason why login failed (session type "failed")
 }
 
 // SessionClaims is an extended structure of JWT's standard claims
 type SessionClaims struct {
-	Type            string `json:"type"`           // session type (pre-login or logged-in)
-	UserId          string `json:"uid,omitempty"`  // id of logged-in user
-	UserDisplayName string `json:"name,omitempty"` // display name of logged-in user
-	Data            []byte `json:"data,omitempty"` // session's arbitrary data
+	Type            string   `json:"type"`           // session type (pre-login, mfa or logged-in)
+	UserId          string   `json:"uid,omitempty"`  // id of logged-in user
+	UserDisplayName string   `json:"name,omitempty"` // display name of logged-in user
+	Data            []byte   `json:"data,omitempty"` // session's arbitrary data
+	Amr             []string `json:"amr,omitempty"`  // (since v0.8.0) authentication methods used to login
+	Acr             string   `json:"acr,omitempty"`  // (since v0.8.0) authentication context class
+
+	// (since v0.8.0) optional profile claims of login tokens, omitted if unknown
+	Picture    string `json:"picture,omitempty"`     // url of user's avatar picture
+	Locale     string `json:"locale,omitempty"`      // user's preferred locale
+	GivenName  string `json:"given_name,omitempty"`  // user's given name
+	FamilyName string `json:"family_name,omitempty"` // user's family name
+
+	RefreshFamilyId string `json:"rfid,omitempty"` // (since v0.8.0) family of the refresh token the login token was issued with (see API "refreshLoginToken")
+
 	jwt.StandardClaims
 }
 
@@ -70,146 +110,374 @@ func saveSession(claims *SessionClaims) (*session.Session, string, error) {
 	}
 	expiry := time.Unix(claims.ExpiresAt, 0)
 	sess := session.NewSession(goapi.AppVersionNumber, claims.Id, claims.Type, claims.Subject, claims.Audience, claims.UserId, jwt, expiry)
-	_, err = sessionDao.Save(sess)
+	if _, err = sessionDao.Save(sess); err == nil {
+		loginWaiters.notify(claims.Id)
+	}
 	return sess, jwt, err
 }
 
+// renewLoginSession extends a login session that is going to expire: the session record is updated with a new login
+// token, valid for another loginSessionTtl but not beyond [gvabe.login_session.max_lifetime] since user logged in.
+// The new login token is returned, or empty string if the session can not be extended any further.
+//
+// available since v0.8.0
+func renewLoginSession(claims *SessionClaims, now time.Time) (string, error) {
+	if claims.Type != sessionTypeLogin || loginSessionMaxLifetime <= 0 {
+		return "", nil
+	}
+	expiry := now.Add(loginSessionTtl * time.Second)
+	if maxExpiry := time.Unix(claims.IssuedAt, 0).Add(loginSessionMaxLifetime); expiry.After(maxExpiry) {
+		expiry = maxExpiry
+	}
+	if expiry.Unix() <= claims.ExpiresAt {
+		return "", nil
+	}
+	// the session must still exist: renewing must not bring back a session that has just been revoked
+	sess, err := sessionDao.Get(claims.Id)
+	if err != nil || sess == nil || sess.IsExpired() || sess.GetSessionType() != sessionTypeLogin {
+		return "", err
+	}
+	renewed := *claims
+	renewed.ExpiresAt = expiry.Unix()
+	_, jwt, err := saveSession(&renewed)
+	return jwt, err
+}
+
 /*----------------------------------------------------------------------*/
 
-func createUserAccountFromFacebookProfile(profile map[string]interface{}) (*user.User, error) {
+// available since v0.8.0
+func loginIdentityFromFacebookProfile(profile map[string]interface{}) (*LoginIdentity, error) {
 	s := semita.NewSemita(profile)
-	if email, err := s.GetValueOfType("email", reddo.TypeString); err != nil {
+	email, err := s.GetValueOfType("email", reddo.TypeString)
+	if err != nil {
 		return nil, err
 	} else if strings.TrimSpace(email.(string)) == "" {
 		return nil, errors.New("facebook profile does not contain email address")
-	} else {
-		var u *user.User
-		var err error
-		email = strings.TrimSpace(email.(string))
-		if u, err = userDao.Get(email.(string)); err == nil && u == nil {
-			u = user.NewUser(goapi.AppVersionNumber, email.(string))
-			var ok bool
-			if ok, err = userDao.Create(u); err != nil || !ok {
-				u = nil
-			}
+	}
+	// Facebook returns only the primary email address confirmed by user
+	ident := &LoginIdentity{Provider: loginChannelFacebook, Email: strings.TrimSpace(email.(string)), EmailVerified: true}
+	if id, err := s.GetValueOfType("id", reddo.TypeString); err == nil && id != nil {
+		ident.Subject = id.(string)
+	}
+	// since v0.4.0: fetch display name from Facebook profile
+	if name, err := s.GetValueOfType("name", reddo.TypeString); err == nil && name != nil {
+		ident.DisplayName = name.(string)
+	}
+	// since v0.8.0: fetch profile attributes from Facebook profile
+	attrList := []string{"first_name", "last_name", "picture.data.url"}
+	targetList := []*string{&ident.GivenName, &ident.FamilyName, &ident.AvatarUrl}
+	for i, attr := range attrList {
+		if v, err := s.GetValueOfType(attr, reddo.TypeString); err == nil && v != nil {
+			*targetList[i] = v.(string)
 		}
-		// since v0.4.0: fetch display name from Facebook profile
-		if err == nil && u != nil && u.GetDisplayName() == "" {
-			name, err := s.GetValueOfType("name", reddo.TypeString)
-			if err == nil && strings.TrimSpace(name.(string)) != "" {
-				u.SetDisplayName(name.(string))
-			} else {
-				u.SetDisplayName(extractNameFromEmailAddress(email.(string)))
-			}
-			_, err = userDao.Update(u)
+	}
+	return ident, nil
+}
+
+// available since v0.8.0
+func loginIdentityFromLinkedInProfile(lu *linkedinUser) (*LoginIdentity, error) {
+	return &LoginIdentity{
+		Provider:      loginChannelLinkedin,
+		Subject:       lu.Id,
+		Email:         lu.Email,
+		EmailVerified: lu.Email != "", // LinkedIn returns only the primary email address confirmed by user
+		DisplayName:   strings.TrimSpace(lu.FirstName + " " + lu.LastName),
+		GivenName:     lu.FirstName,
+		FamilyName:    lu.LastName,
+	}, nil
+}
+
+// loginIdentityFromGitHubProfile builds login identity from user's GitHub profile and email addresses (see githubProfile):
+//   - the profile's public email address is used if it is verified, so that existing accounts keep being found;
+//   - otherwise user's primary verified email address is used, which also covers users who keep their email private;
+//   - lastly the profile's public email address is used as unverified.
+//
+// available since v0.8.0
+func loginIdentityFromGitHubProfile(gp *githubProfile) (*LoginIdentity, error) {
+	ui := gp.User
+	publicEmail := ""
+	if ui.Email != nil {
+		publicEmail = strings.TrimSpace(*ui.Email)
+	}
+	ident := &LoginIdentity{Provider: loginChannelGithub, Email: publicEmail}
+	primaryEmail := ""
+	for _, ue := range gp.Emails {
+		if ue == nil || ue.Email == nil || ue.Verified == nil || !*ue.Verified {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(*ue.Email), publicEmail) {
+			ident.EmailVerified = true
 		}
-		return u, err
+		if ue.Primary != nil && *ue.Primary {
+			primaryEmail = strings.TrimSpace(*ue.Email)
+		}
+	}
+	if !ident.EmailVerified && primaryEmail != "" {
+		ident.Email, ident.EmailVerified = primaryEmail, true
 	}
+	if ident.Email == "" {
+		return nil, errors.New("github profile does not contain email address")
+	}
+	if ui.ID != nil {
+		ident.Subject = strconv.FormatInt(*ui.ID, 10)
+	}
+	// since v0.4.0: fetch display name from GitHub profile
+	if ui.Name != nil {
+		ident.DisplayName = *ui.Name
+	}
+	if ui.AvatarURL != nil {
+		ident.AvatarUrl = *ui.AvatarURL
+	}
+	return ident, nil
 }
 
-func createUserAccountFromLinkedInProfile(gjrcClient *gjrc.Gjrc) (*user.User, error) {
-	var u *user.User
-	var err error
-	var email interface{}
+// available since v0.8.0
+func loginIdentityFromGoogleProfile(ui *goauthv2.Userinfo) (*LoginIdentity, error) {
+	return &LoginIdentity{Provider: loginChannelGoogle, Subject: ui.Id, Email: ui.Email, DisplayName: ui.Name,
+		EmailVerified: ui.VerifiedEmail != nil && *ui.VerifiedEmail,
+		AvatarUrl:     ui.Picture, Locale: ui.Locale, GivenName: ui.GivenName, FamilyName: ui.FamilyName}, nil
+}
 
-	// fetch email address
-	respEmail := gjrcClient.Get("https://api.linkedin.com/v2/emailAddress?q=members&projection=(elements*(handle~))")
-	if respEmail.Error() != nil {
-		return nil, respEmail.Error()
+// available since v0.8.0
+func loginIdentityFromTwitterProfile(tu *twitterUser) (*LoginIdentity, error) {
+	// Twitter does not always return an email address (e.g. app is not granted "users.email" scope, or user has no confirmed email),
+	// in which case the identity is keyed by Twitter's immutable user-id only (see userIdFromLoginIdentity).
+	email := strings.TrimSpace(tu.ConfirmedEmail)
+	displayName := tu.Name
+	if strings.TrimSpace(displayName) == "" {
+		displayName = tu.Username
+	}
+	return &LoginIdentity{Provider: loginChannelTwitter, Subject: tu.Id, Email: email, DisplayName: displayName,
+		EmailVerified: email != "", AvatarUrl: tu.ProfileImageUrl}, nil
+}
+
+// available since v0.8.0
+func loginIdentityFromGitLabProfile(gu *gitlabUser) (*LoginIdentity, error) {
+	if gu.State != "" && gu.State != "active" {
+		return nil, fmt.Errorf("gitlab account is not active (state: %s)", gu.State)
+	}
+	email := gu.verifiedEmail()
+	if email == "" {
+		return nil, errors.New("gitlab profile does not contain a verified email address")
 	}
-	if email, err = respEmail.GetValueAsType("elements[0].handle~.emailAddress", reddo.TypeString); err != nil {
-		return nil, respEmail.Error()
-	} else if email == "" {
-		return nil, errors.New("linkedin profile does not contain email address")
+	displayName := gu.Name
+	if strings.TrimSpace(displayName) == "" {
+		displayName = gu.Username
 	}
+	ident := &LoginIdentity{Provider: loginChannelGitlab, Email: email, EmailVerified: true, DisplayName: displayName, AvatarUrl: gu.AvatarUrl}
+	if gu.Id != 0 {
+		ident.Subject = strconv.FormatInt(gu.Id, 10)
+	}
+	return ident, nil
+}
 
-	if u, err = userDao.Get(email.(string)); err == nil && u == nil {
-		u = user.NewUser(goapi.AppVersionNumber, email.(string))
-		var ok bool
-		if ok, err = userDao.Create(u); err != nil || !ok {
-			u = nil
-		}
+// loginIdentityFromSamlAssertion builds login identity from a SAML assertion: identity providers are distinguished
+// by name; transient NameIDs change upon every login and can not be used as subject id.
+// Configured identity providers are trusted for the email addresses they assert.
+//
+// available since v0.8.0
+func loginIdentityFromSamlAssertion(idp *samlIdp, info *samlAssertionInfo) (*LoginIdentity, error) {
+	email := idp.extractEmail(info)
+	if email == "" {
+		return nil, fmt.Errorf("SAML assertion from [%s] does not contain an email address", idp.name)
+	}
+	ident := &LoginIdentity{Provider: loginChannelSaml + ":" + idp.name, Email: email, EmailVerified: true, DisplayName: idp.extractName(info)}
+	if info.NameIdFormat != samlNameIdFormatTransient {
+		ident.Subject = info.NameId
+	}
+	return ident, nil
+}
+
+// loginIdentityFromLdapUser builds login identity from user's info looked up from the directory;
+// the directory is authoritative for user's email address and display name, the latter is updated upon every login.
+//
+// available since v0.8.0
+func loginIdentityFromLdapUser(lu *ldapUser) (*LoginIdentity, error) {
+	return &LoginIdentity{Provider: loginChannelLdap, Subject: lu.Dn, Email: lu.Email, EmailVerified: true, DisplayName: lu.DisplayName, AuthoritativeName: true}, nil
+}
+
+// createUserAccountFromEmail resolves user account of an email address whose ownership has been proven by a login link.
+//
+// available since v0.8.0
+func createUserAccountFromEmail(email string) (*user.User, error) {
+	return resolveLoginIdentity(&LoginIdentity{Provider: loginChannelEmail, Email: email, EmailVerified: true}, "", "")
+}
+
+// loadPreLoginSession loads a pre-login session that is waiting to be upgraded to login session.
+//
+// available since v0.8.0
+func loadPreLoginSession(sessId, channel string) (*Session, error) {
+	bo, err := sessionDao.Get(sessId)
+	if err != nil {
+		return nil, err
+	}
+	if bo == nil || bo.IsExpired() {
+		return nil, errors.New("session does not exist or has expired")
 	}
-	if err == nil && u != nil && u.GetDisplayName() == "" {
-		// fetch public lite profile (name & id)
-		respMe := gjrcClient.Get("https://api.linkedin.com/v2/me")
-		firstName, err := respMe.GetValueAsType("localizedFirstName", reddo.TypeString)
-		if err != nil {
-			firstName = ""
+	claims, err := parseLoginToken(bo.GetSessionData())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != sessionTypePreLogin || claims.isExpired() {
+		// a pre-login session can be upgraded only once
+		return nil, errors.New("session is not a pending pre-login session")
+	}
+	sess := &Session{}
+	if err := json.Unmarshal(claims.Data, sess); err != nil {
+		return nil, err
+	}
+	if sess.Channel != channel {
+		return nil, fmt.Errorf("invalid login channel: %s", sess.Channel)
+	}
+	return sess, nil
+}
+
+// upgradePreLoginSession upgrades a pre-login session, whose user has been resolved, to login session (or MFA-pending
+// session if multi-factor authentication is required, see genLoginOrMfaClaims).
+//
+// available since v0.8.0
+func upgradePreLoginSession(sessId string, sess *Session) error {
+	claims, err := genLoginOrMfaClaims(sessId, sess)
+	if err != nil {
+		return err
+	}
+	_, _, err = saveSession(claims)
+	return err
+}
+
+// waitLoginSession waits until a pre-login session is no longer pending (upgraded to login or MFA-pending session,
+// marked as failed, or expired) or the timeout is reached, returns the session as last loaded from storage (nil if it
+// does not exist). The session is reloaded as soon as this instance saves it (see saveSession), and also polled from
+// storage so that upgrades made by other Exter instances are seen.
+//
+// available since v0.8.0
+func waitLoginSession(sessId string, timeout time.Duration) (*session.Session, error) {
+	deadline := time.Now().Add(timeout)
+	wakeup := loginWaiters.add(sessId)
+	defer loginWaiters.remove(sessId, wakeup)
+	for {
+		sess, err := sessionDao.Get(sessId)
+		if err != nil || sess == nil || sess.IsExpired() || sess.GetSessionType() != sessionTypePreLogin {
+			return sess, err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return sess, nil
 		}
-		lastName, err := respMe.GetValueAsType("localizedLastName", reddo.TypeString)
-		if err != nil {
-			lastName = ""
+		if remaining > loginWaitPollInterval {
+			remaining = loginWaitPollInterval
 		}
-		displayName := firstName.(string) + " " + lastName.(string)
-		if strings.TrimSpace(displayName) != "" {
-			u.SetDisplayName(displayName)
-		} else {
-			u.SetDisplayName(extractNameFromEmailAddress(email.(string)))
+		select {
+		case <-wakeup:
+		case <-time.After(remaining):
 		}
-		_, err = userDao.Update(u)
 	}
-	return u, err
 }
 
-func createUserAccountFromGitHubProfile(ui *github.User) (*user.User, error) {
-	var u *user.User
-	var err error
-	if ui.Email == nil || strings.TrimSpace(*ui.Email) == "" {
-		return nil, errors.New("github profile does not contain email address")
+// sessionWaiters keeps track of the waitLoginSession calls of this instance, indexed by session id, so that they can be
+// woken up when the session is saved.
+//
+// available since v0.8.0
+type sessionWaiters struct {
+	lock    sync.Mutex
+	waiters map[string]map[chan bool]bool
+}
+
+// add registers a waiter of the session, the returned channel receives a signal whenever the session is saved.
+func (w *sessionWaiters) add(sessId string) chan bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	ch := make(chan bool, 1)
+	if w.waiters[sessId] == nil {
+		w.waiters[sessId] = make(map[chan bool]bool)
 	}
-	if u, err = userDao.Get(*ui.Email); err == nil && u == nil {
-		u = user.NewUser(goapi.AppVersionNumber, *ui.Email)
-		var ok bool
-		if ok, err = userDao.Create(u); err != nil || !ok {
-			u = nil
-		}
+	w.waiters[sessId][ch] = true
+	return ch
+}
+
+// remove unregisters a waiter of the session.
+func (w *sessionWaiters) remove(sessId string, ch chan bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.waiters[sessId], ch)
+	if len(w.waiters[sessId]) == 0 {
+		delete(w.waiters, sessId)
 	}
-	// since v0.4.0: fetch display name from GitHub profile
-	if err == nil && u != nil && u.GetDisplayName() == "" {
-		if ui.Name != nil && strings.TrimSpace(*ui.Name) != "" {
-			u.SetDisplayName(*ui.Name)
-		} else {
-			u.SetDisplayName(extractNameFromEmailAddress(*ui.Email))
+}
+
+// notify wakes up all waiters of the session.
+func (w *sessionWaiters) notify(sessId string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for ch := range w.waiters[sessId] {
+		select {
+		case ch <- true:
+		default:
 		}
-		_, err = userDao.Update(u)
 	}
-	return u, err
 }
 
-func createUserAccountFromGoogleProfile(ui *goauthv2.Userinfo) (*user.User, error) {
-	var u *user.User
-	var err error
-	if u, err = userDao.Get(ui.Email); err == nil && u == nil {
-		u = user.NewUser(goapi.AppVersionNumber, ui.Email)
-		var ok bool
-		if ok, err = userDao.Create(u); err != nil || !ok {
-			u = nil
-		}
+// failPreLoginSession marks a pre-login session as failed: instead of waiting for the session to expire, API
+// "verifyLoginToken" reports the reason to client.
+//
+// available since v0.8.0
+func failPreLoginSession(sessId string, sess *Session, reason error) error {
+	sess.Error = reason.Error()
+	sessData, err := json.Marshal(sess)
+	if err != nil {
+		return err
 	}
-	// since v0.4.0: fetch display name from GitHub profile
-	if err == nil && u != nil && u.GetDisplayName() == "" {
-		if strings.TrimSpace(ui.Name) != "" {
-			u.SetDisplayName(ui.Name)
-		} else {
-			u.SetDisplayName(extractNameFromEmailAddress(ui.Email))
+	_, _, err = saveSession(&SessionClaims{
+		Type: sessionTypeFailed,
+		Data: sessData,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  sess.ClientId,
+			ExpiresAt: sess.ExpiredAt.Unix(),
+			Id:        sessId,
+			IssuedAt:  sess.CreatedAt.Unix(),
+			Subject:   sess.Channel,
+		},
+	})
+	return err
+}
+
+// failedSessionReason returns the reason why login failed, from a session marked by failPreLoginSession.
+//
+// available since v0.8.0
+func failedSessionReason(sessData string) string {
+	if claims, err := parseLoginToken(sessData); err == nil {
+		sess := &Session{}
+		if err := json.Unmarshal(claims.Data, sess); err == nil && sess.Error != "" {
+			return sess.Error
 		}
-		_, err = userDao.Update(u)
 	}
-	return u, err
+	return "login failed"
 }
 
+// genJws signs the session claims with the active key of the keyset (since v0.8.0, the key id is put in the "kid" header).
 func genJws(claim *SessionClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claim)
-	return token.SignedString(rsaPrivKey)
+	return signJwt(claim)
+}
+
+// recordUserLogin records the time and channel of user's login.
+// Failing to record is not fatal to the login, hence error is only logged.
+//
+// available since v0.8.0
+func recordUserLogin(u *user.User, channel string, now time.Time) {
+	u.SetLastLoginAt(now).SetLastLoginChannel(channel)
+	if u.GetFirstSeenAt().IsZero() {
+		u.SetFirstSeenAt(now)
+	}
+	if _, err := userDao.Update(u); err != nil {
+		log.Printf("[WARN] recordUserLogin(%s) - error updating user: %s", u.GetId(), err)
+	}
 }
 
 // genLoginClaims generates a login token as SessionClaims:
 //   - the SessionClaims is created with type=login and populated with data from supplied session
+//   - (since v0.8.0) login channels should call genLoginOrMfaClaims instead, which takes multi-factor authentication into account
+//   - (since v0.8.0) user's last login is recorded and user's profile is embedded as optional claims
 func genLoginClaims(id string, sess *Session) (*SessionClaims, error) {
-	if id == "" {
-		id = utils.UniqueId()
-	}
 	u, err := userDao.Get(sess.UserId)
 	if err != nil {
 		return nil, err
@@ -217,16 +485,47 @@ func genLoginClaims(id string, sess *Session) (*SessionClaims, error) {
 	if u == nil {
 		return nil, errors.New(fmt.Sprintf("user [%s] not found", sess.UserId))
 	}
+	claims, err := buildLoginClaims(id, sess, u)
+	if err != nil {
+		return nil, err
+	}
+	recordUserLogin(u, sess.Channel, time.Now())
+	return claims, nil
+}
+
+// buildLoginClaims builds the SessionClaims of a login token of the user from supplied session, without recording
+// user's login (e.g. the login token is refreshed, see API "refreshLoginToken").
+//
+// available since v0.8.0
+func buildLoginClaims(id string, sess *Session, u *user.User) (*SessionClaims, error) {
+	if id == "" {
+		id = utils.UniqueId()
+	}
+	if len(sess.Amr) == 0 {
+		sess.Amr = []string{channelAmr(sess.Channel)}
+	}
+	if sess.Acr == "" {
+		sess.Acr = acrSingleFactor
+	}
 	sessData, err := json.Marshal(sess)
 	if err != nil {
 		return nil, err
 	}
 	sessData, err = zipAndEncrypt(sessData, []byte(u.GetAesKey()))
+	if err != nil {
+		return nil, err
+	}
 	return &SessionClaims{
 		UserId:          sess.UserId,
 		UserDisplayName: sess.DisplayName,
 		Type:            sessionTypeLogin,
 		Data:            sessData,
+		Amr:             sess.Amr,
+		Acr:             sess.Acr,
+		Picture:         u.GetAvatarUrl(),
+		Locale:          u.GetLocale(),
+		GivenName:       u.GetGivenName(),
+		FamilyName:      u.GetFamilyName(),
 		StandardClaims: jwt.StandardClaims{
 			Audience:  sess.ClientId,
 			ExpiresAt: sess.ExpiredAt.Unix(),
@@ -239,7 +538,7 @@ func genLoginClaims(id string, sess *Session) (*SessionClaims, error) {
 
 // genLoginToken generates a login token in JWT format:
 //   - a SessionClaims is created with type=login and populated with data from supplied session
-//   - the session claim is used to created JWT, the JWT is then signed with rsaPrivKey
+//   - the session claim is used to created JWT, the JWT is then signed with the active key of the keyset
 func genLoginToken(id string, sess *Session) (*SessionClaims, string, error) {
 	claims, err := genLoginClaims(id, sess)
 	if err != nil {
@@ -268,7 +567,7 @@ func genPreLoginClaims(sess *Session) (*SessionClaims, error) {
 
 // genPreLoginToken generates a pre-login token in JWT format:
 //   - a SessionClaims is created with type=pre-login and populated with data from supplied session
-//   - the session claim is used to created JWT, the JWT is then signed with rsaPrivKey
+//   - the session claim is used to created JWT, the JWT is then signed with the active key of the keyset
 func genPreLoginToken(sess *Session) (*SessionClaims, string, error) {
 	claims, err := genPreLoginClaims(sess)
 	if err != nil {
@@ -279,12 +578,7 @@ func genPreLoginToken(sess *Session) (*SessionClaims, string, error) {
 }
 
 func parseLoginToken(jwtStr string) (*SessionClaims, error) {
-	token, err := jwt.Parse(jwtStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("enexpected signing method: %v", token.Header["alg"])
-		}
-		return rsaPubKey, nil
-	})
+	token, err := jwt.Parse(jwtStr, jwtVerificationKey)
 	if err != nil {
 		return nil, err
 	}
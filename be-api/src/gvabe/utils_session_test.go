@@ -0,0 +1,158 @@
+package gvabe
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"main/src/gvabe/bo/session"
+)
+
+// in-memory implementation of session.SessionDao
+type testSessionDao struct {
+	sync.Mutex
+	sessions map[string]*session.Session
+}
+
+func (dao *testSessionDao) Delete(bo *session.Session) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if dao.sessions[bo.GetId()] == nil {
+		return false, nil
+	}
+	delete(dao.sessions, bo.GetId())
+	return true, nil
+}
+
+func (dao *testSessionDao) Get(id string) (*session.Session, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	if sess := dao.sessions[id]; sess != nil {
+		return session.NewSessionFromUbo(sess.UniversalBo), nil
+	}
+	return nil, nil
+}
+
+func (dao *testSessionDao) Save(bo *session.Session) (bool, error) {
+	dao.Lock()
+	defer dao.Unlock()
+	bo.MarshalJSON() // syncs BO's attributes to the underlying universal bo
+	dao.sessions[bo.GetId()] = session.NewSessionFromUbo(bo.UniversalBo)
+	return true, nil
+}
+
+func TestWaitLoginSession(t *testing.T) {
+	testName := "TestWaitLoginSession"
+	defer func(dao session.SessionDao, interval time.Duration) {
+		sessionDao, loginWaitPollInterval = dao, interval
+	}(sessionDao, loginWaitPollInterval)
+	dao := &testSessionDao{sessions: make(map[string]*session.Session)}
+	sessionDao, loginWaitPollInterval = dao, 10*time.Millisecond
+	expiry := time.Now().Add(time.Hour)
+	dao.Save(session.NewSession(0, "sid", sessionTypePreLogin, "google", "exter", "", "pre-login-token", expiry))
+
+	// session is still pending when timeout is reached
+	start := time.Now()
+	if sess, err := waitLoginSession("sid", 50*time.Millisecond); err != nil || sess == nil || sess.GetSessionType() != sessionTypePreLogin {
+		t.Fatalf("%s failed: %#v / %s", testName, sess, err)
+	} else if d := time.Since(start); d < 50*time.Millisecond {
+		t.Fatalf("%s failed: returned after %s", testName, d)
+	}
+
+	// session is upgraded (possibly by another instance) while waiting
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		dao.Save(session.NewSession(0, "sid", sessionTypeLogin, "google", "exter", "user@domain.com", "login-token", expiry))
+	}()
+	if sess, err := waitLoginSession("sid", 10*time.Second); err != nil || sess == nil || sess.GetSessionType() != sessionTypeLogin || sess.GetSessionData() != "login-token" {
+		t.Fatalf("%s failed: %#v / %s", testName, sess, err)
+	}
+
+	// session does not exist
+	if sess, err := waitLoginSession("not-exist", 10*time.Second); err != nil || sess != nil {
+		t.Fatalf("%s failed: %#v / %s", testName, sess, err)
+	}
+
+	// session upgraded by this instance wakes up the waiting call without waiting for the next poll
+	_, _, teardownKeyset := setupTestKeyset(t, testName)
+	defer teardownKeyset()
+	loginWaitPollInterval = time.Hour
+	dao.Save(session.NewSession(0, "sid", sessionTypePreLogin, "google", "exter", "", "pre-login-token", expiry))
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		saveSession(&SessionClaims{Type: sessionTypeFailed, StandardClaims: jwt.StandardClaims{Id: "sid", ExpiresAt: expiry.Unix()}})
+	}()
+	start = time.Now()
+	if sess, err := waitLoginSession("sid", 10*time.Second); err != nil || sess == nil || sess.GetSessionType() != sessionTypeFailed {
+		t.Fatalf("%s failed: %#v / %s", testName, sess, err)
+	} else if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("%s failed: returned after %s", testName, d)
+	}
+	if len(loginWaiters.waiters) != 0 {
+		t.Fatalf("%s failed: waiters must be removed, %#v remaining", testName, len(loginWaiters.waiters))
+	}
+}
+
+func TestRenewLoginSession(t *testing.T) {
+	testName := "TestRenewLoginSession"
+	_, _, teardownKeyset := setupTestKeyset(t, testName)
+	defer teardownKeyset()
+	sessDao, _, teardown := setupTestSessionRevocation(time.Minute)
+	defer teardown()
+	defer func(maxLifetime time.Duration) { loginSessionMaxLifetime = maxLifetime }(loginSessionMaxLifetime)
+	loginSessionMaxLifetime = 24 * time.Hour
+	now := time.Now()
+	// a login session created at the timestamp, expiring in an hour
+	loginSession := func(createdAt time.Time) *SessionClaims {
+		claims := _testLoginSession(sessDao, "user@domain.com", systemAppId, createdAt)
+		claims.ExpiresAt = now.Add(time.Hour).Unix()
+		sessDao.Save(session.NewSession(0, claims.Id, sessionTypeLogin, "local", systemAppId, claims.UserId, "login-token", time.Unix(claims.ExpiresAt, 0)))
+		return claims
+	}
+
+	// the session record is updated with a new token, valid for another loginSessionTtl
+	claims := loginSession(now.Add(-6 * time.Hour))
+	jws, err := renewLoginSession(claims, now)
+	if err != nil || jws == "" {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	renewed, err := parseLoginToken(jws)
+	if err != nil || renewed.Id != claims.Id || renewed.IssuedAt != claims.IssuedAt || renewed.ExpiresAt != now.Add(loginSessionTtl*time.Second).Unix() {
+		t.Fatalf("%s failed: %#v / %s", testName, renewed, err)
+	}
+	if sess, _ := sessDao.Get(claims.Id); sess == nil || sess.GetSessionData() != jws || sess.GetExpiry().Unix() != renewed.ExpiresAt {
+		t.Fatalf("%s failed: session record should be updated", testName)
+	}
+
+	// sessions can not be extended beyond the maximum lifetime since user logged in
+	claims = loginSession(now.Add(-22 * time.Hour))
+	maxExpiry := time.Unix(claims.IssuedAt, 0).Add(loginSessionMaxLifetime).Unix()
+	if jws, err = renewLoginSession(claims, now); err != nil || jws == "" {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if renewed, err = parseLoginToken(jws); err != nil || renewed.ExpiresAt != maxExpiry {
+		t.Fatalf("%s failed: expected expiry %d but received %#v / %s", testName, maxExpiry, renewed, err)
+	}
+	if jws, err = renewLoginSession(renewed, now); err != nil || jws != "" {
+		t.Fatalf("%s failed: session should not be renewed any further / %s", testName, err)
+	}
+
+	// revoked sessions are not brought back
+	claims = loginSession(now.Add(-6 * time.Hour))
+	sessDao.Delete(session.NewSession(0, claims.Id, "", "", "", "", "", time.Time{}))
+	if jws, err = renewLoginSession(claims, now); err != nil || jws != "" {
+		t.Fatalf("%s failed: revoked session should not be renewed / %s", testName, err)
+	}
+	if sess, _ := sessDao.Get(claims.Id); sess != nil {
+		t.Fatalf("%s failed: revoked session should not be recreated", testName)
+	}
+
+	// renewal is disabled
+	loginSessionMaxLifetime = 0
+	claims = loginSession(now.Add(-6 * time.Hour))
+	if jws, err = renewLoginSession(claims, now); err != nil || jws != "" {
+		t.Fatalf("%s failed: renewal should be disabled / %s", testName, err)
+	}
+}
@@ -0,0 +1,203 @@
+package gvabe
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Algorithms Exter signs tokens with: RS256 (RSA), ES256 (ECDSA P-256) and EdDSA (Ed25519).
+//
+// available since v0.8.0
+const (
+	signingAlgRS256 = "RS256"
+	signingAlgES256 = "ES256"
+	signingAlgEdDSA = "EdDSA"
+)
+
+// signingMethodEdDSA implements jwt.SigningMethod for algorithm "EdDSA" (RFC 8037) with Ed25519 keys, which is not
+// provided by package jwt-go.
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(signingAlgEdDSA, func() jwt.SigningMethod { return &signingMethodEdDSA{} })
+}
+
+// Alg implements jwt.SigningMethod.Alg.
+func (m *signingMethodEdDSA) Alg() string {
+	return signingAlgEdDSA
+}
+
+// Verify implements jwt.SigningMethod.Verify.
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	pubKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pubKey, []byte(signingString), sig) {
+		return errors.New("ed25519: verification error")
+	}
+	return nil
+}
+
+// Sign implements jwt.SigningMethod.Sign.
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privKey, []byte(signingString))), nil
+}
+
+// normalizeSigningAlg returns the canonical name of a supported signing algorithm, or empty string if not supported.
+func normalizeSigningAlg(alg string) string {
+	for _, v := range []string{signingAlgRS256, signingAlgES256, signingAlgEdDSA} {
+		if strings.EqualFold(strings.TrimSpace(alg), v) {
+			return v
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(alg), "Ed25519") {
+		return signingAlgEdDSA
+	}
+	return ""
+}
+
+// genSigningKey generates a new private key for the signing algorithm.
+func genSigningKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case signingAlgRS256:
+		return genRsaKey(2048)
+	case signingAlgES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case signingAlgEdDSA:
+		_, privKey, err := ed25519.GenerateKey(rand.Reader)
+		return privKey, err
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+}
+
+// keySigningAlg returns the signing algorithm of a (public or private) key: RSA keys sign with RS256, P-256 keys with
+// ES256 and Ed25519 keys with EdDSA. Empty string is returned for other keys.
+func keySigningAlg(key interface{}) string {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return signingAlgRS256
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return signingAlgES256
+		}
+	case ed25519.PublicKey:
+		return signingAlgEdDSA
+	}
+	return ""
+}
+
+// parsePrivKeyPem parses a private key from a PEM block: PKCS1 ("RSA PRIVATE KEY"), SEC1 ("EC PRIVATE KEY") or PKCS8
+// ("PRIVATE KEY", RSA, EC or Ed25519). der is the (decrypted) content of the block.
+func parsePrivKeyPem(blockType string, der []byte) (crypto.Signer, error) {
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		privKey, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := privKey.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported private key type [%s]", blockType)
+}
+
+// privKeyBytes returns the binary form of a private key: PKCS1 for RSA keys, PKCS8 for other keys.
+func privKeyBytes(privKey crypto.Signer) []byte {
+	if rsaKey, ok := privKey.(*rsa.PrivateKey); ok {
+		return x509.MarshalPKCS1PrivateKey(rsaKey)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(privKey)
+	return der
+}
+
+// publicKeyPems returns a public key in PEM format: PKCS1 (RSA keys only, nil otherwise) and PKIX.
+func publicKeyPems(pubKey crypto.PublicKey) (pkcs1, pkix []byte) {
+	if rsaKey, ok := pubKey.(*rsa.PublicKey); ok {
+		pkcs1 = pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(rsaKey)})
+	}
+	der, _ := x509.MarshalPKIXPublicKey(pubKey)
+	pkix = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return pkcs1, pkix
+}
+
+func _b64BigInt(v *big.Int, size int) string {
+	b := v.Bytes()
+	if len(b) < size {
+		b = append(make([]byte, size-len(b)), b...)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// _jwkRequiredMembers returns the required members of a public key's JWK, which are used to compute its thumbprint.
+func _jwkRequiredMembers(pubKey crypto.PublicKey) map[string]string {
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "n": _b64BigInt(pubKey.N, 0), "e": _b64BigInt(big.NewInt(int64(pubKey.E)), 0)}
+	case *ecdsa.PublicKey:
+		size := (pubKey.Curve.Params().BitSize + 7) / 8
+		return map[string]string{"kty": "EC", "crv": pubKey.Curve.Params().Name, "x": _b64BigInt(pubKey.X, size), "y": _b64BigInt(pubKey.Y, size)}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(pubKey)}
+	}
+	return nil
+}
+
+// publicJwk returns the JSON Web Key of a public key.
+func publicJwk(pubKey crypto.PublicKey) map[string]interface{} {
+	jwk := map[string]interface{}{
+		"use": "sig",
+		"alg": keySigningAlg(pubKey),
+		"kid": jwkThumbprint(pubKey),
+	}
+	for k, v := range _jwkRequiredMembers(pubKey) {
+		jwk[k] = v
+	}
+	return jwk
+}
+
+// jwkThumbprint returns the JWK thumbprint (RFC 7638) of a public key, used as key id.
+func jwkThumbprint(pubKey crypto.PublicKey) string {
+	members := _jwkRequiredMembers(pubKey)
+	var js string
+	switch members["kty"] {
+	case "RSA":
+		js = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, members["e"], members["n"])
+	case "EC":
+		js = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, members["crv"], members["x"], members["y"])
+	case "OKP":
+		js = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, members["crv"], members["x"])
+	}
+	sum := sha256.Sum256([]byte(js))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
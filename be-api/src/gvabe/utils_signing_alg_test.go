@@ -0,0 +1,93 @@
+package gvabe
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestSigningMethodEdDSA(t *testing.T) {
+	testName := "TestSigningMethodEdDSA"
+	// test vector from RFC 8037, appendix A.4
+	seed, _ := base64.RawURLEncoding.DecodeString("nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A")
+	privKey := ed25519.NewKeyFromSeed(seed)
+	signingString := "eyJhbGciOiJFZERTQSJ9.RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc"
+	expected := "hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg"
+
+	method := jwt.GetSigningMethod(signingAlgEdDSA)
+	if method == nil {
+		t.Fatalf("%s failed: signing method [%s] is not registered", testName, signingAlgEdDSA)
+	}
+	if sig, err := method.Sign(signingString, privKey); err != nil || sig != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v / %s", testName, expected, sig, err)
+	}
+	if err := method.Verify(signingString, expected, privKey.Public()); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if err := method.Verify(signingString+".", expected, privKey.Public()); err == nil {
+		t.Fatalf("%s failed: signature should not match", testName)
+	}
+	if _, err := method.Sign(signingString, []byte("secret")); err != jwt.ErrInvalidKeyType {
+		t.Fatalf("%s failed: expected ErrInvalidKeyType but received %s", testName, err)
+	}
+}
+
+func TestJwkThumbprint(t *testing.T) {
+	testName := "TestJwkThumbprint"
+	// test vector from RFC 8037, appendix A.3
+	x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	if kid, expected := jwkThumbprint(ed25519.PublicKey(x)), "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; kid != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, kid)
+	}
+
+	// published JWKs are parsed back to the same public keys
+	for _, alg := range []string{signingAlgRS256, signingAlgES256, signingAlgEdDSA} {
+		privKey, err := genSigningKey(alg)
+		if err != nil {
+			t.Fatalf("%s failed: %s", testName, err)
+		}
+		jwk := publicJwk(privKey.Public())
+		if jwk["alg"] != alg || jwk["kid"] != jwkThumbprint(privKey.Public()) {
+			t.Fatalf("%s failed: %#v", testName, jwk)
+		}
+		pubKey, err := parseJwk(jwk)
+		if err != nil || jwkThumbprint(pubKey) != jwk["kid"] {
+			t.Fatalf("%s failed: %#v / %s", testName, pubKey, err)
+		}
+	}
+}
+
+func TestParsePrivKeyPem(t *testing.T) {
+	testName := "TestParsePrivKeyPem"
+	for _, alg := range []string{signingAlgRS256, signingAlgES256, signingAlgEdDSA} {
+		privKey, _ := genSigningKey(alg)
+		der, _ := x509.MarshalPKCS8PrivateKey(privKey)
+		if v, err := parsePrivKeyPem("PRIVATE KEY", der); err != nil || keySigningAlg(v) != alg {
+			t.Fatalf("%s failed: [%s] %s", testName, alg, err)
+		}
+	}
+
+	privKey, _ := genSigningKey(signingAlgES256)
+	der, _ := x509.MarshalECPrivateKey(privKey.(*ecdsa.PrivateKey))
+	if v, err := parsePrivKeyPem("EC PRIVATE KEY", der); err != nil || keySigningAlg(v) != signingAlgES256 {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if _, err := parsePrivKeyPem("DSA PRIVATE KEY", der); err == nil {
+		t.Fatalf("%s failed: unsupported key type should be rejected", testName)
+	}
+}
+
+func TestNormalizeSigningAlg(t *testing.T) {
+	testName := "TestNormalizeSigningAlg"
+	testCases := map[string]string{"rs256": signingAlgRS256, " ES256 ": signingAlgES256, "eddsa": signingAlgEdDSA,
+		"Ed25519": signingAlgEdDSA, "HS256": "", "none": "", "": ""}
+	for input, expected := range testCases {
+		if v := normalizeSigningAlg(input); v != expected {
+			t.Fatalf("%s failed: expected %#v for %#v but received %#v", testName, expected, input, v)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package gvabe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/btnguyen2k/consu/reddo"
+	hocon "github.com/go-akka/configuration"
+	"golang.org/x/oauth2"
+
+	"main/src/itineris"
+)
+
+const (
+	twitterApiUserMe = "https://api.twitter.com/2/users/me?user.fields=id,name,username,profile_image_url,confirmed_email"
+)
+
+var (
+	twitterOAuthConf = &oauth2.Config{
+		ClientID:     "",
+		ClientSecret: "",
+		Scopes:       []string{"tweet.read", "users.read", "users.email", "offline.access"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   "https://twitter.com/i/oauth2/authorize",
+			TokenURL:  "https://api.twitter.com/2/oauth2/token",
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+	}
+)
+
+// twitterUser captures user profile returned by Twitter API v2 endpoint "/2/users/me".
+//
+// available since v0.8.0
+type twitterUser struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	Username        string `json:"username"`
+	ProfileImageUrl string `json:"profile_image_url"`
+	ConfirmedEmail  string `json:"confirmed_email"`
+}
+
+// twitterExchange exchanges the authorization code for access token using PKCE.
+//
+// available since v0.8.0
+func twitterExchange(ctx context.Context, authCode, codeVerifier string) (*oauth2.Token, error) {
+	if codeVerifier == "" {
+		return nil, errors.New("twitter OAuth flow requires a PKCE code_verifier")
+	}
+	return twitterOAuthConf.Exchange(ctx, authCode, oauth2.AccessTypeOnline, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+}
+
+// twitterFetchUserProfile fetches the authenticated user's profile from Twitter API v2.
+//
+// available since v0.8.0
+func twitterFetchUserProfile(httpClient *http.Client) (*twitterUser, error) {
+	resp, err := httpClient.Get(twitterApiUserMe)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.New("twitter API response status: " + resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result := struct {
+		Data *twitterUser `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if result.Data == nil || strings.TrimSpace(result.Data.Id) == "" {
+		return nil, errors.New("twitter API response does not contain user id")
+	}
+	return result.Data, nil
+}
+
+// twitterLoginChannel implements LoginChannel for Twitter (X) OAuth 2.0.
+//
+// available since v0.8.0
+type twitterLoginChannel struct{}
+
+// Name implements LoginChannel.Name.
+func (ch *twitterLoginChannel) Name() string {
+	return loginChannelTwitter
+}
+
+// Init implements LoginChannel.Init.
+func (ch *twitterLoginChannel) Init(conf *hocon.Config) error {
+	clientId := strings.TrimSpace(conf.GetString("gvabe.channels.twitter.client_id"))
+	if clientId == "" {
+		log.Println("[ERROR] No valid Twitter OAuth app client-id defined at [gvabe.channels.twitter.client_id]")
+	}
+	clientSecret := strings.TrimSpace(conf.GetString("gvabe.channels.twitter.client_secret"))
+	if clientSecret == "" {
+		log.Println("[ERROR] No valid Twitter OAuth app client-secret defined at [gvabe.channels.twitter.client_secret]")
+	}
+	redirectUri := strings.TrimSpace(conf.GetString("gvabe.channels.twitter.redirect_uri"))
+	if redirectUri == "" {
+		log.Println("[ERROR] No valid Twitter OAuth app redirect-uri defined at [gvabe.channels.twitter.redirect_uri]")
+		redirectUri = exterHomeUrl
+	}
+	twitterOAuthConf.ClientID = clientId
+	twitterOAuthConf.ClientSecret = clientSecret
+	twitterOAuthConf.RedirectURL = redirectUri
+	if DEBUG && clientId != "" && clientSecret != "" {
+		log.Printf("[DEBUG] twitterLoginChannel.Init: %s/%s/%s", clientId, "***"+clientSecret[len(clientSecret)-4:], redirectUri)
+	}
+	return nil
+}
+
+// Info implements LoginChannel.Info.
+func (ch *twitterLoginChannel) Info() map[string]interface{} {
+	return map[string]interface{}{"twitter_client_id": twitterOAuthConf.ClientID}
+}
+
+// AuthUrl implements LoginChannel.AuthUrl.
+func (ch *twitterLoginChannel) AuthUrl(state string, opts ...oauth2.AuthCodeOption) string {
+	return twitterOAuthConf.AuthCodeURL(state, opts...)
+}
+
+// Exchange implements LoginChannel.Exchange.
+func (ch *twitterLoginChannel) Exchange(ctx context.Context, params *itineris.ApiParams) (*oauth2.Token, error) {
+	authCode := _extractParam(params, "code", reddo.TypeString, "", nil)
+	codeVerifier := _extractParam(params, "code_verifier", reddo.TypeString, "", nil)
+	return twitterExchange(ctx, authCode.(string), codeVerifier.(string))
+}
+
+// FetchProfile implements LoginChannel.FetchProfile.
+func (ch *twitterLoginChannel) FetchProfile(ctx context.Context, token *oauth2.Token) (interface{}, error) {
+	return twitterFetchUserProfile(twitterOAuthConf.Client(ctx, token))
+}
+
+// MapIdentity implements LoginChannel.MapIdentity.
+func (ch *twitterLoginChannel) MapIdentity(profile interface{}) (*LoginIdentity, error) {
+	if tu, ok := profile.(*twitterUser); ok && tu != nil {
+		return loginIdentityFromTwitterProfile(tu)
+	}
+	return nil, errorProfileType(loginChannelTwitter, profile)
+}
@@ -0,0 +1,155 @@
+package gvabe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestTwitterAuthUrl(t *testing.T) {
+	testName := "TestTwitterAuthUrl"
+	origClientId := twitterOAuthConf.ClientID
+	defer func() { twitterOAuthConf.ClientID = origClientId }()
+	twitterOAuthConf.ClientID = "twitter-client"
+
+	ch := &twitterLoginChannel{}
+	authUrl := ch.AuthUrl("state1", oauth2.SetAuthURLParam("code_challenge", "challenge1"),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if expected := "https://twitter.com/i/oauth2/authorize"; u.Scheme+"://"+u.Host+u.Path != expected {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, authUrl)
+	}
+	query := u.Query()
+	for k, expected := range map[string]string{"client_id": "twitter-client", "response_type": "code", "state": "state1",
+		"code_challenge": "challenge1", "code_challenge_method": "S256"} {
+		if query.Get(k) != expected {
+			t.Fatalf("%s failed: expected %s=%#v but received %#v", testName, k, expected, query.Get(k))
+		}
+	}
+	if scopes := strings.Split(query.Get("scope"), " "); !reflect.DeepEqual(scopes, twitterOAuthConf.Scopes) {
+		t.Fatalf("%s failed: expected scopes %#v but received %#v", testName, twitterOAuthConf.Scopes, scopes)
+	}
+}
+
+func TestTwitterExchange(t *testing.T) {
+	testName := "TestTwitterExchange"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "code1" || r.Form.Get("code_verifier") != "verifier1" || r.Form.Get("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token_type":"bearer","access_token":"access1","expires_in":7200}`))
+	}))
+	defer server.Close()
+	origEndpoint := twitterOAuthConf.Endpoint
+	defer func() { twitterOAuthConf.Endpoint = origEndpoint }()
+	twitterOAuthConf.Endpoint.TokenURL = server.URL + "/2/oauth2/token"
+
+	if _, err := twitterExchange(context.Background(), "code1", ""); err == nil {
+		t.Fatalf("%s failed: expected error for missing code_verifier", testName)
+	}
+	token, err := twitterExchange(context.Background(), "code1", "verifier1")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	if token == nil || token.AccessToken != "access1" {
+		t.Fatalf("%s failed: %#v", testName, token)
+	}
+	if _, err = twitterExchange(context.Background(), "code1", "verifier2"); err == nil {
+		t.Fatalf("%s failed: expected error for wrong code_verifier", testName)
+	}
+}
+
+func TestTwitterFetchUserProfile(t *testing.T) {
+	testName := "TestTwitterFetchUserProfile"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2/users/me" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Header.Get("Authorization") {
+		case "Bearer email":
+			w.Write([]byte(`{"data":{"id":"123","name":"User One","username":"user1","profile_image_url":"https://pbs.twimg.com/1.jpg","confirmed_email":"user1@example.com"}}`))
+		case "Bearer noemail":
+			w.Write([]byte(`{"data":{"id":"456","name":"","username":"user2"}}`))
+		case "Bearer noid":
+			w.Write([]byte(`{"data":{"name":"User Three"}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+
+	doRequest := func(accessToken string) (*twitterUser, error) {
+		client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.URL.Scheme, r.URL.Host = serverUrl.Scheme, serverUrl.Host
+			r.Header.Set("Authorization", "Bearer "+accessToken)
+			return http.DefaultTransport.RoundTrip(r)
+		})}
+		return twitterFetchUserProfile(client)
+	}
+
+	tu, err := doRequest("email")
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &twitterUser{Id: "123", Name: "User One", Username: "user1", ProfileImageUrl: "https://pbs.twimg.com/1.jpg", ConfirmedEmail: "user1@example.com"}
+	if !reflect.DeepEqual(tu, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, tu)
+	}
+	if tu, err = doRequest("noemail"); err != nil || tu.Id != "456" || tu.ConfirmedEmail != "" {
+		t.Fatalf("%s failed: %#v / %s", testName, tu, err)
+	}
+	if _, err = doRequest("noid"); err == nil {
+		t.Fatalf("%s failed: expected error for profile without user id", testName)
+	}
+	if _, err = doRequest("invalid"); err == nil {
+		t.Fatalf("%s failed: expected error for invalid access token", testName)
+	}
+}
+
+func TestLoginIdentityFromTwitterProfile(t *testing.T) {
+	testName := "TestLoginIdentityFromTwitterProfile"
+	ch := &twitterLoginChannel{}
+	ident, err := ch.MapIdentity(&twitterUser{Id: "123", Name: "User One", Username: "user1", ProfileImageUrl: "https://pbs.twimg.com/1.jpg", ConfirmedEmail: "user1@example.com"})
+	if err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected := &LoginIdentity{Provider: loginChannelTwitter, Subject: "123", Email: "user1@example.com", EmailVerified: true,
+		DisplayName: "User One", AvatarUrl: "https://pbs.twimg.com/1.jpg"}
+	if !reflect.DeepEqual(ident, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ident)
+	}
+
+	// Twitter does not always return an email address: the identity is keyed by Twitter's user id only
+	if ident, err = ch.MapIdentity(&twitterUser{Id: "456", Username: "user2"}); err != nil {
+		t.Fatalf("%s failed: %s", testName, err)
+	}
+	expected = &LoginIdentity{Provider: loginChannelTwitter, Subject: "456", DisplayName: "user2"}
+	if !reflect.DeepEqual(ident, expected) {
+		t.Fatalf("%s failed: expected %#v but received %#v", testName, expected, ident)
+	}
+	_, _, teardown := setupTestIdentities()
+	defer teardown()
+	if u, err := findOrCreateUser(ident); err != nil || u == nil || u.GetId() != "twitter:456" {
+		t.Fatalf("%s failed: %#v / %s", testName, u, err)
+	}
+
+	if _, err = ch.MapIdentity(&gitlabUser{}); err == nil {
+		t.Fatalf("%s failed: expected error for profile of another channel", testName)
+	}
+}
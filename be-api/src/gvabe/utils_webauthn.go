@@ -0,0 +1,661 @@
+package gvabe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	hocon "github.com/go-akka/configuration"
+
+	"main/src/goapi"
+	"main/src/gvabe/bo/app"
+	"main/src/gvabe/bo/credential"
+	"main/src/gvabe/bo/session"
+	"main/src/gvabe/bo/user"
+	"main/src/itineris"
+)
+
+const (
+	sessionTypeWebauthnRegister = "webauthn_reg"  // challenge of a registration ceremony
+	sessionTypeWebauthnLogin    = "webauthn_auth" // challenge of an authentication ceremony
+
+	webauthnDefaultTimeout = 5 * time.Minute
+	webauthnChallengeSize  = 32
+
+	webauthnUvRequired    = "required"
+	webauthnUvPreferred   = "preferred"
+	webauthnUvDiscouraged = "discouraged"
+
+	webauthnTypeCreate = "webauthn.create"
+	webauthnTypeGet    = "webauthn.get"
+
+	// authenticator data flags
+	webauthnFlagUp = 0x01 // user present
+	webauthnFlagUv = 0x04 // user verified
+	webauthnFlagAt = 0x40 // attested credential data included
+	webauthnFlagEd = 0x80 // extension data included
+
+	// COSE key parameters and algorithms (RFC 8152)
+	coseKeyKty     = 1
+	coseKeyAlg     = 3
+	coseKeyCrv     = -1
+	coseKeyX       = -2
+	coseKeyY       = -3
+	coseKeyRsaN    = -1
+	coseKeyRsaE    = -2
+	coseKtyOkp     = 1
+	coseKtyEc2     = 2
+	coseKtyRsa     = 3
+	coseAlgEs256   = -7
+	coseAlgEdDsa   = -8
+	coseAlgRs256   = -257
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+var (
+	errorWebauthnInvalidChallenge  = errors.New("invalid or expired WebAuthn challenge")
+	errorWebauthnInvalidCredential = errors.New("invalid WebAuthn credential")
+	errorWebauthnUnknownCredential = errors.New("credential is not registered")
+	errorWebauthnCredentialExists  = errors.New("credential has already been registered")
+	errorWebauthnUnsupportedKey    = errors.New("unsupported credential public key")
+	errorWebauthnSignCount         = errors.New("signature counter did not increase, the authenticator may have been cloned")
+
+	webauthnConf *webauthnConfig
+)
+
+// webauthnConfig captures the relying party settings of WebAuthn ceremonies.
+//
+// available since v0.8.0
+type webauthnConfig struct {
+	rpId             string   // relying party id, the effective domain credentials are scoped to
+	rpName           string   // relying party name displayed by authenticators
+	origins          []string // origins ceremonies are allowed to be performed from
+	userVerification string   // "required", "preferred" or "discouraged"
+	timeout          time.Duration
+}
+
+// webauthnChallengeData is stored as Session.Data of challenge records.
+type webauthnChallengeData struct {
+	Challenge string `json:"challenge"`
+}
+
+// webauthnCredentialJson is the JSON serialization of a PublicKeyCredential sent by client, binary fields are base64url-encoded.
+type webauthnCredentialJson struct {
+	Id       string `json:"id"`
+	RawId    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJson    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"` // registration only
+		Transports        []string `json:"transports"`        // registration only
+		AuthenticatorData string   `json:"authenticatorData"` // authentication only
+		Signature         string   `json:"signature"`         // authentication only
+		UserHandle        string   `json:"userHandle"`        // authentication only
+	} `json:"response"`
+}
+
+// webauthnClientData is the decoded clientDataJSON.
+type webauthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// webauthnAuthData is the decoded authenticator data.
+type webauthnAuthData struct {
+	rpIdHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialId []byte
+	publicKey    []byte // COSE_Key
+}
+
+// webauthnDecode decodes a base64url value (padding is optional), also accepting standard base64 for lenient clients.
+func webauthnDecode(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	if data, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func webauthnEncode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseWebauthnCredential parses the PublicKeyCredential sent by client, either as a JSON string or an already-decoded map.
+func parseWebauthnCredential(input interface{}) (*webauthnCredentialJson, []byte, error) {
+	var js []byte
+	switch v := input.(type) {
+	case string:
+		js = []byte(v)
+	case []byte:
+		js = v
+	default:
+		js, _ = json.Marshal(v)
+	}
+	cred := &webauthnCredentialJson{}
+	if err := json.Unmarshal(js, cred); err != nil || cred.Type != "public-key" {
+		return nil, nil, errorWebauthnInvalidCredential
+	}
+	rawId, err := webauthnDecode(cred.RawId)
+	if cred.RawId == "" {
+		rawId, err = webauthnDecode(cred.Id)
+	}
+	if err != nil || len(rawId) == 0 || len(rawId) > 1023 {
+		return nil, nil, errorWebauthnInvalidCredential
+	}
+	return cred, rawId, nil
+}
+
+// webauthnUserHandle returns the WebAuthn user handle of an user: an opaque value not revealing user's id (email address).
+func webauthnUserHandle(u *user.User) []byte {
+	h := sha256.Sum256([]byte("webauthn:" + u.GetAesKey() + u.GetId()))
+	return h[:]
+}
+
+/*----------------------------------------------------------------------*/
+
+// newChallenge generates a random challenge for a ceremony and stores it as a short-lived session record.
+// The challenge is bound to the user if userId is not empty.
+func (conf *webauthnConfig) newChallenge(sessionType, appId, userId string, now time.Time) (string, error) {
+	buf := make([]byte, webauthnChallengeSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	challenge := webauthnEncode(buf)
+	js, _ := json.Marshal(webauthnChallengeData{Challenge: challenge})
+	sess := session.NewSession(goapi.AppVersionNumber, webauthnChallengeId(challenge), sessionType, loginChannelPasskey, appId, userId, string(js), now.Add(conf.timeout))
+	if _, err := sessionDao.Save(sess); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func webauthnChallengeId(challenge string) string {
+	h := sha256.Sum256([]byte("webauthn:" + challenge))
+	return hex.EncodeToString(h[:])
+}
+
+// consumeChallenge verifies a challenge issued by newChallenge and deletes it so that it can not be used again.
+// The id of the user the challenge is bound to (empty if not bound) is returned.
+func (conf *webauthnConfig) consumeChallenge(sessionType, challenge string) (string, error) {
+	if challenge == "" {
+		return "", errorWebauthnInvalidChallenge
+	}
+	sess, err := sessionDao.Get(webauthnChallengeId(challenge))
+	if err != nil {
+		return "", err
+	}
+	if sess == nil || sess.GetSessionType() != sessionType {
+		return "", errorWebauthnInvalidChallenge
+	}
+	// delete first: only the request that actually removes the record may use the challenge
+	if ok, err := sessionDao.Delete(sess); err != nil {
+		return "", err
+	} else if !ok || sess.IsExpired() {
+		return "", errorWebauthnInvalidChallenge
+	}
+	data := webauthnChallengeData{}
+	if err := json.Unmarshal([]byte(sess.GetSessionData()), &data); err != nil || subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return "", errorWebauthnInvalidChallenge
+	}
+	return sess.GetUserId(), nil
+}
+
+// verifyClientData decodes and verifies clientDataJSON: ceremony type and origin.
+func (conf *webauthnConfig) verifyClientData(raw []byte, expectedType string) (*webauthnClientData, error) {
+	clientData := &webauthnClientData{}
+	if err := json.Unmarshal(raw, clientData); err != nil {
+		return nil, errorWebauthnInvalidCredential
+	}
+	if clientData.Type != expectedType {
+		return nil, fmt.Errorf("invalid ceremony type [%s]", clientData.Type)
+	}
+	origin := strings.TrimRight(clientData.Origin, "/")
+	for _, allowed := range conf.origins {
+		if origin == allowed {
+			return clientData, nil
+		}
+	}
+	return nil, fmt.Errorf("origin [%s] is not allowed", clientData.Origin)
+}
+
+// verifyAuthData checks rpIdHash and user presence/verification flags of authenticator data.
+func (conf *webauthnConfig) verifyAuthData(authData *webauthnAuthData) error {
+	expected := sha256.Sum256([]byte(conf.rpId))
+	if subtle.ConstantTimeCompare(authData.rpIdHash, expected[:]) != 1 {
+		return errors.New("credential is not scoped to this relying party")
+	}
+	if authData.flags&webauthnFlagUp == 0 {
+		return errors.New("user is not present")
+	}
+	if conf.userVerification == webauthnUvRequired && authData.flags&webauthnFlagUv == 0 {
+		return errors.New("user is not verified")
+	}
+	return nil
+}
+
+// parseWebauthnAuthData decodes authenticator data (WebAuthn spec §6.1).
+func parseWebauthnAuthData(data []byte) (*webauthnAuthData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	authData := &webauthnAuthData{
+		rpIdHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if authData.flags&webauthnFlagAt != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		authData.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		if len(rest) < 18+idLen {
+			return nil, errors.New("attested credential data is too short")
+		}
+		authData.credentialId = rest[18 : 18+idLen]
+		rest = rest[18+idLen:]
+		// the COSE key is followed by extension data (if any): decode it to know its length
+		dec := cbor.NewDecoder(bytes.NewReader(rest))
+		var key map[int]interface{}
+		if err := dec.Decode(&key); err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %s", err)
+		}
+		authData.publicKey = rest[:dec.NumBytesRead()]
+		rest = rest[dec.NumBytesRead():]
+	}
+	if authData.flags&webauthnFlagEd == 0 && len(rest) > 0 {
+		return nil, errors.New("unexpected trailing bytes in authenticator data")
+	}
+	return authData, nil
+}
+
+func coseInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), n <= 1<<62
+	}
+	return 0, false
+}
+
+func coseBytes(key map[int]interface{}, label int) []byte {
+	b, _ := key[label].([]byte)
+	return b
+}
+
+// parseCosePublicKey decodes a COSE_Key; supported algorithms are ES256 (P-256), EdDSA (Ed25519) and RS256.
+func parseCosePublicKey(data []byte) (crypto.PublicKey, int64, error) {
+	var key map[int]interface{}
+	if err := cbor.Unmarshal(data, &key); err != nil {
+		return nil, 0, errorWebauthnUnsupportedKey
+	}
+	kty, _ := coseInt(key[coseKeyKty])
+	alg, _ := coseInt(key[coseKeyAlg])
+	switch {
+	case kty == coseKtyEc2 && alg == coseAlgEs256:
+		crv, _ := coseInt(key[coseKeyCrv])
+		x, y := coseBytes(key, coseKeyX), coseBytes(key, coseKeyY)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errorWebauthnUnsupportedKey
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, errorWebauthnUnsupportedKey
+		}
+		return pub, alg, nil
+	case kty == coseKtyOkp && alg == coseAlgEdDsa:
+		crv, _ := coseInt(key[coseKeyCrv])
+		x := coseBytes(key, coseKeyX)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errorWebauthnUnsupportedKey
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == coseKtyRsa && alg == coseAlgRs256:
+		n, e := coseBytes(key, coseKeyRsaN), coseBytes(key, coseKeyRsaE)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errorWebauthnUnsupportedKey
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	}
+	return nil, 0, errorWebauthnUnsupportedKey
+}
+
+// verifyCoseSignature verifies an assertion signature made by the credential's private key.
+func verifyCoseSignature(coseKey, data, sig []byte) error {
+	pub, _, err := parseCosePublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	ok := false
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		var esig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &esig); err == nil && len(rest) == 0 {
+			ok = ecdsa.Verify(pub, digest[:], esig.R, esig.S)
+		}
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, data, sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+/*----------------------------------------------------------------------*/
+
+// webauthnCredentialDescriptors builds the list of PublicKeyCredentialDescriptor of user's credentials.
+func webauthnCredentialDescriptors(credList []*credential.Credential) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(credList))
+	for _, cred := range credList {
+		descriptor := map[string]interface{}{"type": "public-key", "id": webauthnEncode(cred.GetCredentialId())}
+		if transports := cred.GetTransports(); len(transports) > 0 {
+			descriptor["transports"] = transports
+		}
+		result = append(result, descriptor)
+	}
+	return result
+}
+
+// beginRegistration starts a registration ceremony, returning the PublicKeyCredentialCreationOptions to be passed to
+// navigator.credentials.create().
+//
+// available since v0.8.0
+func (conf *webauthnConfig) beginRegistration(u *user.User, appId string, now time.Time) (map[string]interface{}, error) {
+	credList, err := credentialDao.GetUserCredentials(u)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := conf.newChallenge(sessionTypeWebauthnRegister, appId, u.GetId(), now)
+	if err != nil {
+		return nil, err
+	}
+	displayName := u.GetDisplayName()
+	if displayName == "" {
+		displayName = u.GetId()
+	}
+	return map[string]interface{}{
+		"challenge": challenge,
+		"rp":        map[string]interface{}{"id": conf.rpId, "name": conf.rpName},
+		"user":      map[string]interface{}{"id": webauthnEncode(webauthnUserHandle(u)), "name": u.GetId(), "displayName": displayName},
+		"pubKeyCredParams": []map[string]interface{}{
+			{"type": "public-key", "alg": coseAlgEs256},
+			{"type": "public-key", "alg": coseAlgEdDsa},
+			{"type": "public-key", "alg": coseAlgRs256},
+		},
+		"timeout":            conf.timeout.Milliseconds(),
+		"excludeCredentials": webauthnCredentialDescriptors(credList),
+		"authenticatorSelection": map[string]interface{}{
+			"residentKey":      "preferred",
+			"userVerification": conf.userVerification,
+		},
+		"attestation": "none",
+	}, nil
+}
+
+// finishRegistration verifies the credential created by client and stores it. Attestation statements are not verified
+// (attestation conveyance "none" is requested): Exter does not restrict authenticator models.
+//
+// available since v0.8.0
+func (conf *webauthnConfig) finishRegistration(u *user.User, input interface{}, name string, now time.Time) (*credential.Credential, error) {
+	cred, rawId, err := parseWebauthnCredential(input)
+	if err != nil {
+		return nil, err
+	}
+	rawClientData, err := webauthnDecode(cred.Response.ClientDataJson)
+	if err != nil {
+		return nil, errorWebauthnInvalidCredential
+	}
+	clientData, err := conf.verifyClientData(rawClientData, webauthnTypeCreate)
+	if err != nil {
+		return nil, err
+	}
+	if userId, err := conf.consumeChallenge(sessionTypeWebauthnRegister, clientData.Challenge); err != nil {
+		return nil, err
+	} else if userId != u.GetId() {
+		return nil, errorWebauthnInvalidChallenge
+	}
+
+	rawAttestation, err := webauthnDecode(cred.Response.AttestationObject)
+	if err != nil {
+		return nil, errorWebauthnInvalidCredential
+	}
+	attestation := struct {
+		Fmt      string `cbor:"fmt"`
+		AuthData []byte `cbor:"authData"`
+	}{}
+	if err := cbor.Unmarshal(rawAttestation, &attestation); err != nil {
+		return nil, errorWebauthnInvalidCredential
+	}
+	authData, err := parseWebauthnAuthData(attestation.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.verifyAuthData(authData); err != nil {
+		return nil, err
+	}
+	if authData.flags&webauthnFlagAt == 0 || !bytes.Equal(authData.credentialId, rawId) {
+		return nil, errorWebauthnInvalidCredential
+	}
+	if _, _, err := parseCosePublicKey(authData.publicKey); err != nil {
+		return nil, err
+	}
+	if existing, err := credentialDao.Get(credential.IdFromCredentialId(rawId)); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, errorWebauthnCredentialExists
+	}
+
+	bo := credential.NewCredential(goapi.AppVersionNumber, rawId, u.GetId(), authData.publicKey)
+	bo.SetUserHandle(webauthnUserHandle(u)).SetAaguid(authData.aaguid).SetSignCount(authData.signCount).
+		SetTransports(cred.Response.Transports).SetName(name).SetLastUsed(now)
+	if ok, err := credentialDao.Create(bo); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errorWebauthnCredentialExists
+	}
+	return bo, nil
+}
+
+// beginLogin starts an authentication ceremony, returning the PublicKeyCredentialRequestOptions to be passed to
+// navigator.credentials.get(). If u is nil, the ceremony is for a discoverable credential (passkey) and
+// allowCredentials is left empty; otherwise it is bound to the user and lists user's credentials.
+//
+// available since v0.8.0
+func (conf *webauthnConfig) beginLogin(u *user.User, appId string, now time.Time) (map[string]interface{}, error) {
+	allowCredentials := make([]map[string]interface{}, 0)
+	userId := ""
+	if u != nil {
+		credList, err := credentialDao.GetUserCredentials(u)
+		if err != nil {
+			return nil, err
+		}
+		if len(credList) == 0 {
+			return nil, errorWebauthnUnknownCredential
+		}
+		allowCredentials = webauthnCredentialDescriptors(credList)
+		userId = u.GetId()
+	}
+	challenge, err := conf.newChallenge(sessionTypeWebauthnLogin, appId, userId, now)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"challenge":        challenge,
+		"rpId":             conf.rpId,
+		"timeout":          conf.timeout.Milliseconds(),
+		"userVerification": conf.userVerification,
+		"allowCredentials": allowCredentials,
+	}, nil
+}
+
+// finishLogin verifies an assertion and updates the credential's signature counter. The credential and whether
+// user has been verified by the authenticator (PIN, biometric) are returned.
+//
+// available since v0.8.0
+func (conf *webauthnConfig) finishLogin(input interface{}, now time.Time) (*credential.Credential, bool, error) {
+	cred, rawId, err := parseWebauthnCredential(input)
+	if err != nil {
+		return nil, false, err
+	}
+	rawClientData, err := webauthnDecode(cred.Response.ClientDataJson)
+	if err != nil {
+		return nil, false, errorWebauthnInvalidCredential
+	}
+	clientData, err := conf.verifyClientData(rawClientData, webauthnTypeGet)
+	if err != nil {
+		return nil, false, err
+	}
+	userId, err := conf.consumeChallenge(sessionTypeWebauthnLogin, clientData.Challenge)
+	if err != nil {
+		return nil, false, err
+	}
+
+	bo, err := credentialDao.Get(credential.IdFromCredentialId(rawId))
+	if err != nil {
+		return nil, false, err
+	}
+	if bo == nil || !bytes.Equal(bo.GetCredentialId(), rawId) {
+		return nil, false, errorWebauthnUnknownCredential
+	}
+	if userId != "" && userId != bo.GetOwnerId() {
+		return nil, false, errorWebauthnUnknownCredential
+	}
+	if cred.Response.UserHandle != "" {
+		if userHandle, err := webauthnDecode(cred.Response.UserHandle); err != nil || !bytes.Equal(userHandle, bo.GetUserHandle()) {
+			return nil, false, errorWebauthnUnknownCredential
+		}
+	}
+
+	rawAuthData, err := webauthnDecode(cred.Response.AuthenticatorData)
+	if err != nil {
+		return nil, false, errorWebauthnInvalidCredential
+	}
+	authData, err := parseWebauthnAuthData(rawAuthData)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conf.verifyAuthData(authData); err != nil {
+		return nil, false, err
+	}
+	sig, err := webauthnDecode(cred.Response.Signature)
+	if err != nil {
+		return nil, false, errorWebauthnInvalidCredential
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	if err := verifyCoseSignature(bo.GetPublicKey(), append(append([]byte{}, rawAuthData...), clientDataHash[:]...), sig); err != nil {
+		return nil, false, err
+	}
+	if (authData.signCount != 0 || bo.GetSignCount() != 0) && authData.signCount <= bo.GetSignCount() {
+		return nil, false, errorWebauthnSignCount
+	}
+
+	bo.SetSignCount(authData.signCount).SetLastUsed(now)
+	if _, err := credentialDao.Update(bo); err != nil {
+		return nil, false, err
+	}
+	return bo, authData.flags&webauthnFlagUv != 0, nil
+}
+
+// passkeyLoginChannel implements LoginChannel for passkeys: users login with WebAuthn credentials they registered.
+// WebAuthn itself is configured at [gvabe.webauthn], see initWebauthn.
+//
+// available since v0.8.0
+type passkeyLoginChannel struct {
+	BaseLoginChannel
+}
+
+// Init implements LoginChannel.Init.
+func (ch *passkeyLoginChannel) Init(_ *hocon.Config) error {
+	if webauthnConf == nil {
+		return errors.New("no valid WebAuthn settings defined at [gvabe.webauthn]")
+	}
+	return nil
+}
+
+// Login implements LoginHandler.Login.
+func (ch *passkeyLoginChannel) Login(_ *itineris.ApiContext, _ *itineris.ApiAuth, params *itineris.ApiParams, app *app.App, returnUrl string) *itineris.ApiResult {
+	assertion := params.GetParam("credential")
+	if webauthnConf == nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage("Passkey login channel is not configured")
+	}
+	if assertion == nil || assertion == "" {
+		return itineris.NewApiResult(itineris.StatusErrorClient).SetMessage("credential is required")
+	}
+	now := time.Now()
+	cred, verified, err := webauthnConf.finishLogin(assertion, now)
+	if err != nil {
+		if DEBUG {
+			log.Printf("[DEBUG] ERROR passkeyLoginChannel.Login: %s", err)
+		}
+		return _webauthnErrorResult(err)
+	}
+	u, err := userDao.Get(cred.GetOwnerId())
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if u == nil {
+		return itineris.NewApiResult(itineris.StatusNoPermission).SetMessage(errorWebauthnUnknownCredential.Error())
+	}
+
+	sess := &Session{
+		ClientId:    app.GetId(),
+		Channel:     loginChannelPasskey,
+		UserId:      u.GetId(),
+		DisplayName: u.GetDisplayName(),
+		CreatedAt:   now,
+		ExpiredAt:   now.Add(loginSessionTtl * time.Second),
+		Data:        []byte(cred.GetId()), // the credential can not be used again as second factor
+	}
+	var claims *SessionClaims
+	if verified {
+		sess.Amr, sess.Acr = []string{amrHwk, amrMfa}, acrMultiFactor
+		claims, err = genLoginClaims("", sess)
+	} else {
+		claims, err = genLoginOrMfaClaims("", sess)
+	}
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	_, jwt, err := saveSession(claims)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	if claims.Type == sessionTypeMfa {
+		return _mfaPendingResult(claims.UserId, claims.Audience, jwt)
+	}
+	token, returnUrl, err := appLoginResult(app, returnUrl, jwt)
+	if err != nil {
+		return itineris.NewApiResult(itineris.StatusErrorServer).SetMessage(err.Error())
+	}
+	return itineris.NewApiResult(itineris.StatusOk).SetData(token).SetExtras(map[string]interface{}{apiResultExtraReturnUrl: returnUrl})
+}
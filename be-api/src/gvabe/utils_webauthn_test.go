@@ -0,0 +1,163 @@
+package gvabe
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func _testCoseKeyEs256(t *testing.T, name string) (*ecdsa.PrivateKey, []byte) {
+	privKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	x, y := make([]byte, 32), make([]byte, 32)
+	xb, yb := privKey.X.Bytes(), privKey.Y.Bytes()
+	copy(x[32-len(xb):], xb)
+	copy(y[32-len(yb):], yb)
+	coseKey, err := cbor.Marshal(map[int]interface{}{coseKeyKty: coseKtyEc2, coseKeyAlg: coseAlgEs256, coseKeyCrv: coseCrvP256, coseKeyX: x, coseKeyY: y})
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	return privKey, coseKey
+}
+
+func _testAuthData(rpId string, flags byte, signCount uint32, credentialId, coseKey []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], signCount)
+	if credentialId != nil {
+		data = append(data, make([]byte, 16)...)
+		data = append(data, byte(len(credentialId)>>8), byte(len(credentialId)))
+		data = append(data, credentialId...)
+		data = append(data, coseKey...)
+	}
+	return data
+}
+
+func TestWebauthnDecode(t *testing.T) {
+	name := "TestWebauthnDecode"
+	for _, input := range []string{"_-8", "_-8=", "/+8", "/+8="} {
+		if data, err := webauthnDecode(input); err != nil || len(data) != 2 || data[0] != 0xff || data[1] != 0xef {
+			t.Fatalf("%s failed: %#v / %#v / %s", name, input, data, err)
+		}
+	}
+	if webauthnEncode([]byte{0xff, 0xef}) != "_-8" {
+		t.Fatalf("%s failed: %#v", name, webauthnEncode([]byte{0xff, 0xef}))
+	}
+}
+
+func TestParseWebauthnAuthData(t *testing.T) {
+	name := "TestParseWebauthnAuthData"
+	_, coseKey := _testCoseKeyEs256(t, name)
+	credentialId := []byte("credential-id")
+	raw := _testAuthData("example.com", webauthnFlagUp|webauthnFlagAt, 5, credentialId, coseKey)
+	authData, err := parseWebauthnAuthData(raw)
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	if authData.signCount != 5 || string(authData.credentialId) != string(credentialId) || string(authData.publicKey) != string(coseKey) {
+		t.Fatalf("%s failed: %#v", name, authData)
+	}
+
+	// extension data follows the public key
+	raw = append(_testAuthData("example.com", webauthnFlagUp|webauthnFlagAt|webauthnFlagEd, 5, credentialId, coseKey), 0xa0)
+	if authData, err = parseWebauthnAuthData(raw); err != nil || string(authData.publicKey) != string(coseKey) {
+		t.Fatalf("%s failed: extension data must be skipped / %s", name, err)
+	}
+
+	if _, err := parseWebauthnAuthData(raw[:36]); err == nil {
+		t.Fatalf("%s failed: short authenticator data must be rejected", name)
+	}
+	if _, err := parseWebauthnAuthData(append(_testAuthData("example.com", webauthnFlagUp, 5, nil, nil), 0)); err == nil {
+		t.Fatalf("%s failed: trailing bytes must be rejected", name)
+	}
+}
+
+func TestWebauthnConfig_verifyAuthData(t *testing.T) {
+	name := "TestWebauthnConfig_verifyAuthData"
+	conf := &webauthnConfig{rpId: "example.com", userVerification: webauthnUvPreferred}
+	authData, _ := parseWebauthnAuthData(_testAuthData("example.com", webauthnFlagUp, 0, nil, nil))
+	if err := conf.verifyAuthData(authData); err != nil {
+		t.Fatalf("%s failed: %s", name, err)
+	}
+	conf.userVerification = webauthnUvRequired
+	if err := conf.verifyAuthData(authData); err == nil {
+		t.Fatalf("%s failed: user verification is required", name)
+	}
+	authData, _ = parseWebauthnAuthData(_testAuthData("evil.com", webauthnFlagUp|webauthnFlagUv, 0, nil, nil))
+	if err := conf.verifyAuthData(authData); err == nil {
+		t.Fatalf("%s failed: credential of another relying party must be rejected", name)
+	}
+	authData, _ = parseWebauthnAuthData(_testAuthData("example.com", webauthnFlagUv, 0, nil, nil))
+	if err := conf.verifyAuthData(authData); err == nil {
+		t.Fatalf("%s failed: user presence is required", name)
+	}
+}
+
+func TestWebauthnConfig_verifyClientData(t *testing.T) {
+	name := "TestWebauthnConfig_verifyClientData"
+	conf := &webauthnConfig{rpId: "example.com", origins: []string{"https://example.com"}}
+	if clientData, err := conf.verifyClientData([]byte(`{"type":"webauthn.get","challenge":"abc","origin":"https://example.com"}`), webauthnTypeGet); err != nil || clientData.Challenge != "abc" {
+		t.Fatalf("%s failed: %#v / %s", name, clientData, err)
+	}
+	if _, err := conf.verifyClientData([]byte(`{"type":"webauthn.create","challenge":"abc","origin":"https://example.com"}`), webauthnTypeGet); err == nil {
+		t.Fatalf("%s failed: ceremony type must be checked", name)
+	}
+	if _, err := conf.verifyClientData([]byte(`{"type":"webauthn.get","challenge":"abc","origin":"https://evil.com"}`), webauthnTypeGet); err == nil {
+		t.Fatalf("%s failed: origin must be checked", name)
+	}
+}
+
+func TestVerifyCoseSignature(t *testing.T) {
+	name := "TestVerifyCoseSignature"
+	data := []byte("authenticator data || client data hash")
+	digest := sha256.Sum256(data)
+
+	privKey, coseKey := _testCoseKeyEs256(t, name)
+	r, s, _ := ecdsa.Sign(rand.Reader, privKey, digest[:])
+	sig, _ := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err := verifyCoseSignature(coseKey, data, sig); err != nil {
+		t.Fatalf("%s failed: ES256 / %s", name, err)
+	}
+	if err := verifyCoseSignature(coseKey, []byte("tampered"), sig); err == nil {
+		t.Fatalf("%s failed: ES256 signature of tampered data must be rejected", name)
+	}
+
+	pubKey, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	coseKey, _ = cbor.Marshal(map[int]interface{}{coseKeyKty: coseKtyOkp, coseKeyAlg: coseAlgEdDsa, coseKeyCrv: coseCrvEd25519, coseKeyX: []byte(pubKey)})
+	if err := verifyCoseSignature(coseKey, data, ed25519.Sign(edKey, data)); err != nil {
+		t.Fatalf("%s failed: EdDSA / %s", name, err)
+	}
+
+	coseKey, _ = cbor.Marshal(map[int]interface{}{coseKeyKty: coseKtyEc2, coseKeyAlg: -35, coseKeyCrv: 2})
+	if _, _, err := parseCosePublicKey(coseKey); err != errorWebauthnUnsupportedKey {
+		t.Fatalf("%s failed: unsupported algorithm must be rejected", name)
+	}
+}
+
+func TestParseWebauthnCredential(t *testing.T) {
+	name := "TestParseWebauthnCredential"
+	input := map[string]interface{}{
+		"id":       "Y3JlZA",
+		"rawId":    "Y3JlZA",
+		"type":     "public-key",
+		"response": map[string]interface{}{"clientDataJSON": "e30", "signature": "c2ln"},
+	}
+	cred, rawId, err := parseWebauthnCredential(input)
+	if err != nil || string(rawId) != "cred" || cred.Response.Signature != "c2ln" {
+		t.Fatalf("%s failed: %#v / %s", name, cred, err)
+	}
+	if _, _, err := parseWebauthnCredential(`{"id":"Y3JlZA","type":"public-key"}`); err != nil {
+		t.Fatalf("%s failed: JSON string must be accepted / %s", name, err)
+	}
+	if _, _, err := parseWebauthnCredential(`{"id":"Y3JlZA","type":"password"}`); err != errorWebauthnInvalidCredential {
+		t.Fatalf("%s failed: invalid credential type must be rejected", name)
+	}
+}
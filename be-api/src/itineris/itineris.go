@@ -270,13 +270,16 @@ func (prm *ApiParams) GetAllParams() map[string]interface{} {
 /*----------------------------------------------------------------------*/
 
 const (
-	StatusOk             = 200
-	StatusErrorClient    = 400
-	StatusNoPermission   = 403
-	StatusNotFound       = 404
-	StatusDeprecated     = 410
-	StatusErrorServer    = 500
-	StatusNotImplemented = 501
+	StatusOk              = 200
+	StatusSeeOther        = 303 // available since v0.8.0: result's data is the url to redirect client to
+	StatusErrorClient     = 400
+	StatusUnauthorized    = 401 // available since v0.8.0
+	StatusNoPermission    = 403
+	StatusNotFound        = 404
+	StatusDeprecated      = 410
+	StatusTooManyRequests = 429 // available since v0.8.0
+	StatusErrorServer     = 500
+	StatusNotImplemented  = 501
 )
 
 var (
@@ -285,6 +288,18 @@ var (
 	ResultNotFound       = NewApiResult(StatusNotFound).SetMessage("Item not found")
 )
 
+/*
+ApiResultRawContent is used as ApiResult's data when the API returns a non-JSON document (e.g. XML metadata).
+
+Available since v0.8.0
+*/
+type ApiResultRawContent struct {
+	ContentType string
+	Content     []byte
+	HttpStatus  int               // (since v0.8.0) HTTP status code of the response, default 200
+	Headers     map[string]string // (since v0.8.0) extra HTTP response headers
+}
+
 /*
 ApiResult encapsulates result from an API call.
 */
@@ -20,6 +20,8 @@ let appId = appConfig.APP_CONFIG.api_client.app_id + ":" + Math.random()
 let apiInfo = "/info"
 let apiLogin = "/api/login"
 let apiVerifyLoginToken = "/api/verifyLoginToken"
+let apiWaitLoginToken = "/api/waitLoginToken"
+let apiLogout = "/api/logout"
 let apiSystemInfo = "/api/systemInfo"
 let apiApp = "/api/app/:app"
 let apiMyAppList = "/api/myapps"
@@ -32,9 +34,10 @@ function _apiOnSuccess(method, resp, apiUri, callbackSuccessful) {
         router.push({name: "Login", query: {app: appConfig.APP_ID, returnUrl: router.currentRoute.fullPath}})
         return
     }
-    if (resp.hasOwnProperty("data") && resp.data.hasOwnProperty("extras") && resp.data.extras.hasOwnProperty("_access_token_")) {
-        let jwt = utils.parseJwt(resp.data.extras._access_token_)
-        utils.saveLoginSession({uid: jwt.payloadObj.uid, token: resp.data.extras._access_token_})
+    if (resp.hasOwnProperty("data") && resp.data.hasOwnProperty("extras") && resp.data.extras.hasOwnProperty("access_token")) {
+        // login session has been renewed
+        let jwt = utils.parseJwt(resp.data.extras.access_token)
+        utils.saveLoginSession({uid: jwt.payloadObj.uid, token: resp.data.extras.access_token})
     }
     if (callbackSuccessful != null) {
         callbackSuccessful(resp.data)
@@ -93,6 +96,8 @@ export default {
     apiLogin,
     apiApp,
     apiVerifyLoginToken,
+    apiWaitLoginToken,
+    apiLogout,
     apiSystemInfo,
     apiMyAppList,
     apiMyApp,